@@ -70,6 +70,30 @@ func (a *SqlAdapters) Connect(f func(e error)) {
 
 }
 
+// ConnectAlias connect only the database named alias, dipakai proses yang tidak
+// bootstrap semua datasource (master dan `mox revision`)
+func (a *SqlAdapters) ConnectAlias(alias string) (interface{}, error) {
+	cfg, ok := a.config.DatabaseByAlias(alias)
+	if !ok {
+		return nil, fmt.Errorf("database %s is not configured", alias)
+	}
+
+	adapter := a.getAdapterByDriver(cfg.Adapter)
+	if adapter == nil {
+		return nil, fmt.Errorf("cannot find adapter for %s", cfg.Adapter)
+	}
+
+	db, err := adapter.Connect(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect %s database detail=%s", alias, err.Error())
+	}
+
+	a.result[alias] = db
+	a.alias[alias] = adapter
+
+	return db, nil
+}
+
 func (a *SqlAdapters) Disconnect(f func(e error)) {
 	if a.alias != nil {
 		for _, adapter := range a.alias {
@@ -281,3 +281,37 @@ func TestDisconnectConnection(t *testing.T) {
 	adapter.Disconnect(f)
 
 }
+
+func TestConnectAliasAdapter(t *testing.T) {
+	mock := adapter_mock.NewMockAdapter(t)
+	mock2 := adapter_mock.NewMockAdapter(t)
+
+	cfg := config.Config{
+		Database: config.Database{
+			Adapter: "test_adapter",
+			Alias:   "test",
+		},
+		ExternalDatabases: []config.Database{
+			{
+				Adapter: "test_adapter_2",
+				Alias:   "test_2",
+			},
+		},
+	}
+
+	// cuma alias yang diminta yang connect
+	mock.EXPECT().DriverName().Return(cfg.Database.Adapter)
+	mock2.EXPECT().DriverName().Return(cfg.ExternalDatabases[0].Adapter)
+	mock2.EXPECT().Connect(cfg.ExternalDatabases[0]).Return(&sql.DB{}, nil)
+
+	adapter := NewSqlAdapters(&cfg, []Adapter{mock, mock2})
+
+	db, err := adapter.ConnectAlias("test_2")
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.Contains(t, adapter.alias, "test_2")
+	assert.NotContains(t, adapter.alias, "test")
+
+	_, err = adapter.ConnectAlias("unknown")
+	assert.EqualError(t, err, "database unknown is not configured")
+}
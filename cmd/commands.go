@@ -12,6 +12,8 @@ func NewCommands(app core.App) []*cobra.Command {
 		// NewMessageBrokerCommand(app),
 		NewMasterCommand(app),
 		NewWorkerCommand(app),
+		NewImportHAProxyCommand(app),
+		NewRevisionCommand(app),
 		// NewHttpCommand(app),
 		// NewMigration(app),
 		// newVersionCmd(app),
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/pkg/gateway"
+	"mox/pkg/haproxy"
+
+	"github.com/spf13/cobra"
+)
+
+func NewImportHAProxyCommand(app core.App) *cobra.Command {
+	var output string
+
+	command := &cobra.Command{
+		Use:   "import-haproxy <file>",
+		Short: "Convert an existing haproxy.cfg into the [proxy] section of config.toml",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			doc, err := haproxy.ParseDocument(f)
+			if err != nil {
+				return fmt.Errorf("cannot parse %s: %w", args[0], err)
+			}
+
+			model, warnings := haproxy.Import(doc)
+
+			notes := []string{fmt.Sprintf("imported from %s by `mox import-haproxy`", args[0])}
+			for _, w := range warnings {
+				// directive tetap disimpan, cuma ditandai supaya dicek manual
+				notes = append(notes, "warning: "+w.String())
+			}
+
+			if err := model.Validate(); err != nil {
+				notes = append(notes, "invalid, fix before use: "+err.Error())
+			} else if err := gateway.Validate(model, nil); err != nil {
+				// tetap bisa dipakai dengan engine haproxy
+				notes = append(notes, "builtin engine cannot serve this config: "+err.Error())
+			}
+
+			body, err := config.MarshalProxy(config.ProxyConfig{
+				Engine:     config.EngineHAProxy,
+				ConfigFile: "haproxy.cfg",
+				Output:     "/tmp/haproxy_${PID}.cfg",
+				Config:     model,
+			}, notes...)
+			if err != nil {
+				return err
+			}
+
+			if output == "" || output == "-" {
+				_, err = cmd.OutOrStdout().Write(body)
+				return err
+			}
+
+			if err := os.WriteFile(output, body, 0o644); err != nil {
+				return err
+			}
+
+			app.Logger().Info("haproxy config imported", slog.String("output", output), slog.Int("warnings", len(warnings)))
+			for _, w := range warnings {
+				app.Logger().Warn("haproxy directive kept verbatim", slog.String("directive", w.String()))
+			}
+
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&output, "output", "o", "", "Write the [proxy] section to this file instead of stdout")
+
+	return command
+}
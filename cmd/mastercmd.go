@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"log/slog"
+
 	"mox/drivers/http"
 	"mox/drivers/master"
+	"mox/drivers/monitoring"
 	core "mox/internal"
+	"mox/pkg/upgrade"
 
 	"github.com/spf13/cobra"
 )
@@ -17,6 +21,13 @@ func NewMasterCommand(app core.App) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app.OnAfterApplicationBootstrapped().ExecuteWithExclude(core.AfterApplicationBootstrapped{App: app, ConfigPath: configPath}, []string{"b_bootstrap"})
 
+			// gauge stats worker ikut diexport kalau telemetry nyala, log kalau collect log nyala
+			if cfg := app.Config().Monitoring; cfg.EnableTelemetry || cfg.EnableCollectLog {
+				if err := app.Driver().RunDriver(monitoring.NewOtel(app)); err != nil {
+					return err
+				}
+			}
+
 			if err := app.Driver().RunDriver(master.NewMasterAdapter(cmd.Context(), app)); err != nil {
 				return err
 			}
@@ -25,6 +36,11 @@ func NewMasterCommand(app core.App) *cobra.Command {
 				return err
 			}
 
+			// semua socket sudah serve, master lama boleh pergi
+			if err := upgrade.NotifyReady(); err != nil {
+				app.Logger().Error("cannot notify previous master", slog.String("err", err.Error()))
+			}
+
 			<-cmd.Context().Done()
 
 			return nil
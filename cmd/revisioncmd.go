@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"mox/drivers/master"
+	core "mox/internal"
+	"mox/repositories"
+	"mox/use_cases/operation"
+
+	"github.com/spf13/cobra"
+)
+
+func NewRevisionCommand(app core.App) *cobra.Command {
+	var configPath string
+
+	command := &cobra.Command{
+		Use:   "revision",
+		Short: "List, diff and roll back the proxy config revisions recorded by the master",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			app.OnAfterApplicationBootstrapped().ExecuteWithExclude(core.AfterApplicationBootstrapped{App: app, ConfigPath: configPath}, []string{"b_bootstrap"})
+		},
+	}
+
+	command.PersistentFlags().StringVarP(&configPath, "config", "c", "", "Configuration file location")
+
+	command.AddCommand(
+		newRevisionListCommand(app),
+		newRevisionDiffCommand(app),
+		newRevisionRollbackCommand(app),
+	)
+
+	return command
+}
+
+// withRevisions open the revision database straight, list dan diff tidak butuh master jalan
+func withRevisions(app core.App, f func(store *repositories.ConfigRevisionGormRepository) error) error {
+	alias := app.Config().Revisions.Database
+	if alias == "" {
+		return operation.ErrRevisionsDisabled
+	}
+
+	store, sql, err := master.OpenRevisionStore(app, alias)
+	if err != nil {
+		return err
+	}
+
+	defer sql.Disconnect(func(err error) {
+		app.Logger().Error(err.Error())
+	})
+
+	return f(store)
+}
+
+func newRevisionListCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List every recorded revision, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withRevisions(app, func(store *repositories.ConfigRevisionGormRepository) error {
+				revisions, err := store.List(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "REVISION\tCREATED\tAUTHOR\tGENERATION\tHASH\tREASON")
+
+				for _, rev := range revisions {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", rev.Number, rev.CreatedAt.Format(time.RFC3339), rev.Author, rev.Generation, rev.Hash, rev.Reason)
+				}
+
+				return w.Flush()
+			})
+		},
+	}
+}
+
+func newRevisionDiffCommand(app core.App) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff <from> <to>",
+		Short: "Show the unified diff of the haproxy.cfg between two revisions",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			numbers, err := revisionNumbers(args...)
+			if err != nil {
+				return err
+			}
+
+			return withRevisions(app, func(store *repositories.ConfigRevisionGormRepository) error {
+				revisions := make([]operation.ConfigRevision, 0, len(numbers))
+				for _, n := range numbers {
+					rev, err := store.Get(cmd.Context(), n)
+					if err != nil {
+						return fmt.Errorf("revision %d: %w", n, err)
+					}
+
+					revisions = append(revisions, rev)
+				}
+
+				diff, err := operation.DiffRevisions(revisions[0], revisions[1])
+				if err != nil {
+					return err
+				}
+
+				_, err = io.WriteString(cmd.OutOrStdout(), diff)
+				return err
+			})
+		},
+	}
+}
+
+func newRevisionRollbackCommand(app core.App) *cobra.Command {
+	var author, api string
+
+	command := &cobra.Command{
+		Use:   "rollback <revision>",
+		Short: "Ask the running master to roll a new generation onto an earlier revision",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			numbers, err := revisionNumbers(args...)
+			if err != nil {
+				return err
+			}
+
+			if api == "" {
+				api = fmt.Sprintf("http://127.0.0.1:%d", app.Config().Api.Port)
+			}
+
+			if author == "" {
+				author = os.Getenv("USER")
+			}
+
+			body, err := json.Marshal(map[string]string{"author": author})
+			if err != nil {
+				return err
+			}
+
+			// rollback lewat master, cuma master yang bisa rollout worker
+			url := fmt.Sprintf("%s/api/revisions/%d/rollback", api, numbers[0])
+
+			resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			out, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("rollback to revision %d failed (%s): %s", numbers[0], resp.Status, bytes.TrimSpace(out))
+			}
+
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+
+	command.Flags().StringVar(&author, "author", "", "Recorded as the author of the rollback, default $USER")
+	command.Flags().StringVar(&api, "api", "", "Master API address, default http://127.0.0.1:<apis.port>")
+
+	return command
+}
+
+func revisionNumbers(args ...string) ([]int, error) {
+	numbers := make([]int, 0, len(args))
+	for _, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return nil, errors.New("revision must be a positive number, got " + strconv.Quote(arg))
+		}
+
+		numbers = append(numbers, n)
+	}
+
+	return numbers, nil
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"mox/drivers/daemon"
+	"mox/drivers/monitoring"
 	"mox/drivers/worker"
 	core "mox/internal"
 
@@ -17,6 +18,13 @@ func NewWorkerCommand(app core.App) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			app.OnAfterApplicationBootstrapped().ExecuteWithExclude(core.AfterApplicationBootstrapped{App: app, ConfigPath: configPath}, []string{"b_bootstrap"})
 
+			// log haproxy ikut diexport kalau collect log nyala
+			if cfg := app.Config().Monitoring; cfg.EnableTelemetry || cfg.EnableCollectLog {
+				if err := app.Driver().RunDriver(monitoring.NewOtel(app)); err != nil {
+					return err
+				}
+			}
+
 			if err := app.Driver().RunDriver(worker.NewWorkerAdapter(app)); err != nil {
 				return err
 			}
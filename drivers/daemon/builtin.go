@@ -0,0 +1,228 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/pkg/gateway"
+	"mox/pkg/haproxy"
+	"mox/use_cases/manager"
+	"mox/use_cases/operation"
+	"mox/use_cases/workercore"
+)
+
+var _ (workercore.ProxyEngine) = (*BuiltinEngine)(nil)
+
+// BuiltinEngine serve the [proxy] model in-process with pkg/gateway, jadi
+// worker tidak butuh binary haproxy (misal di CI). Listener warisan master
+// langsung di-accept oleh worker.
+type BuiltinEngine struct {
+	app    core.App
+	worker *workercore.Worker
+	gw     *gateway.Gateway
+}
+
+func NewBuiltinEngine(app core.App, worker *workercore.Worker) *BuiltinEngine {
+	return &BuiltinEngine{app: app, worker: worker}
+}
+
+// Name implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Name() string {
+	return config.EngineBuiltin
+}
+
+func (e *BuiltinEngine) lookup(listeners []workercore.Listener) func(string) (string, bool) {
+	names := make([]string, len(listeners))
+	for i, l := range listeners {
+		names[i] = l.Name
+	}
+
+	return haproxy.EnvLookup(haproxy.ProcessEnv(e.worker.PID(), manager.FirstWorkerFD, names))
+}
+
+func (e *BuiltinEngine) model() (haproxy.Config, error) {
+	model, warnings, err := workercore.ProxyConfig(e.app.Config()).Model()
+	if err != nil {
+		return haproxy.Config{}, err
+	}
+
+	for _, w := range warnings {
+		e.app.Logger().Warn("haproxy directive ignored by builtin engine", slog.String("directive", w.String()))
+	}
+
+	return model, nil
+}
+
+// Validate implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Validate(ctx context.Context, listeners []workercore.Listener) error {
+	model, err := e.model()
+	if err != nil {
+		return err
+	}
+
+	return e.check(model, listeners)
+}
+
+// check compile the model and make sure every bind listener was received
+func (e *BuiltinEngine) check(model haproxy.Config, listeners []workercore.Listener) error {
+	if err := gateway.Validate(model, e.lookup(listeners)); err != nil {
+		return err
+	}
+
+	received := map[string]struct{}{}
+	for _, l := range listeners {
+		received[l.Name] = struct{}{}
+	}
+
+	for _, f := range model.Frontends {
+		for _, b := range f.Binds {
+			if _, ok := received[b.Listener]; b.Listener != "" && !ok {
+				return fmt.Errorf("frontend %s: listener %q was not received from the master", f.Name, b.Listener)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Start implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Start(ctx context.Context, listeners []workercore.Listener) error {
+	model, err := e.model()
+	if err != nil {
+		return err
+	}
+
+	if err := e.check(model, listeners); err != nil {
+		return err
+	}
+
+	logger := e.app.Logger().With(
+		slog.String("APP", "BUILTIN"),
+		slog.Int("worker_pid", e.worker.PID()),
+		slog.Int("generation", workercore.Generation()),
+	)
+
+	gw, err := gateway.New(model, logger, e.lookup(listeners))
+	if err != nil {
+		return err
+	}
+
+	// FileListener dup FD-nya, file asli tetap dipegang worker
+	ls := make(map[string]net.Listener, len(listeners))
+	for _, l := range listeners {
+		nl, err := net.FileListener(l.File)
+		if err != nil {
+			for _, opened := range ls {
+				opened.Close()
+			}
+
+			return fmt.Errorf("listener %s: %w", l.Name, err)
+		}
+
+		ls[l.Name] = nl
+	}
+
+	if err := gw.Serve(ls); err != nil {
+		return err
+	}
+
+	e.gw = gw
+	e.app.Logger().Info("builtin proxy started", slog.Int("listeners", len(listeners)))
+
+	restoreServers(ctx, e.app, e)
+
+	return nil
+}
+
+// Reload implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Reload(ctx context.Context) error {
+	if e.gw == nil {
+		return errors.New("builtin proxy is not running")
+	}
+
+	model, err := e.model()
+	if err != nil {
+		return err
+	}
+
+	if err := e.gw.Reload(model); err != nil {
+		return err
+	}
+
+	// routes di-compile ulang dari model, perubahan server dipasang lagi
+	restoreServers(ctx, e.app, e)
+
+	return nil
+}
+
+// Drain implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Drain(ctx context.Context) error {
+	if e.gw == nil {
+		return errors.New("builtin proxy is not running")
+	}
+
+	return e.gw.Drain()
+}
+
+// Stats implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Stats(ctx context.Context) (workercore.EngineStats, error) {
+	if e.gw == nil {
+		return workercore.EngineStats{}, errors.New("builtin proxy is not running")
+	}
+
+	return workercore.EngineStats{Info: e.gw.Info(), Proxies: e.gw.Stats()}, nil
+}
+
+// Stop implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) Stop(ctx context.Context) error {
+	if e.gw == nil {
+		return nil
+	}
+
+	return e.gw.Stop(ctx)
+}
+
+// UpdateServer implements [workercore.ProxyEngine].
+func (e *BuiltinEngine) UpdateServer(ctx context.Context, change operation.ServerChange) error {
+	if e.gw == nil {
+		return errors.New("builtin proxy is not running")
+	}
+
+	switch change.Action {
+	case operation.ServerSetWeight:
+		return e.gw.SetServerWeight(change.Backend, change.Server, change.Weight)
+	case operation.ServerSetState:
+		return e.gw.SetServerState(change.Backend, change.Server, change.State)
+	case operation.ServerSetHealth:
+		return e.gw.SetServerHealth(change.Backend, change.Server, change.Health)
+	case operation.ServerAdd:
+		s := haproxy.Server{Name: change.Server, Address: change.Address, Weight: change.Weight}
+		if err := e.gw.AddServer(change.Backend, s); err != nil {
+			return err
+		}
+
+		return e.gw.SetServerState(change.Backend, change.Server, addedState(change))
+	case operation.ServerDel:
+		if err := e.gw.SetServerState(change.Backend, change.Server, haproxy.StateMaint); err != nil {
+			return err
+		}
+
+		return e.gw.DelServer(change.Backend, change.Server)
+	}
+
+	return fmt.Errorf("unknown server action %q", change.Action)
+}
+
+// Close stop serving right away
+func (e *BuiltinEngine) Close() error {
+	if e.gw == nil {
+		return nil
+	}
+
+	return e.gw.Close()
+}
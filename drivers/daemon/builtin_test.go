@@ -0,0 +1,234 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+	"mox/use_cases/workercore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const builtinConfig = `defaults
+    mode http
+
+frontend gateway
+    bind "${MOX_LISTENER_GATEWAY}"
+    default_backend api
+
+backend api
+    server s1 127.0.0.1:8080
+`
+
+// newTestBuiltin write cfg to a temp haproxy.cfg and build an engine reading it
+func newTestBuiltin(t *testing.T, cfg string) (*BuiltinEngine, config.ProxyConfig) {
+	t.Helper()
+
+	dir := t.TempDir()
+	proxy := config.ProxyConfig{
+		Engine:          config.EngineBuiltin,
+		ConfigFile:      filepath.Join(dir, "haproxy.cfg"),
+		ServerStateFile: filepath.Join(dir, "servers.json"),
+	}
+	require.NoError(t, os.WriteFile(proxy.ConfigFile, []byte(cfg), 0o644))
+
+	app := core.NewTestAppWithConfig(config.Config{Proxy: proxy})
+	e := NewBuiltinEngine(app, workercore.NewWorkerBuilder().SetPID(42).Build())
+	t.Cleanup(func() { e.Close() })
+
+	return e, proxy
+}
+
+// listener open a tcp socket and hand it over as the master would, lewat file
+func listener(t *testing.T, name string) workercore.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	f, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+
+	return workercore.Listener{Name: name, File: f}
+}
+
+// server return the stat row of backend/name, false kalau tidak ada
+func server(t *testing.T, e *BuiltinEngine, backend, name string) (haproxy.Stat, bool) {
+	t.Helper()
+
+	stats, err := e.Stats(context.Background())
+	require.NoError(t, err)
+
+	for _, st := range stats.Proxies {
+		if st.Type == haproxy.TypeServer && st.ProxyName == backend && st.ServiceName == name {
+			return st, true
+		}
+	}
+
+	return haproxy.Stat{}, false
+}
+
+func TestBuiltinEngineStart(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       string
+		listeners []string
+		err       string
+	}{
+		{name: "listener received", cfg: builtinConfig, listeners: []string{"gateway"}},
+		{name: "listener missing", cfg: builtinConfig, listeners: []string{"admin"}, err: `listener "gateway" was not received`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestBuiltin(t, tt.cfg)
+
+			var listeners []workercore.Listener
+			for _, name := range tt.listeners {
+				listeners = append(listeners, listener(t, name))
+			}
+
+			err := e.Start(context.Background(), listeners)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.Nil(t, e.gw)
+				return
+			}
+
+			require.NoError(t, err)
+
+			st, ok := server(t, e, "api", "s1")
+			assert.True(t, ok)
+			assert.Equal(t, "UP", st.Status)
+		})
+	}
+}
+
+func TestBuiltinEngineNotRunning(t *testing.T) {
+	e, _ := newTestBuiltin(t, builtinConfig)
+	ctx := context.Background()
+
+	assert.EqualError(t, e.Reload(ctx), "builtin proxy is not running")
+	assert.EqualError(t, e.Drain(ctx), "builtin proxy is not running")
+	assert.EqualError(t, e.UpdateServer(ctx, operation.ServerChange{Action: operation.ServerSetWeight}), "builtin proxy is not running")
+	assert.NoError(t, e.Stop(ctx))
+}
+
+func TestBuiltinEngineReload(t *testing.T) {
+	e, proxy := newTestBuiltin(t, builtinConfig)
+	ctx := context.Background()
+
+	require.NoError(t, e.Start(ctx, []workercore.Listener{listener(t, "gateway")}))
+
+	// server baru dari config, override di state file dipasang lagi
+	cfg := builtinConfig + "    server s2 127.0.0.1:8081\n"
+	require.NoError(t, os.WriteFile(proxy.ConfigFile, []byte(cfg), 0o644))
+	require.NoError(t, os.WriteFile(proxy.ServerStateFile, []byte(`{"servers":[{"backend":"api","server":"s1","state":"drain"}]}`), 0o644))
+
+	require.NoError(t, e.Reload(ctx))
+
+	st, ok := server(t, e, "api", "s2")
+	assert.True(t, ok)
+	assert.Equal(t, "UP", st.Status)
+
+	st, _ = server(t, e, "api", "s1")
+	assert.Equal(t, "DRAIN", st.Status)
+}
+
+func TestBuiltinEngineDrainStop(t *testing.T) {
+	e, _ := newTestBuiltin(t, builtinConfig)
+	ctx := context.Background()
+
+	require.NoError(t, e.Start(ctx, []workercore.Listener{listener(t, "gateway")}))
+	require.NoError(t, e.Drain(ctx))
+
+	stats, err := e.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, haproxy.TypeFrontend, stats.Proxies[0].Type)
+	assert.Equal(t, "STOP", stats.Proxies[0].Status)
+
+	assert.NoError(t, e.Stop(ctx))
+}
+
+func TestBuiltinEngineUpdateServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  operation.ServerChange
+		status  string
+		weight  int64
+		deleted bool
+		err     string
+	}{
+		{
+			name:   "add is ready by default",
+			change: operation.ServerChange{Action: operation.ServerAdd, Backend: "api", Server: "s2", Address: "127.0.0.1:8081", Weight: 3},
+			status: "UP",
+			weight: 3,
+		},
+		{
+			name:   "add with state",
+			change: operation.ServerChange{Action: operation.ServerAdd, Backend: "api", Server: "s2", Address: "127.0.0.1:8081", State: haproxy.StateDrain},
+			status: "DRAIN",
+			weight: 1,
+		},
+		{
+			name:    "del put the server in maint first",
+			change:  operation.ServerChange{Action: operation.ServerDel, Backend: "api", Server: "s1"},
+			deleted: true,
+		},
+		{
+			name:   "weight",
+			change: operation.ServerChange{Action: operation.ServerSetWeight, Backend: "api", Server: "s1", Weight: 7},
+			status: "UP",
+			weight: 7,
+		},
+		{
+			name:   "state",
+			change: operation.ServerChange{Action: operation.ServerSetState, Backend: "api", Server: "s1", State: haproxy.StateMaint},
+			status: "MAINT",
+			weight: 1,
+		},
+		{
+			name:   "unknown action",
+			change: operation.ServerChange{Action: "restart", Backend: "api", Server: "s1"},
+			err:    `unknown server action "restart"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestBuiltin(t, builtinConfig)
+			ctx := context.Background()
+
+			require.NoError(t, e.Start(ctx, []workercore.Listener{listener(t, "gateway")}))
+
+			err := e.UpdateServer(ctx, tt.change)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			st, ok := server(t, e, tt.change.Backend, tt.change.Server)
+			if tt.deleted {
+				assert.False(t, ok)
+				return
+			}
+
+			require.True(t, ok)
+			assert.Equal(t, tt.status, st.Status)
+			assert.Equal(t, tt.weight, st.Weight)
+		})
+	}
+}
@@ -1,17 +1,18 @@
 package daemon
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"log/slog"
-	"os"
 	"sync"
 
 	"mox/drivers/worker"
 	core "mox/internal"
-	asyncexec "mox/pkg/async"
+	"mox/pkg/config"
 	"mox/pkg/driver"
 	driverv2 "mox/pkg/driver/v2"
-	"mox/tools/utils"
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
 	"mox/use_cases/workercore"
 )
 
@@ -19,93 +20,61 @@ var _ (driver.IDriver) = (*DaemonAdapter)(nil)
 
 const DaemonAdapterName = "DaemonAdapter"
 
+// engine is a ProxyEngine the adapter has to clean up on Close
+type engine interface {
+	workercore.ProxyEngine
+	io.Closer
+}
+
 type DaemonAdapter struct {
 	app    core.App
-	cmd    *asyncexec.Cmd
-	worker *workercore.Worker
+	engine engine
 	l      *sync.RWMutex
 }
 
 // Close implements [driver.IDriver].
 func (d *DaemonAdapter) Close() error {
-	if err := d.cmd.Cancel(); err != nil {
-		return err
+	d.l.RLock()
+	defer d.l.RUnlock()
+
+	if d.engine == nil {
+		return nil
 	}
 
-	return nil
+	return d.engine.Close()
 }
 
-func (d *DaemonAdapter) runHaproxy(file *os.File) error {
-	utils.LookupExecutablePathAbs("haproxy")
-
-	// d.app.Driver().Instance()
-	executable, err := utils.LookupExecutablePathAbs("haproxy")
+// restoreServers apply the server state file persisted by the master, biar
+// worker baru (atau engine yang baru reload) mulai dengan pool server yang sama.
+func restoreServers(ctx context.Context, app core.App, e workercore.ProxyEngine) {
+	applied, err := workercore.RestoreServers(ctx, e, app.Config().Proxy.ServerStateFile)
 	if err != nil {
-		d.app.Logger().Error(err.Error())
-		return err
+		app.Logger().Warn("server state not fully restored", slog.Int("applied", applied), slog.String("err", err.Error()))
+		return
 	}
 
-	argsValidate := []string{"-f", "haproxy.cfg"}
-	cmd := asyncexec.Command(d.app.Context(), executable, argsValidate...)
-
-	// logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	// cmd.Stdout = &logs.SlogWriter{Logger: logger, Level: slog.LevelInfo, App: "HAPROXY"}
-	// cmd.Stderr = &logs.SlogWriter{Logger: logger, Level: slog.LevelError, App: "HAPROXY"}
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	cmd.ExtraFiles = []*os.File{file}
-
-	fmt.Printf("Master: Oper FD dari file %s (FD asli: %d) ke ExtraFiles[0]\n", file.Name(), file.Fd())
-
-	fdOrderEnv := fmt.Sprintf("FD_ORDER=%d", file.Fd())
-	pidEnv := fmt.Sprintf("PID=%d", d.worker.PID())
-	cmd.Env = append(os.Environ(), fdOrderEnv, "APP_VERSION=v1.1", pidEnv)
-
-	if err := cmd.AsyncRun(); err != nil {
-		d.app.Logger().Error("process starting failed", slog.String("err", err.Error()))
-		return err
+	if applied > 0 {
+		app.Logger().Info("server state restored", slog.Int("applied", applied))
 	}
+}
 
-	d.cmd = cmd
-
-	go func(cmd *asyncexec.Cmd) {
-		<-cmd.Terminated
-		d.app.Logger().Info(fmt.Sprintf("process %d terminated : %s", cmd.Process.Pid, cmd.Status()))
-
-		// exit if termination signal was received and the last process terminated abnormally
-		if cmd.ProcessState.ExitCode() != 0 {
-			d.app.Stop()
-			return
-		}
+// addedState is the state of a server after add, kosong = ready
+func addedState(change operation.ServerChange) haproxy.ServerState {
+	if change.State == "" {
+		return haproxy.StateReady
+	}
 
-		// remove the process from tracking
-		d.l.Lock()
-		defer d.l.Unlock()
-		// for i := range cmds {
-		// 	if cmds[i].Process.Pid == cmd.Process.Pid {
-		// 		cmds = append(cmds[:i], cmds[i+1:]...)
-		// 		break
-		// 	}
-		// }
-		//
-		// // exit if there are no more processes running
-		// if len(cmds) == 0 {
-		// 	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() != 0 {
-		// 		os.Exit(cmd.ProcessState.ExitCode())
-		// 	} else {
-		// 		os.Exit(0)
-		// 	}
-		// }
-	}(cmd)
-
-	d.app.Logger().Info(fmt.Sprintf("process started with pid %d and status %s", cmd.Process.Pid, cmd.Status()))
-
-	d.l.Lock()
-	defer d.l.Unlock()
+	return change.State
+}
 
-	return nil
+// newEngine pick the proxy engine from proxy.engine
+func newEngine(app core.App, w *workercore.Worker) engine {
+	switch app.Config().Proxy.Engine {
+	case config.EngineBuiltin:
+		return NewBuiltinEngine(app, w)
+	default:
+		return NewHAProxyEngine(app, w)
+	}
 }
 
 // Init implements [driver.IDriver].
@@ -118,12 +87,20 @@ func (d *DaemonAdapter) Init() error {
 		return err
 	}
 
-	if worker.ExtraFile != nil {
-		d.worker = worker
+	if len(worker.Listeners()) > 0 {
+		e := newEngine(d.app, worker)
+
+		// disimpan dulu biar Close tetap bersih-bersih walau Start gagal
+		d.l.Lock()
+		d.engine = e
+		d.l.Unlock()
 
-		if err := d.runHaproxy(worker.ExtraFile); err != nil {
+		if err := e.Start(d.app.Context(), worker.Listeners()); err != nil {
+			d.app.Logger().Error(err.Error())
 			return nil
 		}
+
+		worker.SetEngine(e)
 	}
 
 	d.app.Logger().Info("daemon running")
@@ -133,7 +110,10 @@ func (d *DaemonAdapter) Init() error {
 
 // Instance implements [driver.IDriver].
 func (d *DaemonAdapter) Instance() interface{} {
-	return nil
+	d.l.RLock()
+	defer d.l.RUnlock()
+
+	return d.engine
 }
 
 // Name implements [driver.IDriver].
@@ -0,0 +1,467 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/pkg/config"
+	"mox/pkg/haproxy"
+	"mox/tools/utils"
+	"mox/use_cases/manager"
+	"mox/use_cases/operation"
+	"mox/use_cases/workercore"
+)
+
+var _ (workercore.ProxyEngine) = (*HAProxyEngine)(nil)
+
+// HAProxyEngine run haproxy as a child process of the worker. Listener dioper
+// lewat ExtraFiles (fd@N), config di-render dari [proxy] atau haproxy.cfg manual.
+type HAProxyEngine struct {
+	app    core.App
+	worker *workercore.Worker
+
+	// field di bawah ini diganti Validate dan Reload, baca lewat e.mu
+	mu         *sync.Mutex
+	executable string
+	files      []*os.File
+	names      []string
+	config     string
+	// rendered is the haproxy.cfg written from [proxy], dihapus waktu Close
+	rendered string
+
+	runtime *haproxy.Client
+	proc    *process
+}
+
+// process is one haproxy instance, Reload bikin instance baru dengan -sf
+type process struct {
+	cmd      *asyncexec.Cmd
+	done     chan struct{}
+	stopping atomic.Bool
+}
+
+func NewHAProxyEngine(app core.App, worker *workercore.Worker) *HAProxyEngine {
+	return &HAProxyEngine{app: app, worker: worker, mu: &sync.Mutex{}}
+}
+
+// Name implements [workercore.ProxyEngine].
+func (e *HAProxyEngine) Name() string {
+	return config.EngineHAProxy
+}
+
+// listenerFiles build ExtraFiles plus the names in the same order.
+// ExtraFiles[i] jadi fd FirstWorkerFD+i di process haproxy.
+func listenerFiles(listeners []workercore.Listener) ([]*os.File, []string) {
+	files := make([]*os.File, 0, len(listeners))
+	names := make([]string, 0, len(listeners))
+
+	for _, l := range listeners {
+		files = append(files, l.File)
+		names = append(names, l.Name)
+	}
+
+	return files, names
+}
+
+// render return the config file haproxy is started with. Kalau [proxy]
+// diisi, model di-render ke proxy.output per worker, selain itu pakai file manual.
+func (e *HAProxyEngine) render() (string, error) {
+	cfg := e.app.Config()
+
+	proxy := workercore.ProxyConfig(cfg)
+	if proxy.Empty() {
+		return proxy.ConfigFile, nil
+	}
+
+	pid := strconv.Itoa(e.worker.PID())
+
+	model := proxy.Config
+	if model.Global.StatsSocket == "" {
+		// Runtime API client nyambung ke socket ini, lihat Start
+		model.Global.StatsSocket = e.socket()
+	}
+
+	body, err := model.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("cannot render haproxy config: %w", err)
+	}
+
+	path := strings.ReplaceAll(cfg.Proxy.Output, "${PID}", pid)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return "", fmt.Errorf("cannot write haproxy config: %w", err)
+	}
+
+	e.mu.Lock()
+	e.rendered = path
+	e.mu.Unlock()
+
+	return path, nil
+}
+
+// socket is the stats socket of this worker, sama dengan `stats socket` di haproxy.cfg
+func (e *HAProxyEngine) socket() string {
+	return strings.ReplaceAll(e.app.Config().Worker.HAProxySocket, "${PID}", strconv.Itoa(e.worker.PID()))
+}
+
+func (e *HAProxyEngine) env(names []string) []string {
+	return haproxy.ProcessEnv(e.worker.PID(), manager.FirstWorkerFD, names)
+}
+
+// Validate implements [workercore.ProxyEngine]. Config di-render lalu dicek
+// dengan `haproxy -c`, config rusak jangan sampai bikin haproxy exit dan worker ikut mati.
+func (e *HAProxyEngine) Validate(ctx context.Context, listeners []workercore.Listener) error {
+	executable, err := utils.LookupExecutablePathAbs("haproxy")
+	if err != nil {
+		return err
+	}
+
+	path, err := e.render()
+	if err != nil {
+		return err
+	}
+
+	files, names := listenerFiles(listeners)
+
+	diagnostics, err := haproxy.NewChecker(executable).SetEnv(e.env(names)).SetFiles(files).Check(ctx, path)
+	for _, diag := range diagnostics {
+		e.app.Logger().Warn("haproxy config check", slog.String("config", path), slog.String("diagnostic", diag.String()))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Stats dan Drain bisa jalan bareng Reload, swap-nya di bawah lock
+	e.mu.Lock()
+	e.executable, e.config = executable, path
+	e.files, e.names = files, names
+	e.mu.Unlock()
+
+	return nil
+}
+
+// Start implements [workercore.ProxyEngine].
+func (e *HAProxyEngine) Start(ctx context.Context, listeners []workercore.Listener) error {
+	if err := e.Validate(ctx, listeners); err != nil {
+		return err
+	}
+
+	p, err := e.spawn()
+	if err != nil {
+		return err
+	}
+
+	rt := haproxy.NewClient(e.socket())
+
+	e.mu.Lock()
+	e.proc, e.runtime = p, rt
+	e.mu.Unlock()
+
+	e.worker.SetHAProxy(p.cmd.Process.Pid)
+	e.worker.SetRuntime(rt)
+
+	go e.restore(p, rt)
+
+	return nil
+}
+
+// runtimeWait is how long restore wait for the stats socket of a new haproxy
+const runtimeWait = 10 * time.Second
+
+// restore tunggu stats socket milik p siap (bukan punya instance lama waktu
+// reload), lalu apply server state file. Jalan di background biar Start tidak nunggu haproxy.
+func (e *HAProxyEngine) restore(p *process, rt *haproxy.Client) {
+	ctx, cancel := context.WithTimeout(e.app.Context(), runtimeWait)
+	defer cancel()
+
+	pid := strconv.Itoa(p.cmd.Process.Pid)
+
+	for {
+		if info, err := rt.ShowInfo(ctx); err == nil && info["Pid"] == pid {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			e.app.Logger().Warn("haproxy runtime api not ready, server state not restored", slog.String("pid", pid))
+			return
+		case <-p.done:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	restoreServers(ctx, e.app, e)
+}
+
+// spawn start one haproxy process with the current config
+func (e *HAProxyEngine) spawn(args ...string) (*process, error) {
+	e.mu.Lock()
+	executable, path, files, names := e.executable, e.config, e.files, e.names
+	e.mu.Unlock()
+
+	e.app.Logger().Info("starting haproxy", slog.String("config", path))
+
+	cmd := asyncexec.Command(e.app.Context(), executable, append([]string{"-f", path}, args...)...)
+
+	// access log dan notice haproxy masuk ke logger mox (termasuk export OTel)
+	logger := e.app.Logger().With(
+		slog.String("APP", "HAPROXY"),
+		slog.Int("worker_pid", e.worker.PID()),
+		slog.Int("generation", workercore.Generation()),
+	)
+	stdout := haproxy.NewLogWriter(logger, slog.LevelInfo)
+	stderr := haproxy.NewLogWriter(logger, slog.LevelError)
+
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	cmd.ExtraFiles = files
+
+	for i, name := range names {
+		fmt.Printf("Worker: Oper listener ke haproxy %s=fd@%d\n", haproxy.ListenerEnv(name), manager.FirstWorkerFD+i)
+	}
+
+	fdOrderEnv := fmt.Sprintf("FD_ORDER=%d", files[0].Fd())
+	cmd.Env = append(append(os.Environ(), fdOrderEnv), e.env(names)...)
+
+	if err := cmd.AsyncRun(); err != nil {
+		e.app.Logger().Error("process starting failed", slog.String("err", err.Error()))
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, done: make(chan struct{})}
+
+	go func() {
+		<-cmd.Terminated
+		stdout.Close()
+		stderr.Close()
+		close(p.done)
+
+		e.app.Logger().Info(fmt.Sprintf("process %d terminated : %s", cmd.Process.Pid, cmd.Status()))
+
+		e.mu.Lock()
+		current := e.proc == p
+		e.mu.Unlock()
+
+		// instance lama yang diganti Reload boleh pergi
+		if !current {
+			return
+		}
+
+		e.worker.SetHAProxy(0)
+
+		// exit if the process terminated abnormally without being asked to stop
+		if cmd.ProcessState.ExitCode() != 0 && !p.stopping.Load() {
+			e.app.Stop()
+		}
+	}()
+
+	e.app.Logger().Info(fmt.Sprintf("process started with pid %d and status %s", cmd.Process.Pid, cmd.Status()))
+
+	return p, nil
+}
+
+// current return the running haproxy and the Runtime API client connected to it
+func (e *HAProxyEngine) current() (*process, *haproxy.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.proc == nil || e.runtime == nil {
+		return nil, nil, errors.New("haproxy is not running")
+	}
+
+	return e.proc, e.runtime, nil
+}
+
+// Reload implements [workercore.ProxyEngine]. Config baru dicek dulu, lalu
+// haproxy baru di-start dengan -sf supaya instance lama selesai dengan soft stop.
+func (e *HAProxyEngine) Reload(ctx context.Context) error {
+	old, _, err := e.current()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	listeners := make([]workercore.Listener, len(e.files))
+	for i := range e.files {
+		listeners[i] = workercore.Listener{Name: e.names[i], File: e.files[i]}
+	}
+	e.mu.Unlock()
+
+	if err := e.Validate(ctx, listeners); err != nil {
+		return err
+	}
+
+	p, err := e.spawn("-sf", strconv.Itoa(old.cmd.Process.Pid))
+	if err != nil {
+		return err
+	}
+
+	// koneksi di pool masih nyambung ke instance lama, mulai dari client baru
+	rt := haproxy.NewClient(e.socket())
+
+	e.mu.Lock()
+	prev := e.runtime
+	e.proc, e.runtime = p, rt
+	e.mu.Unlock()
+
+	e.worker.SetHAProxy(p.cmd.Process.Pid)
+	e.worker.SetRuntime(rt)
+
+	// client lama baru ditutup setelah tidak bisa diambil lagi lewat current
+	prev.Close()
+
+	go e.restore(p, rt)
+
+	return nil
+}
+
+// Drain implements [workercore.ProxyEngine]. Semua frontend di-disable lewat Runtime API.
+func (e *HAProxyEngine) Drain(ctx context.Context) error {
+	_, rt, err := e.current()
+	if err != nil {
+		return err
+	}
+
+	stats, err := rt.ShowStat(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if s.Type != haproxy.TypeFrontend {
+			continue
+		}
+
+		if err := rt.DisableFrontend(ctx, s.ProxyName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stats implements [workercore.ProxyEngine].
+func (e *HAProxyEngine) Stats(ctx context.Context) (workercore.EngineStats, error) {
+	p, rt, err := e.current()
+	if err != nil {
+		return workercore.EngineStats{}, err
+	}
+
+	info, err := rt.ShowInfo(ctx)
+	if err != nil {
+		return workercore.EngineStats{}, err
+	}
+
+	stats, err := rt.ShowStat(ctx)
+	if err != nil {
+		return workercore.EngineStats{}, err
+	}
+
+	return workercore.EngineStats{PID: p.cmd.Process.Pid, Info: info, Proxies: stats}, nil
+}
+
+// Stop implements [workercore.ProxyEngine]. Soft stop (SIGUSR1), kalau ctx
+// habis duluan haproxy di-hard stop (SIGTERM).
+func (e *HAProxyEngine) Stop(ctx context.Context) error {
+	p, _, err := e.current()
+	if err != nil {
+		// haproxy sudah tidak jalan, tidak ada yang perlu di-stop
+		return nil
+	}
+
+	p.stopping.Store(true)
+
+	if err := p.cmd.Process.Signal(syscall.SIGUSR1); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	if err := p.cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateServer implements [workercore.ProxyEngine]. Server dinamis lewat
+// `add server`/`del server` butuh HAProxy 2.4 ke atas.
+func (e *HAProxyEngine) UpdateServer(ctx context.Context, change operation.ServerChange) error {
+	_, rt, err := e.current()
+	if err != nil {
+		return err
+	}
+
+	switch change.Action {
+	case operation.ServerSetWeight:
+		return rt.SetServerWeight(ctx, change.Backend, change.Server, change.Weight)
+	case operation.ServerSetState:
+		return rt.SetServerState(ctx, change.Backend, change.Server, change.State)
+	case operation.ServerSetHealth:
+		return rt.SetServerHealth(ctx, change.Backend, change.Server, change.Health)
+	case operation.ServerAdd:
+		var options []string
+		if change.Weight > 0 {
+			options = append(options, "weight", strconv.Itoa(change.Weight))
+		}
+
+		if err := rt.AddServer(ctx, change.Backend, change.Server, change.Address, options...); err != nil {
+			return err
+		}
+
+		// server dinamis mulai dalam state maint
+		return rt.SetServerState(ctx, change.Backend, change.Server, addedState(change))
+	case operation.ServerDel:
+		if err := rt.SetServerState(ctx, change.Backend, change.Server, haproxy.StateMaint); err != nil {
+			return err
+		}
+
+		return rt.DelServer(ctx, change.Backend, change.Server)
+	}
+
+	return fmt.Errorf("unknown server action %q", change.Action)
+}
+
+// Close kill haproxy and remove the rendered config
+func (e *HAProxyEngine) Close() error {
+	e.mu.Lock()
+	p, rt, rendered := e.proc, e.runtime, e.rendered
+	e.mu.Unlock()
+
+	if rt != nil {
+		rt.Close()
+	}
+
+	if rendered != "" {
+		os.Remove(rendered)
+	}
+
+	if p == nil {
+		return nil
+	}
+
+	p.stopping.Store(true)
+
+	if err := p.cmd.Cancel(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/labstack/echo/v4"
@@ -8,7 +11,9 @@ import (
 	"mox/drivers/master"
 	core "mox/internal"
 	"mox/pkg/driver/v2"
+	"mox/pkg/haproxy"
 	"mox/use_cases/mastercore"
+	"mox/use_cases/operation"
 )
 
 func InitRoutes(e *echo.Echo, app core.App) {
@@ -36,6 +41,15 @@ func InitRoutes(e *echo.Echo, app core.App) {
 		return c.String(200, master.Orchestrator.CheckHealth())
 	})
 
+	prefix.GET("/health/servers", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		return NewApiResponse(master.Orchestrator.Health(), 200, c)
+	})
+
 	prefix.GET("/workers", func(c echo.Context) error {
 		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
 		if err != nil {
@@ -44,4 +58,358 @@ func InitRoutes(e *echo.Echo, app core.App) {
 
 		return c.String(200, strconv.Itoa(int(master.Orchestrator.GetTotalWorkers())))
 	})
+
+	prefix.GET("/workers/stats", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		return NewApiResponse(master.Orchestrator.Stats(), 200, c)
+	})
+
+	// ?format=csv untuk output seperti `show stat`, ?worker=<pid> untuk satu worker saja
+	prefix.GET("/stats", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		format := c.QueryParam("format")
+		if format != "" && format != "json" && format != "csv" {
+			return NewBadRequestError("format must be json or csv", nil)
+		}
+
+		stats := master.Orchestrator.ProxyStats()
+
+		var data any = stats
+		proxies := stats.Proxies
+
+		if pid := c.QueryParam("worker"); pid != "" {
+			n, err := strconv.Atoi(pid)
+			if err != nil {
+				return NewBadRequestError("worker must be a pid", nil)
+			}
+
+			w, ok := stats.Worker(n)
+			if !ok {
+				return NewNotFoundError(fmt.Sprintf("worker %d has no stats", n), nil)
+			}
+
+			data, proxies = w, w.Proxies
+		}
+
+		if format == "csv" {
+			var buf bytes.Buffer
+			if err := haproxy.WriteStat(&buf, proxies); err != nil {
+				return NewInternalServerError(err)
+			}
+
+			return c.Blob(200, "text/csv; charset=utf-8", buf.Bytes())
+		}
+
+		return NewApiResponse(data, 200, c)
+	})
+
+	prefix.GET("/listeners", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		return NewApiResponse(master.Orchestrator.Listeners(), 200, c)
+	})
+
+	prefix.GET("/reload", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		status, ok := master.Orchestrator.ReloadStatus()
+		if !ok {
+			return NewNotFoundError("no reload has run yet", nil)
+		}
+
+		return NewApiResponse(status, 200, c)
+	})
+
+	// blocking sampai rollout selesai, progress-nya bisa dilihat di GET /api/reload.
+	// {"canary": true} = rollout lewat canary, termasuk bake-nya.
+	prefix.POST("/reload", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		var body struct {
+			Author string `json:"author"`
+			Reason string `json:"reason"`
+			Canary bool   `json:"canary"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return NewBadRequestError(err.Error(), nil)
+		}
+
+		if body.Author == "" {
+			body.Author = "api"
+		}
+
+		if body.Reason == "" {
+			body.Reason = "api reload"
+		}
+
+		reload := master.Orchestrator.Reload
+		if body.Canary {
+			reload = master.Orchestrator.Canary
+		}
+
+		if err := reload(body.Author, body.Reason); err != nil {
+			switch {
+			case errors.Is(err, operation.ErrConfigRejected):
+				return NewBadRequestError(err.Error(), nil)
+			case errors.Is(err, operation.ErrReloadInProgress):
+				return NewApiError(409, err.Error(), nil)
+			}
+
+			return NewInternalServerError(err)
+		}
+
+		status, _ := master.Orchestrator.ReloadStatus()
+
+		return NewApiResponse(status, 200, c)
+	})
+
+	prefix.GET("/config/reload", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		report, ok := master.Orchestrator.ConfigReport()
+		if !ok {
+			return NewNotFoundError("config has not been reloaded yet", nil)
+		}
+
+		return NewApiResponse(report, 200, c)
+	})
+
+	// sama seperti SIGHUP, blocking sampai rollout model proxy (kalau ada) selesai
+	prefix.POST("/config/reload", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		report, err := master.Orchestrator.ReloadConfig("api")
+		if err != nil {
+			switch {
+			case errors.Is(err, operation.ErrConfigRejected):
+				return NewBadRequestError(err.Error(), nil)
+			case errors.Is(err, operation.ErrReloadInProgress):
+				return NewApiError(409, err.Error(), nil)
+			}
+
+			return NewInternalServerError(err)
+		}
+
+		return NewApiResponse(report, 200, c)
+	})
+
+	prefix.GET("/revisions", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		revisions, err := master.Orchestrator.Revisions()
+		if err != nil {
+			return revisionError(err)
+		}
+
+		return NewApiResponse(revisions, 200, c)
+	})
+
+	prefix.GET("/revisions/:revision", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		n, err := strconv.Atoi(c.Param("revision"))
+		if err != nil {
+			return NewBadRequestError("invalid revision", nil)
+		}
+
+		revision, err := master.Orchestrator.Revision(n)
+		if err != nil {
+			return revisionError(err)
+		}
+
+		return NewApiResponse(revision, 200, c)
+	})
+
+	// unified diff apa adanya, biar bisa langsung dibaca atau di-pipe ke `patch`
+	prefix.GET("/revisions/:revision/diff/:other", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		from, err := strconv.Atoi(c.Param("revision"))
+		if err != nil {
+			return NewBadRequestError("invalid revision", nil)
+		}
+
+		to, err := strconv.Atoi(c.Param("other"))
+		if err != nil {
+			return NewBadRequestError("invalid revision", nil)
+		}
+
+		diff, err := master.Orchestrator.DiffRevisions(from, to)
+		if err != nil {
+			return revisionError(err)
+		}
+
+		return c.String(200, diff)
+	})
+
+	// blocking sampai rollout selesai seperti POST /api/reload
+	prefix.POST("/revisions/:revision/rollback", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		n, err := strconv.Atoi(c.Param("revision"))
+		if err != nil {
+			return NewBadRequestError("invalid revision", nil)
+		}
+
+		var body struct {
+			Author string `json:"author"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return NewBadRequestError(err.Error(), nil)
+		}
+
+		if body.Author == "" {
+			body.Author = "api"
+		}
+
+		if err := master.Orchestrator.RollbackRevision(n, body.Author); err != nil {
+			return revisionError(err)
+		}
+
+		status, _ := master.Orchestrator.ReloadStatus()
+
+		return NewApiResponse(status, 200, c)
+	})
+
+	prefix.GET("/backends", func(c echo.Context) error {
+		master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+		if err != nil {
+			return c.String(400, "WORKER NOT READY")
+		}
+
+		backends, err := master.Orchestrator.Backends()
+		if err != nil {
+			return NewInternalServerError(err)
+		}
+
+		return NewApiResponse(backends, 200, c)
+	})
+
+	prefix.POST("/backends/:backend/servers", func(c echo.Context) error {
+		var body struct {
+			Name    string              `json:"name"`
+			Address string              `json:"address"`
+			Weight  int                 `json:"weight"`
+			State   haproxy.ServerState `json:"state"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return NewBadRequestError(err.Error(), nil)
+		}
+
+		return updateServer(c, app, operation.ServerChange{
+			Action:  operation.ServerAdd,
+			Backend: c.Param("backend"),
+			Server:  body.Name,
+			Address: body.Address,
+			Weight:  body.Weight,
+			State:   body.State,
+		})
+	})
+
+	prefix.PUT("/backends/:backend/servers/:server/weight", func(c echo.Context) error {
+		var body struct {
+			Weight *int `json:"weight"`
+		}
+		if err := c.Bind(&body); err != nil || body.Weight == nil {
+			return NewBadRequestError("weight is required", nil)
+		}
+
+		return updateServer(c, app, operation.ServerChange{
+			Action:  operation.ServerSetWeight,
+			Backend: c.Param("backend"),
+			Server:  c.Param("server"),
+			Weight:  *body.Weight,
+		})
+	})
+
+	prefix.PUT("/backends/:backend/servers/:server/state", func(c echo.Context) error {
+		var body struct {
+			State haproxy.ServerState `json:"state"`
+		}
+		if err := c.Bind(&body); err != nil {
+			return NewBadRequestError(err.Error(), nil)
+		}
+
+		return updateServer(c, app, operation.ServerChange{
+			Action:  operation.ServerSetState,
+			Backend: c.Param("backend"),
+			Server:  c.Param("server"),
+			State:   body.State,
+		})
+	})
+
+	prefix.DELETE("/backends/:backend/servers/:server", func(c echo.Context) error {
+		return updateServer(c, app, operation.ServerChange{
+			Action:  operation.ServerDel,
+			Backend: c.Param("backend"),
+			Server:  c.Param("server"),
+		})
+	})
+}
+
+// updateServer apply change lewat master, change yang ditolak sebelum disimpan jadi 400
+func updateServer(c echo.Context, app core.App, change operation.ServerChange) error {
+	master, err := driver.Get[*mastercore.Master](app.Driver(), master.MasterAdapterName)
+	if err != nil {
+		return c.String(400, "WORKER NOT READY")
+	}
+
+	if err := master.Orchestrator.UpdateServer(change); err != nil {
+		if errors.Is(err, operation.ErrInvalidServerChange) {
+			return NewBadRequestError(err.Error(), nil)
+		}
+
+		return NewInternalServerError(err)
+	}
+
+	return NewApiResponse(change, 200, c)
+}
+
+// revisionError map the revision and rollout errors to their status code
+func revisionError(err error) error {
+	switch {
+	case errors.Is(err, operation.ErrRevisionNotFound):
+		return NewNotFoundError(err.Error(), nil)
+	case errors.Is(err, operation.ErrRevisionsDisabled):
+		return NewBadRequestError(err.Error(), nil)
+	case errors.Is(err, operation.ErrReloadInProgress):
+		return NewApiError(409, err.Error(), nil)
+	default:
+		return NewInternalServerError(err)
+	}
 }
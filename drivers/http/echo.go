@@ -3,12 +3,14 @@ package http
 import (
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 
 	"mox/drivers/http/api"
 	core "mox/internal"
 	"mox/pkg/driver"
+	"mox/pkg/upgrade"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -23,6 +25,9 @@ var _ (driver.IDriver) = (*EchoWebAdapter)(nil)
 
 const ECHO_ADAPTER = "ECHO_ADAPTER"
 
+// InheritAPI is the upgrade manifest name of the REST API listener
+const InheritAPI = "api"
+
 type EchoWebAdapter struct {
 	ec  *echo.Echo
 	app core.App
@@ -116,6 +121,17 @@ func (e *EchoWebAdapter) Close() error {
 	return e.ec.Close()
 }
 
+// listen reuse the API listener of the previous master on upgrade
+func (e *EchoWebAdapter) listen() (net.Listener, error) {
+	if manifest, ok := upgrade.Inherited(); ok {
+		if _, found := manifest.Files[InheritAPI]; found {
+			return manifest.Listener(InheritAPI)
+		}
+	}
+
+	return net.Listen("tcp", fmt.Sprintf(":%d", e.app.Config().Api.Port))
+}
+
 func (e *EchoWebAdapter) Init() error {
 	// s := http.Server{
 	// 	Addr:    fmt.Sprintf(":%d", e.app.Config().App.WebServerPort),
@@ -125,6 +141,15 @@ func (e *EchoWebAdapter) Init() error {
 
 	schema := "http"
 
+	l, err := e.listen()
+	if err != nil {
+		return err
+	}
+
+	// echo pakai listener ini apa adanya, jadi bisa diwariskan ke master baru
+	e.ec.Listener = l
+	upgrade.RegisterInheritable(InheritAPI, l.(*net.TCPListener).File)
+
 	bold := color.New(color.Bold).Add(color.FgGreen).SprintfFunc()
 
 	go func(e *echo.Echo, app core.App) {
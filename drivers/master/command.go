@@ -2,20 +2,228 @@ package master
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	core "mox/internal"
+	"mox/pkg/haproxy"
 	"mox/use_cases/operation"
 )
 
 func RegisterCommand(app core.App) operation.IControl {
 	registry := operation.NewMasterRegistry()
 
-	registry.Register("noop", "description", "usage", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) error {
+	registry.Register("noop", "Log the command line, handy to test the control connection", "NOOP", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
 		app.Logger().Info(fmt.Sprintf("%s %v", "noop command", cmd))
 
-		return nil
+		return "", nil
+	})
+
+	registry.Register("HELP", "List every control command with its usage", "HELP", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		return registry.Help(), nil
+	})
+
+	registry.Register("WORKERS", "List the workers connected to the master", "WORKERS", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		return formatWorkers(master.Workers(), master.GetDesiredWorkers()), nil
+	})
+
+	registry.Register("DRAIN", "Drain one worker, its HAProxy stops accepting and sessions are left to finish", "DRAIN <pid>", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		args := cmd.Args()
+		if len(args) != 1 {
+			return "", errors.New("usage: DRAIN <pid>")
+		}
+
+		pid, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid pid %q", args[0])
+		}
+
+		return "", master.Drain(pid)
+	})
+
+	registry.Register("SCALE", "Spawn or retire workers until exactly n are running", "SCALE <n>", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		args := cmd.Args()
+		if len(args) != 1 {
+			return "", errors.New("usage: SCALE <n>")
+		}
+
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return "", fmt.Errorf("invalid worker count %q", args[0])
+		}
+
+		return "", master.Scale(n)
+	})
+
+	registry.Register("STATS", "Show `show stat` of every worker merged per frontend, backend and server, as CSV", "STATS", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		var b strings.Builder
+		if err := haproxy.WriteStat(&b, master.ProxyStats().Proxies); err != nil {
+			return "", err
+		}
+
+		return b.String(), nil
+	})
+
+	registry.Register("UPGRADE", "Re-exec the master binary without dropping listeners", "UPGRADE", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		return "", master.Upgrade()
+	})
+
+	registry.Register("LISTENER", "Open or close a public listener, workers are rolled to the new listener set", "LISTENER ADD <name> <address> | LISTENER REMOVE <name>", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		args := cmd.Args()
+		if len(args) == 0 {
+			return "", errors.New("usage: LISTENER ADD <name> <address> | LISTENER REMOVE <name>")
+		}
+
+		switch action := strings.ToUpper(args[0]); {
+		case action == "ADD" && len(args) == 3:
+			return "", master.AddListener(args[1], args[2])
+		case action == "REMOVE" && len(args) == 2:
+			return "", master.RemoveListener(args[1])
+		default:
+			return "", fmt.Errorf("invalid LISTENER arguments %q", strings.Join(args, " "))
+		}
+	})
+
+	reload := func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		reason := "config reload"
+		if args := cmd.Args(); len(args) > 0 {
+			reason = strings.Join(args, " ")
+		}
+
+		if err := master.Reload("control", reason); err != nil {
+			return "", err
+		}
+
+		return reloadSummary(master), nil
+	}
+
+	registry.Register(operation.ConfigReload.String(), "Roll a new generation of workers onto the config on disk, rolled back when it does not become ready", "CONFIG_RELOAD [reason]", reload)
+	registry.Register("RELOAD", "Same as CONFIG_RELOAD", "RELOAD [reason]", reload)
+
+	registry.Register("CANARY", "Start a few workers of a new generation next to the old ones, promoted when their 5xx ratio and latency hold for the bake time", "CANARY [reason]", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		reason := "canary reload"
+		if args := cmd.Args(); len(args) > 0 {
+			reason = strings.Join(args, " ")
+		}
+
+		if err := master.Canary("control", reason); err != nil {
+			return "", err
+		}
+
+		return reloadSummary(master), nil
+	})
+
+	registry.Register("REVISION", "Roll a new generation of workers onto the haproxy.cfg of a stored config revision", "REVISION ROLLBACK <revision>", func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		args := cmd.Args()
+		if len(args) != 2 || !strings.EqualFold(args[0], "ROLLBACK") {
+			return "", errors.New("usage: REVISION ROLLBACK <revision>")
+		}
+
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid revision %q", args[1])
+		}
+
+		if err := master.RollbackRevision(n, "control"); err != nil {
+			return "", err
+		}
+
+		return reloadSummary(master), nil
+	})
+
+	registry.Register("SERVER", "Change one backend server on every worker, the change is kept for workers started later", serverUsage, func(ctx context.Context, master operation.SystemCore, cmd operation.Command) (string, error) {
+		change, err := parseServerChange(cmd.Args())
+		if err != nil {
+			return "", err
+		}
+
+		return "", master.UpdateServer(change)
 	})
 
 	return registry
 }
+
+// formatWorkers render WORKERS as an aligned table followed by the desired count
+func formatWorkers(workers []operation.WorkerInfo, desired int64) string {
+	var b strings.Builder
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PID\tGENERATION\tCONFIG\tSTATE\tLAST SEEN\tRTT")
+
+	for _, w := range workers {
+		state := w.State
+		if w.Retiring {
+			state += " (retiring)"
+		}
+
+		seen := "-"
+		if !w.LastSeen.IsZero() {
+			seen = time.Since(w.LastSeen).Round(time.Second).String() + " ago"
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\n", w.PID, w.Generation, w.ConfigHash, state, seen, w.RTT.Round(time.Microsecond))
+	}
+
+	tw.Flush()
+	fmt.Fprintf(&b, "%d running, %d desired\n", len(workers), desired)
+
+	return b.String()
+}
+
+// reloadSummary is the one line reply of a finished rollout command
+func reloadSummary(master operation.SystemCore) string {
+	status, ok := master.ReloadStatus()
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("generation %d %s (%s)", status.Generation, status.State, status.Mode)
+}
+
+const serverUsage = "SERVER WEIGHT <backend>/<server> <0-256> | SERVER STATE <backend>/<server> ready|drain|maint | SERVER ADD <backend>/<server> <address> [weight] | SERVER DEL <backend>/<server>"
+
+// parseServerChange turn "WEIGHT api/a 10" style arguments into a ServerChange
+func parseServerChange(args []string) (operation.ServerChange, error) {
+	if len(args) < 2 {
+		return operation.ServerChange{}, errors.New("usage: " + serverUsage)
+	}
+
+	backend, server, ok := strings.Cut(args[1], "/")
+	if !ok {
+		return operation.ServerChange{}, fmt.Errorf("server %q must be <backend>/<server>", args[1])
+	}
+
+	change := operation.ServerChange{Backend: backend, Server: server}
+
+	var err error
+
+	switch action := strings.ToUpper(args[0]); {
+	case action == "WEIGHT" && len(args) == 3:
+		change.Action = operation.ServerSetWeight
+		change.Weight, err = strconv.Atoi(args[2])
+	case action == "STATE" && len(args) == 3:
+		change.Action = operation.ServerSetState
+		change.State = haproxy.ServerState(strings.ToLower(args[2]))
+	case action == "ADD" && (len(args) == 3 || len(args) == 4):
+		change.Action = operation.ServerAdd
+		change.Address = args[2]
+
+		if len(args) == 4 {
+			change.Weight, err = strconv.Atoi(args[3])
+		}
+	case action == "DEL" && len(args) == 2:
+		change.Action = operation.ServerDel
+	default:
+		return change, fmt.Errorf("invalid SERVER arguments %q", strings.Join(args, " "))
+	}
+
+	if err != nil {
+		return change, fmt.Errorf("invalid weight: %w", err)
+	}
+
+	return change, change.Validate()
+}
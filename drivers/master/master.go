@@ -2,12 +2,18 @@ package master
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 	"net"
 	"sync"
 
+	"mox/adapters"
 	core "mox/internal"
 	"mox/pkg/driver"
+	"mox/repositories"
 	"mox/use_cases/mastercore"
+
+	"gorm.io/gorm"
 )
 
 var _ (driver.IDriver) = (*MasterAdapter)(nil)
@@ -20,6 +26,7 @@ type MasterAdapter struct {
 	mastercore *mastercore.Master
 	l          net.Listener // instance listener
 	wg         *sync.WaitGroup
+	sql        *adapters.SqlAdapters // koneksi database revisi, nil kalau tidak dipakai
 }
 
 func NewMasterAdapter(ctx context.Context, app core.App) *MasterAdapter {
@@ -28,10 +35,19 @@ func NewMasterAdapter(ctx context.Context, app core.App) *MasterAdapter {
 
 // Close implements [driver.IDriver].
 func (m *MasterAdapter) Close() error {
-	m.mastercore.Connections().CloseAllConnections()
+	// worker sudah diambil alih master baru, jangan disuruh mati
+	if !m.mastercore.HandedOff() {
+		m.mastercore.Connections().CloseAllConnections()
+	}
 
 	m.mastercore.Stop()
 
+	if m.sql != nil {
+		m.sql.Disconnect(func(err error) {
+			m.app.Logger().Error(err.Error())
+		})
+	}
+
 	m.app.Logger().Info("master closed")
 
 	return nil
@@ -46,6 +62,17 @@ func (m *MasterAdapter) Init() error {
 		m.app,
 	).SetOperations(operations)
 
+	// database mati tidak boleh bikin master gagal start, revisi saja yang tidak dicatat
+	if alias := m.app.Config().Revisions.Database; alias != "" {
+		store, sql, err := OpenRevisionStore(m.app, alias)
+		if err != nil {
+			m.app.Logger().Warn("config revisions are disabled", slog.String("database", alias), slog.String("err", err.Error()))
+		} else {
+			m.sql = sql
+			master.SetRevisions(store)
+		}
+	}
+
 	if err := master.Run(); err != nil {
 		m.app.Logger().Error(err.Error())
 		return err
@@ -67,3 +94,24 @@ func (m *MasterAdapter) Instance() interface{} {
 func (m *MasterAdapter) Name() string {
 	return MasterAdapterName
 }
+
+// OpenRevisionStore connect only the database named alias and return the
+// config revision repository on top of it. Caller yang Disconnect sql-nya.
+func OpenRevisionStore(app core.App, alias string) (*repositories.ConfigRevisionGormRepository, *adapters.SqlAdapters, error) {
+	cfg := app.Config()
+
+	sql := adapters.NewSqlAdapters(&cfg, adapters.RegisteredSQLAdapters)
+
+	conn, err := sql.ConnectAlias(alias)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, ok := conn.(*gorm.DB)
+	if !ok {
+		sql.Disconnect(func(err error) {})
+		return nil, nil, fmt.Errorf("database %s is not a gorm connection", alias)
+	}
+
+	return repositories.NewConfigRevisionRepository(db), sql, nil
+}
@@ -39,7 +39,7 @@ func (w *WorkerAdapter) Init() error {
 
 	ctx := w.app.Context()
 
-	socketPath := "/tmp/http_mgr.sock"
+	socketPath := w.app.Config().Master.SocketPath
 
 	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: socketPath, Net: "unix"})
 	if err != nil {
@@ -63,6 +63,9 @@ func (w *WorkerAdapter) Init() error {
 		w.app.Stop()
 	})
 
+	cfg := w.app.Config().Worker
+	go worker.ReportStats(ctx, cfg.StatsInterval)
+
 	return nil
 }
 
@@ -0,0 +1,17 @@
+package models
+
+// ConfigRevision is one proxy config applied by the master
+type ConfigRevision struct {
+	BaseModel
+	Revision   int    `gorm:"not null;uniqueIndex"`
+	Author     string `gorm:"not null"`
+	Reason     string
+	Hash       string `gorm:"not null"`
+	Generation int    `gorm:"not null"`
+	Rendered   string `gorm:"not null"`
+}
+
+// TableName sets the name of the table
+func (ConfigRevision) TableName() string {
+	return "config_revisions"
+}
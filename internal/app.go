@@ -22,6 +22,12 @@ type App interface {
 	// all the configuration for this application
 	Config() config.Config
 
+	// config file location given on the command line, empty means default lookup
+	ConfigPath() string
+
+	// re-read the config file, the current config is kept when it is invalid
+	ReloadConfig() (config.Config, error)
+
 	// base logger application
 	Logger() *slog.Logger
 
@@ -26,13 +26,15 @@ import (
 var _ App = (*BaseApp)(nil)
 
 type BaseApp struct {
-	config   *config.Config
-	logger   *slog.Logger
-	data     *datamanager.DataManager
-	driver   *driver.Driver
-	driverv2 *driverv2.Manager
+	config     *config.Config
+	configPath string
+	logger     *slog.Logger
+	data       *datamanager.DataManager
+	driver     *driver.Driver
+	driverv2   *driverv2.Manager
 
 	mu         *sync.Mutex
+	cfgMu      sync.RWMutex // config dan logger bisa diganti waktu hot reload
 	ctx        context.Context
 	cancelFunc context.CancelFunc
 
@@ -82,6 +84,14 @@ func NewTestApp() *BaseApp {
 	return &BaseApp{}
 }
 
+// NewTestAppWithConfig is NewTestApp with cfg already loaded, tanpa file config
+func NewTestAppWithConfig(cfg config.Config) *BaseApp {
+	b := &BaseApp{mu: &sync.Mutex{}}
+	b.setConfig(&cfg)
+
+	return b
+}
+
 func (b *BaseApp) Migration(migrationPath string) *migrate.Migrate {
 	connection := b.data.Get("sql", "default")
 
@@ -108,11 +118,20 @@ func (b *BaseApp) Migration(migrationPath string) *migrate.Migrate {
 	return m
 }
 
+// loggerName return the instrumentation scope name of the logger
+func loggerName(cfg *config.Config) string {
+	if cfg == nil || cfg.App.Name == "" {
+		return "mox"
+	}
+
+	return cfg.App.Name
+}
+
 func (b *BaseApp) initLogger(cfg *config.Config) *slog.Logger {
 	minLevel := slog.LevelDebug
 
-	if cfg != nil && cfg.App.Mode == "production" {
-		minLevel = slog.LevelInfo
+	if cfg != nil {
+		minLevel = cfg.App.Level()
 	}
 
 	handler := logs.NewBaseLogHandler(&logs.LogOptions{
@@ -132,6 +151,8 @@ func (b *BaseApp) initLogger(cfg *config.Config) *slog.Logger {
 		slogmulti.Fanout(
 			// slog.NewJSONHandler(os.Stdout, nil),
 			handler,
+			// diekspor kalau monitoring.enable_collect_log nyala
+			logs.NewOTelHandler(loggerName(cfg)),
 		)))
 
 	return slog.Default()
@@ -250,9 +271,37 @@ func (b *BaseApp) Cache() {
 
 // Config implements App.
 func (b *BaseApp) Config() config.Config {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+
 	return *b.config
 }
 
+// ReloadConfig implements App.
+func (b *BaseApp) ReloadConfig() (config.Config, error) {
+	cfg, err := config.LoadConfig(b.configParam(b.configPath))
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	b.setConfig(cfg)
+
+	return *cfg, nil
+}
+
+func (b *BaseApp) setConfig(cfg *config.Config) {
+	b.cfgMu.Lock()
+	defer b.cfgMu.Unlock()
+
+	b.config = cfg
+	b.logger = b.initLogger(cfg)
+}
+
+// ConfigPath implements App.
+func (b *BaseApp) ConfigPath() string {
+	return b.configPath
+}
+
 // Data implements App.
 func (b *BaseApp) Data() *datamanager.DataManager {
 	return b.data
@@ -264,11 +313,14 @@ func (b *BaseApp) Sql(connName string) *sql.DB {
 
 // IsDev implements App.
 func (b *BaseApp) IsDev() bool {
-	return b.config.App.Mode == "development"
+	return b.Config().App.Mode == "development"
 }
 
 // Logger implements App.
 func (b *BaseApp) Logger() *slog.Logger {
+	b.cfgMu.RLock()
+	defer b.cfgMu.RUnlock()
+
 	if b.logger == nil {
 		return slog.Default()
 	}
@@ -291,37 +343,42 @@ func (b *BaseApp) getFileName(filePath string) string {
 }
 
 func (b *BaseApp) loadConfig(configPath string) *config.Config {
+	b.configPath = configPath
+
 	var cfg *config.Config
 	if configPath == "" {
 		cfg = config.NewDefaultConfig()
 	} else {
-		cfg = config.NewConfig(config.ConfigParam{
-			ConfigName: b.getFileName(configPath),
-			ConfigType: "toml",
-			Path:       b.getDirectoryPath(configPath),
-		})
+		cfg = config.NewConfig(b.configParam(configPath))
 	}
 
 	return cfg
 }
 
+func (b *BaseApp) configParam(configPath string) config.ConfigParam {
+	if configPath == "" {
+		return config.ConfigParam{ConfigName: "config", ConfigType: "toml", Path: "."}
+	}
+
+	return config.ConfigParam{
+		ConfigName: b.getFileName(configPath),
+		ConfigType: "toml",
+		Path:       b.getDirectoryPath(configPath),
+	}
+}
+
 // Start The Application by blocking the main
 func (b *BaseApp) Bootstrap() error {
 	b.OnBeforeApplicationBootstrapped().Execute(BeforeApplicationBootstrapped{App: b})
 
 	b.OnAfterApplicationBootstrapped().Add("a_load_cfg", func(e AfterApplicationBootstrapped) error {
-		cfg := b.loadConfig(e.ConfigPath)
-		b.config = cfg
-		b.logger = b.initLogger(cfg)
+		b.setConfig(b.loadConfig(e.ConfigPath))
 
 		return nil
 	})
 
 	b.OnAfterApplicationBootstrapped().Add("b_bootstrap", func(e AfterApplicationBootstrapped) error {
-		cfg := b.loadConfig(e.ConfigPath)
-
-		b.config = cfg
-		b.logger = b.initLogger(cfg)
+		b.setConfig(b.loadConfig(e.ConfigPath))
 		e.App.Logger().Info("Bootstrapping Application...")
 
 		b.data = b.initDatasource()
@@ -0,0 +1,14 @@
+-- Every proxy config applied by the master, see [revisions] in config.toml
+CREATE TABLE config_revisions (
+    id VARCHAR(26) PRIMARY KEY,
+    revision INTEGER NOT NULL UNIQUE,
+    author VARCHAR(255) NOT NULL,
+    reason TEXT,
+    hash VARCHAR(64) NOT NULL,
+    generation INTEGER NOT NULL,
+    rendered TEXT NOT NULL,
+    created_at timestamp NOT NULL,
+    created bigint not null,
+    updated BIGINT NOT NULL,
+    deleted_at BIGINT
+);
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// CanaryConfig is the [master.canary] section. Canary = sebagian kecil worker
+// generasi baru jalan bareng generasi lama, dipromosikan kalau selama Bake
+// angka error dan latency-nya masih di bawah batas.
+type CanaryConfig struct {
+	// Workers is how many new-generation workers run next to the old ones,
+	// porsi traffic canary = Workers / (Workers + master.workers)
+	Workers int `json:"workers" mapstructure:"workers"`
+	// Bake is how long the canaries must stay within the thresholds
+	Bake time.Duration `json:"bake" mapstructure:"bake"`
+	// Max5xxRatio is the highest hrsp_5xx / total responses of the canaries, 0.05 = 5%
+	Max5xxRatio float64 `json:"max_5xx_ratio" mapstructure:"max_5xx_ratio"`
+	// MaxLatency is the highest average backend ttime of the canaries, 0 = tidak dicek
+	MaxLatency time.Duration `json:"max_latency" mapstructure:"max_latency"`
+	// MinRequests is how many requests the canaries must serve before they can be promoted
+	MinRequests int64 `json:"min_requests" mapstructure:"min_requests"`
+}
+
+func (config CanaryConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Workers, validation.Required, validation.Min(1)),
+		validation.Field(&config.Bake, validation.Min(time.Second)),
+		validation.Field(&config.Max5xxRatio, validation.Min(0.0), validation.Max(1.0)),
+		validation.Field(&config.MaxLatency, validation.Min(time.Duration(0))),
+		validation.Field(&config.MinRequests, validation.Min(int64(0))),
+	)
+}
@@ -1,16 +1,27 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mox/pkg/haproxy"
 
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/spf13/viper"
 )
 
 type AppConfig struct {
-	Name    string `mapstructure:"name"`
-	Mode    string `mapstructure:"mode"`
-	Version int    `mapstructure:"version"`
+	Name string `mapstructure:"name"`
+	Mode string `mapstructure:"mode"`
+	// LogLevel is debug, info, warn or error, kosong = ikut mode. Diterapkan lagi waktu hot reload.
+	LogLevel string `mapstructure:"log_level"`
+	Version  int    `mapstructure:"version"`
 }
 
 func (config AppConfig) Validate() error {
@@ -19,9 +30,24 @@ func (config AppConfig) Validate() error {
 		validation.Field(&config.Version, validation.Required),
 		validation.Field(&config.Name, validation.Required),
 		validation.Field(&config.Mode, validation.Required, validation.In("development", "production")),
+		validation.Field(&config.LogLevel, validation.In("debug", "info", "warn", "error")),
 	)
 }
 
+// Level return the minimum log level, tanpa log_level production = info, selain itu debug
+func (config AppConfig) Level() slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.LogLevel)); err == nil {
+		return level
+	}
+
+	if config.Mode == "production" {
+		return slog.LevelInfo
+	}
+
+	return slog.LevelDebug
+}
+
 type Monitoring struct {
 	OtelEndpoint     string `mapstructure:"otel_endpoint"`
 	EnableCollectLog bool   `mapstructure:"enable_collect_log"`
@@ -108,12 +134,230 @@ func (config ApiConfig) Validate() error {
 	)
 }
 
+// MasterConfig holds the settings used by `mox master` to supervise
+// its own `mox worker` children.
+type MasterConfig struct {
+	// Workers is the desired number of worker processes the master keeps alive
+	Workers int `json:"workers" mapstructure:"workers"`
+	// Executable is the binary spawned as worker, empty means the running binary itself
+	Executable string `json:"executable" mapstructure:"executable"`
+	// StopTimeout is how long the master waits for a retired worker to exit before killing it
+	StopTimeout time.Duration `json:"stop_timeout" mapstructure:"stop_timeout"`
+	// RestartPolicy decide whether a dead worker is respawned: always, on-failure or never
+	RestartPolicy string `json:"restart_policy" mapstructure:"restart_policy"`
+	// RestartBackoff is the first delay before respawning, doubled on every restart in the window
+	RestartBackoff time.Duration `json:"restart_backoff" mapstructure:"restart_backoff"`
+	// RestartMaxBackoff caps the exponential backoff
+	RestartMaxBackoff time.Duration `json:"restart_max_backoff" mapstructure:"restart_max_backoff"`
+	// CrashLoopRestarts is how many restarts inside CrashLoopWindow mark the pool as degraded
+	CrashLoopRestarts int `json:"crash_loop_restarts" mapstructure:"crash_loop_restarts"`
+	// CrashLoopWindow is the sliding window used to count restarts
+	CrashLoopWindow time.Duration `json:"crash_loop_window" mapstructure:"crash_loop_window"`
+	// UpgradeTimeout is how long the old master waits for the new binary to report ready
+	UpgradeTimeout time.Duration `json:"upgrade_timeout" mapstructure:"upgrade_timeout"`
+	// HeartbeatInterval is how often the master pings every worker
+	HeartbeatInterval time.Duration `json:"heartbeat_interval" mapstructure:"heartbeat_interval"`
+	// HeartbeatMisses is how many unanswered pings mark a worker as Error, one more evicts it
+	HeartbeatMisses int `json:"heartbeat_misses" mapstructure:"heartbeat_misses"`
+	// RequestTimeout is the default time the master waits for a worker reply
+	RequestTimeout time.Duration `json:"request_timeout" mapstructure:"request_timeout"`
+	// DrainTimeout is how long a worker may wait for HAProxy sessions to finish before soft stop
+	DrainTimeout time.Duration `json:"drain_timeout" mapstructure:"drain_timeout"`
+	// SocketPath is the unix socket workers connect to
+	SocketPath string `json:"socket_path" mapstructure:"socket_path"`
+	// SocketMode is the octal file mode of SocketPath, e.g. "0600"
+	SocketMode string `json:"socket_mode" mapstructure:"socket_mode"`
+	// AllowedUIDs and AllowedGIDs is the SO_PEERCRED allow list, both empty = same user as the master
+	AllowedUIDs []int `json:"allowed_uids" mapstructure:"allowed_uids"`
+	AllowedGIDs []int `json:"allowed_gids" mapstructure:"allowed_gids"`
+	// ControlAddress is where the line control protocol (HELP, WORKERS, ...) listens, empty disables it.
+	// "unix:///path" is created with SocketMode and checked against the allow list, "host:port" has no authentication.
+	ControlAddress string `json:"control_address" mapstructure:"control_address"`
+	// BusCodec is the body codec the master offers first to workers: json or protobuf
+	BusCodec string `json:"bus_codec" mapstructure:"bus_codec"`
+	// Canary is the canary rollout used by CANARY
+	Canary CanaryConfig `json:"canary" mapstructure:"canary"`
+}
+
+func (config MasterConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Workers, validation.Min(0)),
+		validation.Field(&config.StopTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&config.RestartPolicy, validation.In("always", "on-failure", "never")),
+		validation.Field(&config.RestartBackoff, validation.Min(time.Duration(0))),
+		validation.Field(&config.RestartMaxBackoff, validation.Min(config.RestartBackoff)),
+		validation.Field(&config.CrashLoopRestarts, validation.Required, validation.Min(1)),
+		validation.Field(&config.CrashLoopWindow, validation.Min(time.Second)),
+		validation.Field(&config.UpgradeTimeout, validation.Min(time.Second)),
+		validation.Field(&config.HeartbeatInterval, validation.Required, validation.Min(100*time.Millisecond)),
+		validation.Field(&config.HeartbeatMisses, validation.Min(1)),
+		validation.Field(&config.RequestTimeout, validation.Min(time.Millisecond)),
+		validation.Field(&config.DrainTimeout, validation.Min(time.Second)),
+		validation.Field(&config.BusCodec, validation.In("json", "protobuf")),
+		validation.Field(&config.SocketPath, validation.Required),
+		validation.Field(&config.SocketMode, validation.Required, validation.By(func(value interface{}) error {
+			_, err := parseFileMode(value.(string))
+			return err
+		})),
+		validation.Field(&config.Canary),
+	)
+}
+
+// SocketFileMode return SocketMode parsed as octal
+func (config MasterConfig) SocketFileMode() os.FileMode {
+	mode, _ := parseFileMode(config.SocketMode)
+
+	return mode
+}
+
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("must be an octal file mode: %w", err)
+	}
+
+	if mode > 0o777 {
+		return 0, errors.New("must be within 0777")
+	}
+
+	return os.FileMode(mode), nil
+}
+
+// WorkerConfig holds the settings used by `mox worker`
+type WorkerConfig struct {
+	// StatsInterval is how often the worker pushes EVENT_STATS to the master
+	StatsInterval time.Duration `json:"stats_interval" mapstructure:"stats_interval"`
+	// HAProxySocket is the HAProxy stats socket, ${PID} is replaced by the worker PID
+	HAProxySocket string `json:"haproxy_socket" mapstructure:"haproxy_socket"`
+}
+
+func (config WorkerConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.StatsInterval, validation.Required, validation.Min(100*time.Millisecond)),
+		validation.Field(&config.HAProxySocket, validation.Required),
+	)
+}
+
+// ListenerConfig is one public socket opened by the master and passed to every worker
+type ListenerConfig struct {
+	// Name identify the listener, HAProxy get it as ${MOX_LISTENER_<NAME>}
+	Name string `json:"name" mapstructure:"name"`
+	// Address is tcp://host:port, tcp6://[::]:port or unix:///path, no scheme means tcp
+	Address string `json:"address" mapstructure:"address"`
+}
+
+var listenerName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func (config ListenerConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Name, validation.Required, validation.Match(listenerName)),
+		validation.Field(&config.Address, validation.Required, validation.By(func(value interface{}) error {
+			network, _, ok := strings.Cut(value.(string), "://")
+			if !ok {
+				return nil
+			}
+
+			switch network {
+			case "tcp", "tcp4", "tcp6", "unix":
+				return nil
+			}
+
+			return fmt.Errorf("unsupported network %q", network)
+		})),
+	)
+}
+
+// uniqueListeners reject two listeners with the same name
+func uniqueListeners(value interface{}) error {
+	seen := make(map[string]struct{})
+	for _, l := range value.([]ListenerConfig) {
+		if _, ok := seen[l.Name]; ok {
+			return fmt.Errorf("duplicate listener name %q", l.Name)
+		}
+
+		seen[l.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// Engine names accepted by proxy.engine
+const (
+	EngineHAProxy = "haproxy"
+	EngineBuiltin = "builtin"
+)
+
+// ProxyConfig is the [proxy] section, model haproxy.cfg yang di-render tiap worker.
+// Kalau frontends/backends kosong worker tetap pakai ConfigFile yang ditulis manual.
+type ProxyConfig struct {
+	// Engine is the proxy each worker runs, "haproxy" atau "builtin" (tanpa binary eksternal)
+	Engine string `json:"engine" mapstructure:"engine"`
+	// ConfigFile is the hand-written haproxy.cfg used when the model is empty
+	ConfigFile string `json:"config_file" mapstructure:"config_file"`
+	// Output is where the rendered config is written, ${PID} is replaced by the worker PID
+	Output string `json:"output" mapstructure:"output"`
+	// ServerStateFile is where the master persist runtime server changes (weight, state,
+	// add/del), worker baru apply isinya begitu engine jalan
+	ServerStateFile string `json:"server_state_file" mapstructure:"server_state_file"`
+
+	haproxy.Config `mapstructure:",squash"`
+}
+
+func (config ProxyConfig) Validate() error {
+	if err := validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Engine, validation.Required, validation.In(EngineHAProxy, EngineBuiltin)),
+		validation.Field(&config.ConfigFile, validation.Required),
+		validation.Field(&config.Output, validation.Required),
+		validation.Field(&config.ServerStateFile, validation.Required),
+	); err != nil {
+		return err
+	}
+
+	if config.Empty() {
+		return nil
+	}
+
+	return config.Config.Validate()
+}
+
+// knownListeners make sure every `bind listener = ...` refer to a [[listeners]] entry
+func (config *Config) knownListeners(value interface{}) error {
+	names := make(map[string]struct{}, len(config.Listeners))
+	for _, l := range config.Listeners {
+		names[l.Name] = struct{}{}
+	}
+
+	for _, f := range value.(ProxyConfig).Frontends {
+		for _, b := range f.Binds {
+			if b.Listener == "" {
+				continue
+			}
+
+			if _, ok := names[b.Listener]; !ok {
+				return fmt.Errorf("frontend %s: unknown listener %q", f.Name, b.Listener)
+			}
+		}
+	}
+
+	return nil
+}
+
 type Config struct {
-	App               AppConfig  `json:"app" mapstructure:"app"`
-	Database          Database   `json:"database" mapstructure:"default_database"`
-	Monitoring        Monitoring `json:"monitoring" mapstructure:"monitoring"`
-	ExternalDatabases []Database `json:"external_databases" mapstructure:"databases_sql"`
-	Api               ApiConfig  `json:"apis" mapstructure:"apis"`
+	App               AppConfig        `json:"app" mapstructure:"app"`
+	Database          Database         `json:"database" mapstructure:"default_database"`
+	Monitoring        Monitoring       `json:"monitoring" mapstructure:"monitoring"`
+	ExternalDatabases []Database       `json:"external_databases" mapstructure:"databases_sql"`
+	Api               ApiConfig        `json:"apis" mapstructure:"apis"`
+	Master            MasterConfig     `json:"master" mapstructure:"master"`
+	Worker            WorkerConfig     `json:"worker" mapstructure:"worker"`
+	Listeners         []ListenerConfig `json:"listeners" mapstructure:"listeners"`
+	Proxy             ProxyConfig      `json:"proxy" mapstructure:"proxy"`
+	Health            HealthConfig     `json:"health" mapstructure:"health"`
+	Revisions         RevisionConfig   `json:"revisions" mapstructure:"revisions"`
 }
 
 func NewDefaultConfig() *Config {
@@ -130,33 +374,96 @@ type ConfigParam struct {
 	Path       string
 }
 
-func NewConfig(param ConfigParam) *Config {
-	viper.SetConfigName(param.ConfigName)
-	viper.SetConfigType(param.ConfigType)
+// setDefaults fills the optional sections so older config files keep working
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("master.workers", 1)
+	v.SetDefault("master.stop_timeout", "10s")
+	v.SetDefault("master.restart_policy", "on-failure")
+	v.SetDefault("master.restart_backoff", "1s")
+	v.SetDefault("master.restart_max_backoff", "30s")
+	v.SetDefault("master.crash_loop_restarts", 5)
+	v.SetDefault("master.crash_loop_window", "1m")
+	v.SetDefault("master.upgrade_timeout", "30s")
+	v.SetDefault("master.bus_codec", "json")
+	v.SetDefault("master.request_timeout", "5s")
+	v.SetDefault("master.drain_timeout", "30s")
+	v.SetDefault("master.heartbeat_interval", "3s")
+	v.SetDefault("master.heartbeat_misses", 3)
+	v.SetDefault("master.socket_path", "/tmp/http_mgr.sock")
+	v.SetDefault("master.socket_mode", "0600")
+	v.SetDefault("master.control_address", "")
+	v.SetDefault("master.canary.workers", 1)
+	v.SetDefault("master.canary.bake", "1m")
+	v.SetDefault("master.canary.max_5xx_ratio", 0.05)
+	v.SetDefault("master.canary.max_latency", "500ms")
+	v.SetDefault("master.canary.min_requests", 0)
+	v.SetDefault("worker.stats_interval", "5s")
+	v.SetDefault("worker.haproxy_socket", "/tmp/haproxy_${PID}.sock")
+	v.SetDefault("proxy.engine", EngineHAProxy)
+	v.SetDefault("proxy.config_file", "haproxy.cfg")
+	v.SetDefault("proxy.output", "/tmp/haproxy_${PID}.cfg")
+	v.SetDefault("proxy.server_state_file", "/tmp/mox_server_state.json")
+	v.SetDefault("health.interval", "2s")
+	v.SetDefault("health.timeout", "1s")
+	v.SetDefault("health.rise", 2)
+	v.SetDefault("health.fall", 3)
+	v.SetDefault("health.history", 20)
+	v.SetDefault("revisions.output", "/tmp/mox_revision_${REV}.cfg")
+	v.SetDefault("listeners", []map[string]interface{}{
+		{"name": "gateway", "address": "tcp://:1111"},
+	})
+}
+
+// ErrConfigNotFound is returned by LoadConfig when the config file does not exist
+var ErrConfigNotFound = errors.New("config not found")
+
+// LoadConfig read, decode and validate the config file. Tiap panggilan pakai
+// viper instance sendiri jadi aman dipanggil ulang waktu hot reload, dan error
+// dikembalikan supaya file yang invalid tidak mematikan proses yang jalan.
+func LoadConfig(param ConfigParam) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	v.SetConfigName(param.ConfigName)
+	v.SetConfigType(param.ConfigType)
 
 	if param.Path != "" {
-		viper.AddConfigPath(param.Path)
+		v.AddConfigPath(param.Path)
 	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			panic("config not found")
-		} else {
-			panic(fmt.Errorf("Config error occured, %s", err.Error()))
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, ErrConfigNotFound
 		}
+
+		return nil, fmt.Errorf("Config error occured, %w", err)
 	}
 
 	var config Config
 
-	if err := viper.Unmarshal(&config); err != nil {
-		panic(fmt.Errorf("error while marshalling to struct %s", err.Error()))
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("error while marshalling to struct %w", err)
 	}
 
 	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func NewConfig(param ConfigParam) *Config {
+	config, err := LoadConfig(param)
+	if errors.Is(err, ErrConfigNotFound) {
+		panic("config not found")
+	}
+
+	if err != nil {
 		panic(err)
 	}
 
-	return &config
+	return config
 }
 
 func (config *Config) Validate() error {
@@ -166,5 +473,11 @@ func (config *Config) Validate() error {
 		validation.Field(&config.Database),
 		validation.Field(&config.ExternalDatabases),
 		validation.Field(&config.Api),
+		validation.Field(&config.Master),
+		validation.Field(&config.Worker),
+		validation.Field(&config.Listeners, validation.Required, validation.By(uniqueListeners)),
+		validation.Field(&config.Proxy, validation.By(config.knownListeners)),
+		validation.Field(&config.Health),
+		validation.Field(&config.Revisions, validation.By(config.knownDatabase)),
 	)
 }
@@ -1,9 +1,13 @@
 package config_test
 
 import (
+	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 
 	config "mox/pkg/config"
+	"mox/pkg/haproxy"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -63,3 +67,151 @@ func TestConfigArray(t *testing.T) {
 	assert.Equal(t, 2, len(c.ExternalDatabases))
 
 }
+
+func TestConfigDefaultListener(t *testing.T) {
+	c := config.NewConfig(config.ConfigParam{
+		ConfigName: "config",
+		ConfigType: "toml",
+		Path:       "./testdata",
+	})
+
+	assert.Equal(t, []config.ListenerConfig{{Name: "gateway", Address: "tcp://:1111"}}, c.Listeners)
+}
+
+func TestListenerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		listener config.ListenerConfig
+		wantErr  bool
+	}{
+		{name: "tcp", listener: config.ListenerConfig{Name: "http", Address: "tcp://:80"}},
+		{name: "no scheme", listener: config.ListenerConfig{Name: "http", Address: ":80"}},
+		{name: "unix", listener: config.ListenerConfig{Name: "admin-sock", Address: "unix:///tmp/admin.sock"}},
+		{name: "udp", listener: config.ListenerConfig{Name: "dns", Address: "udp://:53"}, wantErr: true},
+		{name: "bad name", listener: config.ListenerConfig{Name: "my http", Address: ":80"}, wantErr: true},
+		{name: "no name", listener: config.ListenerConfig{Address: ":80"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.listener.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAppConfigLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		app     config.AppConfig
+		level   slog.Level
+		wantErr bool
+	}{
+		{name: "development", app: config.AppConfig{Mode: "development"}, level: slog.LevelDebug},
+		{name: "production", app: config.AppConfig{Mode: "production"}, level: slog.LevelInfo},
+		{name: "log level over mode", app: config.AppConfig{Mode: "development", LogLevel: "warn"}, level: slog.LevelWarn},
+		{name: "unknown log level", app: config.AppConfig{Mode: "production", LogLevel: "verbose"}, level: slog.LevelInfo, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.app.Name, tt.app.Version = "mox", 1
+
+			assert.Equal(t, tt.level, tt.app.Level())
+
+			err := tt.app.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReturnError(t *testing.T) {
+	_, err := config.LoadConfig(config.ConfigParam{
+		ConfigName: "config_failed",
+		ConfigType: "toml",
+		Path:       "./testdata",
+	})
+	assert.Error(t, err)
+
+	_, err = config.LoadConfig(config.ConfigParam{
+		ConfigName: "missing",
+		ConfigType: "toml",
+		Path:       "./testdata",
+	})
+	assert.ErrorIs(t, err, config.ErrConfigNotFound)
+}
+
+func TestDiff(t *testing.T) {
+	old, err := config.LoadConfig(config.ConfigParam{
+		ConfigName: "config",
+		ConfigType: "toml",
+		Path:       "./testdata",
+	})
+	assert.NoError(t, err)
+
+	next := *old
+	assert.Empty(t, config.Diff(*old, next))
+
+	next.App.Mode = "production"
+	next.Api.Port = 4000
+	next.Master.Workers = 4
+	next.Master.Canary.Workers = 2
+	next.Listeners = []config.ListenerConfig{{Name: "gateway", Address: "tcp://:2222"}}
+	next.Proxy.Backends = append(next.Proxy.Backends, haproxy.Backend{Name: "api"})
+
+	assert.Equal(t, []string{
+		"app.mode",
+		"apis.port",
+		"master.workers",
+		"master.canary",
+		"listeners",
+		"proxy.backends",
+	}, config.Diff(*old, next))
+}
+
+// loadWith load testdata/config.toml with extra TOML appended
+func loadWith(t *testing.T, extra string) error {
+	t.Helper()
+
+	base, err := os.ReadFile("testdata/config.toml")
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.toml"), append(base, []byte("\n"+extra)...), 0o644))
+
+	_, err = config.LoadConfig(config.ConfigParam{ConfigName: "config", ConfigType: "toml", Path: dir})
+
+	return err
+}
+
+func TestLoadConfigRejectInvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+	}{
+		{name: "no crash loop restarts", extra: "[master]\ncrash_loop_restarts = 0\n"},
+		{name: "no heartbeat interval", extra: "[master]\nheartbeat_interval = \"0s\"\n"},
+		{name: "negative heartbeat interval", extra: "[master]\nheartbeat_interval = \"-1s\"\n"},
+		{name: "no stats interval", extra: "[worker]\nstats_interval = \"0s\"\n"},
+		{name: "negative health interval", extra: "[health]\ninterval = \"-1s\"\n"},
+		{name: "negative health timeout", extra: "[health]\ntimeout = \"-2s\"\n"},
+		{name: "negative check interval", extra: "[[health.checks]]\nbackend = \"api\"\ninterval = \"-1s\"\n"},
+		{name: "negative check timeout", extra: "[[health.checks]]\nbackend = \"api\"\ntimeout = \"-2s\"\n"},
+	}
+
+	assert.NoError(t, loadWith(t, ""))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, loadWith(t, tt.extra))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Diff return the keys that differ between two configs, in declaration order.
+// Key-nya satu level di bawah section sesuai nama di TOML (mis.
+// "master.workers", "apis.port"), section bersarang seperti master.canary
+// dan slice seperti [[listeners]] dibandingkan utuh.
+func Diff(old, next Config) []string {
+	keys := []string{}
+
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(next)
+	for i := 0; i < ov.NumField(); i++ {
+		field := ov.Type().Field(i)
+		keys = append(keys, diffSection(tagName(field), ov.Field(i), nv.Field(i))...)
+	}
+
+	return keys
+}
+
+func diffSection(section string, old, next reflect.Value) []string {
+	if old.Kind() != reflect.Struct {
+		if reflect.DeepEqual(old.Interface(), next.Interface()) {
+			return nil
+		}
+
+		return []string{section}
+	}
+
+	keys := []string{}
+
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		// `mapstructure:",squash"` (model haproxy di [proxy]) isinya satu level dengan section
+		if strings.Contains(field.Tag.Get("mapstructure"), "squash") {
+			keys = append(keys, diffSection(section, old.Field(i), next.Field(i))...)
+			continue
+		}
+
+		if !reflect.DeepEqual(old.Field(i).Interface(), next.Field(i).Interface()) {
+			keys = append(keys, section+"."+tagName(field))
+		}
+	}
+
+	return keys
+}
+
+func tagName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+
+	return name
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// Health check types accepted by health.checks[].type
+const (
+	CheckHTTP = "http"
+	CheckTCP  = "tcp"
+)
+
+// HealthConfig is the [health] section. Health check upstream dijalankan
+// master (bukan tiap worker), hasilnya dikirim ke semua worker sebagai state server.
+type HealthConfig struct {
+	// Interval is the default time between two checks of one server
+	Interval time.Duration `json:"interval" mapstructure:"interval"`
+	// Timeout is the default time one check may take
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
+	// Rise is how many consecutive successes bring a down server up
+	Rise int `json:"rise" mapstructure:"rise"`
+	// Fall is how many consecutive failures take an up server down
+	Fall int `json:"fall" mapstructure:"fall"`
+	// History is how many check results and transitions are kept per server
+	History int `json:"history" mapstructure:"history"`
+	// Checks is one entry per backend, server yang tidak tercakup tidak dicek
+	Checks []HealthCheck `json:"checks" mapstructure:"checks"`
+}
+
+// HealthCheck is one [[health.checks]], berlaku untuk semua server di Backend
+type HealthCheck struct {
+	Backend string `json:"backend" mapstructure:"backend"`
+	// Type is http or tcp (connect saja)
+	Type   string `json:"type" mapstructure:"type"`
+	Method string `json:"method" mapstructure:"method"`
+	Path   string `json:"path" mapstructure:"path"`
+	// Host override the Host header, kosong = address server
+	Host string `json:"host" mapstructure:"host"`
+	// ExpectStatus kosong = 2xx atau 3xx, sama seperti HAProxy
+	ExpectStatus []int `json:"expect_status" mapstructure:"expect_status"`
+	// ExpectBody is a regexp the body must match, kosong = body tidak dicek
+	ExpectBody string `json:"expect_body" mapstructure:"expect_body"`
+
+	// nilai 0 = pakai nilai dari [health]
+	Interval time.Duration `json:"interval" mapstructure:"interval"`
+	Timeout  time.Duration `json:"timeout" mapstructure:"timeout"`
+	Rise     int           `json:"rise" mapstructure:"rise"`
+	Fall     int           `json:"fall" mapstructure:"fall"`
+}
+
+func (config HealthConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Interval, validation.Required, validation.Min(time.Millisecond)),
+		validation.Field(&config.Timeout, validation.Required, validation.Min(time.Millisecond)),
+		validation.Field(&config.Rise, validation.Required, validation.Min(1)),
+		validation.Field(&config.Fall, validation.Required, validation.Min(1)),
+		validation.Field(&config.History, validation.Min(0)),
+		validation.Field(&config.Checks, validation.By(uniqueChecks)),
+	)
+}
+
+func (config HealthCheck) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Backend, validation.Required),
+		validation.Field(&config.Type, validation.In(CheckHTTP, CheckTCP)),
+		validation.Field(&config.ExpectStatus, validation.Each(validation.Min(100), validation.Max(599))),
+		validation.Field(&config.ExpectBody, validation.By(func(value interface{}) error {
+			_, err := regexp.Compile(value.(string))
+			return err
+		})),
+		// Min tidak berlaku untuk 0, nilai 0 tetap berarti pakai [health]
+		validation.Field(&config.Interval, validation.Min(time.Millisecond)),
+		validation.Field(&config.Timeout, validation.Min(time.Millisecond)),
+		validation.Field(&config.Rise, validation.Min(0)),
+		validation.Field(&config.Fall, validation.Min(0)),
+	)
+}
+
+// uniqueChecks reject two checks for the same backend
+func uniqueChecks(value interface{}) error {
+	seen := make(map[string]struct{})
+	for _, c := range value.([]HealthCheck) {
+		if _, ok := seen[c.Backend]; ok {
+			return fmt.Errorf("duplicate health check for backend %q", c.Backend)
+		}
+
+		seen[c.Backend] = struct{}{}
+	}
+
+	return nil
+}
+
+// Check return the check of backend with the [health] defaults filled in
+func (config HealthConfig) Check(backend string) (HealthCheck, bool) {
+	for _, c := range config.Checks {
+		if c.Backend != backend {
+			continue
+		}
+
+		if c.Type == "" {
+			c.Type = CheckHTTP
+		}
+
+		if c.Method == "" {
+			c.Method = "GET"
+		}
+
+		if c.Path == "" {
+			c.Path = "/"
+		}
+
+		if c.Interval == 0 {
+			c.Interval = config.Interval
+		}
+
+		if c.Timeout == 0 {
+			c.Timeout = config.Timeout
+		}
+
+		if c.Rise == 0 {
+			c.Rise = config.Rise
+		}
+
+		if c.Fall == 0 {
+			c.Fall = config.Fall
+		}
+
+		return c, true
+	}
+
+	return HealthCheck{}, false
+}
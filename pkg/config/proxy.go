@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"mox/pkg/haproxy"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// struct di bawah ini cermin haproxy.Config buat ditulis ke TOML: durasi jadi
+// string ("30s") dan field kosong dilewati, sehingga hasilnya bisa dibaca
+// NewConfig seperti config.toml yang ditulis tangan.
+
+type proxyFile struct {
+	Proxy proxyTOML `toml:"proxy"`
+}
+
+type proxyTOML struct {
+	Engine          string         `toml:"engine,omitempty"`
+	ConfigFile      string         `toml:"config_file,omitempty"`
+	Output          string         `toml:"output,omitempty"`
+	ServerStateFile string         `toml:"server_state_file,omitempty"`
+	Global          globalTOML     `toml:"global"`
+	Defaults        defaultsTOML   `toml:"defaults"`
+	Frontends       []frontendTOML `toml:"frontends,omitempty"`
+	Backends        []backendTOML  `toml:"backends,omitempty"`
+	Sections        []sectionTOML  `toml:"sections,omitempty"`
+}
+
+type sectionTOML struct {
+	Header string   `toml:"header"`
+	Lines  []string `toml:"lines,omitempty"`
+}
+
+type globalTOML struct {
+	MaxConn      int      `toml:"maxconn,omitempty"`
+	Log          []string `toml:"log,omitempty"`
+	StatsSocket  string   `toml:"stats_socket,omitempty"`
+	StatsTimeout string   `toml:"stats_timeout,omitempty"`
+	Extra        []string `toml:"extra,omitempty"`
+}
+
+type defaultsTOML struct {
+	Mode           string   `toml:"mode,omitempty"`
+	Log            string   `toml:"log,omitempty"`
+	Options        []string `toml:"options,omitempty"`
+	TimeoutConnect string   `toml:"timeout_connect,omitempty"`
+	TimeoutClient  string   `toml:"timeout_client,omitempty"`
+	TimeoutServer  string   `toml:"timeout_server,omitempty"`
+	Extra          []string `toml:"extra,omitempty"`
+}
+
+type frontendTOML struct {
+	Name           string     `toml:"name"`
+	Mode           string     `toml:"mode,omitempty"`
+	Binds          []bindTOML `toml:"binds,omitempty,inline"`
+	Options        []string   `toml:"options,omitempty"`
+	ACLs           []aclTOML  `toml:"acls,omitempty,inline"`
+	HTTPRequest    []ruleTOML `toml:"http_request,omitempty,inline"`
+	HTTPResponse   []ruleTOML `toml:"http_response,omitempty,inline"`
+	UseBackends    []useTOML  `toml:"use_backends,omitempty,inline"`
+	DefaultBackend string     `toml:"default_backend,omitempty"`
+	Extra          []string   `toml:"extra,omitempty"`
+}
+
+type backendTOML struct {
+	Name        string       `toml:"name"`
+	Mode        string       `toml:"mode,omitempty"`
+	Balance     string       `toml:"balance,omitempty"`
+	Options     []string     `toml:"options,omitempty"`
+	ACLs        []aclTOML    `toml:"acls,omitempty,inline"`
+	HTTPRequest []ruleTOML   `toml:"http_request,omitempty,inline"`
+	Servers     []serverTOML `toml:"servers,omitempty,inline"`
+	Extra       []string     `toml:"extra,omitempty"`
+}
+
+type bindTOML struct {
+	Listener string `toml:"listener,omitempty"`
+	Address  string `toml:"address,omitempty"`
+	Options  string `toml:"options,omitempty"`
+}
+
+type aclTOML struct {
+	Name      string `toml:"name"`
+	Criterion string `toml:"criterion"`
+}
+
+type ruleTOML struct {
+	Action string `toml:"action"`
+	Cond   string `toml:"cond,omitempty"`
+}
+
+type useTOML struct {
+	Backend string `toml:"backend"`
+	Cond    string `toml:"cond,omitempty"`
+}
+
+type serverTOML struct {
+	Name    string `toml:"name"`
+	Address string `toml:"address"`
+	Check   bool   `toml:"check,omitempty"`
+	Weight  int    `toml:"weight,omitempty"`
+	MaxConn int    `toml:"maxconn,omitempty"`
+	Backup  bool   `toml:"backup,omitempty"`
+	Options string `toml:"options,omitempty"`
+}
+
+func durationTOML(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	// format HAProxy ("2m", "1500ms") juga dimengerti time.ParseDuration
+	return haproxy.FormatDuration(d)
+}
+
+func rulesTOML(rules []haproxy.Rule) []ruleTOML {
+	out := make([]ruleTOML, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, ruleTOML(r))
+	}
+
+	return out
+}
+
+func aclsTOML(acls []haproxy.ACL) []aclTOML {
+	out := make([]aclTOML, 0, len(acls))
+	for _, a := range acls {
+		out = append(out, aclTOML(a))
+	}
+
+	return out
+}
+
+// MarshalProxy write p as the [proxy] section of config.toml, notes ditulis
+// sebagai komentar di atas section (misal warning hasil import).
+func MarshalProxy(p ProxyConfig, notes ...string) ([]byte, error) {
+	file := proxyFile{Proxy: proxyTOML{
+		Engine:          p.Engine,
+		ConfigFile:      p.ConfigFile,
+		Output:          p.Output,
+		ServerStateFile: p.ServerStateFile,
+		Global: globalTOML{
+			MaxConn:      p.Global.MaxConn,
+			Log:          p.Global.Log,
+			StatsSocket:  p.Global.StatsSocket,
+			StatsTimeout: durationTOML(p.Global.StatsTimeout),
+			Extra:        p.Global.Extra,
+		},
+		Defaults: defaultsTOML{
+			Mode:           p.Defaults.Mode,
+			Log:            p.Defaults.Log,
+			Options:        p.Defaults.Options,
+			TimeoutConnect: durationTOML(p.Defaults.TimeoutConnect),
+			TimeoutClient:  durationTOML(p.Defaults.TimeoutClient),
+			TimeoutServer:  durationTOML(p.Defaults.TimeoutServer),
+			Extra:          p.Defaults.Extra,
+		},
+	}}
+
+	for _, s := range p.Sections {
+		file.Proxy.Sections = append(file.Proxy.Sections, sectionTOML(s))
+	}
+
+	for _, f := range p.Frontends {
+		fe := frontendTOML{
+			Name:           f.Name,
+			Mode:           f.Mode,
+			Options:        f.Options,
+			ACLs:           aclsTOML(f.ACLs),
+			HTTPRequest:    rulesTOML(f.HTTPRequest),
+			HTTPResponse:   rulesTOML(f.HTTPResponse),
+			DefaultBackend: f.DefaultBackend,
+			Extra:          f.Extra,
+		}
+
+		for _, b := range f.Binds {
+			fe.Binds = append(fe.Binds, bindTOML(b))
+		}
+
+		for _, u := range f.UseBackends {
+			fe.UseBackends = append(fe.UseBackends, useTOML(u))
+		}
+
+		file.Proxy.Frontends = append(file.Proxy.Frontends, fe)
+	}
+
+	for _, b := range p.Backends {
+		be := backendTOML{
+			Name:        b.Name,
+			Mode:        b.Mode,
+			Balance:     b.Balance,
+			Options:     b.Options,
+			ACLs:        aclsTOML(b.ACLs),
+			HTTPRequest: rulesTOML(b.HTTPRequest),
+			Extra:       b.Extra,
+		}
+
+		for _, s := range b.Servers {
+			be.Servers = append(be.Servers, serverTOML(s))
+		}
+
+		file.Proxy.Backends = append(file.Proxy.Backends, be)
+	}
+
+	var buf bytes.Buffer
+	for _, note := range notes {
+		fmt.Fprintf(&buf, "# %s\n", strings.ReplaceAll(note, "\n", " "))
+	}
+
+	if len(notes) > 0 {
+		buf.WriteString("\n")
+	}
+
+	enc := toml.NewEncoder(&buf)
+	enc.SetIndentTables(false)
+
+	if err := enc.Encode(file); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Model return the proxy model. Kalau [proxy] kosong, haproxy.cfg manual
+// di-import supaya engine yang tidak bisa baca haproxy.cfg tetap punya model.
+func (p ProxyConfig) Model() (haproxy.Config, []haproxy.Warning, error) {
+	if !p.Empty() {
+		return p.Config, nil, nil
+	}
+
+	f, err := os.Open(p.ConfigFile)
+	if err != nil {
+		return haproxy.Config{}, nil, err
+	}
+	defer f.Close()
+
+	doc, err := haproxy.ParseDocument(f)
+	if err != nil {
+		return haproxy.Config{}, nil, fmt.Errorf("%s: %w", p.ConfigFile, err)
+	}
+
+	model, warnings := haproxy.Import(doc)
+
+	return model, warnings, nil
+}
+
+// Pin return p serving file instead of the model, dipakai waktu rollback ke
+// revisi lama: haproxy.cfg yang tersimpan dibaca seperti config_file manual.
+func (p ProxyConfig) Pin(file string) ProxyConfig {
+	p.Config = haproxy.Config{}
+	p.ConfigFile = file
+
+	return p
+}
+
+// Rendered return the haproxy.cfg p stands for, model di-render dengan socket
+// sebagai stats socket default dan config_file manual dibaca apa adanya.
+func (p ProxyConfig) Rendered(socket string) ([]byte, error) {
+	if p.Empty() {
+		return os.ReadFile(p.ConfigFile)
+	}
+
+	model := p.Config
+	if model.Global.StatsSocket == "" {
+		model.Global.StatsSocket = socket
+	}
+
+	return model.Bytes()
+}
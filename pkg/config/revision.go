@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+// RevisionConfig is the [revisions] section. Tiap config proxy yang berhasil
+// di-rollout disimpan sebagai revisi bernomor di database, bisa di-diff dan
+// di-rollback lewat API atau `mox revision`.
+type RevisionConfig struct {
+	// Database is the alias of a GORM connection ([default_database] atau
+	// [[databases_sql]]), kosong = revisi tidak dicatat
+	Database string `json:"database" mapstructure:"database"`
+	// Output is where a rollback writes the stored haproxy.cfg, ${REV} is replaced by the revision number
+	Output string `json:"output" mapstructure:"output"`
+}
+
+func (config RevisionConfig) Validate() error {
+	return validation.ValidateStruct(
+		&config,
+		validation.Field(&config.Output, validation.Required),
+	)
+}
+
+// knownDatabase make sure revisions.database refer to a configured connection
+func (config *Config) knownDatabase(value interface{}) error {
+	alias := value.(RevisionConfig).Database
+	if alias == "" {
+		return nil
+	}
+
+	if _, ok := config.DatabaseByAlias(alias); !ok {
+		return fmt.Errorf("unknown database %q", alias)
+	}
+
+	return nil
+}
+
+// DatabaseByAlias return the default or external database named alias
+func (config *Config) DatabaseByAlias(alias string) (Database, bool) {
+	for _, db := range append([]Database{config.Database}, config.ExternalDatabases...) {
+		if db.Alias == alias {
+			return db, true
+		}
+	}
+
+	return Database{}, false
+}
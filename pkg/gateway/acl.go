@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mox/pkg/haproxy"
+)
+
+// matcher test one request, dipakai untuk ACL dan kondisi if/unless
+type matcher func(r *http.Request) bool
+
+// fetch return the sample an ACL criterion compares against
+type fetch func(r *http.Request) string
+
+// compileACLs build the named ACLs of one section. ACL dengan nama sama
+// digabung pakai OR seperti di HAProxy.
+func compileACLs(acls []haproxy.ACL, lookup func(string) (string, bool)) (map[string]matcher, error) {
+	out := make(map[string]matcher, len(acls))
+
+	for _, a := range acls {
+		words, err := haproxy.Fields(a.Criterion, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("acl %s: %w", a.Name, err)
+		}
+
+		m, err := compileCriterion(words)
+		if err != nil {
+			return nil, fmt.Errorf("acl %s: %w", a.Name, err)
+		}
+
+		if prev, ok := out[a.Name]; ok {
+			out[a.Name] = func(r *http.Request) bool { return prev(r) || m(r) }
+			continue
+		}
+
+		out[a.Name] = m
+	}
+
+	return out, nil
+}
+
+// compileCriterion support "<fetch> [-i] <value>...", fetch yang dikenal:
+// path, path_beg, path_end, path_sub, method, hdr(<name>), hdr_beg(<name>),
+// hdr_end(<name>), hdr_sub(<name>).
+func compileCriterion(words []string) (matcher, error) {
+	if len(words) == 0 {
+		return nil, fmt.Errorf("criterion is empty")
+	}
+
+	name, arg, _ := strings.Cut(strings.TrimSuffix(words[0], ")"), "(")
+
+	var (
+		get     fetch
+		compare func(sample, value string) bool
+	)
+
+	switch name {
+	case "path", "path_beg", "path_end", "path_sub":
+		get = func(r *http.Request) string { return r.URL.Path }
+		compare = comparator(strings.TrimPrefix(name, "path"))
+	case "method":
+		get = func(r *http.Request) string { return r.Method }
+		compare = comparator("")
+	case "hdr", "hdr_beg", "hdr_end", "hdr_sub":
+		if arg == "" {
+			return nil, fmt.Errorf("%s needs a header name", name)
+		}
+
+		get = func(r *http.Request) string {
+			if strings.EqualFold(arg, "host") {
+				return r.Host
+			}
+
+			return r.Header.Get(arg)
+		}
+		compare = comparator(strings.TrimPrefix(name, "hdr"))
+	default:
+		return nil, fmt.Errorf("fetch %q is not supported by the builtin engine", words[0])
+	}
+
+	fold := false
+	values := words[1:]
+
+	for len(values) > 0 && strings.HasPrefix(values[0], "-") {
+		flag := values[0]
+		values = values[1:]
+
+		if flag == "--" {
+			break
+		}
+
+		if flag != "-i" {
+			return nil, fmt.Errorf("flag %q is not supported by the builtin engine", flag)
+		}
+
+		fold = true
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s needs at least one value", words[0])
+	}
+
+	return func(r *http.Request) bool {
+		sample := get(r)
+		for _, v := range values {
+			if fold {
+				if compare(strings.ToLower(sample), strings.ToLower(v)) {
+					return true
+				}
+
+				continue
+			}
+
+			if compare(sample, v) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// comparator pick the match method from the fetch suffix (_beg, _end, _sub)
+func comparator(suffix string) func(sample, value string) bool {
+	switch suffix {
+	case "_beg":
+		return strings.HasPrefix
+	case "_end":
+		return strings.HasSuffix
+	case "_sub":
+		return strings.Contains
+	default:
+		return func(sample, value string) bool { return sample == value }
+	}
+}
+
+// compileCond parse "[if|unless] a !b || c". Kosong = selalu true.
+// Term dipisah || atau "or", tiap term AND dari ACL-nya.
+func compileCond(cond string, acls map[string]matcher) (matcher, error) {
+	words := strings.Fields(cond)
+	if len(words) == 0 {
+		return func(*http.Request) bool { return true }, nil
+	}
+
+	negate := false
+
+	switch words[0] {
+	case "if":
+		words = words[1:]
+	case "unless":
+		negate = true
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("condition %q has no acl", cond)
+	}
+
+	var (
+		terms [][]matcher
+		term  []matcher
+	)
+
+	for _, w := range words {
+		if w == "||" || w == "or" {
+			if len(term) == 0 {
+				return nil, fmt.Errorf("condition %q has an empty term", cond)
+			}
+
+			terms = append(terms, term)
+			term = nil
+
+			continue
+		}
+
+		if strings.HasPrefix(w, "{") {
+			return nil, fmt.Errorf("anonymous acl in %q is not supported by the builtin engine", cond)
+		}
+
+		not := strings.HasPrefix(w, "!")
+		name := strings.TrimPrefix(w, "!")
+
+		m, ok := acls[name]
+		if !ok {
+			return nil, fmt.Errorf("condition %q use unknown acl %q", cond, name)
+		}
+
+		if not {
+			inner := m
+			m = func(r *http.Request) bool { return !inner(r) }
+		}
+
+		term = append(term, m)
+	}
+
+	if len(term) == 0 {
+		return nil, fmt.Errorf("condition %q has an empty term", cond)
+	}
+
+	terms = append(terms, term)
+
+	return func(r *http.Request) bool {
+		for _, t := range terms {
+			ok := true
+			for _, m := range t {
+				if !m(r) {
+					ok = false
+					break
+				}
+			}
+
+			if ok {
+				return !negate
+			}
+		}
+
+		return negate
+	}, nil
+}
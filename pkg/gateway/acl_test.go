@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"mox/pkg/haproxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileCond(t *testing.T) {
+	acls, err := compileACLs([]haproxy.ACL{
+		{Name: "is_api", Criterion: "path_beg /api/"},
+		{Name: "is_api", Criterion: "path /graphql"},
+		{Name: "is_admin", Criterion: "hdr(host) -i admin.local"},
+		{Name: "is_post", Criterion: "method POST PUT"},
+		{Name: "is_json", Criterion: "hdr_end(content-type) /json"},
+	}, nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		cond   string
+		method string
+		target string
+		host   string
+		want   bool
+	}{
+		{cond: "", target: "/", want: true},
+		{cond: "if is_api", target: "/api/v1", want: true},
+		{cond: "is_api", target: "/graphql", want: true},
+		{cond: "if is_api", target: "/web", want: false},
+		{cond: "unless is_api", target: "/web", want: true},
+		{cond: "if is_api is_post", method: "GET", target: "/api/v1", want: false},
+		{cond: "if is_api is_post", method: "PUT", target: "/api/v1", want: true},
+		{cond: "if is_admin || is_api", target: "/", host: "ADMIN.local", want: true},
+		{cond: "if is_admin or is_api", target: "/", host: "www.local", want: false},
+		{cond: "if !is_api", target: "/", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			m, err := compileCond(tt.cond, acls)
+			assert.NoError(t, err)
+
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+
+			r := httptest.NewRequest(method, tt.target, nil)
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+
+			assert.Equal(t, tt.want, m(r))
+		})
+	}
+}
+
+func TestCompileUnsupported(t *testing.T) {
+	_, err := compileACLs([]haproxy.ACL{{Name: "x", Criterion: "src 10.0.0.0/8"}}, nil)
+	assert.ErrorContains(t, err, `fetch "src" is not supported by the builtin engine`)
+
+	_, err = compileACLs([]haproxy.ACL{{Name: "x", Criterion: "path_beg -m beg /a"}}, nil)
+	assert.ErrorContains(t, err, `flag "-m" is not supported`)
+
+	_, err = compileCond("if missing", map[string]matcher{})
+	assert.ErrorContains(t, err, `unknown acl "missing"`)
+
+	_, err = compileCond("if { path /a }", map[string]matcher{})
+	assert.ErrorContains(t, err, "anonymous acl")
+
+	_, err = compileRules([]haproxy.Rule{{Action: "set-header X-Id %[uuid]"}}, nil, nil, true)
+	assert.ErrorContains(t, err, "log-format is not supported")
+
+	_, err = compileRules([]haproxy.Rule{{Action: "return status 200"}}, nil, nil, false)
+	assert.ErrorContains(t, err, "only supported in http-request")
+
+	_, err = compileRules([]haproxy.Rule{{Action: "redirect scheme https"}}, nil, nil, true)
+	assert.ErrorContains(t, err, "action redirect is not supported")
+}
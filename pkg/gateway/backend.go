@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+
+	"mox/pkg/haproxy"
+)
+
+// server is one upstream of a backend
+type server struct {
+	name   string
+	target *url.URL
+	weight int
+	backup bool
+	state  haproxy.ServerState
+	// down diisi health check master, terpisah dari state admin
+	down  bool
+	proxy *httputil.ReverseProxy
+	stats *counters
+}
+
+// backend pilih server pakai smooth weighted round robin (algoritma nginx),
+// server backup baru dipakai kalau tidak ada server aktif yang bisa dipilih.
+type backend struct {
+	name    string
+	request []rule
+	servers []*server
+	stats   *counters
+
+	mu      *sync.Mutex
+	current map[*server]int
+}
+
+func compileBackend(b haproxy.Backend, reg *registry, transport http.RoundTripper, lookup func(string) (string, bool)) (*backend, error) {
+	mode := b.Mode
+	if mode != "" && mode != "http" {
+		return nil, fmt.Errorf("backend %s: mode %s is not supported by the builtin engine", b.Name, mode)
+	}
+
+	if b.Balance != "" && strings.Fields(b.Balance)[0] != "roundrobin" {
+		return nil, fmt.Errorf("backend %s: balance %s is not supported by the builtin engine", b.Name, b.Balance)
+	}
+
+	if len(b.Extra) > 0 {
+		return nil, fmt.Errorf("backend %s: directive %q is not supported by the builtin engine", b.Name, b.Extra[0])
+	}
+
+	acls, err := compileACLs(b.ACLs, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", b.Name, err)
+	}
+
+	request, err := compileRules(b.HTTPRequest, acls, lookup, true)
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", b.Name, err)
+	}
+
+	be := &backend{
+		name:    b.Name,
+		request: request,
+		stats:   counterOf(reg.backends, b.Name),
+		mu:      &sync.Mutex{},
+		current: map[*server]int{},
+	}
+
+	for _, s := range b.Servers {
+		srv, err := compileServer(s, transport, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", b.Name, err)
+		}
+
+		srv.stats = counterOf(reg.servers, b.Name+"/"+s.Name)
+		be.servers = append(be.servers, srv)
+	}
+
+	return be, nil
+}
+
+// compileServer cuma kenal option "ssl" (upstream https) dan "disabled" (maint),
+// option lain seperti check/inter dibiarkan karena health check bukan urusan engine.
+func compileServer(s haproxy.Server, transport http.RoundTripper, lookup func(string) (string, bool)) (*server, error) {
+	address := haproxy.Word(s.Address, lookup)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("server %s: address %q must be host:port", s.Name, address)
+	}
+
+	options, err := haproxy.Fields(s.Options, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("server %s: %w", s.Name, err)
+	}
+
+	srv := &server{
+		name:   s.Name,
+		target: &url.URL{Scheme: "http", Host: address},
+		weight: s.Weight,
+		backup: s.Backup,
+		state:  haproxy.StateReady,
+	}
+
+	if srv.weight == 0 {
+		srv.weight = 1
+	}
+
+	for _, o := range options {
+		switch o {
+		case "ssl":
+			srv.target.Scheme = "https"
+		case "disabled":
+			srv.state = haproxy.StateMaint
+		}
+	}
+
+	srv.proxy = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(srv.target)
+			pr.SetXForwarded()
+			// Host asli client diteruskan seperti HAProxy, bukan host server
+			pr.Out.Host = pr.In.Host
+		},
+	}
+
+	return srv, nil
+}
+
+// pick return the next server, nil kalau tidak ada yang ready
+func (b *backend) pick() *server {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, backup := range []bool{false, true} {
+		var (
+			best  *server
+			total int
+		)
+
+		for _, s := range b.servers {
+			// drain sama seperti HAProxy: tidak dapat request baru
+			if !s.usable() || s.weight == 0 || s.backup != backup {
+				continue
+			}
+
+			b.current[s] += s.weight
+			total += s.weight
+
+			if best == nil || b.current[s] > b.current[best] {
+				best = s
+			}
+		}
+
+		if best != nil {
+			b.current[best] -= total
+			return best
+		}
+	}
+
+	return nil
+}
+
+// usable true kalau server boleh dapat request baru
+func (s *server) usable() bool {
+	return s.state == haproxy.StateReady && !s.down
+}
+
+func (s *server) stat(backend string) haproxy.Stat {
+	st := s.stats.stat(backend, s.name, haproxy.TypeServer)
+	st.Weight = int64(s.weight)
+	st.Status = "UP"
+
+	switch {
+	case s.state == haproxy.StateMaint:
+		st.Status = "MAINT"
+	case s.down:
+		st.Status = "DOWN"
+	case s.state == haproxy.StateDrain:
+		st.Status = "DRAIN"
+	}
+
+	return st
+}
+
+// rows return the server rows followed by the backend row
+func (b *backend) rows() []haproxy.Stat {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	rows := make([]haproxy.Stat, 0, len(b.servers)+1)
+	for _, s := range b.servers {
+		rows = append(rows, s.stat(b.name))
+	}
+
+	return append(rows, b.stat())
+}
+
+func (b *backend) stat() haproxy.Stat {
+	st := b.stats.stat(b.name, "BACKEND", haproxy.TypeBackend)
+	st.Status = "DOWN"
+
+	for _, s := range b.servers {
+		if s.usable() {
+			st.Weight += int64(s.weight)
+			st.Status = "UP"
+		}
+	}
+
+	// backend yang cuma return tetap dianggap UP, sama seperti HAProxy tanpa server
+	if len(b.servers) == 0 {
+		st.Status = "UP"
+	}
+
+	return st
+}
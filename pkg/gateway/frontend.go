@@ -0,0 +1,177 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"mox/pkg/haproxy"
+)
+
+type route struct {
+	cond    matcher
+	backend *backend
+}
+
+type frontend struct {
+	name     string
+	binds    []haproxy.Bind
+	request  []rule
+	response []rule
+	routes   []route
+	fallback *backend
+	httplog  bool
+	stats    *counters
+}
+
+// backend return the first matching use_backend, default_backend kalau tidak ada
+func (f *frontend) backend(r *http.Request) *backend {
+	for _, rt := range f.routes {
+		if rt.cond(r) {
+			return rt.backend
+		}
+	}
+
+	return f.fallback
+}
+
+// routes is one compiled [proxy] model, diganti utuh waktu Reload
+type routes struct {
+	frontends     map[string]*frontend
+	frontendOrder []string
+	backends      map[string]*backend
+	backendOrder  []string
+	transport     *http.Transport
+	timeouts      haproxy.Defaults
+	maxconn       int
+}
+
+// compile turn the model into routes, semua yang tidak bisa dijalankan engine
+// ini ditolak di sini supaya ketahuan sebelum worker mulai.
+func compile(cfg haproxy.Config, reg *registry, lookup func(string) (string, bool)) (*routes, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Defaults.Mode != "" && cfg.Defaults.Mode != "http" {
+		return nil, fmt.Errorf("defaults: mode %s is not supported by the builtin engine", cfg.Defaults.Mode)
+	}
+
+	rt := &routes{
+		frontends: map[string]*frontend{},
+		backends:  map[string]*backend{},
+		timeouts:  cfg.Defaults,
+		maxconn:   cfg.Global.MaxConn,
+		transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: cfg.Defaults.TimeoutConnect, KeepAlive: 30 * time.Second}).DialContext,
+			ResponseHeaderTimeout: cfg.Defaults.TimeoutServer,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   32,
+		},
+	}
+
+	var errs []error
+
+	for _, b := range cfg.Backends {
+		be, err := compileBackend(b, reg, rt.transport, lookup)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		rt.backends[b.Name] = be
+		rt.backendOrder = append(rt.backendOrder, b.Name)
+	}
+
+	httplog := slices.Contains(cfg.Defaults.Options, "httplog")
+
+	for _, f := range cfg.Frontends {
+		fe, err := compileFrontend(f, rt.backends, reg, lookup)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fe.httplog = fe.httplog || httplog
+		rt.frontends[f.Name] = fe
+		rt.frontendOrder = append(rt.frontendOrder, f.Name)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+func compileFrontend(f haproxy.Frontend, backends map[string]*backend, reg *registry, lookup func(string) (string, bool)) (*frontend, error) {
+	if f.Mode != "" && f.Mode != "http" {
+		return nil, fmt.Errorf("frontend %s: mode %s is not supported by the builtin engine", f.Name, f.Mode)
+	}
+
+	if len(f.Extra) > 0 {
+		return nil, fmt.Errorf("frontend %s: directive %q is not supported by the builtin engine", f.Name, f.Extra[0])
+	}
+
+	for _, b := range f.Binds {
+		if b.Options != "" {
+			return nil, fmt.Errorf("frontend %s: bind options %q are not supported by the builtin engine", f.Name, b.Options)
+		}
+	}
+
+	acls, err := compileACLs(f.ACLs, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("frontend %s: %w", f.Name, err)
+	}
+
+	fe := &frontend{
+		name:    f.Name,
+		binds:   f.Binds,
+		httplog: slices.Contains(f.Options, "httplog"),
+		stats:   counterOf(reg.frontends, f.Name),
+	}
+
+	if fe.request, err = compileRules(f.HTTPRequest, acls, lookup, true); err != nil {
+		return nil, fmt.Errorf("frontend %s: %w", f.Name, err)
+	}
+
+	if fe.response, err = compileRules(f.HTTPResponse, acls, lookup, false); err != nil {
+		return nil, fmt.Errorf("frontend %s: %w", f.Name, err)
+	}
+
+	for _, u := range f.UseBackends {
+		be, ok := backends[u.Backend]
+		if !ok {
+			return nil, fmt.Errorf("frontend %s: backend %q is not supported by the builtin engine", f.Name, u.Backend)
+		}
+
+		c, err := compileCond(u.Cond, acls)
+		if err != nil {
+			return nil, fmt.Errorf("frontend %s: %w", f.Name, err)
+		}
+
+		fe.routes = append(fe.routes, route{cond: c, backend: be})
+	}
+
+	if f.DefaultBackend != "" {
+		be, ok := backends[f.DefaultBackend]
+		if !ok {
+			return nil, fmt.Errorf("frontend %s: backend %q is not supported by the builtin engine", f.Name, f.DefaultBackend)
+		}
+
+		fe.fallback = be
+	}
+
+	return fe, nil
+}
+
+// Validate check the model can be served by the builtin engine, dipakai master
+// sebagai pengganti `haproxy -c`.
+func Validate(cfg haproxy.Config, lookup func(string) (string, bool)) error {
+	_, err := compile(cfg, newRegistry(), lookup)
+
+	return err
+}
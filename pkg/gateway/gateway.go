@@ -0,0 +1,466 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mox/pkg/haproxy"
+)
+
+// noServer is the body HAProxy send when no server can take the request
+const noServer = "<html><body><h1>503 Service Unavailable</h1>\nNo server is available to handle this request.\n</body></html>\n"
+
+// Gateway is an in-process HTTP reverse proxy built from the same [proxy]
+// model yang di-render ke haproxy.cfg, jadi worker bisa jalan tanpa binary haproxy.
+type Gateway struct {
+	logger *slog.Logger
+	lookup func(string) (string, bool)
+
+	mu      *sync.Mutex
+	reg     *registry
+	routes  atomic.Pointer[routes]
+	servers []*served
+	started time.Time
+	drained atomic.Bool
+}
+
+// served is the http.Server of one frontend
+type served struct {
+	frontend  string
+	srv       *http.Server
+	listeners []net.Listener
+}
+
+// New compile cfg, lookup dipakai untuk expand ${VAR} seperti HAProxy
+func New(cfg haproxy.Config, logger *slog.Logger, lookup func(string) (string, bool)) (*Gateway, error) {
+	reg := newRegistry()
+
+	rt, err := compile(cfg, reg, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gateway{logger: logger, lookup: lookup, mu: &sync.Mutex{}, reg: reg}
+	g.routes.Store(rt)
+
+	return g, nil
+}
+
+// Serve start one http.Server per frontend. Bind listener diambil dari
+// listeners (FD warisan master), bind address dibuka sendiri.
+func (g *Gateway) Serve(listeners map[string]net.Listener) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.servers != nil {
+		return errors.New("gateway is already serving")
+	}
+
+	rt := g.routes.Load()
+	used := map[string]string{}
+
+	var servers []*served
+
+	closeAll := func() {
+		for _, s := range servers {
+			for _, l := range s.listeners {
+				l.Close()
+			}
+		}
+	}
+
+	for _, name := range rt.frontendOrder {
+		fe := rt.frontends[name]
+		s := &served{frontend: name}
+		servers = append(servers, s)
+
+		for _, b := range fe.binds {
+			if b.Listener == "" {
+				l, err := net.Listen("tcp", b.Address)
+				if err != nil {
+					closeAll()
+					return fmt.Errorf("frontend %s: %w", name, err)
+				}
+
+				s.listeners = append(s.listeners, l)
+
+				continue
+			}
+
+			l, ok := listeners[b.Listener]
+			if !ok {
+				closeAll()
+				return fmt.Errorf("frontend %s: listener %q was not received from the master", name, b.Listener)
+			}
+
+			if other, ok := used[b.Listener]; ok {
+				closeAll()
+				return fmt.Errorf("frontend %s: listener %q is already bound by frontend %s", name, b.Listener, other)
+			}
+
+			used[b.Listener] = name
+			s.listeners = append(s.listeners, l)
+		}
+
+		s.srv = &http.Server{
+			Handler:           g.handler(name),
+			ReadHeaderTimeout: rt.timeouts.TimeoutClient,
+			IdleTimeout:       rt.timeouts.TimeoutClient,
+			ConnState:         g.connState(fe.stats),
+			ErrorLog:          slog.NewLogLogger(g.logger.Handler(), slog.LevelWarn),
+		}
+	}
+
+	g.servers = servers
+	g.started = time.Now()
+
+	for _, s := range servers {
+		for _, l := range s.listeners {
+			go func(s *served, l net.Listener) {
+				// listener ditutup Drain/Stop, bukan error
+				if err := s.srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+					g.logger.Error("builtin frontend stopped", slog.String("frontend", s.frontend), slog.String("err", err.Error()))
+				}
+			}(s, l)
+		}
+	}
+
+	return nil
+}
+
+// connState count sessions per frontend, satu koneksi = satu session seperti HAProxy
+func (g *Gateway) connState(c *counters) func(net.Conn, http.ConnState) {
+	return func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			c.open()
+		case http.StateClosed, http.StateHijacked:
+			c.close()
+		}
+	}
+}
+
+// Reload swap the routing table. Bind tidak boleh berubah, listener baru
+// butuh generation worker baru.
+func (g *Gateway) Reload(cfg haproxy.Config) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	next, err := compile(cfg, g.reg, g.lookup)
+	if err != nil {
+		return err
+	}
+
+	prev := g.routes.Load()
+
+	if len(next.frontendOrder) != len(prev.frontendOrder) {
+		return errors.New("frontends changed, a new worker generation is required")
+	}
+
+	for _, name := range prev.frontendOrder {
+		fe, ok := next.frontends[name]
+		if !ok || fmt.Sprint(fe.binds) != fmt.Sprint(prev.frontends[name].binds) {
+			return fmt.Errorf("binds of frontend %s changed, a new worker generation is required", name)
+		}
+	}
+
+	g.routes.Store(next)
+	prev.transport.CloseIdleConnections()
+
+	return nil
+}
+
+// Drain stop accepting new connections. Koneksi idle ditutup, request yang
+// sedang jalan dibiarkan selesai dan koneksinya tidak di-keep-alive lagi.
+func (g *Gateway) Drain() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.drained.Store(true)
+
+	var errs []error
+
+	for _, s := range g.servers {
+		s.srv.SetKeepAlivesEnabled(false)
+
+		for _, l := range s.listeners {
+			if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stop wait for the running requests until ctx is done, sisanya diputus paksa
+func (g *Gateway) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var errs []error
+
+	for _, s := range g.servers {
+		if err := s.srv.Shutdown(ctx); err != nil {
+			errs = append(errs, s.srv.Close())
+		}
+	}
+
+	g.routes.Load().transport.CloseIdleConnections()
+
+	return errors.Join(errs...)
+}
+
+// Close stop every frontend right away without waiting for running requests
+func (g *Gateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var errs []error
+
+	for _, s := range g.servers {
+		errs = append(errs, s.srv.Close())
+	}
+
+	g.routes.Load().transport.CloseIdleConnections()
+
+	return errors.Join(errs...)
+}
+
+// Stats return one row per frontend, backend and server like `show stat`
+func (g *Gateway) Stats() []haproxy.Stat {
+	rt := g.routes.Load()
+	stats := make([]haproxy.Stat, 0, len(rt.frontendOrder)+len(rt.backendOrder))
+
+	status := "OPEN"
+	if g.drained.Load() {
+		status = "STOP"
+	}
+
+	for _, name := range rt.frontendOrder {
+		st := rt.frontends[name].stats.stat(name, "FRONTEND", haproxy.TypeFrontend)
+		st.Status = status
+		st.SLim = int64(rt.maxconn)
+		stats = append(stats, st)
+	}
+
+	for _, name := range rt.backendOrder {
+		stats = append(stats, rt.backends[name].rows()...)
+	}
+
+	return stats
+}
+
+// Info return the `show info` fields the worker report to the master
+func (g *Gateway) Info() haproxy.Info {
+	rt := g.routes.Load()
+
+	var cur, cum, req int64
+	for _, fe := range rt.frontends {
+		cur += fe.stats.scur.Load()
+		cum += fe.stats.stot.Load()
+		req += fe.stats.reqTot.Load()
+	}
+
+	uptime := int64(0)
+	if !g.started.IsZero() {
+		uptime = int64(time.Since(g.started).Seconds())
+	}
+
+	return haproxy.Info{
+		"Name":       "mox-builtin",
+		"Pid":        strconv.Itoa(os.Getpid()),
+		"Uptime_sec": strconv.FormatInt(uptime, 10),
+		"Maxconn":    strconv.Itoa(rt.maxconn),
+		"CurrConns":  strconv.FormatInt(cur, 10),
+		"CumConns":   strconv.FormatInt(cum, 10),
+		"CumReq":     strconv.FormatInt(req, 10),
+	}
+}
+
+// recorder catat status dan byte yang dikirim ke client
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+
+	return n, err
+}
+
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// handler run frontend rules, pilih backend, jalankan rule backend lalu
+// teruskan ke server. Urutannya sama dengan HAProxy.
+func (g *Gateway) handler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rt := g.routes.Load()
+		fe := rt.frontends[name]
+		rec := &recorder{ResponseWriter: w}
+
+		l := haproxy.AccessLog{
+			Mode:             "http",
+			AcceptDate:       start.Format("02/Jan/2006:15:04:05.000"),
+			Frontend:         name,
+			Backend:          name,
+			Server:           "<NOSRV>",
+			TimeQueue:        -1,
+			TimeConnect:      -1,
+			TimeResponse:     -1,
+			TerminationState: "----",
+			Request:          r.Method + " " + r.RequestURI + " " + r.Proto,
+		}
+
+		host, port, _ := net.SplitHostPort(r.RemoteAddr)
+		l.ClientIP = host
+		l.ClientPort, _ = strconv.Atoi(port)
+
+		fe.stats.reqTot.Add(1)
+		if r.ContentLength > 0 {
+			fe.stats.bytesIn.Add(r.ContentLength)
+		}
+
+		defer func() {
+			l.Status = rec.status
+			l.Bytes = rec.bytes
+			l.TimeActive = time.Since(start).Truncate(time.Millisecond)
+			l.ActConn = int(fe.stats.scur.Load())
+			l.FeConn = l.ActConn
+
+			fe.stats.status(rec.status)
+			fe.stats.bytesOut.Add(rec.bytes)
+
+			if fe.httplog {
+				g.logger.LogAttrs(r.Context(), l.Level(), "builtin access", l.Attrs()...)
+			}
+		}()
+
+		local := func(rp *reply, c *counters) {
+			if rp.state == "PR--" {
+				c.deniedReq.Add(1)
+			}
+
+			l.TerminationState = rp.state
+			rp.write(rec)
+		}
+
+		if rp := run(fe.request, r, r.Header); rp != nil {
+			local(rp, fe.stats)
+			return
+		}
+
+		be := fe.backend(r)
+		if be == nil {
+			l.TerminationState = "SC--"
+			(&reply{status: http.StatusServiceUnavailable, contentType: "text/html", body: noServer}).write(rec)
+
+			return
+		}
+
+		l.Backend = be.name
+		be.stats.open()
+		be.stats.reqTot.Add(1)
+
+		defer func() {
+			be.stats.close()
+			be.stats.took(time.Since(start))
+			be.stats.status(rec.status)
+			be.stats.bytesOut.Add(rec.bytes)
+			l.BeConn = int(be.stats.scur.Load())
+		}()
+
+		if rp := run(be.request, r, r.Header); rp != nil {
+			local(rp, be.stats)
+			return
+		}
+
+		srv := be.pick()
+		if srv == nil {
+			l.TerminationState = "SC--"
+			be.stats.errorsConn.Add(1)
+			(&reply{status: http.StatusServiceUnavailable, contentType: "text/html", body: noServer}).write(rec)
+
+			return
+		}
+
+		l.Server = srv.name
+		l.TimeQueue = 0
+		srv.stats.open()
+		srv.stats.reqTot.Add(1)
+
+		defer func() {
+			srv.stats.close()
+			srv.stats.took(time.Since(start))
+			srv.stats.status(rec.status)
+			srv.stats.bytesOut.Add(rec.bytes)
+		}()
+
+		trace := &httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) { l.TimeConnect = time.Since(start).Truncate(time.Millisecond) },
+		}
+
+		proxy := *srv.proxy
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			l.TimeResponse = time.Since(start).Truncate(time.Millisecond)
+			run(fe.response, r, resp.Header)
+
+			return nil
+		}
+		proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+			var netErr net.Error
+
+			switch {
+			case errors.Is(err, context.Canceled):
+				// client pergi duluan, tidak ada yang perlu dibalas
+				l.TerminationState = "CH--"
+			case errors.As(err, &netErr) && netErr.Timeout() && l.TimeConnect >= 0:
+				l.TerminationState = "sH--"
+				srv.stats.errorsResp.Add(1)
+				be.stats.errorsResp.Add(1)
+				(&reply{status: http.StatusGatewayTimeout, contentType: "text/html", body: "<html><body><h1>504 Gateway Time-out</h1>\nThe server didn't respond in time.\n</body></html>\n"}).write(w)
+			default:
+				l.TerminationState = "SC--"
+				srv.stats.errorsConn.Add(1)
+				be.stats.errorsConn.Add(1)
+				(&reply{status: http.StatusServiceUnavailable, contentType: "text/html", body: noServer}).write(w)
+			}
+		}
+
+		proxy.ServeHTTP(rec, r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	})
+}
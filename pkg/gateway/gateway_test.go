@@ -0,0 +1,175 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"mox/pkg/haproxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// upstream answer with its own name so the picked server is visible
+func upstream(t *testing.T, name string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Server", name)
+		fmt.Fprintf(w, "%s %s %s", name, r.Host, r.Header.Get("X-Forwarded-For"))
+	}))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+
+	return u.Host
+}
+
+func testModel(a, b string) haproxy.Config {
+	return haproxy.Config{
+		Defaults: haproxy.Defaults{Mode: "http", Options: []string{"httplog"}},
+		Frontends: []haproxy.Frontend{{
+			Name:  "gateway",
+			Binds: []haproxy.Bind{{Listener: "gateway"}},
+			ACLs: []haproxy.ACL{
+				{Name: "is_api", Criterion: "path_beg /api/"},
+				{Name: "is_blocked", Criterion: "path /blocked"},
+			},
+			HTTPRequest:    []haproxy.Rule{{Action: "deny", Cond: "if is_blocked"}},
+			HTTPResponse:   []haproxy.Rule{{Action: `set-header X-Managed-By "Mox-Master"`}},
+			UseBackends:    []haproxy.UseBackend{{Backend: "api", Cond: "if is_api"}},
+			DefaultBackend: "versions",
+		}},
+		Backends: []haproxy.Backend{
+			{Name: "api", Servers: []haproxy.Server{
+				{Name: "a", Address: a, Weight: 2},
+				{Name: "b", Address: b},
+			}},
+			{Name: "versions", HTTPRequest: []haproxy.Rule{
+				{Action: `return status 200 content-type "text/plain" hdr X-Worker-PID "${PID}" string "PID: ${PID}\n"`},
+			}},
+		},
+	}
+}
+
+func serve(t *testing.T, cfg haproxy.Config) (*Gateway, string) {
+	lookup := func(name string) (string, bool) { return "42", name == "PID" }
+
+	gw, err := New(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), lookup)
+	assert.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	assert.NoError(t, gw.Serve(map[string]net.Listener{"gateway": l}))
+	t.Cleanup(func() { gw.Close() })
+
+	return gw, "http://" + l.Addr().String()
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	return resp, string(body)
+}
+
+func TestGatewayServe(t *testing.T) {
+	gw, base := serve(t, testModel(upstream(t, "a"), upstream(t, "b")))
+
+	// return dari backend, tanpa http-response rule seperti HAProxy
+	resp, body := get(t, base+"/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "PID: 42\n", body)
+	assert.Equal(t, "42", resp.Header.Get("X-Worker-PID"))
+	assert.Empty(t, resp.Header.Get("X-Managed-By"))
+
+	resp, _ = get(t, base+"/blocked")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// weight 2:1
+	picked := map[string]int{}
+	for i := 0; i < 6; i++ {
+		resp, body := get(t, base+"/api/v1")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Mox-Master", resp.Header.Get("X-Managed-By"))
+		assert.Contains(t, body, "127.0.0.1")
+		picked[resp.Header.Get("X-Server")]++
+	}
+
+	assert.Equal(t, map[string]int{"a": 4, "b": 2}, picked)
+
+	stats := gw.Stats()
+	assert.Len(t, stats, 5)
+	assert.Equal(t, "gateway", stats[0].ProxyName)
+	assert.Equal(t, haproxy.TypeFrontend, stats[0].Type)
+	assert.Equal(t, "OPEN", stats[0].Status)
+	assert.Equal(t, int64(8), stats[0].ReqTot)
+	assert.Equal(t, int64(7), stats[0].Hrsp2xx)
+	assert.Equal(t, int64(1), stats[0].Hrsp4xx)
+	assert.Equal(t, int64(1), stats[0].DeniedReq)
+	assert.Equal(t, int64(4), stats[1].ReqTot) // server api/a
+	assert.Equal(t, int64(6), stats[3].ReqTot) // backend api
+	assert.Equal(t, int64(3), stats[3].Weight)
+	assert.Equal(t, "8", gw.Info()["CumReq"])
+}
+
+func TestGatewayNoServer(t *testing.T) {
+	cfg := testModel("127.0.0.1:1", "127.0.0.1:1")
+	cfg.Backends[0].Servers[1].Options = "disabled"
+
+	gw, base := serve(t, cfg)
+
+	resp, body := get(t, base+"/api/v1")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, noServer, body)
+
+	stats := gw.Stats()
+	assert.Equal(t, int64(1), stats[1].ErrorsConn)
+	assert.Equal(t, "MAINT", stats[2].Status)
+}
+
+func TestGatewayReloadAndDrain(t *testing.T) {
+	cfg := testModel(upstream(t, "a"), upstream(t, "b"))
+	gw, base := serve(t, cfg)
+
+	cfg.Frontends[0].DefaultBackend = "api"
+	assert.NoError(t, gw.Reload(cfg))
+
+	resp, _ := get(t, base+"/")
+	assert.NotEmpty(t, resp.Header.Get("X-Server"))
+
+	// bind berubah butuh worker baru
+	moved := testModel("127.0.0.1:1", "127.0.0.1:1")
+	moved.Frontends[0].Binds = []haproxy.Bind{{Address: "127.0.0.1:0"}}
+	assert.ErrorContains(t, gw.Reload(moved), "new worker generation")
+
+	assert.NoError(t, gw.Drain())
+	assert.Equal(t, "STOP", gw.Stats()[0].Status)
+
+	_, err := http.Get(base + "/")
+	assert.Error(t, err)
+
+	assert.NoError(t, gw.Stop(context.Background()))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(testModel("127.0.0.1:80", "127.0.0.1:81"), nil))
+
+	cfg := testModel("127.0.0.1:80", "127.0.0.1:81")
+	cfg.Frontends[0].Mode = "tcp"
+	cfg.Backends[0].Balance = "leastconn"
+	cfg.Backends[1].Servers = []haproxy.Server{{Name: "x", Address: "no-port"}}
+
+	err := Validate(cfg, nil)
+	assert.ErrorContains(t, err, "backend api: balance leastconn is not supported")
+	assert.ErrorContains(t, err, `backend versions: server x: address "no-port" must be host:port`)
+	assert.ErrorContains(t, err, "frontend gateway: mode tcp is not supported")
+}
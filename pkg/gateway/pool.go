@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"mox/pkg/haproxy"
+)
+
+// ErrNotFound is returned when the backend or server does not exist
+var ErrNotFound = errors.New("no such backend or server")
+
+// perubahan di sini cuma hidup di routes yang sekarang, Reload compile ulang
+// dari model. Caller (engine) yang apply ulang state-nya setelah Reload,
+// sama seperti HAProxy yang lupa perubahan Runtime API setelah reload.
+
+func (g *Gateway) backend(name string) (*backend, error) {
+	be, ok := g.routes.Load().backends[name]
+	if !ok {
+		return nil, fmt.Errorf("backend %s: %w", name, ErrNotFound)
+	}
+
+	return be, nil
+}
+
+// server cari server di backend, b.mu harus sudah dipegang
+func (b *backend) server(name string) (*server, error) {
+	for _, s := range b.servers {
+		if s.name == name {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("server %s/%s: %w", b.name, name, ErrNotFound)
+}
+
+// SetServerWeight change the weight like `set server <backend>/<server> weight`, 0 = tidak dapat traffic
+func (g *Gateway) SetServerWeight(backend, server string, weight int) error {
+	if weight < 0 || weight > 256 {
+		return fmt.Errorf("server %s/%s: weight %d must be between 0 and 256", backend, server, weight)
+	}
+
+	be, err := g.backend(backend)
+	if err != nil {
+		return err
+	}
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	s, err := be.server(server)
+	if err != nil {
+		return err
+	}
+
+	s.weight = weight
+
+	return nil
+}
+
+// SetServerState change the admin state like `set server <backend>/<server> state`
+func (g *Gateway) SetServerState(backend, server string, state haproxy.ServerState) error {
+	switch state {
+	case haproxy.StateReady, haproxy.StateDrain, haproxy.StateMaint:
+	default:
+		return fmt.Errorf("server %s/%s: unknown state %q", backend, server, state)
+	}
+
+	be, err := g.backend(backend)
+	if err != nil {
+		return err
+	}
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	s, err := be.server(server)
+	if err != nil {
+		return err
+	}
+
+	s.state = state
+
+	return nil
+}
+
+// SetServerHealth mark the server up or down like `set server <backend>/<server> health`
+func (g *Gateway) SetServerHealth(backend, server string, health haproxy.ServerHealth) error {
+	if health != haproxy.HealthUp && health != haproxy.HealthDown {
+		return fmt.Errorf("server %s/%s: unknown health %q", backend, server, health)
+	}
+
+	be, err := g.backend(backend)
+	if err != nil {
+		return err
+	}
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	s, err := be.server(server)
+	if err != nil {
+		return err
+	}
+
+	s.down = health == haproxy.HealthDown
+
+	return nil
+}
+
+// AddServer add a server like `add server`, mulainya ready kecuali option "disabled"
+func (g *Gateway) AddServer(backend string, s haproxy.Server) error {
+	be, err := g.backend(backend)
+	if err != nil {
+		return err
+	}
+
+	rt := g.routes.Load()
+
+	srv, err := compileServer(s, rt.transport, g.lookup)
+	if err != nil {
+		return fmt.Errorf("backend %s: %w", backend, err)
+	}
+
+	srv.stats = counterOf(g.reg.servers, backend+"/"+s.Name)
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	if _, err := be.server(s.Name); err == nil {
+		return fmt.Errorf("server %s/%s already exists", backend, s.Name)
+	}
+
+	be.servers = append(be.servers, srv)
+
+	return nil
+}
+
+// DelServer remove a server like `del server`, server harus maint dulu
+func (g *Gateway) DelServer(backend, name string) error {
+	be, err := g.backend(backend)
+	if err != nil {
+		return err
+	}
+
+	be.mu.Lock()
+	defer be.mu.Unlock()
+
+	s, err := be.server(name)
+	if err != nil {
+		return err
+	}
+
+	if s.state != haproxy.StateMaint {
+		return fmt.Errorf("server %s/%s must be in maintenance mode before it is deleted", backend, name)
+	}
+
+	// request yang sedang jalan tetap pegang *server, aman dihapus dari list
+	be.servers = slices.DeleteFunc(be.servers, func(x *server) bool { return x == s })
+	delete(be.current, s)
+
+	return nil
+}
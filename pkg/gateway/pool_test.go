@@ -0,0 +1,61 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+
+	"mox/pkg/haproxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pickedBy hit the api backend n times and count which server answered
+func pickedBy(t *testing.T, base string, n int) map[string]int {
+	picked := map[string]int{}
+	for i := 0; i < n; i++ {
+		resp, _ := get(t, base+"/api/v1")
+		picked[resp.Header.Get("X-Server")]++
+	}
+
+	return picked
+}
+
+func TestGatewayServerPool(t *testing.T) {
+	gw, base := serve(t, testModel(upstream(t, "a"), upstream(t, "b")))
+
+	assert.NoError(t, gw.SetServerWeight("api", "a", 1))
+	assert.Equal(t, map[string]int{"a": 2, "b": 2}, pickedBy(t, base, 4))
+
+	// drain dan weight 0 sama-sama tidak dapat request baru
+	assert.NoError(t, gw.SetServerState("api", "a", haproxy.StateDrain))
+	assert.Equal(t, map[string]int{"b": 3}, pickedBy(t, base, 3))
+	assert.Equal(t, "DRAIN", gw.Stats()[1].Status)
+
+	assert.NoError(t, gw.AddServer("api", haproxy.Server{Name: "c", Address: upstream(t, "c"), Weight: 2}))
+	assert.Equal(t, map[string]int{"b": 1, "c": 2}, pickedBy(t, base, 3))
+
+	// down dari health check, state admin tetap ready
+	assert.NoError(t, gw.SetServerHealth("api", "c", haproxy.HealthDown))
+	assert.Equal(t, map[string]int{"b": 2}, pickedBy(t, base, 2))
+	assert.Equal(t, "DOWN", gw.Stats()[3].Status)
+	assert.NoError(t, gw.SetServerHealth("api", "c", haproxy.HealthUp))
+
+	assert.NoError(t, gw.SetServerWeight("api", "b", 0))
+	assert.Equal(t, map[string]int{"c": 2}, pickedBy(t, base, 2))
+
+	assert.ErrorContains(t, gw.DelServer("api", "c"), "maintenance mode")
+	assert.NoError(t, gw.SetServerState("api", "c", haproxy.StateMaint))
+	assert.NoError(t, gw.DelServer("api", "c"))
+
+	resp, _ := get(t, base+"/api/v1")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	stats := gw.Stats()
+	assert.Len(t, stats, 5)
+	assert.Equal(t, int64(0), stats[3].Weight)
+
+	assert.ErrorIs(t, gw.SetServerWeight("api", "z", 1), ErrNotFound)
+	assert.ErrorIs(t, gw.SetServerState("web", "a", haproxy.StateReady), ErrNotFound)
+	assert.ErrorContains(t, gw.AddServer("api", haproxy.Server{Name: "a", Address: "127.0.0.1:1"}), "already exists")
+	assert.ErrorContains(t, gw.SetServerWeight("api", "a", 300), "between 0 and 256")
+}
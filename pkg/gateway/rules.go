@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mox/pkg/haproxy"
+)
+
+// reply is a response produced by the gateway itself (return / deny)
+type reply struct {
+	status      int
+	contentType string
+	headers     [][2]string
+	body        string
+	// state is the termination state di access log, LR = return, PR = deny
+	state string
+}
+
+func (rp *reply) write(w http.ResponseWriter) {
+	for _, h := range rp.headers {
+		w.Header().Add(h[0], h[1])
+	}
+
+	if rp.contentType != "" {
+		w.Header().Set("Content-Type", rp.contentType)
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(rp.body)))
+	w.WriteHeader(rp.status)
+	w.Write([]byte(rp.body))
+}
+
+// rule is one compiled http-request / http-response line
+type rule struct {
+	cond matcher
+	// header diubah di sini, request atau response tergantung section-nya
+	apply func(h http.Header)
+	// reply terisi untuk return/deny, cuma boleh di http-request
+	reply *reply
+}
+
+// compileRules support set-header, add-header, del-header, return dan deny.
+// Log-format (%[...]) tidak didukung karena engine ini tidak punya sample fetch.
+func compileRules(rules []haproxy.Rule, acls map[string]matcher, lookup func(string) (string, bool), request bool) ([]rule, error) {
+	out := make([]rule, 0, len(rules))
+
+	for _, r := range rules {
+		words, err := haproxy.Fields(r.Action, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", r.Action, err)
+		}
+
+		if len(words) == 0 {
+			return nil, fmt.Errorf("rule action is empty")
+		}
+
+		for _, w := range words {
+			if strings.Contains(w, "%[") {
+				return nil, fmt.Errorf("rule %q: log-format is not supported by the builtin engine", r.Action)
+			}
+		}
+
+		c, err := compileCond(r.Cond, acls)
+		if err != nil {
+			return nil, err
+		}
+
+		compiled := rule{cond: c}
+
+		switch words[0] {
+		case "set-header", "add-header":
+			if len(words) != 3 {
+				return nil, fmt.Errorf("rule %q: %s expects <name> <value>", r.Action, words[0])
+			}
+
+			name, value := words[1], words[2]
+			if words[0] == "set-header" {
+				compiled.apply = func(h http.Header) { h.Set(name, value) }
+			} else {
+				compiled.apply = func(h http.Header) { h.Add(name, value) }
+			}
+		case "del-header":
+			if len(words) != 2 {
+				return nil, fmt.Errorf("rule %q: del-header expects <name>", r.Action)
+			}
+
+			name := words[1]
+			compiled.apply = func(h http.Header) { h.Del(name) }
+		case "return", "deny":
+			if !request {
+				return nil, fmt.Errorf("rule %q: %s is only supported in http-request", r.Action, words[0])
+			}
+
+			rp, err := compileReply(words)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Action, err)
+			}
+
+			compiled.reply = rp
+		default:
+			return nil, fmt.Errorf("rule %q: action %s is not supported by the builtin engine", r.Action, words[0])
+		}
+
+		out = append(out, compiled)
+	}
+
+	return out, nil
+}
+
+// compileReply read "return [status <code>] [content-type <type>] [hdr <name> <value>]... [string <body>]"
+// dan "deny [deny_status <code>]"
+func compileReply(words []string) (*reply, error) {
+	rp := &reply{status: http.StatusOK, state: "LR--"}
+	if words[0] == "deny" {
+		rp = &reply{status: http.StatusForbidden, contentType: "text/html", body: "<html><body><h1>403 Forbidden</h1>\nRequest forbidden by administrative rules.\n</body></html>\n", state: "PR--"}
+	}
+
+	args := words[1:]
+	for len(args) > 0 {
+		need := 2
+		if args[0] == "hdr" {
+			need = 3
+		}
+
+		if len(args) < need {
+			return nil, fmt.Errorf("%s expects a value", args[0])
+		}
+
+		switch args[0] {
+		case "status", "deny_status":
+			code, err := strconv.Atoi(args[1])
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status %q", args[1])
+			}
+
+			rp.status = code
+		case "content-type":
+			rp.contentType = args[1]
+		case "hdr":
+			rp.headers = append(rp.headers, [2]string{args[1], args[2]})
+		case "string":
+			rp.body = args[1]
+		default:
+			return nil, fmt.Errorf("%s option %s is not supported by the builtin engine", words[0], args[0])
+		}
+
+		args = args[need:]
+	}
+
+	return rp, nil
+}
+
+// run apply every matching rule, berhenti di return/deny pertama
+func run(rules []rule, r *http.Request, h http.Header) *reply {
+	for _, rl := range rules {
+		if !rl.cond(r) {
+			continue
+		}
+
+		if rl.reply != nil {
+			return rl.reply
+		}
+
+		rl.apply(h)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"sync/atomic"
+	"time"
+
+	"mox/pkg/haproxy"
+)
+
+// counters of one frontend, backend or server, bentuknya mengikuti kolom
+// `show stat` supaya stats engine ini bisa diperlakukan sama dengan HAProxy.
+type counters struct {
+	scur, smax, stot       atomic.Int64
+	reqTot                 atomic.Int64
+	bytesIn, bytesOut      atomic.Int64
+	deniedReq              atomic.Int64
+	errorsConn, errorsResp atomic.Int64
+	hrsp                   [6]atomic.Int64 // index = status/100, 0 = lainnya
+	timed, ttimeSum        atomic.Int64    // ms, ttime = rata-rata sejak start
+}
+
+func (c *counters) open() {
+	n := c.scur.Add(1)
+	c.stot.Add(1)
+
+	for {
+		max := c.smax.Load()
+		if n <= max || c.smax.CompareAndSwap(max, n) {
+			return
+		}
+	}
+}
+
+func (c *counters) close() {
+	c.scur.Add(-1)
+}
+
+func (c *counters) status(code int) {
+	class := code / 100
+	if class < 1 || class > 5 {
+		class = 0
+	}
+
+	c.hrsp[class].Add(1)
+}
+
+// took record the total time of one finished request
+func (c *counters) took(d time.Duration) {
+	c.timed.Add(1)
+	c.ttimeSum.Add(d.Milliseconds())
+}
+
+func (c *counters) ttime() int64 {
+	n := c.timed.Load()
+	if n == 0 {
+		return 0
+	}
+
+	return c.ttimeSum.Load() / n
+}
+
+func (c *counters) stat(proxy, service string, t haproxy.StatType) haproxy.Stat {
+	return haproxy.Stat{
+		ProxyName:   proxy,
+		ServiceName: service,
+		Type:        t,
+		SCur:        c.scur.Load(),
+		SMax:        c.smax.Load(),
+		STot:        c.stot.Load(),
+		BytesIn:     c.bytesIn.Load(),
+		BytesOut:    c.bytesOut.Load(),
+		DeniedReq:   c.deniedReq.Load(),
+		ErrorsConn:  c.errorsConn.Load(),
+		ErrorsResp:  c.errorsResp.Load(),
+		ReqTot:      c.reqTot.Load(),
+		Hrsp1xx:     c.hrsp[1].Load(),
+		Hrsp2xx:     c.hrsp[2].Load(),
+		Hrsp3xx:     c.hrsp[3].Load(),
+		Hrsp4xx:     c.hrsp[4].Load(),
+		Hrsp5xx:     c.hrsp[5].Load(),
+		TTime:       c.ttime(),
+	}
+}
+
+// registry keep counters by name, jadi angka tidak reset waktu Reload
+type registry struct {
+	frontends map[string]*counters
+	backends  map[string]*counters
+	servers   map[string]*counters // "backend/server"
+}
+
+func newRegistry() *registry {
+	return &registry{
+		frontends: map[string]*counters{},
+		backends:  map[string]*counters{},
+		servers:   map[string]*counters{},
+	}
+}
+
+func counterOf(m map[string]*counters, name string) *counters {
+	c, ok := m[name]
+	if !ok {
+		c = &counters{}
+		m[name] = c
+	}
+
+	return c
+}
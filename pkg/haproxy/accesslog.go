@@ -0,0 +1,251 @@
+package haproxy
+
+import (
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLog is one `option httplog` / `option tcplog` line. Timer bernilai -1
+// kalau fase itu tidak pernah tercapai (misal koneksi diputus sebelum connect).
+type AccessLog struct {
+	Mode       string // "http" atau "tcp"
+	ClientIP   string
+	ClientPort int
+	AcceptDate string
+	Frontend   string
+	Backend    string
+	Server     string
+
+	// httplog TR/Tw/Tc/Tr/Ta, tcplog Tw/Tc/Tt
+	TimeRequest  time.Duration
+	TimeQueue    time.Duration
+	TimeConnect  time.Duration
+	TimeResponse time.Duration
+	TimeActive   time.Duration
+	TimeTotal    time.Duration
+
+	Status           int
+	Bytes            int64
+	TerminationState string
+
+	ActConn      int
+	FeConn       int
+	BeConn       int
+	SrvConn      int
+	Retries      int
+	SrvQueue     int
+	BackendQueue int
+
+	Request string
+}
+
+// ParseAccessLog parse one httplog/tcplog line, prefix "haproxy[pid]: " boleh ada
+//
+//	10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /index.html HTTP/1.1"
+//	10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0
+func ParseAccessLog(line string) (AccessLog, bool) {
+	line = strings.TrimSpace(line)
+
+	// buang "process[pid]: " kalau log dikirim dengan header
+	if head, rest, ok := strings.Cut(line, ": "); ok && strings.HasSuffix(head, "]") && !strings.Contains(head, " ") {
+		line = rest
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 8 {
+		return AccessLog{}, false
+	}
+
+	var l AccessLog
+
+	host, port, err := net.SplitHostPort(fields[0])
+	if err != nil {
+		// client unix socket ditulis "unix:<n>"
+		host, port = fields[0], ""
+	}
+
+	l.ClientIP = host
+	l.ClientPort, _ = strconv.Atoi(port)
+
+	if !strings.HasPrefix(fields[1], "[") || !strings.HasSuffix(fields[1], "]") {
+		return AccessLog{}, false
+	}
+
+	l.AcceptDate = strings.Trim(fields[1], "[]")
+	l.Frontend = strings.TrimSuffix(fields[2], "~") // ~ = frontend SSL
+
+	backend, server, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return AccessLog{}, false
+	}
+
+	l.Backend, l.Server = backend, server
+
+	timers, ok := parseTimers(fields[4])
+	if !ok {
+		return AccessLog{}, false
+	}
+
+	rest := fields[5:]
+
+	switch len(timers) {
+	case 5:
+		// status bytes cookie_req cookie_resp termination conns queues
+		if len(rest) < 7 {
+			return AccessLog{}, false
+		}
+
+		l.Mode = "http"
+		l.TimeRequest, l.TimeQueue, l.TimeConnect, l.TimeResponse, l.TimeActive = timers[0], timers[1], timers[2], timers[3], timers[4]
+
+		if l.Status, err = strconv.Atoi(rest[0]); err != nil {
+			return AccessLog{}, false
+		}
+
+		l.Bytes, _ = strconv.ParseInt(strings.TrimPrefix(rest[1], "+"), 10, 64)
+		l.TerminationState = rest[4]
+		rest = rest[5:]
+	case 3:
+		// bytes termination conns queues
+		if len(rest) < 4 {
+			return AccessLog{}, false
+		}
+
+		l.Mode = "tcp"
+		l.TimeQueue, l.TimeConnect, l.TimeTotal = timers[0], timers[1], timers[2]
+		l.Bytes, _ = strconv.ParseInt(strings.TrimPrefix(rest[0], "+"), 10, 64)
+		l.TerminationState = rest[1]
+		rest = rest[2:]
+	default:
+		return AccessLog{}, false
+	}
+
+	conns := ints(rest[0], 5)
+	queues := ints(rest[1], 2)
+	if conns == nil || queues == nil {
+		return AccessLog{}, false
+	}
+
+	l.ActConn, l.FeConn, l.BeConn, l.SrvConn, l.Retries = conns[0], conns[1], conns[2], conns[3], conns[4]
+	l.SrvQueue, l.BackendQueue = queues[0], queues[1]
+
+	if l.Mode == "http" {
+		// captured header {..} dilewati, request line selalu diapit quote di akhir
+		if start := strings.IndexByte(line, '"'); start >= 0 {
+			l.Request = strings.TrimSuffix(line[start+1:], `"`)
+		}
+	}
+
+	return l, true
+}
+
+// parseTimers read "10/0/30/69/+109", -1 dibiarkan, + (logasap) dibuang
+func parseTimers(s string) ([]time.Duration, bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 && len(parts) != 5 {
+		return nil, false
+	}
+
+	out := make([]time.Duration, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseInt(strings.TrimPrefix(p, "+"), 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		if n < 0 {
+			out[i] = -1
+			continue
+		}
+
+		out[i] = time.Duration(n) * time.Millisecond
+	}
+
+	return out, true
+}
+
+func ints(s string, n int) []int {
+	parts := strings.Split(s, "/")
+	if len(parts) != n {
+		return nil
+	}
+
+	out := make([]int, n)
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimPrefix(p, "+"))
+		if err != nil {
+			return nil
+		}
+
+		out[i] = v
+	}
+
+	return out
+}
+
+// Level is Warn for 5xx and sessions terminated by an error, Info otherwise
+func (l AccessLog) Level() slog.Level {
+	if l.Status >= 500 {
+		return slog.LevelWarn
+	}
+
+	// huruf pertama termination state: C client, S server, P proxy, R resource, I internal
+	if l.TerminationState != "" && strings.ContainsRune("SPRI", rune(l.TerminationState[0])) {
+		return slog.LevelWarn
+	}
+
+	return slog.LevelInfo
+}
+
+// Attrs return the typed slog attributes of the line
+func (l AccessLog) Attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("mode", l.Mode),
+		slog.String("client_ip", l.ClientIP),
+		slog.Int("client_port", l.ClientPort),
+		slog.String("frontend", l.Frontend),
+		slog.String("backend", l.Backend),
+		slog.String("server", l.Server),
+	}
+
+	timer := func(key string, d time.Duration) {
+		// -1 = fase tidak tercapai, tidak ditulis
+		if d >= 0 {
+			attrs = append(attrs, slog.Duration(key, d))
+		}
+	}
+
+	if l.Mode == "http" {
+		timer("time_request", l.TimeRequest)
+		timer("time_queue", l.TimeQueue)
+		timer("time_connect", l.TimeConnect)
+		timer("time_response", l.TimeResponse)
+		timer("time_active", l.TimeActive)
+		attrs = append(attrs, slog.Int("status", l.Status))
+	} else {
+		timer("time_queue", l.TimeQueue)
+		timer("time_connect", l.TimeConnect)
+		timer("time_total", l.TimeTotal)
+	}
+
+	attrs = append(attrs,
+		slog.Int64("bytes", l.Bytes),
+		slog.String("termination_state", l.TerminationState),
+		slog.Int("actconn", l.ActConn),
+		slog.Int("feconn", l.FeConn),
+		slog.Int("beconn", l.BeConn),
+		slog.Int("srv_conn", l.SrvConn),
+		slog.Int("retries", l.Retries),
+		slog.Int("srv_queue", l.SrvQueue),
+		slog.Int("backend_queue", l.BackendQueue),
+	)
+
+	if l.Request != "" {
+		attrs = append(attrs, slog.String("request", l.Request))
+	}
+
+	return attrs
+}
@@ -0,0 +1,74 @@
+package haproxy
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccessLog(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want AccessLog
+		ok   bool
+	}{
+		{
+			name: "httplog",
+			line: `10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /index.html HTTP/1.1"`,
+			want: AccessLog{
+				Mode: "http", ClientIP: "10.0.1.2", ClientPort: 33317, AcceptDate: "06/Feb/2009:12:14:14.655",
+				Frontend: "http-in", Backend: "static", Server: "srv1",
+				TimeRequest: 10 * time.Millisecond, TimeQueue: 0, TimeConnect: 30 * time.Millisecond,
+				TimeResponse: 69 * time.Millisecond, TimeActive: 109 * time.Millisecond,
+				Status: 200, Bytes: 2750, TerminationState: "----",
+				ActConn: 1, FeConn: 1, BeConn: 1, SrvConn: 1,
+				Request: "GET /index.html HTTP/1.1",
+			},
+			ok: true,
+		},
+		{
+			name: "httplog with header and aborted connect",
+			line: `haproxy[4242]: 127.0.0.1:5000 [17/Oct/2026:10:00:00.000] gateway~ versions_backend/<NOSRV> 0/-1/-1/-1/3 503 217 - - SC-- 2/2/0/0/3 0/0 {host} "POST /v1 HTTP/1.1"`,
+			want: AccessLog{
+				Mode: "http", ClientIP: "127.0.0.1", ClientPort: 5000, AcceptDate: "17/Oct/2026:10:00:00.000",
+				Frontend: "gateway", Backend: "versions_backend", Server: "<NOSRV>",
+				TimeQueue: -1, TimeConnect: -1, TimeResponse: -1, TimeActive: 3 * time.Millisecond,
+				Status: 503, Bytes: 217, TerminationState: "SC--",
+				ActConn: 2, FeConn: 2, Retries: 3,
+				Request: "POST /v1 HTTP/1.1",
+			},
+			ok: true,
+		},
+		{
+			name: "tcplog",
+			line: `10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0`,
+			want: AccessLog{
+				Mode: "tcp", ClientIP: "10.0.1.2", ClientPort: 33313, AcceptDate: "06/Feb/2009:12:12:51.443",
+				Frontend: "fnt", Backend: "bck", Server: "srv1",
+				TimeTotal: 5007 * time.Millisecond, Bytes: 212, TerminationState: "--", Retries: 3,
+			},
+			ok: true,
+		},
+		{name: "notice", line: `[NOTICE]   (1) : haproxy version is 2.8.3`},
+		{name: "empty", line: ""},
+		{name: "bad timers", line: `10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0 212 -- 0/0/0/0/3 0/0`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseAccessLog(tt.line)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAccessLogLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelInfo, AccessLog{Status: 200, TerminationState: "----"}.Level())
+	assert.Equal(t, slog.LevelInfo, AccessLog{Status: 200, TerminationState: "CD--"}.Level())
+	assert.Equal(t, slog.LevelWarn, AccessLog{Status: 502, TerminationState: "----"}.Level())
+	assert.Equal(t, slog.LevelWarn, AccessLog{Mode: "tcp", TerminationState: "SC"}.Level())
+}
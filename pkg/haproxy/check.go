@@ -0,0 +1,207 @@
+package haproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AppVersion is passed to HAProxy as ${APP_VERSION}
+const AppVersion = "v1.1"
+
+// ProcessEnv return the env HAProxy is started (and checked) with: ${PID} plus
+// MOX_LISTENER_<NAME>=fd@N untuk tiap listener, urutan sama dengan ExtraFiles.
+func ProcessEnv(pid, firstFD int, listeners []string) []string {
+	env := []string{
+		fmt.Sprintf("PID=%d", pid),
+		"APP_VERSION=" + AppVersion,
+	}
+
+	for i, name := range listeners {
+		env = append(env, fmt.Sprintf("%s=fd@%d", ListenerEnv(name), firstFD+i))
+	}
+
+	return env
+}
+
+// EnvLookup resolve ${VAR} from env (format KEY=VALUE, misal hasil ProcessEnv)
+// dulu, baru environment process, sama seperti yang dilihat haproxy.
+func EnvLookup(env []string) func(string) (string, bool) {
+	vars := make(map[string]string, len(env))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		vars[k] = v
+	}
+
+	return func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+
+		return os.LookupEnv(name)
+	}
+}
+
+// Diagnostic is one [ALERT]/[WARNING] line printed by `haproxy -c`
+type Diagnostic struct {
+	Level   string `json:"level"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.File == "" {
+		return fmt.Sprintf("%s: %s", d.Level, d.Message)
+	}
+
+	return fmt.Sprintf("%s: %s:%d: %s", d.Level, d.File, d.Line, d.Message)
+}
+
+// Fatal true untuk ALERT, WARNING tidak menggagalkan check
+func (d Diagnostic) Fatal() bool {
+	return d.Level == "ALERT"
+}
+
+// contoh format yang dikenali:
+//
+//	[ALERT]    (1234) : config : parsing [/tmp/h.cfg:12] : unknown keyword 'foo' in 'frontend' section
+//	[WARNING]  (1234) : config : parsing [/tmp/h.cfg:3] : 'option httplog' not usable with ...
+//	[ALERT] 123/101010 (1234) : parsing [h.cfg:12] : ... (HAProxy < 2.4)
+var diagnosticLine = regexp.MustCompile(`^\[(ALERT|WARNING|NOTICE)\][^:]*:\s*(?:config\s*:\s*)?(?:parsing \[(.+):(\d+)\]\s*:\s*)?(.*)$`)
+
+// ParseCheckOutput turn the output of `haproxy -c` into diagnostics
+func ParseCheckOutput(out string) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		m := diagnosticLine.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if m == nil || m[1] == "NOTICE" {
+			continue
+		}
+
+		d := Diagnostic{Level: m[1], File: m[2], Message: strings.TrimSpace(m[4])}
+		d.Line, _ = strconv.Atoi(m[3])
+
+		diagnostics = append(diagnostics, d)
+	}
+
+	return diagnostics
+}
+
+// CheckError is returned when `haproxy -c` reject a config
+type CheckError struct {
+	File        string
+	Diagnostics []Diagnostic
+	Output      string
+	Err         error
+}
+
+func (e *CheckError) Error() string {
+	var lines []string
+	for _, d := range e.Diagnostics {
+		if d.Fatal() {
+			lines = append(lines, d.String())
+		}
+	}
+
+	if len(lines) == 0 {
+		// format output tidak dikenal, pakai output mentah
+		lines = append(lines, strings.TrimSpace(e.Output))
+	}
+
+	return fmt.Sprintf("haproxy config %s is invalid: %s", e.File, strings.Join(lines, "; "))
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// Checker run `haproxy -c -f <file>` with the same env and FDs the real process gets
+type Checker struct {
+	executable string
+	env        []string
+	files      []*os.File
+	timeout    time.Duration
+}
+
+func NewChecker(executable string) *Checker {
+	return &Checker{executable: executable, timeout: 10 * time.Second}
+}
+
+// SetEnv add env vars on top of os.Environ()
+func (c *Checker) SetEnv(env []string) *Checker {
+	c.env = env
+
+	return c
+}
+
+// SetFiles pass the listener FDs, fd@N di config harus valid waktu check
+func (c *Checker) SetFiles(files []*os.File) *Checker {
+	c.files = files
+
+	return c
+}
+
+// SetTimeout bound how long one check may run
+func (c *Checker) SetTimeout(d time.Duration) *Checker {
+	c.timeout = d
+
+	return c
+}
+
+// Check validate file, hasilnya *CheckError kalau HAProxy menolak config
+func (c *Checker) Check(ctx context.Context, file string) ([]Diagnostic, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.executable, "-c", "-f", file)
+	cmd.Env = append(os.Environ(), c.env...)
+	cmd.ExtraFiles = c.files
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	diagnostics := ParseCheckOutput(out.String())
+
+	if err != nil {
+		return diagnostics, &CheckError{File: file, Diagnostics: diagnostics, Output: out.String(), Err: err}
+	}
+
+	return diagnostics, nil
+}
+
+// CheckConfig render cfg into a temporary file and check it
+func (c *Checker) CheckConfig(ctx context.Context, cfg Config) ([]Diagnostic, error) {
+	body, err := cfg.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "mox_check_*.cfg")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return c.Check(ctx, f.Name())
+}
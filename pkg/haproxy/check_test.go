@@ -0,0 +1,79 @@
+package haproxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCheckOutput(t *testing.T) {
+	out := `[NOTICE]   (4242) : haproxy version is 2.8.3
+[WARNING]  (4242) : config : parsing [/tmp/h.cfg:3] : 'option httplog' not usable with proxy 'tcp' (needs 'mode http').
+[ALERT]    (4242) : config : parsing [/tmp/h.cfg:12] : unknown keyword 'bnd' in 'frontend' section
+[ALERT] 123/101010 (17) : parsing [old.cfg:7] : 'server' expects <name> and <addr> as arguments.
+[ALERT]    (4242) : config : Fatal errors found in configuration.
+Configuration file is valid
+`
+
+	assert.Equal(t, []Diagnostic{
+		{Level: "WARNING", File: "/tmp/h.cfg", Line: 3, Message: "'option httplog' not usable with proxy 'tcp' (needs 'mode http')."},
+		{Level: "ALERT", File: "/tmp/h.cfg", Line: 12, Message: "unknown keyword 'bnd' in 'frontend' section"},
+		{Level: "ALERT", File: "old.cfg", Line: 7, Message: "'server' expects <name> and <addr> as arguments."},
+		{Level: "ALERT", Message: "Fatal errors found in configuration."},
+	}, ParseCheckOutput(out))
+
+	assert.Empty(t, ParseCheckOutput("Configuration file is valid\n"))
+}
+
+// fakeHAProxy write a script that behave like `haproxy -c`
+func fakeHAProxy(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "haproxy")
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+
+	return path
+}
+
+func TestCheckerCheck(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		bin := fakeHAProxy(t, `[ "$1 $2 $3" = "-c -f /tmp/ok.cfg" ] || exit 2
+[ "$PID" = "7" ] || exit 3
+[ "$MOX_LISTENER_GATEWAY" = "fd@3" ] || exit 4
+echo "Configuration file is valid"`)
+
+		diagnostics, err := NewChecker(bin).SetEnv(ProcessEnv(7, 3, []string{"gateway"})).Check(context.Background(), "/tmp/ok.cfg")
+		assert.NoError(t, err)
+		assert.Empty(t, diagnostics)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		bin := fakeHAProxy(t, `echo "[ALERT]    (1) : config : parsing [$3:2] : unknown keyword 'bnd' in 'frontend' section" >&2
+echo "[ALERT]    (1) : config : Fatal errors found in configuration." >&2
+exit 1`)
+
+		diagnostics, err := NewChecker(bin).Check(context.Background(), "bad.cfg")
+		assert.Len(t, diagnostics, 2)
+
+		var checkErr *CheckError
+		assert.True(t, errors.As(err, &checkErr))
+		assert.Equal(t, 2, checkErr.Diagnostics[0].Line)
+		assert.EqualError(t, err, "haproxy config bad.cfg is invalid: ALERT: bad.cfg:2: unknown keyword 'bnd' in 'frontend' section; ALERT: Fatal errors found in configuration.")
+
+		var exitErr *exec.ExitError
+		assert.True(t, errors.As(err, &exitErr))
+	})
+
+	t.Run("render before check", func(t *testing.T) {
+		bin := fakeHAProxy(t, `grep -q 'bind "${MOX_LISTENER_GATEWAY}"' "$3" || exit 1`)
+
+		_, err := NewChecker(bin).CheckConfig(context.Background(), testConfig())
+		assert.NoError(t, err)
+
+		_, err = NewChecker(bin).CheckConfig(context.Background(), Config{Frontends: []Frontend{{Name: "x"}}})
+		assert.ErrorContains(t, err, "at least one bind is required")
+	})
+}
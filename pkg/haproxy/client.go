@@ -0,0 +1,270 @@
+package haproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prompt HAProxy di mode interaktif, tiap respon diakhiri ini
+const prompt = "> "
+
+// staleError is a failure where HAProxy did not answer a single byte, koneksi
+// dari pool yang sudah ditutup HAProxy. Command-nya tidak jalan, aman diulang.
+type staleError struct{ err error }
+
+func (e *staleError) Error() string { return e.err.Error() }
+
+func (e *staleError) Unwrap() error { return e.err }
+
+var (
+	// ErrClosed is returned after Close
+	ErrClosed = errors.New("haproxy runtime client is closed")
+	// ErrCommand wrap the message HAProxy print when a command is refused
+	ErrCommand = errors.New("haproxy refused the command")
+)
+
+// Client talk to the HAProxy Runtime API (stats socket level admin).
+// Koneksi dibuka dalam mode prompt supaya bisa dipakai ulang, idle connection
+// disimpan di pool dan dibuang sebelum kena `stats timeout` HAProxy.
+type Client struct {
+	socket      string
+	timeout     time.Duration
+	idleTimeout time.Duration
+
+	mu      *sync.Mutex
+	idle    []*conn
+	maxIdle int
+	closed  bool
+}
+
+type conn struct {
+	net.Conn
+	r        *bufio.Reader
+	lastUsed time.Time
+}
+
+func NewClient(socket string) *Client {
+	return &Client{
+		socket:      socket,
+		timeout:     2 * time.Second,
+		idleTimeout: 10 * time.Second,
+		maxIdle:     2,
+		mu:          &sync.Mutex{},
+	}
+}
+
+// SetTimeout set the dial + round trip timeout of one command
+func (c *Client) SetTimeout(d time.Duration) *Client {
+	c.timeout = d
+
+	return c
+}
+
+// SetIdleTimeout set how long a pooled connection may stay unused,
+// harus lebih kecil dari `stats timeout` di haproxy.cfg
+func (c *Client) SetIdleTimeout(d time.Duration) *Client {
+	c.idleTimeout = d
+
+	return c
+}
+
+// SetMaxIdle set how many idle connections are kept, 0 = tanpa pool
+func (c *Client) SetMaxIdle(n int) *Client {
+	c.maxIdle = n
+
+	return c
+}
+
+// Socket return the stats socket path
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// Close drop every pooled connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+
+	var errs []error
+	for _, cn := range c.idle {
+		errs = append(errs, cn.Close())
+	}
+
+	c.idle = nil
+
+	return errors.Join(errs...)
+}
+
+// Execute run one raw command and return its output without the prompt
+func (c *Client) Execute(ctx context.Context, command string) (string, error) {
+	if strings.ContainsAny(command, "\n;") {
+		return "", fmt.Errorf("command %q must be a single command", command)
+	}
+
+	cn, reused, err := c.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := c.roundTrip(ctx, cn, command)
+
+	// koneksi dari pool bisa sudah ditutup HAProxy, coba sekali lagi dengan
+	// koneksi baru. Kalau HAProxy sudah mulai menjawab, command-nya sudah jalan
+	// dan tidak boleh diulang (add server, set weight dsb bukan idempotent).
+	var stale *staleError
+	if reused && errors.As(err, &stale) {
+		cn.Close()
+
+		if cn, err = c.dial(ctx); err != nil {
+			return "", err
+		}
+
+		out, err = c.roundTrip(ctx, cn, command)
+	}
+
+	if err != nil {
+		cn.Close()
+		return "", fmt.Errorf("haproxy %q: %w", command, err)
+	}
+
+	c.put(cn)
+
+	return out, nil
+}
+
+func (c *Client) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(c.timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(d) {
+		return dl
+	}
+
+	return d
+}
+
+func (c *Client) get(ctx context.Context) (*conn, bool, error) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return nil, false, ErrClosed
+	}
+
+	for len(c.idle) > 0 {
+		cn := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+
+		if time.Since(cn.lastUsed) < c.idleTimeout {
+			c.mu.Unlock()
+			return cn, true, nil
+		}
+
+		cn.Close()
+	}
+
+	c.mu.Unlock()
+
+	cn, err := c.dial(ctx)
+
+	return cn, false, err
+}
+
+func (c *Client) put(cn *conn) {
+	cn.lastUsed = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= c.maxIdle {
+		cn.Close()
+		return
+	}
+
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	d := net.Dialer{Deadline: c.deadline(ctx)}
+
+	nc, err := d.DialContext(ctx, "unix", c.socket)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	// masuk mode interaktif, koneksi tidak ditutup HAProxy setelah satu command
+	if _, err := c.roundTrip(ctx, cn, "prompt"); err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("cannot enter prompt mode: %w", err)
+	}
+
+	return cn, nil
+}
+
+func (c *Client) roundTrip(ctx context.Context, cn *conn, command string) (string, error) {
+	cn.SetDeadline(c.deadline(ctx))
+
+	if _, err := io.WriteString(cn, command+"\n"); err != nil {
+		return "", &staleError{err}
+	}
+
+	// EOF atau reset sebelum ada balasan berarti koneksinya sudah ditutup,
+	// timeout tidak termasuk karena command-nya bisa saja masih jalan
+	if _, err := cn.r.Peek(1); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		return "", &staleError{err}
+	}
+
+	return readResponse(cn.r)
+}
+
+// readResponse read until the interactive prompt, prompt-nya dibuang
+func readResponse(r *bufio.Reader) (string, error) {
+	var buf bytes.Buffer
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		buf.WriteByte(b)
+
+		if b != ' ' || !bytes.HasSuffix(buf.Bytes(), []byte(prompt)) {
+			continue
+		}
+
+		// prompt selalu di awal baris
+		out := buf.Bytes()[:buf.Len()-len(prompt)]
+		if len(out) == 0 || out[len(out)-1] == '\n' {
+			return strings.TrimRight(string(out), "\n"), nil
+		}
+	}
+}
+
+// expectEmpty turn the output of a setter into an error. Command set HAProxy
+// diam kalau sukses, sebagian cuma kasih info (misal "IP changed from ...").
+func expectEmpty(out string, info ...string) error {
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return nil
+	}
+
+	for _, prefix := range info {
+		if strings.HasPrefix(out, prefix) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrCommand, out)
+}
@@ -0,0 +1,256 @@
+package haproxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRuntime mimic the HAProxy stats socket in prompt mode
+func fakeRuntime(t *testing.T, answers map[string]string) (string, *atomic.Int32) {
+	socket := filepath.Join(t.TempDir(), "haproxy.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	dials := &atomic.Int32{}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			dials.Add(1)
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				interactive := false
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					command := scanner.Text()
+					if command == "prompt" {
+						interactive = true
+						conn.Write([]byte("\n" + prompt))
+						continue
+					}
+
+					out, ok := answers[command]
+					if !ok {
+						out = "Unknown command: '" + command + "'\n"
+					}
+
+					conn.Write([]byte(out + "\n"))
+
+					if !interactive {
+						return
+					}
+
+					conn.Write([]byte(prompt))
+				}
+			}(conn)
+		}
+	}()
+
+	return socket, dials
+}
+
+func TestClientReuseConnection(t *testing.T) {
+	socket, dials := fakeRuntime(t, map[string]string{
+		"show info":                "Name: HAProxy\nCurrConns: 3\n",
+		"disable frontend gateway": "",
+	})
+
+	c := NewClient(socket)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		info, err := c.ShowInfo(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), info.Int("CurrConns"))
+	}
+
+	assert.NoError(t, c.DisableFrontend(ctx, "gateway"))
+	assert.Equal(t, int32(1), dials.Load())
+}
+
+func TestClientCommandError(t *testing.T) {
+	socket, _ := fakeRuntime(t, map[string]string{
+		"disable frontend nope": "No such frontend.",
+	})
+
+	c := NewClient(socket)
+	defer c.Close()
+
+	err := c.DisableFrontend(context.Background(), "nope")
+	assert.True(t, errors.Is(err, ErrCommand))
+	assert.Contains(t, err.Error(), "No such frontend.")
+
+	_, err = c.Execute(context.Background(), "show info; shutdown sessions")
+	assert.Error(t, err)
+}
+
+func TestClientDynamicServer(t *testing.T) {
+	socket, _ := fakeRuntime(t, map[string]string{
+		"add server api/c 127.0.0.1:9003 weight 5": "New server registered.",
+		"add server api/a 127.0.0.1:9001":          "Already exists a server with the same name in backend.",
+		"del server api/c":                         "Server deleted.",
+		"del server api/a":                         "Only servers in maintenance mode can be deleted.",
+	})
+
+	c := NewClient(socket)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	assert.NoError(t, c.AddServer(ctx, "api", "c", "127.0.0.1:9003", "weight", "5"))
+	assert.NoError(t, c.DelServer(ctx, "api", "c"))
+
+	err := c.AddServer(ctx, "api", "a", "127.0.0.1:9001")
+	assert.True(t, errors.Is(err, ErrCommand))
+
+	err = c.DelServer(ctx, "api", "a")
+	assert.ErrorContains(t, err, "maintenance mode")
+}
+
+func TestClientIdleTimeout(t *testing.T) {
+	socket, dials := fakeRuntime(t, map[string]string{"show info": "Name: HAProxy\n"})
+
+	c := NewClient(socket).SetIdleTimeout(10 * time.Millisecond)
+	defer c.Close()
+
+	_, err := c.ShowInfo(context.Background())
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.ShowInfo(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), dials.Load())
+}
+
+func TestClientTimeout(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "slow.sock")
+
+	l, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	defer l.Close()
+
+	// tidak pernah balas
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	start := time.Now()
+	_, err = NewClient(socket).SetTimeout(50*time.Millisecond).Execute(context.Background(), "show info")
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.False(t, strings.Contains(err.Error(), "Unknown"))
+}
+
+// scriptedRuntime is a prompt mode stats socket whose reply decide the answer
+// of each command and whether the connection is closed right after it
+func scriptedRuntime(t *testing.T, reply func(command string) (string, bool)) (string, *atomic.Int32) {
+	socket := filepath.Join(t.TempDir(), "haproxy.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	executed := &atomic.Int32{}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if scanner.Text() == "prompt" {
+						conn.Write([]byte("\n" + prompt))
+						continue
+					}
+
+					executed.Add(1)
+
+					out, closing := reply(scanner.Text())
+					conn.Write([]byte(out))
+
+					if closing {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return socket, executed
+}
+
+func TestClientRetryStaleConnection(t *testing.T) {
+	// jawaban lengkap lalu koneksi ditutup, seperti kena `stats timeout`
+	socket, executed := scriptedRuntime(t, func(command string) (string, bool) {
+		return "\n" + prompt, true
+	})
+
+	c := NewClient(socket)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	assert.NoError(t, c.SetServerWeight(ctx, "api", "a", 10))
+	time.Sleep(10 * time.Millisecond)
+
+	// koneksi di pool sudah mati, command dikirim ulang lewat koneksi baru
+	assert.NoError(t, c.SetServerWeight(ctx, "api", "a", 20))
+	assert.Equal(t, int32(2), executed.Load())
+}
+
+func TestClientNoRetryAfterPartialReply(t *testing.T) {
+	socket, executed := scriptedRuntime(t, func(command string) (string, bool) {
+		if strings.HasPrefix(command, "add server") {
+			// HAProxy sudah jalanin command-nya lalu koneksi putus di tengah jawaban
+			return "New server reg", true
+		}
+
+		return "\n" + prompt, false
+	})
+
+	c := NewClient(socket)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	assert.NoError(t, c.SetServerWeight(ctx, "api", "a", 10))
+
+	err := c.AddServer(ctx, "api", "c", "127.0.0.1:9003")
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), executed.Load())
+}
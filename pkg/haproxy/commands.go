@@ -0,0 +1,162 @@
+package haproxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ServerState is the admin state accepted by `set server ... state`
+type ServerState string
+
+const (
+	StateReady ServerState = "ready"
+	StateDrain ServerState = "drain"
+	StateMaint ServerState = "maint"
+)
+
+// ServerHealth is the operational state accepted by `set server ... health`
+type ServerHealth string
+
+const (
+	HealthUp   ServerHealth = "up"
+	HealthDown ServerHealth = "down"
+)
+
+// ShowInfo run `show info`
+func (c *Client) ShowInfo(ctx context.Context) (Info, error) {
+	out, err := c.Execute(ctx, "show info")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseInfo(strings.NewReader(out))
+}
+
+// ShowStat run `show stat`, satu baris per frontend, backend dan server
+func (c *Client) ShowStat(ctx context.Context) ([]Stat, error) {
+	out, err := c.Execute(ctx, "show stat")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseStat(strings.NewReader(out))
+}
+
+// ShowServersState run `show servers state`, backend kosong = semua backend
+func (c *Client) ShowServersState(ctx context.Context, backend string) ([]ServerStatus, error) {
+	out, err := c.Execute(ctx, strings.TrimSpace("show servers state "+backend))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseServersState(strings.NewReader(out))
+}
+
+// ShowSess run `show sess`
+func (c *Client) ShowSess(ctx context.Context) ([]Session, error) {
+	out, err := c.Execute(ctx, "show sess")
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSess(strings.NewReader(out))
+}
+
+// SetServerState run `set server <backend>/<server> state <state>`
+func (c *Client) SetServerState(ctx context.Context, backend, server string, state ServerState) error {
+	return c.set(ctx, fmt.Sprintf("set server %s/%s state %s", backend, server, state))
+}
+
+// SetServerWeight run `set server <backend>/<server> weight <weight>`
+func (c *Client) SetServerWeight(ctx context.Context, backend, server string, weight int) error {
+	return c.set(ctx, fmt.Sprintf("set server %s/%s weight %d", backend, server, weight))
+}
+
+// SetServerHealth run `set server <backend>/<server> health <health>`, dipakai
+// health check master menggantikan check bawaan HAProxy
+func (c *Client) SetServerHealth(ctx context.Context, backend, server string, health ServerHealth) error {
+	return c.set(ctx, fmt.Sprintf("set server %s/%s health %s", backend, server, health))
+}
+
+// SetServerAddr run `set server <backend>/<server> addr <addr> [port <port>]`, port 0 = tidak diubah
+func (c *Client) SetServerAddr(ctx context.Context, backend, server, addr string, port int) error {
+	command := fmt.Sprintf("set server %s/%s addr %s", backend, server, addr)
+	if port > 0 {
+		command += " port " + strconv.Itoa(port)
+	}
+
+	return c.set(ctx, command, "IP changed", "no need to change", "port changed")
+}
+
+// AddServer run `add server <backend>/<server> <addr> [options]` (HAProxy 2.4+).
+// Server dinamis mulai dalam state maint, enable dengan SetServerState.
+func (c *Client) AddServer(ctx context.Context, backend, server, addr string, options ...string) error {
+	command := strings.Join(append([]string{fmt.Sprintf("add server %s/%s %s", backend, server, addr)}, options...), " ")
+
+	return c.set(ctx, command, "New server registered.")
+}
+
+// DelServer run `del server <backend>/<server>`, server harus maint dan tanpa session
+func (c *Client) DelServer(ctx context.Context, backend, server string) error {
+	return c.set(ctx, fmt.Sprintf("del server %s/%s", backend, server), "Server deleted.")
+}
+
+// SetMaxConnFrontend run `set maxconn frontend <frontend> <n>`
+func (c *Client) SetMaxConnFrontend(ctx context.Context, frontend string, n int) error {
+	return c.set(ctx, fmt.Sprintf("set maxconn frontend %s %d", frontend, n))
+}
+
+// SetMaxConnServer run `set maxconn server <backend>/<server> <n>`
+func (c *Client) SetMaxConnServer(ctx context.Context, backend, server string, n int) error {
+	return c.set(ctx, fmt.Sprintf("set maxconn server %s/%s %d", backend, server, n))
+}
+
+// SetMaxConnGlobal run `set maxconn global <n>`
+func (c *Client) SetMaxConnGlobal(ctx context.Context, n int) error {
+	return c.set(ctx, fmt.Sprintf("set maxconn global %d", n))
+}
+
+// DisableFrontend stop accepting new connections on every bind of the frontend
+func (c *Client) DisableFrontend(ctx context.Context, frontend string) error {
+	return c.set(ctx, "disable frontend "+frontend)
+}
+
+// EnableFrontend resume a frontend stopped by DisableFrontend
+func (c *Client) EnableFrontend(ctx context.Context, frontend string) error {
+	return c.set(ctx, "enable frontend "+frontend)
+}
+
+// AddMap run `add map <map> <key> <value>`, map bisa path file atau #<id>
+func (c *Client) AddMap(ctx context.Context, m, key, value string) error {
+	return c.set(ctx, fmt.Sprintf("add map %s %s %s", m, key, value))
+}
+
+// DelMap run `del map <map> <key>`
+func (c *Client) DelMap(ctx context.Context, m, key string) error {
+	return c.set(ctx, fmt.Sprintf("del map %s %s", m, key))
+}
+
+// AddACL run `add acl <acl> <pattern>`
+func (c *Client) AddACL(ctx context.Context, acl, pattern string) error {
+	return c.set(ctx, fmt.Sprintf("add acl %s %s", acl, pattern))
+}
+
+// DelACL run `del acl <acl> <pattern>`
+func (c *Client) DelACL(ctx context.Context, acl, pattern string) error {
+	return c.set(ctx, fmt.Sprintf("del acl %s %s", acl, pattern))
+}
+
+func (c *Client) set(ctx context.Context, command string, info ...string) error {
+	out, err := c.Execute(ctx, command)
+	if err != nil {
+		return err
+	}
+
+	if err := expectEmpty(out, info...); err != nil {
+		return fmt.Errorf("haproxy %q: %w", command, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,320 @@
+package haproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sectionKeywords is every keyword that start a new section in haproxy.cfg
+var sectionKeywords = map[string]struct{}{
+	"global":      {},
+	"defaults":    {},
+	"frontend":    {},
+	"backend":     {},
+	"listen":      {},
+	"resolvers":   {},
+	"peers":       {},
+	"userlist":    {},
+	"mailers":     {},
+	"program":     {},
+	"http-errors": {},
+	"ring":        {},
+	"cache":       {},
+	"fcgi-app":    {},
+	"log-forward": {},
+	"crt-store":   {},
+	"traces":      {},
+}
+
+// Document is a parsed haproxy.cfg. Token disimpan mentah (quote, escape dan
+// ${VAR} tidak diubah) supaya Render menghasilkan file yang ekuivalen.
+type Document struct {
+	// Head is the comment and blank lines before the first section
+	Head     []Line
+	Sections []*Section
+}
+
+// Section is one `global`, `frontend <name>`, ... block
+type Section struct {
+	Kind    string
+	Args    []string
+	Comment string
+	LineNo  int
+	Lines   []Line
+}
+
+// Line is one directive, or a comment/blank line kalau Args kosong
+type Line struct {
+	Args    []string
+	Comment string
+	LineNo  int
+}
+
+// Name return the first argument of the section header, misal nama frontend
+func (s *Section) Name() string {
+	if len(s.Args) == 0 {
+		return ""
+	}
+
+	return s.Args[0]
+}
+
+// Header return the section header as written in haproxy.cfg
+func (s *Section) Header() string {
+	return strings.Join(append([]string{s.Kind}, s.Args...), " ")
+}
+
+// Directives return the lines that are not comment or blank
+func (s *Section) Directives() []Line {
+	out := make([]Line, 0, len(s.Lines))
+	for _, l := range s.Lines {
+		if len(l.Args) > 0 {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
+
+// Keyword return the first token of the directive
+func (l Line) Keyword() string {
+	if len(l.Args) == 0 {
+		return ""
+	}
+
+	return l.Args[0]
+}
+
+// Text return the directive without the comment
+func (l Line) Text() string {
+	return strings.Join(l.Args, " ")
+}
+
+// ParseDocument read haproxy.cfg into a Document
+func ParseDocument(r io.Reader) (*Document, error) {
+	doc := &Document{}
+
+	var current *Section
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+
+		args, comment, err := tokenize(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		if len(args) > 0 {
+			if _, ok := sectionKeywords[args[0]]; ok {
+				current = &Section{Kind: args[0], Args: args[1:], Comment: comment, LineNo: n}
+				doc.Sections = append(doc.Sections, current)
+
+				continue
+			}
+
+			if current == nil {
+				return nil, fmt.Errorf("line %d: directive %q outside of a section", n, args[0])
+			}
+		}
+
+		line := Line{Args: args, Comment: comment, LineNo: n}
+		if current == nil {
+			doc.Head = append(doc.Head, line)
+		} else {
+			current.Lines = append(current.Lines, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// Render write the document back, indentasi dinormalisasi ke 4 spasi
+func (d *Document) Render(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	write := func(indent string, l Line) {
+		text := l.Text()
+
+		switch {
+		case text == "" && l.Comment == "":
+			bw.WriteString("\n")
+		case text == "":
+			fmt.Fprintf(bw, "%s#%s\n", indent, l.Comment)
+		case l.Comment == "":
+			fmt.Fprintf(bw, "%s%s\n", indent, text)
+		default:
+			fmt.Fprintf(bw, "%s%s #%s\n", indent, text, l.Comment)
+		}
+	}
+
+	for _, l := range d.Head {
+		write("", l)
+	}
+
+	for _, s := range d.Sections {
+		write("", Line{Args: append([]string{s.Kind}, s.Args...), Comment: s.Comment})
+
+		for _, l := range s.Lines {
+			write("    ", l)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// String render the document into a string
+func (d *Document) String() string {
+	var b strings.Builder
+	d.Render(&b)
+
+	return b.String()
+}
+
+// tokenize split one line like HAProxy does: whitespace separated, quote dan
+// backslash escape tetap ikut di token, '#' di luar quote mulai komentar.
+func tokenize(line string) ([]string, string, error) {
+	var (
+		args  []string
+		token strings.Builder
+		quote byte
+	)
+
+	flush := func() {
+		if token.Len() > 0 {
+			args = append(args, token.String())
+			token.Reset()
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(line):
+			token.WriteByte(c)
+			token.WriteByte(line[i+1])
+			i++
+		case quote != 0:
+			token.WriteByte(c)
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			token.WriteByte(c)
+			quote = c
+		case c == '#':
+			flush()
+			return args, line[i+1:], nil
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			token.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		return nil, "", fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	flush()
+
+	return args, "", nil
+}
+
+// Fields split s like one HAProxy config line and return every word already
+// unquoted dan di-expand lewat lookup, komentar dibuang.
+func Fields(s string, lookup func(string) (string, bool)) ([]string, error) {
+	args, _, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = Word(arg, lookup)
+	}
+
+	return words, nil
+}
+
+// Word unquote a raw token and expand environment variables the way HAProxy
+// does: ${VAR} dan $VAR di-expand di luar quote dan di dalam double quote,
+// single quote tidak. lookup nil = variabel dibiarkan apa adanya.
+func Word(token string, lookup func(string) (string, bool)) string {
+	var (
+		out   strings.Builder
+		quote byte
+	)
+
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(token):
+			i++
+			out.WriteByte(unescape(token[i]))
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == quote:
+			quote = 0
+		case c == '$' && quote != '\'' && lookup != nil:
+			name, size := envName(token[i+1:])
+			if size == 0 {
+				out.WriteByte(c)
+				continue
+			}
+
+			if value, ok := lookup(name); ok {
+				out.WriteString(value)
+			}
+
+			i += size
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.String()
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	default:
+		return c
+	}
+}
+
+// envName read "{NAME}" or "NAME" after '$', size = jumlah byte yang dipakai
+func envName(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 2 {
+			return "", 0
+		}
+
+		return s[1:end], end + 1
+	}
+
+	size := 0
+	for size < len(s) && (s[size] == '_' || s[size] >= 'A' && s[size] <= 'Z' || s[size] >= 'a' && s[size] <= 'z' || size > 0 && s[size] >= '0' && s[size] <= '9') {
+		size++
+	}
+
+	return s[:size], size
+}
@@ -0,0 +1,130 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDocument = `# legacy config
+global
+	log stdout format raw local0   # ke stdout
+  maxconn 4096
+
+frontend web # public
+    bind "${MOX_LISTENER_GATEWAY}"
+    http-request return string "a # b\n" if { path / }
+    # komentar di dalam section
+    acl bad hdr(x) 'it''s'
+`
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line    string
+		args    []string
+		comment string
+		err     bool
+	}{
+		{line: "", args: nil},
+		{line: "   # only comment", args: nil, comment: " only comment"},
+		{line: "\tbind :80  accept-proxy", args: []string{"bind", ":80", "accept-proxy"}},
+		{line: `bind "${X}" # env`, args: []string{"bind", `"${X}"`}, comment: " env"},
+		{line: `set-header X "a # b"`, args: []string{"set-header", "X", `"a # b"`}},
+		{line: `x a\ b\#c`, args: []string{"x", `a\ b\#c`}},
+		{line: `x 'single " quote'`, args: []string{"x", `'single " quote'`}},
+		{line: `x "open`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			args, comment, err := tokenize(tt.line)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.args, args)
+			assert.Equal(t, tt.comment, comment)
+		})
+	}
+}
+
+func TestWord(t *testing.T) {
+	env := map[string]string{"PID": "42", "HOME_DIR": "/home"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{`"${PID}"`, "42"},
+		{`/tmp/haproxy_${PID}.sock`, "/tmp/haproxy_42.sock"},
+		{`$HOME_DIR/x`, "/home/x"},
+		{`'${PID}'`, "${PID}"},
+		{`"${MISSING}"`, ""},
+		{`"a\nb"`, "a\nb"},
+		{`a\ b`, "a b"},
+		{`cost$`, "cost$"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Word(tt.token, lookup), tt.token)
+	}
+
+	// tanpa lookup variabel tidak di-expand
+	assert.Equal(t, "${PID}", Word(`"${PID}"`, nil))
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader(testDocument))
+	assert.NoError(t, err)
+
+	assert.Len(t, doc.Head, 1)
+	assert.Len(t, doc.Sections, 2)
+	assert.Equal(t, "frontend web", doc.Sections[1].Header())
+	assert.Equal(t, " public", doc.Sections[1].Comment)
+	assert.Len(t, doc.Sections[1].Directives(), 3)
+	assert.Equal(t, 7, doc.Sections[1].Lines[0].LineNo)
+
+	want := `# legacy config
+global
+    log stdout format raw local0 # ke stdout
+    maxconn 4096
+
+frontend web # public
+    bind "${MOX_LISTENER_GATEWAY}"
+    http-request return string "a # b\n" if { path / }
+    # komentar di dalam section
+    acl bad hdr(x) 'it''s'
+`
+	assert.Equal(t, want, doc.String())
+
+	// render -> parse -> render harus stabil
+	again, err := ParseDocument(strings.NewReader(doc.String()))
+	assert.NoError(t, err)
+	assert.Equal(t, doc.String(), again.String())
+}
+
+func TestParseDocumentError(t *testing.T) {
+	_, err := ParseDocument(strings.NewReader("maxconn 10\n"))
+	assert.ErrorContains(t, err, `line 1: directive "maxconn" outside of a section`)
+
+	_, err = ParseDocument(strings.NewReader("global\n  log \"stdout\n"))
+	assert.ErrorContains(t, err, "line 2: unterminated \" quote")
+}
+
+func TestFields(t *testing.T) {
+	lookup := func(name string) (string, bool) { return map[string]string{"PID": "42"}[name], name == "PID" }
+
+	words, err := Fields(`return status 200 hdr X-PID "${PID}" string 'raw ${PID}' # komentar`, lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"return", "status", "200", "hdr", "X-PID", "42", "string", "raw ${PID}"}, words)
+
+	_, err = Fields(`string "open`, lookup)
+	assert.Error(t, err)
+}
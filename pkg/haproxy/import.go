@@ -0,0 +1,321 @@
+package haproxy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// managedSocketOptions is the option the renderer always write for stats socket
+const managedSocketOptions = "mode 660 level admin"
+
+// RawSection is a section the model does not understand, disalin apa adanya
+type RawSection struct {
+	Header string   `json:"header" mapstructure:"header"`
+	Lines  []string `json:"lines" mapstructure:"lines"`
+}
+
+// Warning flag a directive that was imported verbatim instead of into the model
+type Warning struct {
+	LineNo    int
+	Section   string
+	Directive string
+	Reason    string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d [%s] %s: %s", w.LineNo, w.Section, w.Directive, w.Reason)
+}
+
+// Import convert a parsed haproxy.cfg into the model. Directive yang tidak
+// dikenal tidak dibuang: masuk Extra (atau Sections untuk section asing) dan
+// dilaporkan sebagai Warning.
+func Import(doc *Document) (Config, []Warning) {
+	im := &importer{}
+
+	seenGlobal, seenDefaults := false, false
+
+	for _, s := range doc.Sections {
+		switch {
+		case s.Kind == "global" && !seenGlobal:
+			seenGlobal = true
+			im.global(s)
+		case s.Kind == "defaults" && !seenDefaults && len(s.Args) == 0:
+			seenDefaults = true
+			im.defaults(s)
+		case s.Kind == "frontend" && len(s.Args) == 1:
+			im.frontend(s)
+		case s.Kind == "backend" && len(s.Args) == 1:
+			im.backend(s)
+		default:
+			im.raw(s)
+		}
+	}
+
+	return im.cfg, im.warnings
+}
+
+type importer struct {
+	cfg      Config
+	warnings []Warning
+}
+
+func (im *importer) warn(s *Section, l Line, reason string) {
+	im.warnings = append(im.warnings, Warning{
+		LineNo:    l.LineNo,
+		Section:   s.Header(),
+		Directive: l.Text(),
+		Reason:    reason,
+	})
+}
+
+// extra keep the directive verbatim and flag it
+func (im *importer) extra(s *Section, l Line, dst *[]string) {
+	*dst = append(*dst, l.Text())
+	im.warn(s, l, "not modeled, kept verbatim")
+}
+
+func (im *importer) raw(s *Section) {
+	raw := RawSection{Header: s.Header()}
+	for _, l := range s.Directives() {
+		raw.Lines = append(raw.Lines, l.Text())
+	}
+
+	im.cfg.Sections = append(im.cfg.Sections, raw)
+	im.warn(s, Line{Args: []string{s.Kind}, LineNo: s.LineNo}, "section not modeled, kept verbatim")
+}
+
+func (im *importer) global(s *Section) {
+	g := &im.cfg.Global
+
+	for _, l := range s.Directives() {
+		args := l.Args
+
+		switch {
+		case args[0] == "maxconn" && len(args) == 2:
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				im.extra(s, l, &g.Extra)
+				continue
+			}
+
+			g.MaxConn = n
+		case args[0] == "log" && len(args) > 1:
+			g.Log = append(g.Log, join(args[1:]))
+		case is(args, "stats", "socket") && len(args) > 2 && g.StatsSocket == "" && join(args[3:]) == managedSocketOptions:
+			g.StatsSocket = args[2]
+		case is(args, "stats", "timeout") && len(args) == 3:
+			d, err := ParseDuration(args[2])
+			if err != nil {
+				im.extra(s, l, &g.Extra)
+				continue
+			}
+
+			g.StatsTimeout = d
+		default:
+			im.extra(s, l, &g.Extra)
+		}
+	}
+}
+
+func (im *importer) defaults(s *Section) {
+	d := &im.cfg.Defaults
+
+	for _, l := range s.Directives() {
+		args := l.Args
+
+		switch {
+		case args[0] == "mode" && len(args) == 2:
+			d.Mode = args[1]
+		case args[0] == "log" && len(args) > 1:
+			d.Log = join(args[1:])
+		case args[0] == "option" && len(args) > 1:
+			d.Options = append(d.Options, join(args[1:]))
+		case args[0] == "timeout" && len(args) == 3:
+			dst := map[string]*time.Duration{
+				"connect": &d.TimeoutConnect,
+				"client":  &d.TimeoutClient,
+				"server":  &d.TimeoutServer,
+			}[args[1]]
+
+			v, err := ParseDuration(args[2])
+			if dst == nil || err != nil {
+				im.extra(s, l, &d.Extra)
+				continue
+			}
+
+			*dst = v
+		default:
+			im.extra(s, l, &d.Extra)
+		}
+	}
+}
+
+func (im *importer) frontend(s *Section) {
+	f := Frontend{Name: s.Name()}
+
+	for _, l := range s.Directives() {
+		args := l.Args
+
+		switch {
+		case args[0] == "mode" && len(args) == 2:
+			f.Mode = args[1]
+		case args[0] == "bind" && len(args) > 1:
+			f.Binds = append(f.Binds, parseBind(args[1:]))
+		case args[0] == "option" && len(args) > 1:
+			f.Options = append(f.Options, join(args[1:]))
+		case args[0] == "acl" && len(args) > 2:
+			f.ACLs = append(f.ACLs, ACL{Name: args[1], Criterion: join(args[2:])})
+		case args[0] == "http-request" && len(args) > 1:
+			f.HTTPRequest = append(f.HTTPRequest, parseRule(args[1:]))
+		case args[0] == "http-response" && len(args) > 1:
+			f.HTTPResponse = append(f.HTTPResponse, parseRule(args[1:]))
+		case args[0] == "use_backend" && len(args) > 1:
+			r := parseRule(args[1:])
+			f.UseBackends = append(f.UseBackends, UseBackend{Backend: r.Action, Cond: r.Cond})
+		case args[0] == "default_backend" && len(args) == 2:
+			f.DefaultBackend = args[1]
+		default:
+			im.extra(s, l, &f.Extra)
+		}
+	}
+
+	im.cfg.Frontends = append(im.cfg.Frontends, f)
+}
+
+func (im *importer) backend(s *Section) {
+	b := Backend{Name: s.Name()}
+
+	for _, l := range s.Directives() {
+		args := l.Args
+
+		switch {
+		case args[0] == "mode" && len(args) == 2:
+			b.Mode = args[1]
+		case args[0] == "balance" && len(args) > 1:
+			b.Balance = join(args[1:])
+		case args[0] == "option" && len(args) > 1:
+			b.Options = append(b.Options, join(args[1:]))
+		case args[0] == "acl" && len(args) > 2:
+			b.ACLs = append(b.ACLs, ACL{Name: args[1], Criterion: join(args[2:])})
+		case args[0] == "http-request" && len(args) > 1:
+			b.HTTPRequest = append(b.HTTPRequest, parseRule(args[1:]))
+		case args[0] == "server" && len(args) > 2:
+			b.Servers = append(b.Servers, parseServer(args[1:]))
+		default:
+			im.extra(s, l, &b.Extra)
+		}
+	}
+
+	im.cfg.Backends = append(im.cfg.Backends, b)
+}
+
+// parseBind map "${MOX_LISTENER_<NAME>}" back to a mox listener
+func parseBind(args []string) Bind {
+	b := Bind{Address: args[0], Options: join(args[1:])}
+
+	target := Word(args[0], nil)
+	if name, ok := strings.CutPrefix(target, "${MOX_LISTENER_"); ok && strings.HasSuffix(name, "}") {
+		b.Listener = strings.ToLower(strings.TrimSuffix(name, "}"))
+		b.Address = ""
+	}
+
+	return b
+}
+
+// parseRule split "<action...> [if|unless <cond>]", "if" tidak disimpan di Cond
+func parseRule(args []string) Rule {
+	for i, a := range args {
+		switch a {
+		case "if":
+			return Rule{Action: join(args[:i]), Cond: join(args[i+1:])}
+		case "unless":
+			return Rule{Action: join(args[:i]), Cond: join(args[i:])}
+		}
+	}
+
+	return Rule{Action: join(args)}
+}
+
+func parseServer(args []string) Server {
+	s := Server{Name: args[0], Address: args[1]}
+
+	var rest []string
+	for i := 2; i < len(args); i++ {
+		switch {
+		case args[i] == "check":
+			s.Check = true
+		case args[i] == "backup":
+			s.Backup = true
+		case (args[i] == "weight" || args[i] == "maxconn") && i+1 < len(args):
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				rest = append(rest, args[i])
+				continue
+			}
+
+			if args[i] == "weight" {
+				s.Weight = n
+			} else {
+				s.MaxConn = n
+			}
+
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+
+	s.Options = join(rest)
+
+	return s
+}
+
+// ParseDuration parse a HAProxy time value, tanpa unit = milidetik
+func ParseDuration(value string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"us", time.Microsecond},
+		{"ms", time.Millisecond},
+		{"s", time.Second},
+		{"m", time.Minute},
+		{"h", time.Hour},
+		{"d", 24 * time.Hour},
+	}
+
+	unit, number := time.Millisecond, value
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(value, u.suffix); ok {
+			unit, number = u.unit, n
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid haproxy time %q", value)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+func is(args []string, words ...string) bool {
+	if len(args) < len(words) {
+		return false
+	}
+
+	for i, w := range words {
+		if args[i] != w {
+			return false
+		}
+	}
+
+	return true
+}
+
+func join(args []string) string {
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,137 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testImport = `global
+    maxconn 4096
+    log stdout format raw local0
+    daemon
+    stats socket /tmp/haproxy.sock mode 660 level admin
+    stats timeout 2m
+
+defaults
+    mode http
+    option httplog
+    timeout connect 5000
+    retries 3
+
+frontend web
+    bind "${MOX_LISTENER_GATEWAY}" accept-proxy
+    bind :80
+    acl is_api path_beg /api
+    http-request deny if { src 10.0.0.0/8 } !is_api
+    http-response del-header Server unless is_api
+    use_backend api if is_api
+    default_backend api
+    compression algo gzip
+
+backend api
+    balance roundrobin
+    server a1 10.0.0.1:80 check weight 10 inter 2s
+    server a2 10.0.0.2:80 backup
+
+listen stats
+    bind :8404
+    stats enable
+`
+
+func TestImport(t *testing.T) {
+	doc, err := ParseDocument(strings.NewReader(testImport))
+	assert.NoError(t, err)
+
+	cfg, warnings := Import(doc)
+
+	assert.Equal(t, Global{
+		MaxConn:      4096,
+		Log:          []string{"stdout format raw local0"},
+		StatsSocket:  "/tmp/haproxy.sock",
+		StatsTimeout: 2 * time.Minute,
+		Extra:        []string{"daemon"},
+	}, cfg.Global)
+
+	assert.Equal(t, Defaults{
+		Mode:           "http",
+		Options:        []string{"httplog"},
+		TimeoutConnect: 5 * time.Second,
+		Extra:          []string{"retries 3"},
+	}, cfg.Defaults)
+
+	assert.Equal(t, []Frontend{{
+		Name:           "web",
+		Binds:          []Bind{{Listener: "gateway", Options: "accept-proxy"}, {Address: ":80"}},
+		ACLs:           []ACL{{Name: "is_api", Criterion: "path_beg /api"}},
+		HTTPRequest:    []Rule{{Action: "deny", Cond: "{ src 10.0.0.0/8 } !is_api"}},
+		HTTPResponse:   []Rule{{Action: "del-header Server", Cond: "unless is_api"}},
+		UseBackends:    []UseBackend{{Backend: "api", Cond: "is_api"}},
+		DefaultBackend: "api",
+		Extra:          []string{"compression algo gzip"},
+	}}, cfg.Frontends)
+
+	assert.Equal(t, []Server{
+		{Name: "a1", Address: "10.0.0.1:80", Check: true, Weight: 10, Options: "inter 2s"},
+		{Name: "a2", Address: "10.0.0.2:80", Backup: true},
+	}, cfg.Backends[0].Servers)
+
+	assert.Equal(t, []RawSection{{Header: "listen stats", Lines: []string{"bind :8404", "stats enable"}}}, cfg.Sections)
+
+	var flagged []string
+	for _, w := range warnings {
+		flagged = append(flagged, w.Directive)
+	}
+
+	assert.Equal(t, []string{"daemon", "retries 3", "compression algo gzip", "listen"}, flagged)
+	assert.Equal(t, "line 4 [global] daemon: not modeled, kept verbatim", warnings[0].String())
+
+	// hasil import harus bisa di-render lagi tanpa ada directive yang hilang
+	out, err := cfg.Bytes()
+	assert.NoError(t, err)
+
+	for _, line := range []string{
+		"    daemon",
+		"    retries 3",
+		"    compression algo gzip",
+		`    bind "${MOX_LISTENER_GATEWAY}" accept-proxy`,
+		"    http-request deny if { src 10.0.0.0/8 } !is_api",
+		"    http-response del-header Server unless is_api",
+		"    timeout connect 5s",
+		"listen stats",
+		"    stats enable",
+	} {
+		assert.Contains(t, string(out), line+"\n")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "5000", want: 5 * time.Second},
+		{in: "50s", want: 50 * time.Second},
+		{in: "1500ms", want: 1500 * time.Millisecond},
+		{in: "2m", want: 2 * time.Minute},
+		{in: "1h", want: time.Hour},
+		{in: "1d", want: 24 * time.Hour},
+		{in: "100us", want: 100 * time.Microsecond},
+		{in: "5x", err: true},
+		{in: "", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if tt.err {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+}
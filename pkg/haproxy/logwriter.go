@@ -0,0 +1,101 @@
+package haproxy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxLogLine batas satu baris yang ditahan di buffer, sisanya langsung di-flush
+const maxLogLine = 64 * 1024
+
+// noticeLine match "[NOTICE]   (1) : ..." / "[WARNING] ..." / "[ALERT] ..."
+var noticeLine = regexp.MustCompile(`^\[(NOTICE|WARNING|ALERT)\][^:]*:\s*(.*)$`)
+
+// LogWriter is used as cmd.Stdout/cmd.Stderr of haproxy. Tiap baris access log
+// jadi slog record dengan atribut bertipe, baris lain (notice/alert) diteruskan
+// dengan level sesuai prefix-nya.
+type LogWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+
+	mu  *sync.Mutex
+	buf []byte
+}
+
+// NewLogWriter log every line with logger, level dipakai untuk baris tanpa prefix level
+func NewLogWriter(logger *slog.Logger, level slog.Level) *LogWriter {
+	return &LogWriter{logger: logger, level: level, mu: &sync.Mutex{}}
+}
+
+// Write implements io.Writer.
+func (w *LogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.emit(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+
+	if len(w.buf) > maxLogLine {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+
+	return len(p), nil
+}
+
+// Close flush the last line without newline
+func (w *LogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+
+	return nil
+}
+
+func (w *LogWriter) emit(line string) {
+	line = strings.TrimRight(line, "\r ")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+
+	ctx := context.Background()
+
+	if l, ok := ParseAccessLog(line); ok {
+		w.logger.LogAttrs(ctx, l.Level(), "haproxy access", l.Attrs()...)
+		return
+	}
+
+	if m := noticeLine.FindStringSubmatch(line); m != nil {
+		level := slog.LevelInfo
+
+		switch m[1] {
+		case "WARNING":
+			level = slog.LevelWarn
+		case "ALERT":
+			level = slog.LevelError
+		}
+
+		w.logger.LogAttrs(ctx, level, m[2])
+
+		return
+	}
+
+	w.logger.LogAttrs(ctx, w.level, line)
+}
@@ -0,0 +1,75 @@
+package haproxy
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type captureHandler struct {
+	attrs   []slog.Attr
+	records *[]slog.Record
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	r.AddAttrs(h.attrs...)
+	*h.records = append(*h.records, r)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &captureHandler{attrs: append(append([]slog.Attr{}, h.attrs...), attrs...), records: h.records}
+}
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+func recordAttrs(r slog.Record) map[string]slog.Value {
+	out := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		out[a.Key] = a.Value
+		return true
+	})
+
+	return out
+}
+
+func TestLogWriter(t *testing.T) {
+	var records []slog.Record
+	logger := slog.New(&captureHandler{records: &records}).With(slog.Int("worker_pid", 42), slog.Int("generation", 3))
+
+	w := NewLogWriter(logger, slog.LevelError)
+
+	// satu baris bisa datang dalam beberapa write
+	_, _ = w.Write([]byte(`10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 `))
+	assert.Empty(t, records)
+
+	_, _ = w.Write([]byte("200 2750 - - ---- 1/1/1/1/0 0/0 \"GET / HTTP/1.1\"\n[WARNING]  (1) : config : missing timeouts\n\n[NOTICE]   (1) : New worker (2) forked\r\n"))
+	_, _ = w.Write([]byte("something else"))
+	assert.Len(t, records, 3)
+
+	assert.NoError(t, w.Close())
+	assert.Len(t, records, 4)
+
+	access := recordAttrs(records[0])
+	assert.Equal(t, "haproxy access", records[0].Message)
+	assert.Equal(t, slog.LevelInfo, records[0].Level)
+	assert.Equal(t, int64(200), access["status"].Int64())
+	assert.Equal(t, 69*time.Millisecond, access["time_response"].Duration())
+	assert.Equal(t, "static", access["backend"].String())
+	assert.Equal(t, int64(42), access["worker_pid"].Int64())
+	assert.Equal(t, int64(3), access["generation"].Int64())
+
+	assert.Equal(t, "config : missing timeouts", records[1].Message)
+	assert.Equal(t, slog.LevelWarn, records[1].Level)
+
+	assert.Equal(t, "New worker (2) forked", records[2].Message)
+	assert.Equal(t, slog.LevelInfo, records[2].Level)
+
+	assert.Equal(t, "something else", records[3].Message)
+	assert.Equal(t, slog.LevelError, records[3].Level)
+}
@@ -0,0 +1,232 @@
+package haproxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Config is the structured haproxy.cfg, diisi dari section [proxy] di config mox
+type Config struct {
+	Global    Global     `json:"global" mapstructure:"global"`
+	Defaults  Defaults   `json:"defaults" mapstructure:"defaults"`
+	Frontends []Frontend `json:"frontends" mapstructure:"frontends"`
+	Backends  []Backend  `json:"backends" mapstructure:"backends"`
+	// Sections is written verbatim after the backends, hasil import section yang belum dimodelkan
+	Sections []RawSection `json:"sections" mapstructure:"sections"`
+}
+
+type Global struct {
+	MaxConn int      `json:"maxconn" mapstructure:"maxconn"`
+	Log     []string `json:"log" mapstructure:"log"`
+	// StatsSocket is the Runtime API socket, kosong = diisi dari worker.haproxy_socket
+	StatsSocket  string        `json:"stats_socket" mapstructure:"stats_socket"`
+	StatsTimeout time.Duration `json:"stats_timeout" mapstructure:"stats_timeout"`
+	// Extra is copied verbatim, buat keyword yang belum dimodelkan
+	Extra []string `json:"extra" mapstructure:"extra"`
+}
+
+type Defaults struct {
+	Mode           string        `json:"mode" mapstructure:"mode"`
+	Log            string        `json:"log" mapstructure:"log"`
+	Options        []string      `json:"options" mapstructure:"options"`
+	TimeoutConnect time.Duration `json:"timeout_connect" mapstructure:"timeout_connect"`
+	TimeoutClient  time.Duration `json:"timeout_client" mapstructure:"timeout_client"`
+	TimeoutServer  time.Duration `json:"timeout_server" mapstructure:"timeout_server"`
+	Extra          []string      `json:"extra" mapstructure:"extra"`
+}
+
+type Frontend struct {
+	Name           string       `json:"name" mapstructure:"name"`
+	Mode           string       `json:"mode" mapstructure:"mode"`
+	Binds          []Bind       `json:"binds" mapstructure:"binds"`
+	Options        []string     `json:"options" mapstructure:"options"`
+	ACLs           []ACL        `json:"acls" mapstructure:"acls"`
+	HTTPRequest    []Rule       `json:"http_request" mapstructure:"http_request"`
+	HTTPResponse   []Rule       `json:"http_response" mapstructure:"http_response"`
+	UseBackends    []UseBackend `json:"use_backends" mapstructure:"use_backends"`
+	DefaultBackend string       `json:"default_backend" mapstructure:"default_backend"`
+	Extra          []string     `json:"extra" mapstructure:"extra"`
+}
+
+// Bind is either a mox listener (diteruskan lewat fd@N) or a raw HAProxy address
+type Bind struct {
+	Listener string `json:"listener" mapstructure:"listener"`
+	Address  string `json:"address" mapstructure:"address"`
+	Options  string `json:"options" mapstructure:"options"`
+}
+
+type ACL struct {
+	Name      string `json:"name" mapstructure:"name"`
+	Criterion string `json:"criterion" mapstructure:"criterion"`
+}
+
+// Rule is one http-request / http-response line, Cond tanpa "if" = dianggap "if"
+type Rule struct {
+	Action string `json:"action" mapstructure:"action"`
+	Cond   string `json:"cond" mapstructure:"cond"`
+}
+
+type UseBackend struct {
+	Backend string `json:"backend" mapstructure:"backend"`
+	Cond    string `json:"cond" mapstructure:"cond"`
+}
+
+type Backend struct {
+	Name        string   `json:"name" mapstructure:"name"`
+	Mode        string   `json:"mode" mapstructure:"mode"`
+	Balance     string   `json:"balance" mapstructure:"balance"`
+	Options     []string `json:"options" mapstructure:"options"`
+	ACLs        []ACL    `json:"acls" mapstructure:"acls"`
+	HTTPRequest []Rule   `json:"http_request" mapstructure:"http_request"`
+	Servers     []Server `json:"servers" mapstructure:"servers"`
+	Extra       []string `json:"extra" mapstructure:"extra"`
+}
+
+type Server struct {
+	Name    string `json:"name" mapstructure:"name"`
+	Address string `json:"address" mapstructure:"address"`
+	Check   bool   `json:"check" mapstructure:"check"`
+	Weight  int    `json:"weight" mapstructure:"weight"`
+	MaxConn int    `json:"maxconn" mapstructure:"maxconn"`
+	Backup  bool   `json:"backup" mapstructure:"backup"`
+	Options string `json:"options" mapstructure:"options"`
+}
+
+// Empty true kalau section [proxy] tidak diisi, haproxy.cfg manual yang dipakai
+func (c Config) Empty() bool {
+	return len(c.Frontends) == 0 && len(c.Backends) == 0 && len(c.Sections) == 0
+}
+
+// ListenerEnv return the env var HAProxy use to bind the named listener,
+// misal "admin-v6" jadi MOX_LISTENER_ADMIN_V6.
+func ListenerEnv(name string) string {
+	key := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+
+		return '_'
+	}, name)
+
+	return "MOX_LISTENER_" + key
+}
+
+// Validate check names and references so the rendered file can be loaded
+func (c Config) Validate() error {
+	var errs []error
+
+	check := func(where, value string) {
+		if strings.ContainsAny(value, "\r\n") {
+			errs = append(errs, fmt.Errorf("%s: line break is not allowed", where))
+		}
+	}
+
+	backends := make(map[string]struct{}, len(c.Backends))
+	for _, b := range c.Backends {
+		if b.Name == "" {
+			errs = append(errs, errors.New("backend name is required"))
+			continue
+		}
+
+		if _, ok := backends[b.Name]; ok {
+			errs = append(errs, fmt.Errorf("duplicate backend %q", b.Name))
+		}
+
+		backends[b.Name] = struct{}{}
+
+		servers := make(map[string]struct{}, len(b.Servers))
+		for _, s := range b.Servers {
+			where := fmt.Sprintf("backend %s server %s", b.Name, s.Name)
+
+			if s.Name == "" || s.Address == "" {
+				errs = append(errs, fmt.Errorf("%s: name and address are required", where))
+			}
+
+			if _, ok := servers[s.Name]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate server", where))
+			}
+
+			servers[s.Name] = struct{}{}
+			check(where, s.Address+s.Options)
+		}
+
+		for _, r := range b.HTTPRequest {
+			check("backend "+b.Name, r.Action+r.Cond)
+		}
+
+		for _, a := range b.ACLs {
+			check("backend "+b.Name, a.Name+a.Criterion)
+		}
+
+		check("backend "+b.Name, strings.Join(append(b.Options, b.Extra...), "")+b.Mode+b.Balance)
+	}
+
+	frontends := make(map[string]struct{}, len(c.Frontends))
+	for _, f := range c.Frontends {
+		where := "frontend " + f.Name
+
+		if f.Name == "" {
+			errs = append(errs, errors.New("frontend name is required"))
+			continue
+		}
+
+		if _, ok := frontends[f.Name]; ok {
+			errs = append(errs, fmt.Errorf("duplicate frontend %q", f.Name))
+		}
+
+		frontends[f.Name] = struct{}{}
+
+		if len(f.Binds) == 0 {
+			errs = append(errs, fmt.Errorf("%s: at least one bind is required", where))
+		}
+
+		for _, b := range f.Binds {
+			if (b.Listener == "") == (b.Address == "") {
+				errs = append(errs, fmt.Errorf("%s: bind needs exactly one of listener or address", where))
+			}
+
+			check(where, b.Listener+b.Address+b.Options)
+		}
+
+		if f.DefaultBackend != "" {
+			if _, ok := backends[f.DefaultBackend]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown default_backend %q", where, f.DefaultBackend))
+			}
+		}
+
+		for _, u := range f.UseBackends {
+			// nama dinamis seperti %[req.hdr(host)] baru diketahui saat runtime
+			if _, ok := backends[u.Backend]; !ok && !strings.Contains(u.Backend, "%[") {
+				errs = append(errs, fmt.Errorf("%s: unknown use_backend %q", where, u.Backend))
+			}
+
+			check(where, u.Cond)
+		}
+
+		for _, r := range append(append([]Rule{}, f.HTTPRequest...), f.HTTPResponse...) {
+			check(where, r.Action+r.Cond)
+		}
+
+		for _, a := range f.ACLs {
+			check(where, a.Name+a.Criterion)
+		}
+
+		check(where, strings.Join(append(f.Options, f.Extra...), "")+f.Mode)
+	}
+
+	for _, raw := range c.Sections {
+		if raw.Header == "" {
+			errs = append(errs, errors.New("raw section header is required"))
+		}
+
+		check("section "+raw.Header, raw.Header+strings.Join(raw.Lines, ""))
+	}
+
+	check("global", strings.Join(append(c.Global.Log, c.Global.Extra...), "")+c.Global.StatsSocket)
+	check("defaults", strings.Join(append(c.Defaults.Options, c.Defaults.Extra...), "")+c.Defaults.Mode+c.Defaults.Log)
+
+	return errors.Join(errs...)
+}
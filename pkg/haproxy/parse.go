@@ -0,0 +1,370 @@
+package haproxy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Info is the output of `show info`, nama field persis seperti di HAProxy
+type Info map[string]string
+
+// ParseInfo read the `Name: value` lines of `show info`
+func ParseInfo(r io.Reader) (Info, error) {
+	info := make(Info)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		info[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return info, scanner.Err()
+}
+
+// Int return a numeric field, 0 kalau tidak ada atau bukan angka
+func (i Info) Int(name string) int64 {
+	n, _ := strconv.ParseInt(i[name], 10, 64)
+
+	return n
+}
+
+// StatType is the `type` column of `show stat`
+type StatType int
+
+const (
+	TypeFrontend StatType = iota
+	TypeBackend
+	TypeServer
+	TypeListener
+)
+
+func (t StatType) String() string {
+	switch t {
+	case TypeFrontend:
+		return "frontend"
+	case TypeBackend:
+		return "backend"
+	case TypeServer:
+		return "server"
+	case TypeListener:
+		return "listener"
+	default:
+		return "unknown"
+	}
+}
+
+// Stat is one row of `show stat`. Kolom yang tidak dipetakan tetap ada di Fields.
+type Stat struct {
+	ProxyName   string   `json:"pxname"`
+	ServiceName string   `json:"svname"`
+	Type        StatType `json:"type"`
+	Status      string   `json:"status"`
+	CheckStatus string   `json:"check_status,omitempty"`
+
+	QCur       int64 `json:"qcur"`
+	SCur       int64 `json:"scur"`
+	SMax       int64 `json:"smax"`
+	SLim       int64 `json:"slim"`
+	STot       int64 `json:"stot"`
+	BytesIn    int64 `json:"bin"`
+	BytesOut   int64 `json:"bout"`
+	DeniedReq  int64 `json:"dreq"`
+	DeniedResp int64 `json:"dresp"`
+	ErrorsReq  int64 `json:"ereq"`
+	ErrorsConn int64 `json:"econ"`
+	ErrorsResp int64 `json:"eresp"`
+	Weight     int64 `json:"weight"`
+	LastChange int64 `json:"lastchg"`
+	Rate       int64 `json:"rate"`
+	ReqRate    int64 `json:"req_rate"`
+	ReqTot     int64 `json:"req_tot"`
+	Hrsp1xx    int64 `json:"hrsp_1xx"`
+	Hrsp2xx    int64 `json:"hrsp_2xx"`
+	Hrsp3xx    int64 `json:"hrsp_3xx"`
+	Hrsp4xx    int64 `json:"hrsp_4xx"`
+	Hrsp5xx    int64 `json:"hrsp_5xx"`
+	// rata-rata waktu dalam ms, HAProxy menghitungnya dari 1024 request terakhir
+	QTime int64 `json:"qtime"`
+	CTime int64 `json:"ctime"`
+	RTime int64 `json:"rtime"`
+	TTime int64 `json:"ttime"`
+
+	Fields map[string]string `json:"-"`
+}
+
+func (s *Stat) ints() map[string]*int64 {
+	return map[string]*int64{
+		"qcur":     &s.QCur,
+		"scur":     &s.SCur,
+		"smax":     &s.SMax,
+		"slim":     &s.SLim,
+		"stot":     &s.STot,
+		"bin":      &s.BytesIn,
+		"bout":     &s.BytesOut,
+		"dreq":     &s.DeniedReq,
+		"dresp":    &s.DeniedResp,
+		"ereq":     &s.ErrorsReq,
+		"econ":     &s.ErrorsConn,
+		"eresp":    &s.ErrorsResp,
+		"weight":   &s.Weight,
+		"lastchg":  &s.LastChange,
+		"rate":     &s.Rate,
+		"req_rate": &s.ReqRate,
+		"req_tot":  &s.ReqTot,
+		"hrsp_1xx": &s.Hrsp1xx,
+		"hrsp_2xx": &s.Hrsp2xx,
+		"hrsp_3xx": &s.Hrsp3xx,
+		"hrsp_4xx": &s.Hrsp4xx,
+		"hrsp_5xx": &s.Hrsp5xx,
+		"qtime":    &s.QTime,
+		"ctime":    &s.CTime,
+		"rtime":    &s.RTime,
+		"ttime":    &s.TTime,
+	}
+}
+
+// ParseStat read the CSV of `show stat`, header diawali "# "
+func ParseStat(r io.Reader) ([]Stat, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty show stat output")
+		}
+
+		return nil, err
+	}
+
+	if len(header) == 0 || !strings.HasPrefix(header[0], "# ") {
+		return nil, fmt.Errorf("unexpected show stat header %q", strings.Join(header, ","))
+	}
+
+	header[0] = strings.TrimPrefix(header[0], "# ")
+
+	stats := []Stat{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		s := Stat{Fields: make(map[string]string, len(header))}
+		ints := s.ints()
+
+		for i, value := range record {
+			if i >= len(header) || header[i] == "" {
+				continue
+			}
+
+			name := header[i]
+			s.Fields[name] = value
+
+			switch name {
+			case "pxname":
+				s.ProxyName = value
+			case "svname":
+				s.ServiceName = value
+			case "status":
+				s.Status = value
+			case "check_status":
+				s.CheckStatus = value
+			case "type":
+				t, _ := strconv.Atoi(value)
+				s.Type = StatType(t)
+			default:
+				if dst, ok := ints[name]; ok {
+					*dst, _ = strconv.ParseInt(value, 10, 64)
+				}
+			}
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// admin state bit `show servers state`, lihat srv_admin_state di management.txt
+const (
+	adminForcedMaint    = 0x01
+	adminInheritedMaint = 0x02
+	adminConfigMaint    = 0x04
+	adminForcedDrain    = 0x08
+	adminInheritedDrain = 0x10
+	adminResolveMaint   = 0x20
+)
+
+// ServerStatus is one row of `show servers state`
+type ServerStatus struct {
+	BackendID   int    `json:"be_id"`
+	Backend     string `json:"be_name"`
+	ServerID    int    `json:"srv_id"`
+	Server      string `json:"srv_name"`
+	Addr        string `json:"srv_addr"`
+	Port        int    `json:"srv_port"`
+	OpState     int    `json:"srv_op_state"`    // 0 stopped, 1 starting, 2 running, 3 stopping
+	AdminState  int    `json:"srv_admin_state"` // bitfield maint/drain
+	UserWeight  int    `json:"srv_uweight"`
+	InitWeight  int    `json:"srv_iweight"`
+	LastChange  int64  `json:"srv_time_since_last_change"`
+	CheckStatus int    `json:"srv_check_status"`
+	CheckHealth int    `json:"srv_check_health"`
+
+	Fields map[string]string `json:"-"`
+}
+
+// Up true kalau server running dan tidak maint
+func (s ServerStatus) Up() bool {
+	return s.OpState == 2 && !s.Maint()
+}
+
+// Maint true kalau server di maintenance, manual maupun warisan
+func (s ServerStatus) Maint() bool {
+	return s.AdminState&(adminForcedMaint|adminInheritedMaint|adminConfigMaint|adminResolveMaint) != 0
+}
+
+// Draining true kalau server di-drain
+func (s ServerStatus) Draining() bool {
+	return s.AdminState&(adminForcedDrain|adminInheritedDrain) != 0
+}
+
+// ParseServersState read `show servers state`. Baris pertama versi format,
+// baris kedua header "# be_id be_name ...", kolom dipisah spasi.
+func ParseServersState(r io.Reader) ([]ServerStatus, error) {
+	var header []string
+
+	servers := []ServerStatus{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			header = strings.Fields(strings.TrimPrefix(line, "#"))
+			continue
+		}
+
+		if header == nil {
+			// baris versi
+			continue
+		}
+
+		s := ServerStatus{Fields: make(map[string]string, len(header))}
+		ints := map[string]*int{
+			"be_id":            &s.BackendID,
+			"srv_id":           &s.ServerID,
+			"srv_port":         &s.Port,
+			"srv_op_state":     &s.OpState,
+			"srv_admin_state":  &s.AdminState,
+			"srv_uweight":      &s.UserWeight,
+			"srv_iweight":      &s.InitWeight,
+			"srv_check_status": &s.CheckStatus,
+			"srv_check_health": &s.CheckHealth,
+		}
+
+		for i, value := range strings.Fields(line) {
+			if i >= len(header) {
+				break
+			}
+
+			name := header[i]
+			s.Fields[name] = value
+
+			switch name {
+			case "be_name":
+				s.Backend = value
+			case "srv_name":
+				s.Server = value
+			case "srv_addr":
+				s.Addr = value
+			case "srv_time_since_last_change":
+				s.LastChange, _ = strconv.ParseInt(value, 10, 64)
+			default:
+				if dst, ok := ints[name]; ok {
+					*dst, _ = strconv.Atoi(value)
+				}
+			}
+		}
+
+		servers = append(servers, s)
+	}
+
+	return servers, scanner.Err()
+}
+
+// Session is one line of `show sess`
+type Session struct {
+	ID       string `json:"id"`
+	Proto    string `json:"proto"`
+	Source   string `json:"src"`
+	Frontend string `json:"fe"`
+	Backend  string `json:"be"`
+	Server   string `json:"srv"`
+	Age      string `json:"age"`
+
+	Fields map[string]string `json:"-"`
+}
+
+// ParseSess read `show sess`, format tiap baris "0x55d...: proto=tcpv4 src=... fe=... be=..."
+func ParseSess(r io.Reader) ([]Session, error) {
+	sessions := []Session{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		id, rest, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ": ")
+		if !ok || !strings.HasPrefix(id, "0x") {
+			continue
+		}
+
+		s := Session{ID: id, Fields: make(map[string]string)}
+
+		for _, token := range strings.Fields(rest) {
+			key, value, ok := strings.Cut(token, "=")
+			// token seperti rq[f=...] isinya detail buffer, dilewati
+			if !ok || strings.ContainsAny(key, "[]") {
+				continue
+			}
+
+			s.Fields[key] = value
+
+			switch key {
+			case "proto":
+				s.Proto = value
+			case "src":
+				s.Source = value
+			case "fe":
+				s.Frontend = value
+			case "be":
+				s.Backend = value
+			case "srv":
+				s.Server = value
+			case "age":
+				s.Age = value
+			}
+		}
+
+		sessions = append(sessions, s)
+	}
+
+	return sessions, scanner.Err()
+}
@@ -0,0 +1,99 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInfo(t *testing.T) {
+	info, err := ParseInfo(strings.NewReader("Name: HAProxy\nVersion: 2.8.3\nCurrConns: 7\nUptime_sec: 42\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2.8.3", info["Version"])
+	assert.Equal(t, int64(7), info.Int("CurrConns"))
+	assert.Equal(t, int64(0), info.Int("Missing"))
+}
+
+func TestParseStat(t *testing.T) {
+	out := `# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,hanafail,req_rate,req_rate_max,req_tot,
+gateway,FRONTEND,,,3,10,2000,120,4096,8192,0,0,1,,,,,OPEN,,,,,,,,,1,2,0,,,,0,5,0,9,,,,0,100,0,4,2,0,,5,9,106,
+web,s1,0,0,1,4,,60,2048,4096,,0,,0,0,0,0,UP,1,1,0,0,0,30,0,,1,3,1,,60,,2,2,,5,L4OK,,0,0,50,0,1,0,0,,,,,
+`
+
+	stats, err := ParseStat(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	fe := stats[0]
+	assert.Equal(t, "gateway", fe.ProxyName)
+	assert.Equal(t, "FRONTEND", fe.ServiceName)
+	assert.Equal(t, TypeFrontend, fe.Type)
+	assert.Equal(t, "OPEN", fe.Status)
+	assert.Equal(t, int64(3), fe.SCur)
+	assert.Equal(t, int64(2000), fe.SLim)
+	assert.Equal(t, int64(100), fe.Hrsp2xx)
+	assert.Equal(t, int64(106), fe.ReqTot)
+
+	srv := stats[1]
+	assert.Equal(t, TypeServer, srv.Type)
+	assert.Equal(t, "UP", srv.Status)
+	assert.Equal(t, "L4OK", srv.CheckStatus)
+	assert.Equal(t, int64(1), srv.Weight)
+	assert.Equal(t, "3", srv.Fields["iid"])
+
+	_, err = ParseStat(strings.NewReader("Unknown command\n"))
+	assert.Error(t, err)
+}
+
+func TestParseServersState(t *testing.T) {
+	out := `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight srv_iweight srv_time_since_last_change srv_check_status srv_check_result srv_check_health srv_check_state srv_agent_state bk_f_forced_id srv_f_forced_id srv_fqdn srv_port srvrecord
+3 web 1 s1 10.0.0.1 2 0 1 1 120 6 3 4 6 0 0 0 - 8080 -
+3 web 2 s2 10.0.0.2 2 8 1 1 10 6 3 4 6 0 0 0 - 8080 -
+3 web 3 s3 10.0.0.3 0 1 1 1 5 6 3 0 6 0 0 0 - 8080 -
+`
+
+	servers, err := ParseServersState(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, servers, 3)
+
+	assert.Equal(t, "web", servers[0].Backend)
+	assert.Equal(t, "s1", servers[0].Server)
+	assert.Equal(t, "10.0.0.1", servers[0].Addr)
+	assert.Equal(t, 8080, servers[0].Port)
+	assert.Equal(t, int64(120), servers[0].LastChange)
+	assert.True(t, servers[0].Up())
+
+	assert.True(t, servers[1].Draining())
+	assert.True(t, servers[1].Up())
+
+	assert.True(t, servers[2].Maint())
+	assert.False(t, servers[2].Up())
+}
+
+func TestParseSess(t *testing.T) {
+	out := `0x55d7c5a8b0e0: proto=tcpv4 src=127.0.0.1:48392 fe=gateway be=web srv=s1 ts=00 epoch=0 age=2s calls=2 rate=0 cpu=0 lat=0 rq[f=848000h,i=0,an=00h,rx=,wx=,ax=] rp[f=80048000h,i=0,an=00h,rx=,wx=,ax=] scf=[8,200h,fd=12,rex=,wex=] scb=[8,1h,fd=-1,rex=,wex=] exp=
+0x55d7c5a8c000: proto=unix_stream src=unix:1 fe=GLOBAL be=<NONE> srv=<none> ts=00 epoch=0x1 age=0s calls=1 rate=1 cpu=0 lat=0 rq[f=c48200h,i=0,an=00h,rx=,wx=,ax=] exp=
+`
+
+	sessions, err := ParseSess(strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	assert.Equal(t, Session{
+		ID:       "0x55d7c5a8b0e0",
+		Proto:    "tcpv4",
+		Source:   "127.0.0.1:48392",
+		Frontend: "gateway",
+		Backend:  "web",
+		Server:   "s1",
+		Age:      "2s",
+		Fields:   sessions[0].Fields,
+	}, sessions[0])
+	assert.Equal(t, "2", sessions[0].Fields["calls"])
+	assert.NotContains(t, sessions[0].Fields, "rq[f")
+
+	assert.Equal(t, "GLOBAL", sessions[1].Frontend)
+}
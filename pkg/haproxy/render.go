@@ -0,0 +1,284 @@
+package haproxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// header ditulis di baris pertama file hasil render
+const renderHeader = "# generated by mox from the [proxy] section, do not edit"
+
+// Render write c as haproxy.cfg. Output deterministik: urutan section dan
+// baris selalu sama untuk Config yang sama, jadi hasilnya bisa di-diff/hash.
+func (c Config) Render(w io.Writer) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	r := &renderer{w: bw}
+
+	r.line(renderHeader)
+	r.global(c.Global)
+	r.defaults(c.Defaults)
+
+	for _, f := range c.Frontends {
+		r.frontend(f)
+	}
+
+	for _, b := range c.Backends {
+		r.backend(b)
+	}
+
+	for _, raw := range c.Sections {
+		r.section(raw.Header)
+
+		for _, l := range raw.Lines {
+			r.kw(l)
+		}
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return bw.Flush()
+}
+
+// Bytes render c into memory
+func (c Config) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.Render(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+type renderer struct {
+	w   io.Writer
+	err error
+}
+
+func (r *renderer) line(format string, args ...any) {
+	if r.err != nil {
+		return
+	}
+
+	_, r.err = fmt.Fprintf(r.w, format+"\n", args...)
+}
+
+func (r *renderer) section(name string) {
+	r.line("")
+	r.line("%s", name)
+}
+
+// kw write one indented keyword line, argumen kosong dibuang
+func (r *renderer) kw(parts ...string) {
+	words := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			words = append(words, p)
+		}
+	}
+
+	if len(words) == 0 {
+		return
+	}
+
+	r.line("    %s", strings.Join(words, " "))
+}
+
+func (r *renderer) global(g Global) {
+	r.section("global")
+
+	if g.MaxConn > 0 {
+		r.kw("maxconn", strconv.Itoa(g.MaxConn))
+	}
+
+	for _, l := range g.Log {
+		r.kw("log", l)
+	}
+
+	if g.StatsSocket != "" {
+		r.kw("stats socket", g.StatsSocket, "mode 660 level admin")
+	}
+
+	if g.StatsTimeout > 0 {
+		r.kw("stats timeout", FormatDuration(g.StatsTimeout))
+	}
+
+	for _, e := range g.Extra {
+		r.kw(e)
+	}
+}
+
+func (r *renderer) defaults(d Defaults) {
+	r.section("defaults")
+
+	r.mode(d.Mode)
+
+	if d.Log != "" {
+		r.kw("log", d.Log)
+	}
+
+	r.options(d.Options)
+
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"connect", d.TimeoutConnect},
+		{"client", d.TimeoutClient},
+		{"server", d.TimeoutServer},
+	} {
+		if t.d > 0 {
+			r.kw("timeout", t.name, FormatDuration(t.d))
+		}
+	}
+
+	for _, e := range d.Extra {
+		r.kw(e)
+	}
+}
+
+func (r *renderer) frontend(f Frontend) {
+	r.section("frontend " + f.Name)
+
+	r.mode(f.Mode)
+
+	for _, b := range f.Binds {
+		r.kw("bind", b.Target(), b.Options)
+	}
+
+	r.options(f.Options)
+	r.acls(f.ACLs)
+	r.rules("http-request", f.HTTPRequest)
+	r.rules("http-response", f.HTTPResponse)
+
+	for _, u := range f.UseBackends {
+		r.kw("use_backend", u.Backend, cond(u.Cond))
+	}
+
+	if f.DefaultBackend != "" {
+		r.kw("default_backend", f.DefaultBackend)
+	}
+
+	for _, e := range f.Extra {
+		r.kw(e)
+	}
+}
+
+func (r *renderer) backend(b Backend) {
+	r.section("backend " + b.Name)
+
+	r.mode(b.Mode)
+
+	if b.Balance != "" {
+		r.kw("balance", b.Balance)
+	}
+
+	r.options(b.Options)
+	r.acls(b.ACLs)
+	r.rules("http-request", b.HTTPRequest)
+
+	for _, s := range b.Servers {
+		r.kw(s.Line())
+	}
+
+	for _, e := range b.Extra {
+		r.kw(e)
+	}
+}
+
+func (r *renderer) mode(mode string) {
+	if mode != "" {
+		r.kw("mode", mode)
+	}
+}
+
+func (r *renderer) options(options []string) {
+	for _, o := range options {
+		r.kw("option", o)
+	}
+}
+
+func (r *renderer) acls(acls []ACL) {
+	for _, a := range acls {
+		r.kw("acl", a.Name, a.Criterion)
+	}
+}
+
+func (r *renderer) rules(keyword string, rules []Rule) {
+	for _, rule := range rules {
+		r.kw(keyword, rule.Action, cond(rule.Cond))
+	}
+}
+
+// Target return the bind address, listener mox jadi "${MOX_LISTENER_<NAME>}"
+// yang di-expand HAProxy dari env worker (fd@N).
+func (b Bind) Target() string {
+	if b.Listener != "" {
+		return strconv.Quote("${" + ListenerEnv(b.Listener) + "}")
+	}
+
+	return b.Address
+}
+
+// Line return the `server` line of s
+func (s Server) Line() string {
+	parts := []string{"server", s.Name, s.Address}
+
+	if s.Check {
+		parts = append(parts, "check")
+	}
+
+	if s.Weight > 0 {
+		parts = append(parts, "weight", strconv.Itoa(s.Weight))
+	}
+
+	if s.MaxConn > 0 {
+		parts = append(parts, "maxconn", strconv.Itoa(s.MaxConn))
+	}
+
+	if s.Backup {
+		parts = append(parts, "backup")
+	}
+
+	if s.Options != "" {
+		parts = append(parts, s.Options)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// cond prefix the condition with "if" kalau belum ada if/unless
+func cond(c string) string {
+	c = strings.TrimSpace(c)
+	if c == "" || strings.HasPrefix(c, "if ") || strings.HasPrefix(c, "unless ") {
+		return c
+	}
+
+	return "if " + c
+}
+
+// FormatDuration format d in the biggest unit HAProxy understands without losing precision
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	case d%time.Millisecond == 0:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	default:
+		return strconv.FormatInt(int64(d/time.Microsecond), 10) + "us"
+	}
+}
@@ -0,0 +1,185 @@
+package haproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testConfig() Config {
+	return Config{
+		Global: Global{
+			MaxConn:      2000,
+			Log:          []string{"stdout format raw local0"},
+			StatsSocket:  "/tmp/haproxy_1.sock",
+			StatsTimeout: 30 * time.Second,
+		},
+		Defaults: Defaults{
+			Mode:           "http",
+			Log:            "global",
+			Options:        []string{"httplog", "forwardfor"},
+			TimeoutConnect: 5 * time.Second,
+			TimeoutClient:  1500 * time.Millisecond,
+			TimeoutServer:  time.Minute,
+		},
+		Frontends: []Frontend{
+			{
+				Name:  "gateway",
+				Binds: []Bind{{Listener: "gateway"}, {Address: ":8080", Options: "accept-proxy"}},
+				ACLs:  []ACL{{Name: "is_api", Criterion: "path_beg /api"}},
+				HTTPRequest: []Rule{
+					{Action: "deny", Cond: "!is_api"},
+					{Action: "set-header X-Api 1", Cond: "unless !is_api"},
+				},
+				HTTPResponse:   []Rule{{Action: `set-header X-Managed-By "Mox-Master"`}},
+				UseBackends:    []UseBackend{{Backend: "api", Cond: "is_api"}},
+				DefaultBackend: "web",
+			},
+		},
+		Backends: []Backend{
+			{
+				Name:    "api",
+				Balance: "roundrobin",
+				Options: []string{"httpchk GET /health"},
+				Servers: []Server{
+					{Name: "a1", Address: "10.0.0.1:80", Check: true, Weight: 10, MaxConn: 100},
+					{Name: "a2", Address: "10.0.0.2:80", Backup: true, Options: "inter 2s"},
+				},
+			},
+			{Name: "web", Mode: "http", Extra: []string{"http-reuse safe"}},
+		},
+	}
+}
+
+const testRendered = `# generated by mox from the [proxy] section, do not edit
+
+global
+    maxconn 2000
+    log stdout format raw local0
+    stats socket /tmp/haproxy_1.sock mode 660 level admin
+    stats timeout 30s
+
+defaults
+    mode http
+    log global
+    option httplog
+    option forwardfor
+    timeout connect 5s
+    timeout client 1500ms
+    timeout server 1m
+
+frontend gateway
+    bind "${MOX_LISTENER_GATEWAY}"
+    bind :8080 accept-proxy
+    acl is_api path_beg /api
+    http-request deny if !is_api
+    http-request set-header X-Api 1 unless !is_api
+    http-response set-header X-Managed-By "Mox-Master"
+    use_backend api if is_api
+    default_backend web
+
+backend api
+    balance roundrobin
+    option httpchk GET /health
+    server a1 10.0.0.1:80 check weight 10 maxconn 100
+    server a2 10.0.0.2:80 backup inter 2s
+
+backend web
+    mode http
+    http-reuse safe
+`
+
+func TestConfigRender(t *testing.T) {
+	first, err := testConfig().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, testRendered, string(first))
+
+	// render ulang harus byte-per-byte sama
+	second, err := testConfig().Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *Config) {},
+		},
+		{
+			name:   "unknown default backend",
+			modify: func(c *Config) { c.Frontends[0].DefaultBackend = "missing" },
+			err:    `frontend gateway: unknown default_backend "missing"`,
+		},
+		{
+			name:   "unknown use_backend",
+			modify: func(c *Config) { c.Frontends[0].UseBackends[0].Backend = "missing" },
+			err:    `frontend gateway: unknown use_backend "missing"`,
+		},
+		{
+			name:   "duplicate backend",
+			modify: func(c *Config) { c.Backends[1].Name = "api" },
+			err:    `duplicate backend "api"`,
+		},
+		{
+			name:   "bind without target",
+			modify: func(c *Config) { c.Frontends[0].Binds[0] = Bind{} },
+			err:    "frontend gateway: bind needs exactly one of listener or address",
+		},
+		{
+			name:   "server without address",
+			modify: func(c *Config) { c.Backends[0].Servers[0].Address = "" },
+			err:    "backend api server a1: name and address are required",
+		},
+		{
+			name:   "line break injection",
+			modify: func(c *Config) { c.Frontends[0].HTTPRequest[0].Action = "deny\n    bind :9999" },
+			err:    "frontend gateway: line break is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			tt.modify(&c)
+
+			err := c.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.err)
+
+			_, err = c.Bytes()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{2 * time.Hour, "2h"},
+		{90 * time.Minute, "90m"},
+		{50 * time.Second, "50s"},
+		{1500 * time.Millisecond, "1500ms"},
+		{250 * time.Microsecond, "250us"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, FormatDuration(tt.in))
+	}
+}
+
+func TestListenerEnv(t *testing.T) {
+	assert.Equal(t, "MOX_LISTENER_GATEWAY", ListenerEnv("gateway"))
+	assert.Equal(t, "MOX_LISTENER_ADMIN_V6", ListenerEnv("admin-v6"))
+}
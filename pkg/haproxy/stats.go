@@ -0,0 +1,141 @@
+package haproxy
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// statColumns is the column order of WriteStat, urutan sama seperti `show stat`
+var statColumns = []string{
+	"pxname", "svname", "qcur", "scur", "smax", "slim", "stot", "bin", "bout",
+	"dreq", "dresp", "ereq", "econ", "eresp", "status", "weight", "lastchg",
+	"type", "rate", "check_status", "hrsp_1xx", "hrsp_2xx", "hrsp_3xx",
+	"hrsp_4xx", "hrsp_5xx", "req_rate", "req_tot", "qtime", "ctime", "rtime",
+	"ttime",
+}
+
+// statusRank order the status column from healthy to unhealthy, dipakai
+// waktu merge: kalau process beda pendapat, ambil yang paling buruk
+var statusRank = map[string]int{
+	"OPEN":     0,
+	"UP":       0,
+	"no check": 0,
+	"DRAIN":    2,
+	"NOLB":     2,
+	"MAINT":    3,
+	"DOWN":     4,
+}
+
+func rank(status string) int {
+	if r, ok := statusRank[status]; ok {
+		return r
+	}
+
+	// UP 1/3, DOWN 1/2 dan sejenisnya (transisi check)
+	return 1
+}
+
+// MergeStats merge the `show stat` rows of several HAProxy processes per
+// proxy and service. Counter dan rate dijumlah, waktu rata-rata dibobot
+// jumlah session, weight diambil yang terbesar, lastchg yang terbaru dan
+// status yang paling buruk. Urutan baris mengikuti kemunculan pertama, jadi
+// sama seperti output `show stat` biasa.
+func MergeStats(samples ...[]Stat) []Stat {
+	type key struct{ proxy, service string }
+
+	merged := []Stat{}
+	index := make(map[key]int)
+
+	for _, rows := range samples {
+		for _, row := range rows {
+			k := key{row.ProxyName, row.ServiceName}
+
+			i, ok := index[k]
+			if !ok {
+				row.Fields = nil
+				index[k] = len(merged)
+				merged = append(merged, row)
+				continue
+			}
+
+			merged[i].add(row)
+		}
+	}
+
+	return merged
+}
+
+func (s *Stat) add(o Stat) {
+	dst, src := s.ints(), o.ints()
+	for name, v := range src {
+		switch name {
+		case "weight":
+			*dst[name] = max(*dst[name], *v)
+		case "lastchg":
+			*dst[name] = min(*dst[name], *v)
+		case "qtime", "ctime", "rtime", "ttime":
+			// stot belum dijumlah di sini, bobotnya masih per process
+			if total := s.STot + o.STot; total > 0 {
+				*dst[name] = (*dst[name]*s.STot + *v*o.STot) / total
+			}
+		}
+	}
+
+	for name, v := range src {
+		switch name {
+		case "weight", "lastchg", "qtime", "ctime", "rtime", "ttime":
+		default:
+			*dst[name] += *v
+		}
+	}
+
+	if rank(o.Status) > rank(s.Status) {
+		s.Status = o.Status
+		s.CheckStatus = o.CheckStatus
+	}
+}
+
+// WriteStat write stats as `show stat` CSV, bisa dibaca lagi dengan ParseStat
+// atau tool yang biasa baca stats HAProxy
+func WriteStat(w io.Writer, stats []Stat) error {
+	cw := csv.NewWriter(w)
+
+	header := append([]string{}, statColumns...)
+	header[0] = "# " + header[0]
+
+	// HAProxy selalu menutup baris dengan koma
+	if err := cw.Write(append(header, "")); err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		ints := s.ints()
+
+		record := make([]string, 0, len(statColumns)+1)
+		for _, name := range statColumns {
+			switch name {
+			case "pxname":
+				record = append(record, s.ProxyName)
+			case "svname":
+				record = append(record, s.ServiceName)
+			case "status":
+				record = append(record, s.Status)
+			case "check_status":
+				record = append(record, s.CheckStatus)
+			case "type":
+				record = append(record, strconv.Itoa(int(s.Type)))
+			default:
+				record = append(record, strconv.FormatInt(*ints[name], 10))
+			}
+		}
+
+		if err := cw.Write(append(record, "")); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
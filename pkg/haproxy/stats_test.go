@@ -0,0 +1,71 @@
+package haproxy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeStats(t *testing.T) {
+	a := []Stat{
+		{ProxyName: "gateway", ServiceName: "FRONTEND", Type: TypeFrontend, Status: "OPEN", SCur: 3, STot: 100, ReqRate: 4, Hrsp2xx: 90, Hrsp5xx: 1},
+		{ProxyName: "api", ServiceName: "s1", Type: TypeServer, Status: "UP", Weight: 1, LastChange: 30, STot: 60},
+		{ProxyName: "api", ServiceName: "BACKEND", Type: TypeBackend, Status: "UP", Weight: 1, STot: 60, TTime: 10},
+	}
+	b := []Stat{
+		{ProxyName: "gateway", ServiceName: "FRONTEND", Type: TypeFrontend, Status: "OPEN", SCur: 2, STot: 50, ReqRate: 1, Hrsp2xx: 40, Hrsp5xx: 2},
+		{ProxyName: "api", ServiceName: "s1", Type: TypeServer, Status: "DOWN", CheckStatus: "L4CON", Weight: 1, LastChange: 5, STot: 20},
+		{ProxyName: "api", ServiceName: "s2", Type: TypeServer, Status: "UP", Weight: 2, STot: 7},
+		{ProxyName: "api", ServiceName: "BACKEND", Type: TypeBackend, Status: "UP", Weight: 2, STot: 27, TTime: 97},
+	}
+
+	merged := MergeStats(a, b)
+	assert.Len(t, merged, 4)
+
+	fe := merged[0]
+	assert.Equal(t, int64(5), fe.SCur)
+	assert.Equal(t, int64(150), fe.STot)
+	assert.Equal(t, int64(5), fe.ReqRate)
+	assert.Equal(t, int64(130), fe.Hrsp2xx)
+	assert.Equal(t, int64(3), fe.Hrsp5xx)
+
+	s1 := merged[1]
+	assert.Equal(t, "DOWN", s1.Status)
+	assert.Equal(t, "L4CON", s1.CheckStatus)
+	assert.Equal(t, int64(1), s1.Weight)
+	assert.Equal(t, int64(5), s1.LastChange)
+	assert.Equal(t, int64(80), s1.STot)
+
+	assert.Equal(t, "BACKEND", merged[2].ServiceName)
+	assert.Equal(t, int64(2), merged[2].Weight)
+	assert.Equal(t, int64(87), merged[2].STot)
+	// (10*60 + 97*27) / 87
+	assert.Equal(t, int64(37), merged[2].TTime)
+	assert.Equal(t, "s2", merged[3].ServiceName)
+
+	// input tidak ikut berubah
+	assert.Equal(t, int64(3), a[0].SCur)
+}
+
+func TestWriteStat(t *testing.T) {
+	stats := []Stat{
+		{ProxyName: "gateway", ServiceName: "FRONTEND", Type: TypeFrontend, Status: "OPEN", SCur: 3, SLim: 2000, Hrsp2xx: 100, ReqTot: 106},
+		{ProxyName: "web", ServiceName: "s1", Type: TypeServer, Status: "no check", Weight: 1, TTime: 12},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteStat(&buf, stats))
+	assert.True(t, strings.HasPrefix(buf.String(), "# pxname,svname,qcur,"))
+	assert.Contains(t, buf.String(), "web,s1,0,0,0,0,0,0,0,0,0,0,0,0,no check,1,0,2,")
+
+	parsed, err := ParseStat(&buf)
+	assert.NoError(t, err)
+
+	for i := range parsed {
+		parsed[i].Fields = nil
+	}
+
+	assert.Equal(t, stats, parsed)
+}
@@ -0,0 +1,95 @@
+package procstat
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// USER_HZ Linux, nilai /proc/<pid>/stat dihitung dalam tick ini
+const clockTicks = 100
+
+// Process is one resource sample of a process, diambil dari /proc
+type Process struct {
+	PID     int           `json:"pid"`
+	CPUTime time.Duration `json:"cpu_time_ns"` // user + system
+	RSS     uint64        `json:"rss_bytes"`
+	OpenFDs int           `json:"open_fds"`
+	Threads int           `json:"threads"`
+}
+
+// Sample read /proc for pid
+func Sample(pid int) (Process, error) {
+	raw, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return Process{}, err
+	}
+
+	p, err := ParseStat(string(raw), os.Getpagesize())
+	if err != nil {
+		return Process{}, fmt.Errorf("cannot parse stat of pid %d: %w", pid, err)
+	}
+
+	fds, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return Process{}, err
+	}
+
+	p.OpenFDs = len(fds)
+
+	return p, nil
+}
+
+// ParseStat parse one /proc/<pid>/stat line. Nama proses (field 2) bisa
+// berisi spasi atau kurung, jadi field setelahnya dihitung dari ')' terakhir.
+func ParseStat(stat string, pageSize int) (Process, error) {
+	open := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return Process{}, fmt.Errorf("malformed stat %q", stat)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:open]))
+	if err != nil {
+		return Process{}, err
+	}
+
+	// fields[0] adalah field 3 (state) di man proc
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return Process{}, fmt.Errorf("stat has only %d fields", len(fields)+2)
+	}
+
+	field := func(n int) (uint64, error) {
+		return strconv.ParseUint(fields[n-3], 10, 64)
+	}
+
+	utime, err := field(14)
+	if err != nil {
+		return Process{}, err
+	}
+
+	stime, err := field(15)
+	if err != nil {
+		return Process{}, err
+	}
+
+	threads, err := field(20)
+	if err != nil {
+		return Process{}, err
+	}
+
+	rss, err := field(24)
+	if err != nil {
+		return Process{}, err
+	}
+
+	return Process{
+		PID:     pid,
+		CPUTime: time.Duration(utime+stime) * time.Second / clockTicks,
+		RSS:     rss * uint64(pageSize),
+		Threads: int(threads),
+	}, nil
+}
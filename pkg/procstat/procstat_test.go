@@ -0,0 +1,56 @@
+package procstat
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		name string
+		stat string
+		want Process
+		err  bool
+	}{
+		{
+			name: "plain name",
+			stat: "1234 (haproxy) S 1 1234 1234 0 -1 4194560 1200 0 0 0 250 50 0 0 20 0 4 0 100 123456789 2048 18446744073709551615",
+			want: Process{PID: 1234, CPUTime: 3 * time.Second, RSS: 2048 * 4096, Threads: 4},
+		},
+		{
+			name: "name with spaces and parens",
+			stat: "77 (mox (worker) 1) R 1 77 77 0 -1 0 0 0 0 0 1 1 0 0 20 0 9 0 100 1 10 0",
+			want: Process{PID: 77, CPUTime: 20 * time.Millisecond, RSS: 10 * 4096, Threads: 9},
+		},
+		{
+			name: "truncated",
+			stat: "1 (init) S 0 1",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStat(tt.stat, 4096)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSampleSelf(t *testing.T) {
+	p, err := Sample(os.Getpid())
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), p.PID)
+	assert.Greater(t, p.RSS, uint64(0))
+	assert.Greater(t, p.OpenFDs, 0)
+	assert.Greater(t, p.Threads, 0)
+}
@@ -0,0 +1,214 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+)
+
+// EnvManifest carry the JSON manifest from the old master to the new one
+const EnvManifest = "MOX_UPGRADE_MANIFEST"
+
+// well-known inherited file names
+const (
+	FileReady = "ready"
+)
+
+// first fd of exec.Cmd.ExtraFiles, 0-2 is stdin/stdout/stderr
+const firstExtraFD = 3
+
+// Worker is a live worker connection handed over to the new master
+type Worker struct {
+	PID int `json:"pid"`
+	FD  int `json:"fd"`
+
+	// protocol bus yang sudah disepakati, worker tidak handshake ulang
+	Version uint8 `json:"version"`
+	Codec   uint8 `json:"codec"`
+
+	Generation int    `json:"generation"`
+	ConfigHash string `json:"config_hash"`
+}
+
+// Manifest describe which fd holds which socket in the new process
+type Manifest struct {
+	ParentPID int            `json:"parent_pid"`
+	Files     map[string]int `json:"files"`
+	Workers   []Worker       `json:"workers"`
+	Desired   int            `json:"desired"`
+}
+
+// Builder collect files for exec.Cmd.ExtraFiles and build the matching manifest
+type Builder struct {
+	files    []*os.File
+	manifest Manifest
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		manifest: Manifest{
+			ParentPID: os.Getpid(),
+			Files:     make(map[string]int),
+		},
+	}
+}
+
+func (b *Builder) add(f *os.File) int {
+	b.files = append(b.files, f)
+
+	return firstExtraFD + len(b.files) - 1
+}
+
+// AddFile register a named file, e.g. a listener
+func (b *Builder) AddFile(name string, f *os.File) *Builder {
+	b.manifest.Files[name] = b.add(f)
+
+	return b
+}
+
+// AddWorker register the bus connection of a live worker, FD diisi builder
+func (b *Builder) AddWorker(w Worker, f *os.File) *Builder {
+	w.FD = b.add(f)
+	b.manifest.Workers = append(b.manifest.Workers, w)
+
+	return b
+}
+
+func (b *Builder) SetDesired(n int) *Builder {
+	b.manifest.Desired = n
+
+	return b
+}
+
+// Files return the files in ExtraFiles order
+func (b *Builder) Files() []*os.File {
+	return b.files
+}
+
+// Env return the manifest as KEY=VALUE environment entry
+func (b *Builder) Env() (string, error) {
+	raw, err := json.Marshal(b.manifest)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode upgrade manifest: %w", err)
+	}
+
+	return fmt.Sprintf("%s=%s", EnvManifest, raw), nil
+}
+
+// Close close every collected file, the child already has its own copy
+func (b *Builder) Close() {
+	for _, f := range b.files {
+		f.Close()
+	}
+
+	b.files = nil
+}
+
+var (
+	once      sync.Once
+	inherited *Manifest
+)
+
+// Inherited return the manifest handed by the previous master, if any.
+// Env-nya langsung dihapus supaya worker yang di-spawn tidak ikut mewarisi.
+func Inherited() (*Manifest, bool) {
+	once.Do(func() {
+		raw, ok := os.LookupEnv(EnvManifest)
+		if !ok {
+			return
+		}
+
+		os.Unsetenv(EnvManifest)
+
+		var m Manifest
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			return
+		}
+
+		inherited = &m
+	})
+
+	return inherited, inherited != nil
+}
+
+// File open the inherited file registered under name
+func (m *Manifest) File(name string) (*os.File, bool) {
+	fd, ok := m.Files[name]
+	if !ok {
+		return nil, false
+	}
+
+	return os.NewFile(uintptr(fd), name), true
+}
+
+// Listener rebuild the inherited listener registered under name
+func (m *Manifest) Listener(name string) (net.Listener, error) {
+	f, ok := m.File(name)
+	if !ok {
+		return nil, fmt.Errorf("no inherited listener %q", name)
+	}
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+// NotifyReady tell the previous master that this process is serving.
+// No-op kalau process ini bukan hasil upgrade.
+func NotifyReady() error {
+	m, ok := Inherited()
+	if !ok {
+		return nil
+	}
+
+	f, ok := m.File(FileReady)
+	if !ok {
+		return errors.New("upgrade manifest has no ready pipe")
+	}
+	defer f.Close()
+
+	_, err := fmt.Fprintf(f, "READY %d\n", os.Getpid())
+
+	return err
+}
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]func() (*os.File, error))
+)
+
+// RegisterInheritable let a driver hand its own socket to the next master,
+// misal listener HTTP API supaya port-nya tidak lepas saat upgrade.
+func RegisterInheritable(name string, provider func() (*os.File, error)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	providers[name] = provider
+}
+
+// CollectInheritables add every registered provider into the builder
+func (b *Builder) CollectInheritables() error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		f, err := providers[name]()
+		if err != nil {
+			return fmt.Errorf("cannot collect inheritable %q: %w", name, err)
+		}
+
+		b.AddFile(name, f)
+	}
+
+	return nil
+}
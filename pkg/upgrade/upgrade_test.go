@@ -0,0 +1,47 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderManifest(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+
+	b := NewBuilder().
+		AddFile("public", r).
+		AddWorker(Worker{PID: 4242, Version: 1, Codec: 2}, w).
+		SetDesired(3)
+
+	env, err := b.Env()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(env, EnvManifest+"="))
+
+	var m Manifest
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(env, EnvManifest+"=")), &m))
+
+	assert.Equal(t, os.Getpid(), m.ParentPID)
+	assert.Equal(t, map[string]int{"public": 3}, m.Files)
+	assert.Equal(t, []Worker{{PID: 4242, FD: 4, Version: 1, Codec: 2}}, m.Workers)
+	assert.Equal(t, 3, m.Desired)
+	assert.Len(t, b.Files(), 2)
+
+	b.Close()
+	assert.Empty(t, b.Files())
+}
+
+func TestCollectInheritables(t *testing.T) {
+	RegisterInheritable("b", func() (*os.File, error) { return os.Open(os.DevNull) })
+	RegisterInheritable("a", func() (*os.File, error) { return os.Open(os.DevNull) })
+
+	b := NewBuilder()
+	defer b.Close()
+
+	assert.NoError(t, b.CollectInheritables())
+	assert.Equal(t, map[string]int{"a": 3, "b": 4}, b.manifest.Files)
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"mox/gorm/models"
+	"mox/use_cases/operation"
+
+	"gorm.io/gorm"
+)
+
+var _ operation.RevisionStore = (*ConfigRevisionGormRepository)(nil)
+
+type ConfigRevisionGormRepository struct {
+	db *gorm.DB
+}
+
+func NewConfigRevisionRepository(db *gorm.DB) *ConfigRevisionGormRepository {
+	return &ConfigRevisionGormRepository{
+		db: db,
+	}
+}
+
+// Save implements [operation.RevisionStore].
+func (r *ConfigRevisionGormRepository) Save(ctx context.Context, rev operation.ConfigRevision) (operation.ConfigRevision, error) {
+	p := models.ConfigRevision{
+		Author:     rev.Author,
+		Reason:     rev.Reason,
+		Hash:       rev.Hash,
+		Generation: rev.Generation,
+		Rendered:   rev.Config,
+	}
+
+	// nomor berikutnya diambil di transaksi yang sama, unique index jaga kalau ada yang balapan
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&models.ConfigRevision{}).Select("COALESCE(MAX(revision), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		p.Revision = last + 1
+
+		return tx.Create(&p).Error
+	})
+	if err != nil {
+		return operation.ConfigRevision{}, err
+	}
+
+	return toRevision(p, true), nil
+}
+
+// List implements [operation.RevisionStore].
+func (r *ConfigRevisionGormRepository) List(ctx context.Context) ([]operation.ConfigRevision, error) {
+	var rows []models.ConfigRevision
+	if err := r.db.WithContext(ctx).Omit("rendered").Order("revision DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	revisions := make([]operation.ConfigRevision, 0, len(rows))
+	for _, row := range rows {
+		revisions = append(revisions, toRevision(row, false))
+	}
+
+	return revisions, nil
+}
+
+// Get implements [operation.RevisionStore].
+func (r *ConfigRevisionGormRepository) Get(ctx context.Context, n int) (operation.ConfigRevision, error) {
+	return r.first(r.db.WithContext(ctx).Where("revision = ?", n))
+}
+
+// Latest implements [operation.RevisionStore].
+func (r *ConfigRevisionGormRepository) Latest(ctx context.Context) (operation.ConfigRevision, error) {
+	return r.first(r.db.WithContext(ctx).Order("revision DESC"))
+}
+
+func (r *ConfigRevisionGormRepository) first(q *gorm.DB) (operation.ConfigRevision, error) {
+	var row models.ConfigRevision
+	if err := q.First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return operation.ConfigRevision{}, operation.ErrRevisionNotFound
+		}
+
+		return operation.ConfigRevision{}, err
+	}
+
+	return toRevision(row, true), nil
+}
+
+func toRevision(row models.ConfigRevision, withConfig bool) operation.ConfigRevision {
+	rev := operation.ConfigRevision{
+		Number:     row.Revision,
+		Author:     row.Author,
+		Reason:     row.Reason,
+		Hash:       row.Hash,
+		Generation: row.Generation,
+		CreatedAt:  row.CreatedAt,
+	}
+
+	if withConfig {
+		rev.Config = row.Rendered
+	}
+
+	return rev
+}
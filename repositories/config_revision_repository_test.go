@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"mox/gorm/models"
+	"mox/infrastructure/persistent"
+	"mox/pkg/config"
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestConfigRevisionRepository(t *testing.T) {
+	adapter := &persistent.SQLiteGorm{}
+	conn, err := adapter.Connect(config.Database{Adapter: "sqlite-gorm", Alias: "test", Path: filepath.Join(t.TempDir(), "mox.db")})
+	assert.NoError(t, err)
+	t.Cleanup(func() { adapter.Close() })
+
+	db := conn.(*gorm.DB)
+	assert.NoError(t, db.AutoMigrate(&models.ConfigRevision{}))
+
+	repo := NewConfigRevisionRepository(db)
+	ctx := context.Background()
+
+	_, err = repo.Latest(ctx)
+	assert.ErrorIs(t, err, operation.ErrRevisionNotFound)
+
+	first, err := repo.Save(ctx, operation.ConfigRevision{Author: "master", Reason: "startup", Hash: "aaa", Config: "global\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, first.Number)
+
+	second, err := repo.Save(ctx, operation.ConfigRevision{Author: "ops", Reason: "api reload", Hash: "bbb", Generation: 1, Config: "global\n    maxconn 10\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, second.Number)
+
+	latest, err := repo.Latest(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "bbb", latest.Hash)
+	assert.Equal(t, "global\n    maxconn 10\n", latest.Config)
+
+	list, err := repo.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, 2, list[0].Number)
+	assert.Equal(t, "ops", list[0].Author)
+	assert.Empty(t, list[0].Config)
+
+	got, err := repo.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "global\n", got.Config)
+
+	_, err = repo.Get(ctx, 9)
+	assert.ErrorIs(t, err, operation.ErrRevisionNotFound)
+}
@@ -60,7 +60,6 @@ type LogOptions struct {
 type LogHandler struct {
 	mu      *sync.Mutex
 	options *LogOptions
-	buf     []byte
 	logs    []*Log
 	groups  []groupOrAttr
 }
@@ -71,8 +70,6 @@ func NewBaseLogHandler(opt *LogOptions) *LogHandler {
 		options: opt,
 	}
 
-	l.buf = make([]byte, 1024)
-
 	if opt.WriteFunc == nil {
 		panic("WriteFunc must be set")
 	}
@@ -118,18 +115,21 @@ func (l *LogHandler) Handle(ctx context.Context, record slog.Record) error {
 		}
 	}
 
+	// buffer per record, Handle dipanggil dari banyak goroutine sekaligus
+	var payload []byte
+
 	data := make(map[string]any, record.NumAttrs())
 	stack := stack.New[slog.Attr]()
 
 	if len(goas) > 0 {
 		for i := len(goas) - 1; i >= 0; i-- {
 			if goas[i].group != "" {
-				l.buf = fmt.Appendf(l.buf, "%s.", goas[i].group)
+				payload = fmt.Appendf(payload, "%s.", goas[i].group)
 
 				key := make(map[string]any, stack.Len())
 				for _, a := range stack.PopByLength(stack.Len()) {
 					buf, _ := l.appendAttr(key, a)
-					l.buf = append(l.buf, string(buf)...)
+					payload = append(payload, string(buf)...)
 				}
 
 				data[goas[i].group] = key
@@ -144,7 +144,7 @@ func (l *LogHandler) Handle(ctx context.Context, record slog.Record) error {
 		if stack.Len() > 0 {
 			for _, a := range stack.PopByLength(stack.Len()) {
 				buf, _ := l.appendAttr(data, a)
-				l.buf = append(l.buf, string(buf)...)
+				payload = append(payload, string(buf)...)
 			}
 		}
 	}
@@ -160,7 +160,7 @@ func (l *LogHandler) Handle(ctx context.Context, record slog.Record) error {
 
 	record.Attrs(func(a slog.Attr) bool {
 		buf, err := l.appendAttr(data, a)
-		l.buf = append(l.buf, string(buf)...)
+		payload = append(payload, string(buf)...)
 
 		if err != nil {
 			return false
@@ -174,16 +174,15 @@ func (l *LogHandler) Handle(ctx context.Context, record slog.Record) error {
 		Level:   record.Level,
 		Data:    data,
 		Source:  source,
-		Payload: l.buf,
+		Payload: payload,
 	}
 
-	if l.options.Filterrable != nil && !l.options.Filterrable(ctx, l.buf, log) {
+	if l.options.Filterrable != nil && !l.options.Filterrable(ctx, payload, log) {
 		return nil
 	}
 
 	l.mu.Lock()
 	l.logs = append(l.logs, log)
-	l.buf = nil
 	logLength := len(l.logs)
 	l.mu.Unlock()
 
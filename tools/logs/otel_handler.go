@@ -0,0 +1,116 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+var _ slog.Handler = (*OTelHandler)(nil)
+
+// OTelHandler forward slog records to the global OTel LoggerProvider.
+// Sebelum monitoring.enable_collect_log menyalakan provider, global provider
+// masih noop jadi handler ini tidak melakukan apa-apa.
+type OTelHandler struct {
+	logger otellog.Logger
+	attrs  []otellog.KeyValue
+	prefix string
+}
+
+func NewOTelHandler(name string) *OTelHandler {
+	return &OTelHandler{logger: global.GetLoggerProvider().Logger(name)}
+}
+
+// severity map slog level ke OTel: Debug(-4)=5, Info(0)=9, Warn(4)=13, Error(8)=17
+func severity(level slog.Level) otellog.Severity {
+	return otellog.Severity(int(level) + 9)
+}
+
+// Enabled implements slog.Handler.
+func (h *OTelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.Enabled(ctx, otellog.EnabledParameters{Severity: severity(level)})
+}
+
+// Handle implements slog.Handler.
+func (h *OTelHandler) Handle(ctx context.Context, record slog.Record) error {
+	var r otellog.Record
+
+	r.SetTimestamp(record.Time)
+	r.SetObservedTimestamp(time.Now())
+	r.SetSeverity(severity(record.Level))
+	r.SetSeverityText(record.Level.String())
+	r.SetBody(otellog.StringValue(record.Message))
+	r.AddAttributes(h.attrs...)
+
+	record.Attrs(func(a slog.Attr) bool {
+		r.AddAttributes(h.convert(a))
+		return true
+	})
+
+	h.logger.Emit(ctx, r)
+
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *OTelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = make([]otellog.KeyValue, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, h.convert(a))
+	}
+
+	return &h2
+}
+
+// WithGroup implements slog.Handler. Group jadi prefix key, misal "http.status".
+func (h *OTelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+
+	return &h2
+}
+
+func (h *OTelHandler) convert(a slog.Attr) otellog.KeyValue {
+	return otellog.KeyValue{Key: h.prefix + a.Key, Value: value(a.Value)}
+}
+
+func value(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		return otellog.Int64Value(int64(v.Uint64()))
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return otellog.Int64Value(v.Duration().Milliseconds())
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		kvs := make([]otellog.KeyValue, 0, len(v.Group()))
+		for _, a := range v.Group() {
+			kvs = append(kvs, otellog.KeyValue{Key: a.Key, Value: value(a.Value)})
+		}
+
+		return otellog.MapValue(kvs...)
+	case slog.KindLogValuer:
+		return value(v.Resolve())
+	default:
+		return otellog.StringValue(fmt.Sprint(v.Any()))
+	}
+}
@@ -1,7 +1,9 @@
 package bus
 
 import (
+	"fmt"
 	"io"
+	"strings"
 
 	"mox/use_cases/operation"
 )
@@ -11,4 +13,38 @@ type Event struct {
 	Payload  operation.Command
 	Output   io.Writer
 	Closer   io.Closer
+	// Done is closed by Reply, pengirim nunggu ini sebelum baca baris berikutnya
+	Done chan struct{}
+}
+
+// Reply write the output of the command followed by one status line, "OK"
+// atau "ERR <alasan>", jadi client cukup baca sampai ketemu baris status.
+func (e Event) Reply(out string, err error) error {
+	if e.Done != nil {
+		defer close(e.Done)
+	}
+
+	if e.Output == nil {
+		return nil
+	}
+
+	var b strings.Builder
+
+	if out != "" {
+		b.WriteString(out)
+
+		if !strings.HasSuffix(out, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(&b, "ERR %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+	} else {
+		b.WriteString("OK\n")
+	}
+
+	_, werr := io.WriteString(e.Output, b.String())
+
+	return werr
 }
@@ -0,0 +1,36 @@
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventReply(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		err  error
+		want string
+	}{
+		{name: "ok", want: "OK\n"},
+		{name: "output", out: "pid generation\n42  3", want: "pid generation\n42  3\nOK\n"},
+		{name: "error", err: errors.New("invalid worker count -1"), want: "ERR invalid worker count -1\n"},
+		{name: "multi line error", err: errors.Join(errors.New("pid 1: timeout"), errors.New("pid 2: timeout")), want: "ERR pid 1: timeout pid 2: timeout\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			done := make(chan struct{})
+
+			assert.NoError(t, Event{Output: &buf, Done: done}.Reply(tt.out, tt.err))
+			assert.Equal(t, tt.want, buf.String())
+
+			_, open := <-done
+			assert.False(t, open)
+		})
+	}
+}
@@ -2,7 +2,10 @@ package bus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 
 	core "mox/internal"
 	"mox/use_cases/operation"
@@ -19,23 +22,40 @@ func NewEventBus(app core.App) *EventBus {
 	return &EventBus{app: app}
 }
 
-// Broadcast implements [Messaging].
-func (e *EventBus) Broadcast(ctx context.Context, payload operation.MessagePayload, workers []workerclient.WorkerProcess) error {
-	for _, v := range workers {
+// Broadcast implements [Messaging]. Semua worker ditanya barengan, hasilnya urut per PID.
+func (e *EventBus) Broadcast(ctx context.Context, payload operation.MessagePayload, workers []workerclient.WorkerProcess) Results {
+	results := make(Results, len(workers))
+	wg := &sync.WaitGroup{}
+
+	for i, v := range workers {
 		e.app.Logger().Info(fmt.Sprintf("broadcast to PID: %d, MsgType : %s", v.PID(), payload.Payload.Type.String()))
 
 		if v.State() == workerclient.Disconnected {
 			e.app.Logger().Info(fmt.Sprintf("PID: %d, msg : still disconnected", v.PID()))
+			results[i] = Result{PID: v.PID(), Err: workerclient.ErrDisconnected}
 			continue
 		}
 
-		if err := e.Send(ctx, payload, v); err != nil {
-			v.Shutdown()
-			e.app.Logger().Error(fmt.Sprintf("error sending heart PID: %d, msg : %s", v.PID(), err.Error()))
-			continue
-		}
+		wg.Add(1)
+		go func(i int, v workerclient.WorkerProcess) {
+			defer wg.Done()
+
+			reply, err := e.Request(ctx, payload, v)
+			results[i] = Result{PID: v.PID(), Reply: reply, Err: err}
+
+			// timeout belum tentu mati, tapi gagal kirim berarti koneksinya rusak
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				v.Shutdown()
+				e.app.Logger().Error(fmt.Sprintf("error sending heart PID: %d, msg : %s", v.PID(), err.Error()))
+			}
+		}(i, v)
 	}
-	return nil
+
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].PID < results[j].PID })
+
+	return results
 }
 
 // Send implements [Messaging]. disini bisa implement retryable process / middleware
@@ -50,3 +70,14 @@ func (e *EventBus) Send(ctx context.Context, msg operation.MessagePayload, paylo
 
 	return nil
 }
+
+// Request implements [Messaging]. Kalau ctx belum punya deadline, pakai master.request_timeout.
+func (e *EventBus) Request(ctx context.Context, msg operation.MessagePayload, worker workerclient.WorkerProcess) (operation.Reply, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.app.Config().Master.RequestTimeout)
+		defer cancel()
+	}
+
+	return worker.Request(ctx, msg)
+}
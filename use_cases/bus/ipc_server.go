@@ -3,152 +3,432 @@ package bus
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	core "mox/internal"
+	"mox/pkg/upgrade"
+	"mox/use_cases/manager"
 	"mox/use_cases/operation"
+	"mox/use_cases/wire"
 	"mox/use_cases/workerclient"
 )
 
+// nama file di manifest upgrade, listener publik pakai prefix InheritListener
+const (
+	InheritListener = "listener:"
+	InheritBus      = "bus"
+)
+
 type IPCServerGateway struct {
 	SocketPath  string
-	Type        NetworkType
-	Port        int
 	Event       chan Event
 	WorkerEvent chan workerclient.WorkerProcess
 
 	app          core.App
-	l            net.Listener
-	fdFile       *os.File // file descriptor
+	listeners    *manager.ListenerManager
 	unixListener *net.UnixListener
 	mu           *sync.RWMutex
+	paused       atomic.Bool // stop accepting worker selama handover upgrade
+	policy       PeerPolicy
+	socketMode   os.FileMode
+
+	controlAddress string       // address control protocol (host:port atau unix://path), kosong = mati
+	control        net.Listener // nil selama handover upgrade
 }
 
 func NewIPCServerGateway(
 	app core.App,
 	SocketPath string,
-	Host string,
-	Port int,
-	Type NetworkType,
+	listeners *manager.ListenerManager,
 ) *IPCServerGateway {
 	return &IPCServerGateway{
 		app:         app,
 		SocketPath:  SocketPath,
-		Port:        Port,
-		Type:        Type,
+		listeners:   listeners,
 		mu:          &sync.RWMutex{},
 		WorkerEvent: make(chan workerclient.WorkerProcess, 1),
 		Event:       make(chan Event, 1),
+		policy:      NewPeerPolicy(nil, nil),
+		socketMode:  0o600,
 	}
 }
 
-func (c *IPCServerGateway) ListenAndServe() {
-	address := fmt.Sprintf(":%d", c.Port)
-	os.Remove(c.SocketPath)
-	l, err := net.Listen(c.Type.String(), address)
+// SetPeerPolicy set which local users may connect as worker
+func (c *IPCServerGateway) SetPeerPolicy(p PeerPolicy) *IPCServerGateway {
+	c.policy = p
+
+	return c
+}
+
+// SetSocketMode set the permission of the worker socket file
+func (c *IPCServerGateway) SetSocketMode(mode os.FileMode) *IPCServerGateway {
+	c.socketMode = mode
+
+	return c
+}
+
+// SetControlAddress set the address of the line control protocol, kosong = tidak dibuka.
+// Format-nya sama dengan listener: "host:port" atau "unix:///path/to.sock".
+func (c *IPCServerGateway) SetControlAddress(address string) *IPCServerGateway {
+	c.controlAddress = address
+
+	return c
+}
+
+// listenControl open the control port. Port ini opsional, kalau gagal master
+// tetap jalan dan bisa dikontrol lewat HTTP API.
+func (c *IPCServerGateway) listenControl() {
+	if c.controlAddress == "" {
+		return
+	}
+
+	l, err := c.openControl()
+	if err != nil {
+		c.app.Logger().Warn("cannot open the control port", slog.String("address", c.controlAddress), slog.String("err", err.Error()))
+		return
+	}
+
+	c.control = l
+	c.app.Logger().Info(fmt.Sprintf("control protocol listening on %s", l.Addr()))
+
+	go c.handleController(c.app.Context(), l)
+}
+
+// openControl listen on the control address. Unix socket dibuat dengan
+// socket_mode dan peer-nya dicek PeerPolicy, TCP tidak ada autentikasi.
+func (c *IPCServerGateway) openControl() (net.Listener, error) {
+	network, addr, err := manager.ParseAddress(c.controlAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if network != "unix" {
+		c.app.Logger().Warn("control protocol over TCP has no authentication, use a unix:// control address", slog.String("address", c.controlAddress))
+		return net.Listen(network, addr)
+	}
+
+	if err := manager.RemoveStaleSocket(addr); err != nil {
+		return nil, err
+	}
+
+	return c.listenUnix(addr)
+}
+
+func (c *IPCServerGateway) closeControl() {
+	if c.control == nil {
+		return
+	}
+
+	c.control.Close()
+	c.control = nil
+}
+
+// inherit rebuild every listener from the previous master during a binary upgrade,
+// urutan fd di manifest = urutan open di master lama.
+func (c *IPCServerGateway) inherit(m *upgrade.Manifest) (*net.UnixListener, error) {
+	for _, name := range manager.SortByFD(m.Files) {
+		if !strings.HasPrefix(name, InheritListener) {
+			continue
+		}
+
+		l, err := m.Listener(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.listeners.Adopt(strings.TrimPrefix(name, InheritListener), l); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	bl, err := m.Listener(InheritBus)
 	if err != nil {
+		return nil, err
+	}
+
+	unixListener, ok := bl.(*net.UnixListener)
+	if !ok {
+		bl.Close()
+		return nil, fmt.Errorf("inherited bus listener is %T, expected unix", bl)
+	}
+
+	return unixListener, nil
+}
+
+// open every listener from config
+func (c *IPCServerGateway) open() error {
+	for _, l := range c.app.Config().Listeners {
+		if _, err := c.listeners.OpenListener(l.Name, l.Address); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *IPCServerGateway) ListenAndServe() error {
+	if m, ok := upgrade.Inherited(); ok {
+		unixListener, err := c.inherit(m)
+		if err != nil {
+			c.listeners.Close()
+			c.app.Logger().Error("cannot inherit listeners", slog.String("err", err.Error()))
+			return err
+		}
+
+		c.app.Logger().Info("listeners inherited from previous master", slog.Int("parent_pid", m.ParentPID))
+
+		return c.serve(unixListener)
+	}
+
+	if err := c.open(); err != nil {
+		c.listeners.Close()
 		c.app.Logger().Error("cannot run the listener", slog.String("err", err.Error()))
+		return err
 	}
 
-	unixListener, err := net.ListenUnix("unix", &net.UnixAddr{
-		Name: c.SocketPath,
-		Net:  "unix",
-	})
+	os.Remove(c.SocketPath)
+	unixListener, err := c.listenUnix(c.SocketPath)
 	if err != nil {
+		c.listeners.Close()
 		c.app.Logger().Error("cannot run the unix listener", slog.String("err", err.Error()))
 		c.app.Stop()
-		return
+		return err
+	}
+
+	return c.serve(unixListener)
+}
+
+// listenUnix create a socket at path already restricted to socketMode,
+// umask dipasang biar tidak ada jeda socket kebuka untuk semua user.
+func (c *IPCServerGateway) listenUnix(path string) (*net.UnixListener, error) {
+	old := syscall.Umask(0o777 &^ int(c.socketMode.Perm()))
+	l, err := net.ListenUnix("unix", &net.UnixAddr{
+		Name: path,
+		Net:  "unix",
+	})
+	syscall.Umask(old)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, c.socketMode.Perm()); err != nil {
+		l.Close()
+		return nil, err
 	}
 
+	return l, nil
+}
+
+func (c *IPCServerGateway) serve(unixListener *net.UnixListener) error {
 	c.mu.Lock()
-	c.l = l
-	c.fdFile = c.getFD(l)
 	c.unixListener = unixListener
 	c.mu.Unlock()
 
-	c.app.Logger().Info(fmt.Sprintf("IPC Server gateway listening on %s", address))
+	for _, l := range c.listeners.Listeners() {
+		c.app.Logger().Info(fmt.Sprintf("IPC Server gateway listening on %s", l.Address), slog.String("listener", l.Name), slog.String("network", l.Network))
+	}
+
 	c.app.Logger().Info(fmt.Sprintf("IPC Server gateway unix listening on socket path %s", c.SocketPath))
 
+	c.mu.Lock()
+	c.listenControl()
+	c.mu.Unlock()
+
 	go c.handleWorker(c.app.Context())
+
+	return nil
 }
 
-func (c *IPCServerGateway) Close() {
-	if c.l == nil {
-		return
+// Handover stop accepting new workers and add a copy of every listener into
+// the upgrade builder, listener publik urut sesuai fd. Socket file tidak di-unlink waktu Close.
+func (c *IPCServerGateway) Handover(b *upgrade.Builder) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.unixListener == nil {
+		return errors.New("gateway is not listening")
 	}
 
-	if err := c.fdFile.Close(); err != nil {
-		c.app.Logger().Error(err.Error())
+	c.paused.Store(true)
+	c.unixListener.SetDeadline(time.Now())
+
+	public, err := c.listeners.Files()
+	if err != nil {
+		c.resume()
+		return err
+	}
+
+	bus, err := c.unixListener.File()
+	if err != nil {
+		for _, l := range public {
+			l.File().Close()
+		}
+
+		c.resume()
+		return err
+	}
+
+	for _, l := range public {
+		b.AddFile(InheritListener+l.Name, l.File())
+	}
+
+	b.AddFile(InheritBus, bus)
+
+	c.unixListener.SetUnlinkOnClose(false)
+	c.listeners.SetUnlinkOnClose(false)
+
+	// port control tidak diwariskan, master baru bind ulang sendiri
+	c.closeControl()
+
+	return nil
+}
+
+// Resume accept workers again after a failed upgrade
+func (c *IPCServerGateway) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resume()
+}
+
+func (c *IPCServerGateway) resume() {
+	c.unixListener.SetUnlinkOnClose(true)
+	c.listeners.SetUnlinkOnClose(true)
+	c.paused.Store(false)
+
+	if c.control == nil {
+		c.listenControl()
+	}
+}
+
+// AdoptWorkers rebuild the worker clients handed over by the previous master
+func (c *IPCServerGateway) AdoptWorkers() []workerclient.WorkerProcess {
+	m, ok := upgrade.Inherited()
+	if !ok {
+		return nil
+	}
+
+	workers := make([]workerclient.WorkerProcess, 0, len(m.Workers))
+	for _, w := range m.Workers {
+		f := os.NewFile(uintptr(w.FD), fmt.Sprintf("worker-%d", w.PID))
+		conn, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			c.app.Logger().Error("cannot adopt worker", slog.Int("pid", w.PID), slog.String("err", err.Error()))
+			continue
+		}
+
+		unixConn, ok := conn.(*net.UnixConn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+
+		proto := wire.Protocol{Version: w.Version, Codec: wire.Codec(w.Codec)}
+		if err := wire.Verify(wire.Hello{Version: proto.Version, Codec: proto.Codec}); err != nil {
+			c.app.Logger().Error("cannot adopt worker", slog.Int("pid", w.PID), slog.String("err", err.Error()))
+			unixConn.Close()
+			continue
+		}
+
+		workers = append(workers, workerclient.NewWorkerClient(c.app, unixConn, w.PID).SetProtocol(proto).SetGeneration(w.Generation, w.ConfigHash))
 	}
 
-	if err := c.l.Close(); err != nil {
+	return workers
+}
+
+func (c *IPCServerGateway) Close() {
+	c.mu.Lock()
+	c.closeControl()
+	c.mu.Unlock()
+
+	if err := c.listeners.Close(); err != nil {
 		c.app.Logger().Error(err.Error())
 	}
 }
 
-func (c *IPCServerGateway) handleHandshake(conn *net.UnixConn) {
+// handleHandshake read the worker hello, cocokkan PID-nya dengan SO_PEERCRED
+// dan pilih versi + codec. FD listener baru dikirim setelah semuanya lolos.
+func (c *IPCServerGateway) handleHandshake(conn *net.UnixConn, cred PeerCred) {
 	conn.SetReadDeadline(time.Now().Add(1 * time.Second))
 
-	reader := bufio.NewReader(conn)
-	payload, err := reader.ReadString('\n')
+	f, err := wire.ReadFrame(conn)
 	if err != nil {
-		c.app.Logger().Error("Handshake failed: cannot read PID", err)
-		conn.Close()
+		c.app.Logger().Error("Handshake failed: cannot read hello", slog.String("err", err.Error()))
+		c.reject(conn, err)
+		return
+	}
+
+	offer, err := wire.ParseHello(f)
+	if err != nil {
+		c.app.Logger().Error("Handshake failed: protocol rejected", slog.Int("pid", offer.PID), slog.String("err", err.Error()))
+		c.reject(conn, err)
+		return
+	}
+
+	// PID yang diklaim worker harus sama dengan yang dilaporkan kernel
+	if offer.PID != cred.PID {
+		err := fmt.Errorf("worker claims pid %d but the kernel reports pid %d", offer.PID, cred.PID)
+		c.app.Logger().Error("Handshake failed: pid mismatch", slog.String("err", err.Error()))
+		c.reject(conn, err)
 		return
 	}
 
-	// 3. Bersihin string & Parse
-	payload = strings.TrimSpace(payload)
-	pid, err := strconv.Atoi(payload)
+	var hello wire.Hello
+
+	codec, err := wire.ParseCodec(c.app.Config().Master.BusCodec)
+	if err == nil {
+		hello, err = wire.Choose(offer, codec)
+	}
+
 	if err != nil {
-		c.app.Logger().Error("Handshake failed: invalid PID format", err)
+		c.app.Logger().Error("Handshake failed: protocol rejected", slog.Int("pid", cred.PID), slog.String("err", err.Error()))
+		c.reject(conn, err)
+		return
+	}
+
+	// deadline cuma buat handshake
+	conn.SetReadDeadline(time.Time{})
+
+	if err := c.writeProceedConnection(conn, hello); err != nil {
+		c.app.Logger().Error("failed to send FD", slog.Int("pid", cred.PID), slog.String("err", err.Error()))
 		conn.Close()
 		return
 	}
 
-	worker := workerclient.NewWorkerClient(c.app, conn, pid)
+	proto := wire.Protocol{Version: hello.Version, Codec: hello.Codec}
+	worker := workerclient.NewWorkerClient(c.app, conn, cred.PID).
+		SetProtocol(proto).
+		SetGeneration(offer.Generation, offer.ConfigHash)
 
 	// regitering
 	c.WorkerEvent <- worker
 
-	c.app.Logger().Debug(fmt.Sprintf("got pid %d", pid))
+	c.app.Logger().Debug(fmt.Sprintf("got pid %d", cred.PID), slog.Int("version", int(proto.Version)), slog.String("codec", proto.Codec.String()))
 }
 
-func (c *IPCServerGateway) getFD(l net.Listener) *os.File {
-	f, err := l.(*net.TCPListener).File()
+// reject tell the worker why it is refused, then close the connection
+func (c *IPCServerGateway) reject(conn *net.UnixConn, reason error) {
+	defer conn.Close()
+
+	f, err := wire.RejectFrame(reason)
 	if err != nil {
-		c.app.Logger().Error("master cannot get their fd file", slog.String("err", err.Error()))
-	}
-
-	// -------------------------------------------------------------------------
-	// NOTE: [CRITICAL WARNING]
-	// Jangan pernah mengganti implementasi di bawah ini dengan `int(f.Fd())`.
-	// Memanggil f.Fd() akan memaksa socket keluar dari Go Netpoller dan masuk
-	// ke mode BLOCKING system call.
-	// Efek fatalnya:
-	// 1. TCP Listener utama (l) akan ikut berubah jadi Blocking.
-	// 2. l.Accept() akan macet total (hang) dan tidak bisa di-interrupt.
-	// 3. l.SetDeadline() tidak akan berfungsi lagi.
-	// Gunakan SyscallConn().Control() untuk mengintip nilai FD dengan aman
-	// tanpa mengubah mode socket.
-	// referensi: https://morsmachine.dk/netpoller.html
-	// -------------------------------------------------------------------------
-	// conn, _ := f.SyscallConn()
-	// fd := -1
-	//
-	// conn.Control(func(fdUint uintptr) {
-	// 	fd = int(fdUint)
-	// })
-
-	return f
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(1 * time.Second))
+	wire.WriteFrame(conn, f)
 }
 
 func (c *IPCServerGateway) handleWorker(ctx context.Context) {
@@ -169,6 +449,11 @@ func (c *IPCServerGateway) processWorker() {
 	duration := time.Duration(5 * time.Minute)
 
 	for {
+		if c.paused.Load() {
+			time.Sleep(100 * time.Millisecond)
+			return
+		}
+
 		if err := listener.SetDeadline(time.Now().Add(duration)); err != nil {
 			c.app.Logger().Error(err.Error())
 			continue
@@ -181,13 +466,19 @@ func (c *IPCServerGateway) processWorker() {
 		}
 
 		go func(conn *net.UnixConn) {
-			if err := c.writeProceedConnection(conn); err != nil {
-				c.app.Logger().Error("failed to send FD", slog.String("err", err.Error()))
-				conn.Close()
+			// cek identitas peer dulu, FD listener jangan sampai bocor ke proses asing
+			cred, err := peerCred(conn)
+			if err == nil {
+				err = c.policy.Allow(cred)
+			}
+
+			if err != nil {
+				c.app.Logger().Warn("worker connection refused", slog.Int("pid", cred.PID), slog.Int("uid", cred.UID), slog.Int("gid", cred.GID), slog.String("err", err.Error()))
+				c.reject(conn, err)
 				return
 			}
 
-			c.handleHandshake(conn)
+			c.handleHandshake(conn, cred)
 		}(conn)
 
 		c.app.Logger().Info("berhasil mengirim kunci FD ke worker")
@@ -196,12 +487,29 @@ func (c *IPCServerGateway) processWorker() {
 	}
 }
 
-func (m *IPCServerGateway) writeProceedConnection(conn *net.UnixConn) error {
-	payload := []byte("PROCEED")
+// writeProceedConnection send every listener FD together with the master
+// hello, dipanggil setelah hello worker lolos dicek
+func (m *IPCServerGateway) writeProceedConnection(conn *net.UnixConn, hello wire.Hello) error {
+	// semua listener dikirim sekaligus, namanya ikut di hello sesuai urutan FD
+	names, fds := m.listeners.Rights()
+	if len(fds) == 0 {
+		return errors.New("there is no listener to send")
+	}
+
+	hello.Listeners = names
+
+	// hello master ikut di paket yang sama dengan FD
+	f, err := wire.HelloFrame(operation.Hello, hello)
+	if err != nil {
+		return err
+	}
 
-	fdInt := int(m.fdFile.Fd())
+	payload, err := f.Bytes()
+	if err != nil {
+		return err
+	}
 
-	rights := syscall.UnixRights(fdInt)
+	rights := syscall.UnixRights(fds...)
 
 	// 2. KIRIM
 	n, oobn, err := conn.WriteMsgUnix(payload, rights, nil)
@@ -209,58 +517,90 @@ func (m *IPCServerGateway) writeProceedConnection(conn *net.UnixConn) error {
 		return fmt.Errorf("gagal kirim msg unix: %v", err)
 	}
 
-	m.app.Logger().Info(fmt.Sprintf("[IPC-SEND] Success! Payload: %d bytes | OOB (FD): %d bytes | Target: %s | FD %d", n, oobn, conn.RemoteAddr(), fdInt))
+	m.app.Logger().Info(fmt.Sprintf("[IPC-SEND] Success! Payload: %d bytes | OOB (FD): %d bytes | Target: %s | FD %v %v", n, oobn, conn.RemoteAddr(), names, fds))
 
 	return nil
 }
 
-func (c *IPCServerGateway) handleController(ctx context.Context) {
+func (c *IPCServerGateway) handleController(ctx context.Context, l net.Listener) {
 	for {
-		conn, err := c.l.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			}
-
-			// B. Kalau bangun karena Listener ditutup paksa (Close)
-			if strings.Contains(err.Error(), "use of closed network connection") {
+			// listener ditutup (Close, handover upgrade) atau master berhenti
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
 				return
 			}
 
-			c.app.Logger().Error("Accept error", slog.String("err", err.Error()))
+			c.app.Logger().Warn("control accept error", slog.String("err", err.Error()))
+			time.Sleep(100 * time.Millisecond)
 
-			return
+			continue
+		}
+
+		if unixConn, ok := conn.(*net.UnixConn); ok {
+			if err := c.allowController(unixConn); err != nil {
+				c.app.Logger().Warn("control connection refused", slog.String("err", err.Error()))
+				fmt.Fprintf(conn, "ERR %s\n", err.Error())
+				conn.Close()
+
+				continue
+			}
 		}
 
-		go c.handleConnectionController(conn)
+		go c.handleConnectionController(ctx, conn)
+	}
+}
+
+// allowController check the peer of a unix control connection, sama seperti worker
+func (c *IPCServerGateway) allowController(conn *net.UnixConn) error {
+	cred, err := peerCred(conn)
+	if err != nil {
+		return err
 	}
+
+	return c.policy.Allow(cred)
 }
 
-func (c *IPCServerGateway) handleConnectionController(conn net.Conn) {
+// handleConnectionController read one command per line and hand it to the
+// master. Baris berikutnya baru dibaca setelah balasan ditulis lewat
+// Event.Reply, jadi urutan balasan sama dengan urutan command.
+func (c *IPCServerGateway) handleConnectionController(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
 	scanner := bufio.NewScanner(conn)
 	for scanner.Scan() {
-		text := scanner.Bytes()
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
 
-		// COPY data biar aman dari race condition buffer scanner
-		payload := make([]byte, len(text))
-		copy(payload, text)
+		done := make(chan struct{})
 
 		// Bungkus & Lempar ke Master
-		c.Event <- Event{
+		select {
+		case c.Event <- Event{
 			SourceID: conn.RemoteAddr().String(),
 			Payload: operation.Command{
-				Name:    string(payload),
-				Type:    operation.Ping,
-				Payload: payload,
+				Name:    line,
+				Type:    operation.Chat,
+				Payload: []byte(line),
 			},
 			Output: conn,
 			Closer: conn,
+			Done:   done,
+		}:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		c.app.Logger().Error(err.Error())
-		return
+		c.app.Logger().Warn("control connection error", slog.String("source", conn.RemoteAddr().String()), slog.String("err", err.Error()))
 	}
 }
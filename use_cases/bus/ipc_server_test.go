@@ -0,0 +1,180 @@
+package bus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/use_cases/manager"
+	"mox/use_cases/operation"
+	"mox/use_cases/wire"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlUnixSocket(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy PeerPolicy
+		reply  []string
+	}{
+		{name: "same user", policy: NewPeerPolicy(nil, nil), reply: []string{"pong", "OK"}},
+		{name: "user not allowed", policy: NewPeerPolicy([]int{os.Getuid() + 1}, nil), reply: []string{"ERR peer pid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "control.sock")
+
+			c := NewIPCServerGateway(core.NewTestAppWithConfig(config.Config{}), "", manager.NewListenerManager()).
+				SetPeerPolicy(tt.policy).
+				SetControlAddress("unix://" + path)
+
+			l, err := c.openControl()
+			require.NoError(t, err)
+			defer l.Close()
+
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go c.handleController(ctx, l)
+			go func() {
+				select {
+				case e := <-c.Event:
+					e.Reply("pong", nil)
+				case <-ctx.Done():
+				}
+			}()
+
+			conn, err := net.Dial("unix", path)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// peer yang ditolak bisa keburu diputus sebelum command-nya terkirim
+			conn.SetDeadline(time.Now().Add(time.Second))
+			conn.Write([]byte("NOOP\n"))
+
+			r := bufio.NewReader(conn)
+			for _, want := range tt.reply {
+				line, err := r.ReadString('\n')
+				require.NoError(t, err)
+				assert.Contains(t, line, want)
+			}
+		})
+	}
+}
+
+func TestControlKeepOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[app]\n"), 0o600))
+
+	c := NewIPCServerGateway(core.NewTestAppWithConfig(config.Config{}), "", manager.NewListenerManager()).
+		SetControlAddress("unix://" + path)
+
+	_, err := c.openControl()
+	assert.Error(t, err)
+	assert.FileExists(t, path)
+}
+
+func TestHandshakeFDAfterPeerCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		pid  int
+		err  string
+	}{
+		{name: "pid matches", pid: os.Getpid()},
+		{name: "pid mismatch", pid: os.Getpid() + 1, err: "kernel reports"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listeners := manager.NewListenerManager()
+			_, err := listeners.OpenListener("gateway", "tcp://127.0.0.1:0")
+			require.NoError(t, err)
+			defer listeners.Close()
+
+			app := core.NewTestAppWithConfig(config.Config{Master: config.MasterConfig{BusCodec: "json"}})
+			c := NewIPCServerGateway(app, "", listeners)
+
+			addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "worker.sock"), Net: "unix"}
+			l, err := net.ListenUnix("unix", addr)
+			require.NoError(t, err)
+			defer l.Close()
+
+			conn, err := net.DialUnix("unix", nil, addr)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			peer, err := l.AcceptUnix()
+			require.NoError(t, err)
+
+			cred, err := peerCred(peer)
+			require.NoError(t, err)
+
+			go c.handleHandshake(peer, cred)
+
+			// worker kenalan duluan, FD baru boleh datang setelah PID-nya dicek
+			offer := wire.Offer(wire.CodecProto)
+			offer.PID = tt.pid
+
+			f, err := wire.HelloFrame(operation.Hello, offer)
+			require.NoError(t, err)
+			_, err = wire.WriteFrame(conn, f)
+			require.NoError(t, err)
+
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+
+			buf, oob := make([]byte, 4096), make([]byte, syscall.CmsgSpace(4))
+			n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+			require.NoError(t, err)
+
+			f, err = wire.ReadFrame(bytes.NewReader(buf[:n]))
+			require.NoError(t, err)
+
+			hello, err := wire.ParseHello(f)
+
+			if tt.err != "" {
+				assert.ErrorIs(t, err, wire.ErrRejected)
+				assert.Contains(t, err.Error(), tt.err)
+				assert.Zero(t, oobn)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, wire.CodecJSON, hello.Codec)
+			assert.Equal(t, []string{"gateway"}, hello.Listeners)
+
+			msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+			require.NoError(t, err)
+			require.Len(t, msgs, 1)
+
+			fds, err := syscall.ParseUnixRights(&msgs[0])
+			require.NoError(t, err)
+			assert.Len(t, fds, 1)
+
+			for _, fd := range fds {
+				syscall.Close(fd)
+			}
+
+			select {
+			case w := <-c.WorkerEvent:
+				assert.Equal(t, tt.pid, w.PID())
+			case <-time.After(time.Second):
+				t.Fatal("worker was not registered")
+			}
+		})
+	}
+}
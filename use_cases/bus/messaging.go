@@ -8,6 +8,7 @@ import (
 )
 
 type Messaging interface {
-	Send(ctx context.Context, msg operation.MessagePayload, payload workerclient.WorkerProcess) error            // send to one PID
-	Broadcast(ctx context.Context, payload operation.MessagePayload, workers []workerclient.WorkerProcess) error // send to all PID
+	Send(ctx context.Context, msg operation.MessagePayload, payload workerclient.WorkerProcess) error                      // send to one PID
+	Request(ctx context.Context, msg operation.MessagePayload, worker workerclient.WorkerProcess) (operation.Reply, error) // send to one PID and wait for the reply
+	Broadcast(ctx context.Context, payload operation.MessagePayload, workers []workerclient.WorkerProcess) Results         // send to all PID
 }
@@ -0,0 +1,66 @@
+package bus
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"syscall"
+)
+
+// PeerCred is the identity of the process on the other end of a unix socket,
+// dilaporkan kernel lewat SO_PEERCRED jadi tidak bisa dipalsukan peer.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+func peerCred(conn *net.UnixConn) (PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCred{}, err
+	}
+
+	var (
+		cred *syscall.Ucred
+		cerr error
+	)
+
+	if err := raw.Control(func(fd uintptr) {
+		cred, cerr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCred{}, err
+	}
+
+	if cerr != nil {
+		return PeerCred{}, fmt.Errorf("cannot read SO_PEERCRED: %w", cerr)
+	}
+
+	return PeerCred{PID: int(cred.Pid), UID: int(cred.Uid), GID: int(cred.Gid)}, nil
+}
+
+// PeerPolicy decide which local users may connect as a worker
+type PeerPolicy struct {
+	UIDs []int
+	GIDs []int
+}
+
+// NewPeerPolicy build the allow list, kalau dua-duanya kosong cuma
+// user yang sama dengan master yang boleh masuk.
+func NewPeerPolicy(uids, gids []int) PeerPolicy {
+	if len(uids) == 0 && len(gids) == 0 {
+		uids = []int{os.Getuid()}
+	}
+
+	return PeerPolicy{UIDs: uids, GIDs: gids}
+}
+
+// Allow return an error when the peer is not in the allow list
+func (p PeerPolicy) Allow(c PeerCred) error {
+	if slices.Contains(p.UIDs, c.UID) || slices.Contains(p.GIDs, c.GID) {
+		return nil
+	}
+
+	return fmt.Errorf("peer pid %d uid %d gid %d is not allowed", c.PID, c.UID, c.GID)
+}
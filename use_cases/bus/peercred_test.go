@@ -0,0 +1,57 @@
+package bus
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  PeerPolicy
+		cred    PeerCred
+		allowed bool
+	}{
+		{"default allows own uid", NewPeerPolicy(nil, nil), PeerCred{UID: os.Getuid()}, true},
+		{"default rejects other uid", NewPeerPolicy(nil, nil), PeerCred{UID: os.Getuid() + 1}, false},
+		{"uid in list", NewPeerPolicy([]int{1000, 1001}, nil), PeerCred{UID: 1001, GID: 5}, true},
+		{"gid in list", NewPeerPolicy([]int{1000}, []int{50}), PeerCred{UID: 2000, GID: 50}, true},
+		{"neither in list", NewPeerPolicy([]int{1000}, []int{50}), PeerCred{UID: 2000, GID: 51}, false},
+		{"gid only list does not add own uid", NewPeerPolicy(nil, []int{50}), PeerCred{UID: os.Getuid(), GID: 51}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Allow(tt.cred)
+			if tt.allowed {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPeerCred(t *testing.T) {
+	path := t.TempDir() + "/peer.sock"
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	assert.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.AcceptUnix()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	cred, err := peerCred(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, PeerCred{PID: os.Getpid(), UID: os.Getuid(), GID: os.Getgid()}, cred)
+}
@@ -0,0 +1,48 @@
+package bus
+
+import (
+	"errors"
+	"fmt"
+
+	"mox/use_cases/operation"
+)
+
+// Result is the outcome of one worker in a broadcast
+type Result struct {
+	PID   int
+	Reply operation.Reply
+	Err   error // gagal kirim, timeout atau worker putus
+}
+
+// Error return the transport error or the error reported by the worker
+func (r Result) Error() error {
+	if r.Err != nil {
+		return r.Err
+	}
+
+	return r.Reply.Err()
+}
+
+type Results []Result
+
+// Failed return only the workers that did not acknowledge
+func (r Results) Failed() Results {
+	var failed Results
+	for _, v := range r {
+		if v.Error() != nil {
+			failed = append(failed, v)
+		}
+	}
+
+	return failed
+}
+
+// Err join every failure, nil kalau semua worker ack
+func (r Results) Err() error {
+	var errs []error
+	for _, v := range r.Failed() {
+		errs = append(errs, fmt.Errorf("pid %d: %w", v.PID, v.Error()))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package bus
+
+import (
+	"errors"
+	"testing"
+
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultsErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		results Results
+		failed  []int
+	}{
+		{
+			name: "all acked",
+			results: Results{
+				{PID: 1, Reply: operation.Reply{Status: operation.ReplyAck}},
+				{PID: 2, Reply: operation.Reply{Status: operation.ReplyResult, Payload: []byte("ok")}},
+			},
+		},
+		{
+			name: "transport and worker errors",
+			results: Results{
+				{PID: 1, Reply: operation.Reply{Status: operation.ReplyAck}},
+				{PID: 2, Err: errors.New("timeout")},
+				{PID: 3, Reply: operation.Reply{Status: operation.ReplyError, Error: "no handler"}},
+			},
+			failed: []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pids []int
+			for _, r := range tt.results.Failed() {
+				pids = append(pids, r.PID)
+			}
+
+			assert.Equal(t, tt.failed, pids)
+
+			if tt.failed == nil {
+				assert.NoError(t, tt.results.Err())
+				return
+			}
+
+			assert.ErrorContains(t, tt.results.Err(), "pid 3: no handler")
+		})
+	}
+}
@@ -1,8 +1,409 @@
 package manager
 
-import "net"
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
 
+// Listener is one named socket owned by the master
+type Listener struct {
+	Name    string `json:"name"`
+	Network string `json:"network"`
+	Address string `json:"address"`
+	FD      int    `json:"fd"`   // fd duplikat di master yang dikirim ke worker
+	Bind    string `json:"bind"` // fd@N di process haproxy
+
+	l    net.Listener
+	file *os.File
+}
+
+// FirstWorkerFD is the fd of the first listener in haproxy, 0-2 is stdin/stdout/stderr
+const FirstWorkerFD = 3
+
+// ListenerManager own every public listener of the master, urutan open
+// dipertahankan supaya urutan FD yang dikirim ke worker selalu sama.
 type ListenerManager struct {
-	ports []int
-	conns map[int]net.Listener
+	mu        *sync.RWMutex
+	listeners map[string]*Listener
+	order     []string
+}
+
+func NewListenerManager() *ListenerManager {
+	return &ListenerManager{
+		mu:        &sync.RWMutex{},
+		listeners: make(map[string]*Listener),
+	}
+}
+
+// ParseAddress split "network://address", tanpa scheme dianggap tcp.
+// Network yang didukung: tcp, tcp4, tcp6 dan unix (stream).
+func ParseAddress(address string) (string, string, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		network, addr = "tcp", address
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return "", "", fmt.Errorf("unsupported listener network %q", network)
+	}
+
+	if addr == "" {
+		return "", "", errors.New("listener address is empty")
+	}
+
+	return network, addr, nil
+}
+
+// RemoveStaleSocket remove the unix socket left by a previous run at path.
+// File lain di path itu tidak dihapus, address listener datang dari control
+// protocol dan config jadi tidak boleh dipakai buat unlink file sembarangan.
+func RemoveStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+
+	return os.Remove(path)
+}
+
+// OpenListener implements [mastercore.ConnectionManager].
+func (m *ListenerManager) OpenListener(name string, address string) (net.Listener, error) {
+	network, addr, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.listeners[name]; ok {
+		return nil, fmt.Errorf("listener %q already open", name)
+	}
+
+	if network == "unix" {
+		if err := RemoveStaleSocket(addr); err != nil {
+			return nil, fmt.Errorf("cannot open listener %q: %w", name, err)
+		}
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open listener %q on %s: %w", name, address, err)
+	}
+
+	if err := m.add(name, network, l); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Adopt register a listener opened somewhere else, misal warisan master lama
+func (m *ListenerManager) Adopt(name string, l net.Listener) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.listeners[name]; ok {
+		return fmt.Errorf("listener %q already open", name)
+	}
+
+	// sekarang kita pemiliknya, socket file dihapus waktu Close
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(true)
+	}
+
+	return m.add(name, l.Addr().Network(), l)
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+func (m *ListenerManager) add(name, network string, l net.Listener) error {
+	fl, ok := l.(filer)
+	if !ok {
+		return fmt.Errorf("listener %q (%T) has no file descriptor", name, l)
+	}
+
+	// -------------------------------------------------------------------------
+	// NOTE: [CRITICAL WARNING]
+	// Jangan pernah mengganti implementasi di bawah ini dengan `int(f.Fd())`
+	// pada listener utama. Memanggil Fd() akan memaksa socket keluar dari Go
+	// Netpoller dan masuk ke mode BLOCKING system call, l.Accept() macet dan
+	// l.SetDeadline() tidak berfungsi lagi.
+	// File() di sini mengembalikan dup, jadi Fd() aman dipanggil pada dup-nya.
+	// referensi: https://morsmachine.dk/netpoller.html
+	// -------------------------------------------------------------------------
+	f, err := fl.File()
+	if err != nil {
+		return fmt.Errorf("cannot duplicate listener %q: %w", name, err)
+	}
+
+	m.listeners[name] = &Listener{
+		Name:    name,
+		Network: network,
+		Address: l.Addr().String(),
+		FD:      int(f.Fd()),
+		l:       l,
+		file:    f,
+	}
+	m.order = append(m.order, name)
+
+	return nil
+}
+
+// CloseListener implements [mastercore.ConnectionManager].
+func (m *ListenerManager) CloseListener(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.listeners[name]
+	if !ok {
+		return fmt.Errorf("listener %q not found", name)
+	}
+
+	delete(m.listeners, name)
+	for i, n := range m.order {
+		if n == name {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+
+	return errors.Join(entry.file.Close(), entry.l.Close())
+}
+
+// Detached is a listener taken out of the set by Detach. Socket dan fd
+// duplikatnya belum ditutup, worker lama masih accept di FD yang sama.
+type Detached struct {
+	entry *Listener
+	index int
+}
+
+// Detach take name out of the set without closing it. Listener terakhir tidak
+// boleh dilepas, dicek di bawah lock yang sama supaya dua remove yang jalan
+// barengan tidak bisa sama-sama lolos.
+func (m *ListenerManager) Detach(name string) (Detached, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.listeners[name]
+	if !ok {
+		return Detached{}, fmt.Errorf("listener %q not found", name)
+	}
+
+	if len(m.order) == 1 {
+		return Detached{}, fmt.Errorf("listener %q is the last one", name)
+	}
+
+	index := slices.Index(m.order, name)
+
+	delete(m.listeners, name)
+	m.order = slices.Delete(m.order, index, index+1)
+
+	return Detached{entry: entry, index: index}, nil
+}
+
+// Restore put a detached listener back at its place, tanpa bind ulang address-nya
+func (m *ListenerManager) Restore(d Detached) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.listeners[d.entry.Name]; ok {
+		return fmt.Errorf("listener %q already open", d.entry.Name)
+	}
+
+	m.listeners[d.entry.Name] = d.entry
+	m.order = slices.Insert(m.order, min(d.index, len(m.order)), d.entry.Name)
+
+	return nil
+}
+
+// Close close the detached socket and its duplicated fd
+func (d Detached) Close() error {
+	return errors.Join(d.entry.file.Close(), d.entry.l.Close())
+}
+
+// GetListener implements [mastercore.ConnectionManager].
+func (m *ListenerManager) GetListener(name string) (net.Listener, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.listeners[name]
+	if !ok {
+		return nil, false
+	}
+
+	return entry.l, true
+}
+
+// GetRawFD implements [mastercore.ConnectionManager].
+func (m *ListenerManager) GetRawFD(name string) (uintptr, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.listeners[name]
+	if !ok {
+		return 0, fmt.Errorf("listener %q not found", name)
+	}
+
+	return uintptr(entry.FD), nil
+}
+
+// ActivePorts implements [mastercore.ConnectionManager].
+func (m *ListenerManager) ActivePorts() []string {
+	listeners := m.Listeners()
+
+	ports := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		ports = append(ports, l.String())
+	}
+
+	return ports
+}
+
+// Listeners return a snapshot of every listener in FD order
+func (m *ListenerManager) Listeners() []Listener {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Listener, 0, len(m.order))
+	for i, name := range m.order {
+		l := *m.listeners[name]
+		l.Bind = fmt.Sprintf("fd@%d", FirstWorkerFD+i)
+		out = append(out, l)
+	}
+
+	return out
+}
+
+// Lookup return one listener by name
+func (m *ListenerManager) Lookup(name string) (Listener, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i, n := range m.order {
+		if n == name {
+			l := *m.listeners[name]
+			l.Bind = fmt.Sprintf("fd@%d", FirstWorkerFD+i)
+			return l, true
+		}
+	}
+
+	return Listener{}, false
+}
+
+// URL return the address in the form accepted by OpenListener
+func (l Listener) URL() string {
+	return l.Network + "://" + l.Address
+}
+
+// String describe the listener for logs and control output
+func (l Listener) String() string {
+	return fmt.Sprintf("%s %s fd=%d bind=%s", l.Name, l.URL(), l.FD, l.Bind)
+}
+
+// Rights return the names and raw FDs to send in one SCM_RIGHTS message
+func (m *ListenerManager) Rights() ([]string, []int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := append([]string(nil), m.order...)
+	fds := make([]int, 0, len(names))
+	for _, name := range names {
+		fds = append(fds, m.listeners[name].FD)
+	}
+
+	return names, fds
+}
+
+// Files return a fresh dup of every listener in FD order, dipakai waktu upgrade
+func (m *ListenerManager) Files() ([]Listener, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	files := make([]Listener, 0, len(m.order))
+	for _, name := range m.order {
+		entry := *m.listeners[name]
+
+		f, err := entry.l.(filer).File()
+		if err != nil {
+			for _, l := range files {
+				l.file.Close()
+			}
+
+			return nil, fmt.Errorf("cannot duplicate listener %q: %w", name, err)
+		}
+
+		entry.FD = int(f.Fd())
+		entry.file = f
+		files = append(files, entry)
+	}
+
+	return files, nil
+}
+
+// File return the underlying file of the listener
+func (l Listener) File() *os.File {
+	return l.file
+}
+
+// SetUnlinkOnClose control whether unix socket files are removed on Close,
+// dimatikan selama handover supaya master baru masih bisa pakai.
+func (m *ListenerManager) SetUnlinkOnClose(unlink bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, entry := range m.listeners {
+		if ul, ok := entry.l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(unlink)
+		}
+	}
+}
+
+// Close close every listener
+func (m *ListenerManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for _, name := range m.order {
+		entry := m.listeners[name]
+		errs = append(errs, entry.file.Close(), entry.l.Close())
+	}
+
+	m.listeners = make(map[string]*Listener)
+	m.order = nil
+
+	return errors.Join(errs...)
+}
+
+// SortByFD order inherited files by their fd so the original open order is kept
+func SortByFD(files map[string]int) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool { return files[names[i]] < files[names[j]] })
+
+	return names
 }
@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		network string
+		addr    string
+		wantErr bool
+	}{
+		{name: "no scheme", address: ":1111", network: "tcp", addr: ":1111"},
+		{name: "tcp", address: "tcp://127.0.0.1:80", network: "tcp", addr: "127.0.0.1:80"},
+		{name: "tcp6", address: "tcp6://[::1]:443", network: "tcp6", addr: "[::1]:443"},
+		{name: "unix", address: "unix:///tmp/mox.sock", network: "unix", addr: "/tmp/mox.sock"},
+		{name: "udp not supported", address: "udp://:53", wantErr: true},
+		{name: "empty address", address: "tcp://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network, addr, err := ParseAddress(tt.address)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.network, network)
+			assert.Equal(t, tt.addr, addr)
+		})
+	}
+}
+
+func TestListenerManager(t *testing.T) {
+	m := NewListenerManager()
+	defer m.Close()
+
+	_, err := m.OpenListener("http", "tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+
+	_, err = m.OpenListener("admin", "unix://"+filepath.Join(t.TempDir(), "admin.sock"))
+	assert.NoError(t, err)
+
+	_, err = m.OpenListener("http", "tcp://127.0.0.1:0")
+	assert.Error(t, err, "duplicate name")
+
+	assert.Len(t, m.ActivePorts(), 2)
+	assert.Contains(t, m.ActivePorts()[1], "admin unix://")
+
+	names, fds := m.Rights()
+	assert.Equal(t, []string{"http", "admin"}, names)
+	assert.Len(t, fds, 2)
+
+	fd, err := m.GetRawFD("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, uintptr(fds[1]), fd)
+
+	admin, ok := m.Lookup("admin")
+	assert.True(t, ok)
+	assert.Equal(t, "fd@4", admin.Bind)
+
+	assert.NoError(t, m.CloseListener("http"))
+
+	names, _ = m.Rights()
+	assert.Equal(t, []string{"admin"}, names)
+
+	_, ok = m.GetListener("http")
+	assert.False(t, ok)
+}
+
+func TestListenerDetachRestore(t *testing.T) {
+	m := NewListenerManager()
+	defer m.Close()
+
+	http, err := m.OpenListener("http", "tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+
+	_, err = m.OpenListener("admin", "tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+
+	before, _ := m.Lookup("http")
+
+	d, err := m.Detach("http")
+	assert.NoError(t, err)
+
+	names, _ := m.Rights()
+	assert.Equal(t, []string{"admin"}, names)
+
+	_, err = m.Detach("admin")
+	assert.ErrorContains(t, err, "last one")
+
+	_, err = m.Detach("http")
+	assert.ErrorContains(t, err, "not found")
+
+	// masih bound selama dilepas, address-nya belum bisa dipakai lagi
+	_, err = net.Listen("tcp", http.Addr().String())
+	assert.Error(t, err)
+
+	// dikembalikan ke urutan semula dengan FD yang sama
+	assert.NoError(t, m.Restore(d))
+
+	after, ok := m.Lookup("http")
+	assert.True(t, ok)
+	assert.Equal(t, before, after)
+
+	d, err = m.Detach("http")
+	assert.NoError(t, err)
+	assert.NoError(t, d.Close())
+
+	l, err := net.Listen("tcp", http.Addr().String())
+	assert.NoError(t, err)
+	l.Close()
+}
+
+func TestSortByFD(t *testing.T) {
+	assert.Equal(t, []string{"c", "a", "b"}, SortByFD(map[string]int{"a": 4, "b": 5, "c": 3}))
+}
+
+func TestOpenListenerKeepOtherFiles(t *testing.T) {
+	m := NewListenerManager()
+	defer m.Close()
+
+	dir := t.TempDir()
+
+	// file biasa di address unix tidak boleh ikut terhapus
+	file := filepath.Join(dir, "important.conf")
+	assert.NoError(t, os.WriteFile(file, []byte("keep me"), 0o600))
+
+	_, err := m.OpenListener("admin", "unix://"+file)
+	assert.Error(t, err)
+	assert.FileExists(t, file)
+
+	// socket sisa run sebelumnya boleh diganti
+	sock := filepath.Join(dir, "admin.sock")
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	assert.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	_, err = m.OpenListener("admin", "unix://"+sock)
+	assert.NoError(t, err)
+	assert.NoError(t, m.CloseListener("admin"))
+
+	assert.NoError(t, RemoveStaleSocket(filepath.Join(dir, "missing.sock")))
+}
@@ -0,0 +1,105 @@
+package mastercore
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"mox/pkg/config"
+	"mox/use_cases/operation"
+)
+
+// ReloadConfig implements [operation.SystemCore]. Config mox dibaca ulang,
+// key yang aman langsung dipakai (app.log_level, jumlah worker, heartbeat),
+// model proxy dan [worker] di-rollout ke generasi baru, sisanya dilaporkan
+// butuh restart. File yang invalid ditolak dan config yang jalan tetap dipakai.
+func (o *Orchestrator) ReloadConfig(trigger string) (operation.ConfigReport, error) {
+	report, err := o.applyConfig(trigger)
+	if err != nil || len(report.Rollout) == 0 {
+		return report, err
+	}
+
+	reason := fmt.Sprintf("%s: %s changed", trigger, strings.Join(report.Rollout, ", "))
+	if err := o.reloadGeneration("config", reason); err != nil {
+		report.Error = err.Error()
+		o.cfgReport.Store(&report)
+
+		return report, fmt.Errorf("config rollout: %w", err)
+	}
+
+	return report, nil
+}
+
+// ConfigReport implements [operation.SystemCore].
+func (o *Orchestrator) ConfigReport() (operation.ConfigReport, bool) {
+	report := o.cfgReport.Load()
+	if report == nil {
+		return operation.ConfigReport{}, false
+	}
+
+	return *report, true
+}
+
+// applyConfig swap the app config with the file on disk and apply the keys
+// that are not picked up by themselves, rollout diserahkan ke pemanggil
+func (o *Orchestrator) applyConfig(trigger string) (operation.ConfigReport, error) {
+	o.cfgMu.Lock()
+	defer o.cfgMu.Unlock()
+
+	old := o.app.Config()
+
+	next, err := o.app.ReloadConfig()
+	if err != nil {
+		report := operation.ClassifyConfig(trigger, nil)
+		report.Error = err.Error()
+		o.cfgReport.Store(&report)
+
+		o.app.Logger().Error("config file rejected, keeping the running config",
+			slog.String("trigger", trigger),
+			slog.String("err", err.Error()),
+		)
+
+		return report, fmt.Errorf("%w: %w", operation.ErrConfigRejected, err)
+	}
+
+	// file yang tidak berubah (SIGHUP lalu event inotify yang sama) tidak
+	// menimpa laporan terakhir
+	report := operation.ClassifyConfig(trigger, config.Diff(old, next))
+	if !report.Changed() {
+		o.app.Logger().Debug("config file unchanged", slog.String("trigger", trigger))
+		return report, nil
+	}
+
+	o.cfgReport.Store(&report)
+
+	// logger sudah dibuat ulang oleh app, heartbeat dan timeout dibaca tiap dipakai.
+	// Beda dengan SCALE, breaker crash loop tidak di-reset: file yang disimpan
+	// bukan tanda masalahnya sudah beres.
+	if slices.Contains(report.Applied, "master.workers") {
+		o.mu.Lock()
+		o.desired.Store(int64(next.Master.Workers))
+
+		if err := o.reconcileUnlock(); err != nil {
+			report.Error = err.Error()
+			o.cfgReport.Store(&report)
+
+			return report, fmt.Errorf("scale to %d workers: %w", next.Master.Workers, err)
+		}
+	}
+
+	if len(report.Restart) > 0 {
+		o.app.Logger().Warn("config changes need a master restart",
+			slog.String("trigger", trigger),
+			slog.Any("keys", report.Restart),
+		)
+	}
+
+	o.app.Logger().Info("config reloaded",
+		slog.String("trigger", trigger),
+		slog.Any("applied", report.Applied),
+		slog.Any("rollout", report.Rollout),
+	)
+
+	return report, nil
+}
@@ -0,0 +1,75 @@
+package mastercore
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyConfigRejectHeartbeatInterval(t *testing.T) {
+	base, err := os.ReadFile("../../pkg/config/testdata/config.toml")
+	require.NoError(t, err)
+
+	// NewTestAppWithConfig baca ulang config.toml dari working directory
+	o, _ := newTestOrchestrator(t, 1)
+
+	write := func(heartbeat string) {
+		body := string(base) + "\n[master]\nworkers = 1\nheartbeat_interval = \"" + heartbeat + "\"\n"
+		require.NoError(t, os.WriteFile("config.toml", []byte(body), 0o644))
+	}
+
+	write("2s")
+	_, err = o.applyConfig("SIGHUP")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, o.app.Config().Master.HeartbeatInterval)
+
+	for _, heartbeat := range []string{"0s", "-1s"} {
+		t.Run(heartbeat, func(t *testing.T) {
+			write(heartbeat)
+
+			report, err := o.applyConfig("SIGHUP")
+			assert.ErrorIs(t, err, operation.ErrConfigRejected)
+			assert.NotEmpty(t, report.Error)
+
+			// config yang jalan tidak diganti, ticker heartbeat tetap pakai 2s
+			assert.Equal(t, 2*time.Second, o.app.Config().Master.HeartbeatInterval)
+		})
+	}
+}
+
+func TestApplyConfigKeepBreaker(t *testing.T) {
+	base, err := os.ReadFile("../../pkg/config/testdata/config.toml")
+	require.NoError(t, err)
+
+	o, provider := newTestOrchestrator(t, 1)
+	o.SetSupervisor(NewSupervisor(o.app, o))
+	require.NoError(t, o.Reconcile())
+
+	// breaker kebuka karena crash loop
+	o.super.loop.degraded = true
+
+	body := strings.Replace(string(base), "mode = \"development\"", "mode = \"development\"\nlog_level = \"warn\"", 1)
+	body += "\n[master]\nworkers = 2\n"
+	require.NoError(t, os.WriteFile("config.toml", []byte(body), 0o644))
+
+	report, err := o.applyConfig("inotify")
+	require.NoError(t, err)
+	assert.Contains(t, report.Applied, "master.workers")
+	assert.Contains(t, report.Applied, "app.log_level")
+
+	assert.Equal(t, int64(2), o.GetDesiredWorkers())
+	assert.Equal(t, []int{1, 2}, provider.PIDs())
+	assert.True(t, o.super.Degraded(), "a config file save must not reset the breaker")
+
+	// logger dibuat ulang dengan log_level yang baru
+	assert.False(t, o.app.Logger().Enabled(context.Background(), slog.LevelInfo))
+	assert.True(t, o.app.Logger().Enabled(context.Background(), slog.LevelWarn))
+}
@@ -0,0 +1,90 @@
+package mastercore
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// configSettle is how long the config file must stay quiet before it is read,
+// editor biasanya nulis file lewat beberapa event (truncate, write, rename)
+const configSettle = 300 * time.Millisecond
+
+func (m *Master) configFile() string {
+	if path := m.app.ConfigPath(); path != "" {
+		return filepath.Clean(path)
+	}
+
+	return "config.toml"
+}
+
+// watchConfig re-read the mox config on SIGHUP and whenever the file changes.
+// Yang di-watch direktorinya, bukan file-nya, supaya file yang diganti lewat
+// rename (vim, sed -i, ConfigMap k8s) tetap terdeteksi.
+func (m *Master) watchConfig() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	file := m.configFile()
+
+	var (
+		events chan fsnotify.Event
+		errs   chan error
+	)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(filepath.Dir(file))
+	}
+
+	if err != nil {
+		m.app.Logger().Warn("cannot watch config file, only SIGHUP reloads it", slog.String("file", file), slog.String("err", err.Error()))
+	} else {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	settle := time.NewTimer(configSettle)
+	settle.Stop()
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-m.Context.Done():
+			return
+		case <-sig:
+			m.reloadConfig("SIGHUP")
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			if filepath.Clean(e.Name) != file || e.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			settle.Reset(configSettle)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+
+			m.app.Logger().Warn("config watcher error", slog.String("err", err.Error()))
+		case <-settle.C:
+			m.reloadConfig("inotify")
+		}
+	}
+}
+
+func (m *Master) reloadConfig(trigger string) {
+	// error sudah di-log dan dicatat di ConfigReport
+	_, _ = m.orch.ReloadConfig(trigger)
+}
@@ -1,6 +1,12 @@
 package mastercore
 
-import "net"
+import (
+	"net"
+
+	"mox/use_cases/manager"
+)
+
+var _ (ConnectionManager) = (*manager.ListenerManager)(nil)
 
 type ConnectionManager interface {
 	// OpenListener membuka port TCP baru (misal: ":80") dan menyimpannya di registry
@@ -20,6 +26,7 @@ type ConnectionManager interface {
 
 	// --- Inventory & Health ---
 
-	// ActivePorts mengembalikan daftar nama port yang sedang dikelola Master
+	// ActivePorts mengembalikan daftar port yang sedang dikelola Master,
+	// satu baris per listener: nama, alamat dan FD-nya
 	ActivePorts() []string
 }
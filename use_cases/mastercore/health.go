@@ -0,0 +1,457 @@
+package mastercore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+)
+
+// maxHealthBody is how much of the response body is matched against expect_body
+const maxHealthBody = 64 << 10
+
+// healthState is the rise/fall state machine of one server, sama seperti HAProxy:
+// server up baru dianggap down setelah fall kali gagal berturut-turut, dan sebaliknya.
+type healthState struct {
+	up        bool
+	rise      int
+	fall      int
+	successes int
+	failures  int
+}
+
+// observe record one result, true kalau server berubah up/down
+func (h *healthState) observe(ok bool) bool {
+	if ok {
+		h.successes++
+		h.failures = 0
+
+		if !h.up && h.successes >= h.rise {
+			h.up = true
+			return true
+		}
+
+		return false
+	}
+
+	h.failures++
+	h.successes = 0
+
+	if h.up && h.failures >= h.fall {
+		h.up = false
+		return true
+	}
+
+	return false
+}
+
+func (h *healthState) health() haproxy.ServerHealth {
+	if h.up {
+		return haproxy.HealthUp
+	}
+
+	return haproxy.HealthDown
+}
+
+// prober run one [[health.checks]] against a server address
+type prober struct {
+	check  config.HealthCheck
+	body   *regexp.Regexp
+	client *http.Client
+}
+
+func newProber(check config.HealthCheck) (*prober, error) {
+	p := &prober{
+		check: check,
+		client: &http.Client{
+			Transport: &http.Transport{DisableKeepAlives: true},
+			// redirect dinilai dari status-nya sendiri, tidak diikuti
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+
+	if check.ExpectBody != "" {
+		body, err := regexp.Compile(check.ExpectBody)
+		if err != nil {
+			return nil, fmt.Errorf("health check %s: %w", check.Backend, err)
+		}
+
+		p.body = body
+	}
+
+	return p, nil
+}
+
+func (p *prober) probe(ctx context.Context, address string) operation.HealthResult {
+	ctx, cancel := context.WithTimeout(ctx, p.check.Timeout)
+	defer cancel()
+
+	res := operation.HealthResult{Time: time.Now()}
+
+	var err error
+	if p.check.Type == config.CheckTCP {
+		err = p.tcp(ctx, address)
+	} else {
+		res.Status, err = p.http(ctx, address)
+	}
+
+	res.Duration = time.Since(res.Time)
+	res.OK = err == nil
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+func (p *prober) tcp(ctx context.Context, address string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func (p *prober) http(ctx context.Context, address string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, p.check.Method, "http://"+address+p.check.Path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.check.Host != "" {
+		req.Host = p.check.Host
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if !p.expectStatus(resp.StatusCode) {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if p.body == nil {
+		return resp.StatusCode, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if !p.body.Match(body) {
+		return resp.StatusCode, fmt.Errorf("body does not match %q", p.check.ExpectBody)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (p *prober) expectStatus(code int) bool {
+	if len(p.check.ExpectStatus) == 0 {
+		return code >= 200 && code < 400
+	}
+
+	return slices.Contains(p.check.ExpectStatus, code)
+}
+
+// healthTarget is one checked server
+type healthTarget struct {
+	backend string
+	server  string
+	address string
+	check   config.HealthCheck
+	cancel  context.CancelFunc
+
+	mu          *sync.Mutex
+	state       healthState
+	lastCheck   time.Time
+	history     []operation.HealthResult
+	transitions []operation.HealthTransition
+}
+
+// record simpan res ke history, return from/to kalau server berubah up/down
+func (t *healthTarget) record(res operation.HealthResult, keep int) (from, to haproxy.ServerHealth, changed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastCheck = res.Time
+	t.history = ring(t.history, res, keep)
+
+	from = t.state.health()
+	if !t.state.observe(res.OK) {
+		return from, from, false
+	}
+
+	to = t.state.health()
+
+	reason := res.Error
+	if res.OK {
+		reason = "check passed"
+		if res.Status > 0 {
+			reason = fmt.Sprintf("status %d", res.Status)
+		}
+	}
+
+	t.transitions = ring(t.transitions, operation.HealthTransition{Time: res.Time, From: from, To: to, Reason: reason}, keep)
+
+	return from, to, true
+}
+
+func (t *healthTarget) report() operation.HealthReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return operation.HealthReport{
+		Backend:     t.backend,
+		Server:      t.server,
+		Address:     t.address,
+		Check:       t.check.Type,
+		Health:      t.state.health(),
+		Successes:   t.state.successes,
+		Failures:    t.state.failures,
+		LastCheck:   t.lastCheck,
+		History:     slices.Clone(t.history),
+		Transitions: slices.Clone(t.transitions),
+	}
+}
+
+// ring append v and keep only the last n items
+func ring[T any](items []T, v T, n int) []T {
+	items = append(items, v)
+	if n <= 0 {
+		return items[:0]
+	}
+
+	if len(items) > n {
+		items = slices.Delete(items, 0, len(items)-n)
+	}
+
+	return items
+}
+
+// HealthChecker run the [health] checks from the master. Daftar server
+// diambil dari Backends() (termasuk server dinamis), hasil up/down dikirim
+// ke semua worker lewat UpdateServer biar worker baru juga ikut.
+type HealthChecker struct {
+	app  core.App
+	orch *Orchestrator
+
+	mu      *sync.Mutex
+	targets map[string]*healthTarget
+}
+
+func NewHealthChecker(app core.App, orch *Orchestrator) *HealthChecker {
+	return &HealthChecker{app: app, orch: orch, mu: &sync.Mutex{}, targets: make(map[string]*healthTarget)}
+}
+
+// Run check every configured server until ctx is done, daftar server
+// disinkronkan tiap health.interval supaya server baru/hapus ikut terdeteksi.
+func (h *HealthChecker) Run(ctx context.Context) {
+	cfg := h.app.Config().Health
+	if len(cfg.Checks) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		h.sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			h.stopAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecker) sync(ctx context.Context) {
+	backends, err := h.orch.Backends()
+	if err != nil {
+		h.app.Logger().Warn("health check cannot list backends", slog.String("err", err.Error()))
+		return
+	}
+
+	cfg := h.app.Config().Health
+	want := make(map[string]*healthTarget)
+
+	for _, b := range backends {
+		check, ok := cfg.Check(b.Name)
+		if !ok {
+			continue
+		}
+
+		for _, s := range b.Servers {
+			// server maint tidak dicek, sama seperti HAProxy
+			if s.State == haproxy.StateMaint {
+				continue
+			}
+
+			want[b.Name+"/"+s.Name] = &healthTarget{
+				backend: b.Name,
+				server:  s.Name,
+				address: haproxy.Word(s.Address, os.LookupEnv),
+				check:   check,
+				mu:      &sync.Mutex{},
+				// mulai dari health terakhir yang disimpan master
+				state: healthState{up: s.Health != haproxy.HealthDown, rise: check.Rise, fall: check.Fall},
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, t := range h.targets {
+		if w, ok := want[key]; !ok || w.address != t.address || !reflect.DeepEqual(w.check, t.check) {
+			t.cancel()
+			delete(h.targets, key)
+		}
+	}
+
+	for key, t := range want {
+		if _, ok := h.targets[key]; ok {
+			continue
+		}
+
+		p, err := newProber(t.check)
+		if err != nil {
+			h.app.Logger().Warn("health check not started", slog.String("server", key), slog.String("err", err.Error()))
+			continue
+		}
+
+		var tctx context.Context
+		tctx, t.cancel = context.WithCancel(ctx)
+		h.targets[key] = t
+
+		go h.loop(tctx, t, p)
+	}
+}
+
+func (h *HealthChecker) stopAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, t := range h.targets {
+		t.cancel()
+		delete(h.targets, key)
+	}
+}
+
+func (h *HealthChecker) loop(ctx context.Context, t *healthTarget, p *prober) {
+	ticker := time.NewTicker(t.check.Interval)
+	defer ticker.Stop()
+
+	keep := h.app.Config().Health.History
+
+	for {
+		res := p.probe(ctx, t.address)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if from, to, changed := t.record(res, keep); changed {
+			h.transition(t, from, to, res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// transition log the event and push the new health to every worker
+func (h *HealthChecker) transition(t *healthTarget, from, to haproxy.ServerHealth, res operation.HealthResult) {
+	level := slog.LevelInfo
+	if to == haproxy.HealthDown {
+		level = slog.LevelWarn
+	}
+
+	h.app.Logger().LogAttrs(context.Background(), level, "upstream health changed",
+		slog.String("event", "health.transition"),
+		slog.String("backend", t.backend),
+		slog.String("server", t.server),
+		slog.String("address", t.address),
+		slog.String("from", string(from)),
+		slog.String("to", string(to)),
+		slog.Int("status", res.Status),
+		slog.String("error", res.Error),
+		slog.Duration("duration", res.Duration),
+	)
+
+	err := h.orch.UpdateServer(operation.ServerChange{
+		Action:  operation.ServerSetHealth,
+		Backend: t.backend,
+		Server:  t.server,
+		Health:  to,
+	})
+	if err != nil {
+		h.app.Logger().Warn("health change not applied", slog.String("server", t.backend+"/"+t.server), slog.String("err", err.Error()))
+	}
+}
+
+// Reports return the health of every checked server, urut per backend lalu server
+func (h *HealthChecker) Reports() []operation.HealthReport {
+	h.mu.Lock()
+	targets := make([]*healthTarget, 0, len(h.targets))
+	for _, t := range h.targets {
+		targets = append(targets, t)
+	}
+	h.mu.Unlock()
+
+	reports := make([]operation.HealthReport, 0, len(targets))
+	for _, t := range targets {
+		reports = append(reports, t.report())
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Backend != reports[j].Backend {
+			return reports[i].Backend < reports[j].Backend
+		}
+
+		return reports[i].Server < reports[j].Server
+	})
+
+	return reports
+}
+
+// DownBackends return the checked backends without any server up
+func (h *HealthChecker) DownBackends() []string {
+	up := map[string]bool{}
+	for _, r := range h.Reports() {
+		up[r.Backend] = up[r.Backend] || r.Health == haproxy.HealthUp
+	}
+
+	down := []string{}
+	for backend, ok := range up {
+		if !ok {
+			down = append(down, backend)
+		}
+	}
+
+	sort.Strings(down)
+
+	return down
+}
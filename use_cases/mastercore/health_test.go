@@ -0,0 +1,120 @@
+package mastercore
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mox/pkg/config"
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthState(t *testing.T) {
+	tableTests := []struct {
+		name     string
+		up       bool
+		results  []bool
+		expected []bool // transisi tiap result
+		finalUp  bool
+	}{
+		{name: "up stays up below fall", up: true, results: []bool{false, false, true, false}, expected: []bool{false, false, false, false}, finalUp: true},
+		{name: "up goes down after fall", up: true, results: []bool{false, false, false, false}, expected: []bool{false, false, true, false}, finalUp: false},
+		{name: "down comes up after rise", up: false, results: []bool{true, true, true}, expected: []bool{false, true, false}, finalUp: true},
+		{name: "failure resets rise", up: false, results: []bool{true, false, true}, expected: []bool{false, false, false}, finalUp: false},
+	}
+
+	for _, test := range tableTests {
+		t.Run(test.name, func(t *testing.T) {
+			h := &healthState{up: test.up, rise: 2, fall: 3}
+
+			transitions := make([]bool, 0, len(test.results))
+			for _, ok := range test.results {
+				transitions = append(transitions, h.observe(ok))
+			}
+
+			assert.Equal(t, test.expected, transitions)
+			assert.Equal(t, test.finalUp, h.up)
+		})
+	}
+}
+
+func TestHealthProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte("status: ok"))
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	address := strings.TrimPrefix(srv.URL, "http://")
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	tableTests := []struct {
+		name    string
+		check   config.HealthCheck
+		address string
+		ok      bool
+		status  int
+	}{
+		{name: "http ok", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/healthz"}, address: address, ok: true, status: 200},
+		{name: "http body match", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/healthz", ExpectBody: "status: (ok|up)"}, address: address, ok: true, status: 200},
+		{name: "http body mismatch", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/healthz", ExpectBody: "down"}, address: address, ok: false, status: 200},
+		{name: "http redirect is 3xx", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/moved"}, address: address, ok: true, status: 302},
+		{name: "http expect status", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/moved", ExpectStatus: []int{200}}, address: address, ok: false, status: 302},
+		{name: "http unavailable", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/"}, address: address, ok: false, status: 503},
+		{name: "http refused", check: config.HealthCheck{Type: config.CheckHTTP, Path: "/"}, address: closedAddress, ok: false},
+		{name: "tcp ok", check: config.HealthCheck{Type: config.CheckTCP}, address: address, ok: true},
+		{name: "tcp refused", check: config.HealthCheck{Type: config.CheckTCP}, address: closedAddress, ok: false},
+	}
+
+	for _, test := range tableTests {
+		t.Run(test.name, func(t *testing.T) {
+			test.check.Method = "GET"
+			test.check.Timeout = time.Second
+
+			p, err := newProber(test.check)
+			assert.NoError(t, err)
+
+			res := p.probe(context.Background(), test.address)
+			assert.Equal(t, test.ok, res.OK, res.Error)
+			assert.Equal(t, test.status, res.Status)
+		})
+	}
+}
+
+func TestHealthTargetHistory(t *testing.T) {
+	target := &healthTarget{
+		backend: "api",
+		server:  "a",
+		check:   config.HealthCheck{Type: config.CheckHTTP},
+		mu:      &sync.Mutex{},
+		state:   healthState{up: true, rise: 1, fall: 1},
+	}
+
+	for i := 0; i < 5; i++ {
+		target.record(operation.HealthResult{OK: i%2 == 0, Status: i}, 3)
+	}
+
+	report := target.report()
+	assert.Len(t, report.History, 3)
+	assert.Equal(t, 4, report.History[2].Status)
+	assert.Len(t, report.Transitions, 3)
+	assert.Equal(t, haproxy.HealthUp, report.Health)
+	assert.Equal(t, haproxy.HealthDown, report.Transitions[2].From)
+}
@@ -2,10 +2,17 @@ package mastercore
 
 import (
 	"context"
+	"log/slog"
+	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
 
 	core "mox/internal"
+	"mox/pkg/upgrade"
 	"mox/use_cases/bus"
+	"mox/use_cases/manager"
 	"mox/use_cases/operation"
 	"mox/use_cases/workerclient"
 )
@@ -15,6 +22,13 @@ type Master struct {
 	workers *ConnectionRegistry
 	control operation.IControl
 	server  *bus.IPCServerGateway
+	conns   *manager.ListenerManager
+	orch    *Orchestrator
+	super   *Supervisor
+	upgr    *Upgrader
+	health  *HealthChecker
+
+	handedOff atomic.Bool // true setelah master baru ambil alih semua socket
 
 	Orchestrator operation.SystemCore
 	Mu           sync.RWMutex    // Biar aman pas nambah/hapus worker dari goroutine
@@ -28,13 +42,41 @@ func NewMasterCore(
 ) *Master {
 	conns := NewConnectionRegistry(ctx, app)
 	orchestrator := NewOrchestrator(app, conns)
+	supervisor := NewSupervisor(app, orchestrator)
+	orchestrator.SetSupervisor(supervisor)
+
+	listeners := manager.NewListenerManager()
+	orchestrator.SetListeners(listeners)
+
+	health := NewHealthChecker(app, orchestrator)
+	orchestrator.SetHealthChecker(health)
 
-	return &Master{
+	m := &Master{
 		app:          app,
 		Context:      ctx,
 		workers:      conns,
+		orch:         orchestrator,
+		super:        supervisor,
+		conns:        listeners,
+		health:       health,
 		Orchestrator: orchestrator,
 	}
+
+	if err := registerMetrics(conns); err != nil {
+		app.Logger().Warn("worker metrics are not registered", slog.String("err", err.Error()))
+	}
+
+	m.upgr = NewUpgrader(app, m)
+	orchestrator.SetUpgrader(m.upgr)
+
+	return m
+}
+
+// SetRevisions attach the store where every applied proxy config is kept
+func (m *Master) SetRevisions(store operation.RevisionStore) *Master {
+	m.orch.SetRevisions(store)
+
+	return m
 }
 
 func (m *Master) SetOperations(control operation.IControl) *Master {
@@ -47,27 +89,68 @@ func (m *Master) Stop() {
 	m.server.Close()
 }
 
+// HandedOff true kalau master ini sudah digantikan binary baru,
+// worker jangan di-shutdown waktu Close.
+func (m *Master) HandedOff() bool {
+	return m.handedOff.Load()
+}
+
+// adopt register workers handed over by the previous master
+func (m *Master) adopt() {
+	for _, w := range m.server.AdoptWorkers() {
+		if err := w.Start(); err != nil {
+			m.app.Logger().Error("adopted worker is not reachable", slog.Int("pid", w.PID()), slog.String("err", err.Error()))
+			continue
+		}
+
+		m.workers.Add(w)
+		m.orch.adoptGeneration(w.Generation())
+		m.app.Logger().Info("worker adopted", slog.Int("pid", w.PID()), slog.Int("generation", w.Generation()))
+	}
+
+	if manifest, ok := upgrade.Inherited(); ok {
+		m.orch.Scale(manifest.Desired)
+	}
+}
+
+func (m *Master) watchUpgradeSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-m.Context.Done():
+			return
+		case <-sig:
+			if err := m.upgr.Upgrade(); err != nil {
+				m.app.Logger().Error("master upgrade failed", slog.String("err", err.Error()))
+			}
+		}
+	}
+}
+
 func (m *Master) Run() error {
 	m.app.Logger().Info("running all IPC Server")
 
+	cfg := m.app.Config().Master
+
 	server := bus.NewIPCServerGateway(
 		m.app,
-		"/tmp/http_mgr.sock",
-		"localhost",
-		1111,
-		bus.TCP,
-	)
-
-	// nangkep listen
+		cfg.SocketPath,
+		m.conns,
+	).
+		SetPeerPolicy(bus.NewPeerPolicy(cfg.AllowedUIDs, cfg.AllowedGIDs)).
+		SetSocketMode(cfg.SocketFileMode()).
+		SetControlAddress(cfg.ControlAddress)
+
+	// nangkep listen, tiap command jalan sendiri biar RELOAD atau CANARY yang
+	// lama tidak menahan HELP/WORKERS/STATS dari client lain. Urutan balasan
+	// per koneksi tetap, baris berikutnya baru dibaca setelah Done ditutup.
 	go func(evt chan bus.Event) {
 		m.app.Logger().Info("listening all messages")
 		for e := range evt {
-			_, err := m.control.Execute(m.Context, m.Orchestrator, e.Payload)
-			if err != nil {
-				m.app.Logger().Error(err.Error())
-				return
-			}
-
+			go m.execute(e)
 		}
 	}(server.Event)
 
@@ -84,15 +167,47 @@ func (m *Master) Run() error {
 		}
 	}(server.WorkerEvent)
 
-	// run for this TCP server
-	go server.ListenAndServe()
+	// run for this TCP server, unix socket harus siap sebelum worker di-spawn
+	if err := server.ListenAndServe(); err != nil {
+		return err
+	}
+	m.server = server
+
+	// worker warisan harus terdaftar dulu sebelum reconcile, biar tidak spawn kelebihan
+	m.adopt()
+
 	go m.workers.CheckHealthWorkers()
+	go m.super.Run(m.Context, m.workers.Exits())
+	go m.watchUpgradeSignal()
+	go m.watchConfig()
+	go m.health.Run(m.Context)
 
-	m.server = server
+	if err := m.orch.Reconcile(); err != nil {
+		m.app.Logger().Error("cannot spawn desired workers", slog.String("err", err.Error()))
+	}
+
+	m.orch.recordStartup()
 
 	return nil
 }
 
+// execute run one control command, command yang gagal cuma dibalas ERR ke client-nya
+func (m *Master) execute(e bus.Event) {
+	out, err := m.control.Execute(m.Context, m.Orchestrator, e.Payload)
+	if err != nil {
+		m.app.Logger().Warn("control command failed", slog.String("command", e.Payload.Name), slog.String("source", e.SourceID), slog.String("err", err.Error()))
+	}
+
+	if err := e.Reply(out, err); err != nil {
+		m.app.Logger().Warn("cannot reply to control client", slog.String("source", e.SourceID), slog.String("err", err.Error()))
+	}
+}
+
 func (m *Master) Connections() *ConnectionRegistry {
 	return m.workers
 }
+
+// Listeners return the public listeners shared with every worker
+func (m *Master) Listeners() ConnectionManager {
+	return m.conns
+}
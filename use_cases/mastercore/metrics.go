@@ -0,0 +1,95 @@
+package mastercore
+
+import (
+	"context"
+	"strconv"
+
+	"mox/pkg/procstat"
+	"mox/use_cases/operation"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "mox/master"
+
+// registerMetrics expose the worker stats as OTel gauges. Pakai global meter
+// provider, jadi kalau telemetry mati semuanya no-op.
+func registerMetrics(registry Registry) error {
+	meter := otel.Meter(meterName)
+
+	cpu, err := meter.Float64ObservableGauge("mox.process.cpu.time", metric.WithUnit("s"), metric.WithDescription("CPU time (user+system) of worker and haproxy processes"))
+	if err != nil {
+		return err
+	}
+
+	rss, err := meter.Int64ObservableGauge("mox.process.memory.rss", metric.WithUnit("By"), metric.WithDescription("Resident set size of worker and haproxy processes"))
+	if err != nil {
+		return err
+	}
+
+	fds, err := meter.Int64ObservableGauge("mox.process.open_fds", metric.WithDescription("Open file descriptors of worker and haproxy processes"))
+	if err != nil {
+		return err
+	}
+
+	threads, err := meter.Int64ObservableGauge("mox.process.threads", metric.WithDescription("Thread count of worker and haproxy processes"))
+	if err != nil {
+		return err
+	}
+
+	currConns, err := meter.Int64ObservableGauge("mox.haproxy.connections.current", metric.WithDescription("Current HAProxy connections per worker"))
+	if err != nil {
+		return err
+	}
+
+	cumConns, err := meter.Int64ObservableGauge("mox.haproxy.connections.total", metric.WithDescription("Cumulative HAProxy connections per worker"))
+	if err != nil {
+		return err
+	}
+
+	cumReq, err := meter.Int64ObservableGauge("mox.haproxy.requests.total", metric.WithDescription("Cumulative HAProxy requests per worker"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		for _, s := range registry.Stats().Workers {
+			worker := strconv.Itoa(s.Worker.PID)
+
+			observe := func(kind string, p procstat.Process) {
+				attrs := metric.WithAttributes(
+					attribute.String("worker.pid", worker),
+					attribute.String("process.kind", kind),
+				)
+
+				o.ObserveFloat64(cpu, p.CPUTime.Seconds(), attrs)
+				o.ObserveInt64(rss, int64(p.RSS), attrs)
+				o.ObserveInt64(fds, int64(p.OpenFDs), attrs)
+				o.ObserveInt64(threads, int64(p.Threads), attrs)
+			}
+
+			observe("worker", s.Worker)
+			if s.HAProxy != nil {
+				observe("haproxy", *s.HAProxy)
+			}
+
+			if s.Counters != nil {
+				observeCounters(o, worker, *s.Counters, currConns, cumConns, cumReq)
+			}
+		}
+
+		return nil
+	}, cpu, rss, fds, threads, currConns, cumConns, cumReq)
+
+	return err
+}
+
+func observeCounters(o metric.Observer, worker string, c operation.HAProxyCounters, curr, cum, req metric.Int64Observable) {
+	attrs := metric.WithAttributes(attribute.String("worker.pid", worker))
+
+	o.ObserveInt64(curr, c.CurrConns, attrs)
+	o.ObserveInt64(cum, c.CumConns, attrs)
+	o.ObserveInt64(req, c.CumReq, attrs)
+}
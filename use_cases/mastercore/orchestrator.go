@@ -1,46 +1,64 @@
 package mastercore
 
 import (
+	"errors"
 	"fmt"
-	"time"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	core "mox/internal"
-	"mox/tools/utils"
 	"mox/use_cases/bus"
+	"mox/use_cases/manager"
 	"mox/use_cases/operation"
-	"mox/use_cases/workerclient"
 )
 
 var _ (operation.SystemCore) = (*Orchestrator)(nil)
 
 type Orchestrator struct {
 	app      core.App
-	provider workerclient.WorkerProvider
+	provider Registry
 	bus      bus.Messaging
+	spawner  Spawner
+	super    *Supervisor
+	upgr     *Upgrader
+	health   *HealthChecker
+	conns    *manager.ListenerManager
+
+	mu         *sync.Mutex  // serialize scale operation
+	desired    atomic.Int64 // diubah di bawah mu, dibaca tanpa lock selama rollout jalan
+	generation int          // generasi yang sedang serve, naik tiap rollout berhasil
+	issued     int          // generasi tertinggi yang pernah di-spawn, termasuk yang di-rollback
+
+	reloading atomic.Bool
+	rlMu      *sync.Mutex
+	reload    *operation.ReloadStatus // rollout terakhir
+
+	revisions operation.RevisionStore
+	pinned    atomic.Pointer[string] // haproxy.cfg revisi hasil rollback, nil = config di disk
+
+	cfgMu     *sync.Mutex // serialize baca ulang config mox (SIGHUP, inotify, reload)
+	cfgReport atomic.Pointer[operation.ConfigReport]
+
+	retMu    *sync.Mutex
+	retiring map[int]struct{} // worker yang sengaja dipensiunkan, jangan di-restart
+	starting map[int]struct{} // worker generasi baru yang belum dipromosikan, tidak dihitung reconcile
+
+	srvMu *sync.Mutex // serialize perubahan server state file
 }
 
 // Drain implements [operation.SystemCore].
 func (o *Orchestrator) Drain(pid int) error {
 	worker := o.provider.Get(pid)
 	if worker == nil {
-		o.app.Logger().Error(fmt.Sprintf("there is no worker process found in pid %d", pid))
-		return nil
+		return fmt.Errorf("there is no worker process found in pid %d", pid)
 	}
 
-	command := "set maxconn frontend mygateway 100"
+	_, err := worker.Drain()
 
-	return o.bus.Send(o.app.Context(), operation.MessagePayload{
-		ID:      utils.GenerateUUID(),
-		FromPID: pid,
-		Payload: operation.Command{
-			Name:        "Draining",
-			Description: "Draining one PID to shut the gate",
-			Usage:       command,
-			Type:        operation.Drain,
-			Payload:     []byte(command),
-		},
-		Timestamp: time.Now().UnixMilli(),
-	}, worker)
+	return err
 }
 
 // GetTotalWorkers implements [operation.SystemCore].
@@ -48,21 +66,418 @@ func (o *Orchestrator) GetTotalWorkers() int64 {
 	return o.provider.Total()
 }
 
-func NewOrchestrator(app core.App, provider workerclient.WorkerProvider) *Orchestrator {
-	return &Orchestrator{app: app, bus: bus.NewEventBus(app), provider: provider}
+// Workers implements [operation.SystemCore].
+func (o *Orchestrator) Workers() []operation.WorkerInfo {
+	o.retMu.Lock()
+	retiring := maps.Clone(o.retiring)
+	o.retMu.Unlock()
+
+	workers := []operation.WorkerInfo{}
+	for _, w := range o.provider.GetAll() {
+		_, r := retiring[w.PID()]
+
+		workers = append(workers, operation.WorkerInfo{
+			PID:        w.PID(),
+			Generation: w.Generation(),
+			ConfigHash: w.ConfigHash(),
+			State:      w.State().String(),
+			Retiring:   r,
+			LastSeen:   w.LastSeen(),
+			RTT:        w.RTT(),
+		})
+	}
+
+	slices.SortFunc(workers, func(a, b operation.WorkerInfo) int {
+		return a.PID - b.PID
+	})
+
+	return workers
+}
+
+// Stats implements [operation.SystemCore].
+func (o *Orchestrator) Stats() operation.StatsReport {
+	return o.provider.Stats()
+}
+
+// ProxyStats implements [operation.SystemCore].
+func (o *Orchestrator) ProxyStats() operation.ProxyStats {
+	return o.provider.Stats().ProxyStats()
+}
+
+// GetDesiredWorkers implements [operation.SystemCore].
+func (o *Orchestrator) GetDesiredWorkers() int64 {
+	return o.desired.Load()
+}
+
+func NewOrchestrator(app core.App, provider Registry) *Orchestrator {
+	o := &Orchestrator{
+		app:      app,
+		bus:      bus.NewEventBus(app),
+		provider: provider,
+		spawner:  NewWorkerSpawner(app),
+		mu:       &sync.Mutex{},
+		retMu:    &sync.Mutex{},
+		retiring: make(map[int]struct{}),
+		starting: make(map[int]struct{}),
+		srvMu:    &sync.Mutex{},
+		rlMu:     &sync.Mutex{},
+		cfgMu:    &sync.Mutex{},
+	}
+	o.desired.Store(int64(app.Config().Master.Workers))
+
+	return o
+}
+
+// SetSupervisor attach the supervisor so health and manual scaling know about crash loops
+func (o *Orchestrator) SetSupervisor(s *Supervisor) *Orchestrator {
+	o.super = s
+
+	return o
+}
+
+func (o *Orchestrator) SetUpgrader(u *Upgrader) *Orchestrator {
+	o.upgr = u
+
+	return o
+}
+
+// SetHealthChecker attach the upstream health checker owned by the master
+func (o *Orchestrator) SetHealthChecker(h *HealthChecker) *Orchestrator {
+	o.health = h
+
+	return o
+}
+
+// SetListeners attach the listener set shared with the workers
+func (o *Orchestrator) SetListeners(conns *manager.ListenerManager) *Orchestrator {
+	o.conns = conns
+
+	return o
+}
+
+// Listeners implements [operation.SystemCore].
+func (o *Orchestrator) Listeners() []manager.Listener {
+	if o.conns == nil {
+		return []manager.Listener{}
+	}
+
+	return o.conns.Listeners()
+}
+
+// AddListener implements [operation.SystemCore].
+func (o *Orchestrator) AddListener(name string, address string) error {
+	if o.conns == nil {
+		return errors.New("listeners are not available")
+	}
+
+	if _, err := o.conns.OpenListener(name, address); err != nil {
+		return err
+	}
+
+	if err := o.Rollout("master", fmt.Sprintf("listener %s added", name)); err != nil {
+		// worker lama masih pakai set FD lama, listener baru dibuang lagi
+		o.conns.CloseListener(name)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveListener implements [operation.SystemCore].
+func (o *Orchestrator) RemoveListener(name string) error {
+	if o.conns == nil {
+		return errors.New("listeners are not available")
+	}
+
+	// worker lama tetap pegang FD-nya sampai selesai di-drain
+	detached, err := o.conns.Detach(name)
+	if err != nil {
+		return err
+	}
+
+	if err := o.Rollout("master", fmt.Sprintf("listener %s removed", name)); err != nil {
+		// socket-nya belum ditutup, dikembalikan tanpa bind ulang address yang masih dipakai worker lama
+		if rerr := o.conns.Restore(detached); rerr != nil {
+			o.app.Logger().Error("cannot restore listener", slog.String("listener", name), slog.String("err", rerr.Error()))
+		}
+
+		return err
+	}
+
+	return detached.Close()
+}
+
+// Upgrade implements [operation.SystemCore].
+func (o *Orchestrator) Upgrade() error {
+	if o.upgr == nil {
+		return errors.New("upgrade is not available")
+	}
+
+	return o.upgr.Upgrade()
 }
 
 // CheckHealth implements [operation.SystemCore].
 func (o *Orchestrator) CheckHealth() string {
+	if o.super != nil && o.super.Degraded() {
+		return "DEGRADED"
+	}
+
+	// ada backend yang semua server-nya down dari health check
+	if o.health != nil && len(o.health.DownBackends()) > 0 {
+		return "DEGRADED"
+	}
+
 	return "HEALTHY"
 }
 
+// Health implements [operation.SystemCore].
+func (o *Orchestrator) Health() []operation.HealthReport {
+	if o.health == nil {
+		return []operation.HealthReport{}
+	}
+
+	return o.health.Reports()
+}
+
+// resetBreaker dipanggil tiap operator scale manual, anggap masalahnya sudah ditangani
+func (o *Orchestrator) resetBreaker() {
+	if o.super != nil {
+		o.super.Reset()
+	}
+}
+
+// retired report (and forget) whether pid was retired on purpose. Worker
+// generasi baru yang mati sebelum dipromosikan diurus rollback rollout-nya.
+func (o *Orchestrator) retired(pid int) bool {
+	o.retMu.Lock()
+	defer o.retMu.Unlock()
+
+	_, retiring := o.retiring[pid]
+	_, starting := o.starting[pid]
+	delete(o.retiring, pid)
+	delete(o.starting, pid)
+
+	return retiring || starting
+}
+
+// adoptGeneration continue the generation count of workers handed over by the previous master
+func (o *Orchestrator) adoptGeneration(gen int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.generation = max(o.generation, gen)
+}
+
+// forget shrink the desired count after a worker that won't be restarted
+func (o *Orchestrator) forget() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.desired.Load() > 0 {
+		o.desired.Add(-1)
+	}
+}
+
 // ScaleDown implements [operation.SystemCore].
-func (o *Orchestrator) ScaleDown() {
-	panic("unimplemented")
+func (o *Orchestrator) ScaleDown() error {
+	o.mu.Lock()
+
+	if o.desired.Load() == 0 {
+		o.mu.Unlock()
+		return errors.New("there is no worker left to scale down")
+	}
+
+	o.desired.Add(-1)
+	o.resetBreaker()
+
+	return o.reconcileUnlock()
 }
 
 // ScaleUp implements [operation.SystemCore].
-func (o *Orchestrator) ScaleUp() {
-	panic("unimplemented")
+func (o *Orchestrator) ScaleUp() error {
+	o.mu.Lock()
+
+	o.desired.Add(1)
+	o.resetBreaker()
+
+	return o.reconcileUnlock()
+}
+
+// Scale implements [operation.SystemCore].
+func (o *Orchestrator) Scale(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid worker count %d", n)
+	}
+
+	o.mu.Lock()
+
+	o.desired.Store(int64(n))
+	o.resetBreaker()
+
+	return o.reconcileUnlock()
+}
+
+// Reconcile spawn or retire workers until the live count match the desired count
+func (o *Orchestrator) Reconcile() error {
+	o.mu.Lock()
+
+	return o.reconcileUnlock()
+}
+
+// reconcileUnlock reconcile with o.mu held, lepas lock-nya, baru worker yang
+// kelebihan dipensiunkan. Drain + stop_timeout bisa lama, operasi lain dan
+// respawn supervisor tidak boleh ikut nunggu.
+func (o *Orchestrator) reconcileUnlock() error {
+	victims, err := o.reconcile()
+	o.mu.Unlock()
+
+	return errors.Join(err, o.retireAll(victims))
+}
+
+// reconcile spawn the missing workers and return the ones to retire, sudah
+// ditandai retiring supaya reconcile berikutnya tidak menghitung atau memilih
+// mereka lagi. Dipanggil dengan o.mu dipegang.
+func (o *Orchestrator) reconcile() ([]int, error) {
+	live := o.provider.Live() - o.asideLive()
+	desired := int(o.desired.Load())
+
+	o.app.Logger().Info("reconciling workers", slog.Int("live", live), slog.Int("desired", desired))
+
+	for ; live < desired; live++ {
+		if err := o.spawn(); err != nil {
+			return nil, err
+		}
+	}
+
+	var victims []int
+
+	for ; live > desired; live-- {
+		pid, ok := o.provider.Newest(o.aside)
+		if !ok {
+			break
+		}
+
+		o.markRetiring(pid)
+		victims = append(victims, pid)
+	}
+
+	return victims, nil
+}
+
+// retireAll retire every pid one after another, error-nya digabung
+func (o *Orchestrator) retireAll(pids []int) error {
+	var errs []error
+	for _, pid := range pids {
+		if err := o.retire(pid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o *Orchestrator) markRetiring(pid int) {
+	o.retMu.Lock()
+	defer o.retMu.Unlock()
+
+	o.retiring[pid] = struct{}{}
+	delete(o.starting, pid)
+}
+
+func (o *Orchestrator) markStarting(pid int) {
+	o.retMu.Lock()
+	defer o.retMu.Unlock()
+
+	o.starting[pid] = struct{}{}
+}
+
+// unstage count pids as regular workers again, generasinya sudah dipromosikan
+func (o *Orchestrator) unstage(pids []int) {
+	o.retMu.Lock()
+	defer o.retMu.Unlock()
+
+	for _, pid := range pids {
+		delete(o.starting, pid)
+	}
+}
+
+// aside tell whether pid is retiring or part of a generation not promoted yet
+func (o *Orchestrator) aside(pid int) bool {
+	o.retMu.Lock()
+	defer o.retMu.Unlock()
+
+	_, retiring := o.retiring[pid]
+	_, starting := o.starting[pid]
+
+	return retiring || starting
+}
+
+// asideLive count retiring and not yet promoted workers that are still alive
+func (o *Orchestrator) asideLive() int {
+	o.retMu.Lock()
+	pids := slices.Collect(maps.Keys(o.retiring))
+	pids = append(pids, slices.Collect(maps.Keys(o.starting))...)
+	o.retMu.Unlock()
+
+	n := 0
+	for _, pid := range pids {
+		if _, tracked := o.provider.Process(pid); tracked || o.provider.Get(pid) != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (o *Orchestrator) spawn() error {
+	cmd, err := o.spawner.Spawn(o.generation, o.configHash(), o.pinnedFile())
+	if err != nil {
+		o.app.Logger().Error(err.Error())
+		return err
+	}
+
+	o.provider.Track(cmd)
+
+	return nil
+}
+
+// retire drain the worker first, ask it to shut down and wait
+// until the process is gone. Kalau bandel, kill paksa.
+func (o *Orchestrator) retire(pid int) error {
+	o.app.Logger().Info("retiring worker", slog.Int("pid", pid))
+
+	o.markRetiring(pid)
+
+	// dicek sebelum shutdown, proses yang keburu exit tetap dianggap spawn master
+	cmd, tracked := o.provider.Process(pid)
+
+	if worker := o.provider.Get(pid); worker != nil {
+		if _, err := worker.Drain(); err != nil {
+			o.app.Logger().Warn("drain failed", slog.Int("pid", pid), slog.String("err", err.Error()))
+		}
+
+		if err := worker.Shutdown(); err != nil {
+			o.app.Logger().Warn("shutdown failed", slog.Int("pid", pid), slog.String("err", err.Error()))
+		}
+	}
+
+	if !tracked {
+		// worker external, master cuma bisa minta dia berhenti
+		o.retired(pid)
+		return o.provider.Remove(pid)
+	}
+
+	if o.provider.Wait(pid, o.app.Config().Master.StopTimeout) {
+		return nil
+	}
+
+	o.app.Logger().Warn("worker did not exit in time, killing", slog.Int("pid", pid))
+
+	if err := cmd.Process.Kill(); err != nil {
+		return fmt.Errorf("cannot kill worker %d: %w", pid, err)
+	}
+
+	o.provider.Wait(pid, o.app.Config().Master.StopTimeout)
+
+	return nil
 }
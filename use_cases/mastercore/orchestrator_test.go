@@ -0,0 +1,445 @@
+package mastercore
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/pkg/config"
+	"mox/use_cases/manager"
+	"mox/use_cases/operation"
+	"mox/use_cases/workerclient"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeWorker is a connected worker, Shutdown bikin prosesnya "exit"
+type fakeWorker struct {
+	workerclient.WorkerProcess
+
+	pid      int
+	provider *fakeProvider
+	draining chan struct{} // ditutup waktu Drain mulai
+	release  chan struct{} // nil = drain langsung selesai
+}
+
+func (w *fakeWorker) PID() int { return w.pid }
+
+func (w *fakeWorker) State() workerclient.WorkerClientState { return workerclient.Connected }
+
+func (w *fakeWorker) Generation() int { return 0 }
+
+func (w *fakeWorker) Ready(ctx context.Context) error { return nil }
+
+func (w *fakeWorker) Stats() (operation.WorkerStats, bool) { return operation.WorkerStats{}, false }
+
+func (w *fakeWorker) Drain() (operation.DrainReport, error) {
+	close(w.draining)
+
+	if w.release != nil {
+		<-w.release
+	}
+
+	return operation.DrainReport{}, nil
+}
+
+func (w *fakeWorker) Shutdown() error {
+	w.provider.exit(w.pid)
+	return nil
+}
+
+// fakeProvider keep workers in memory, urutan spawn = urutan pid
+type fakeProvider struct {
+	mu      sync.Mutex
+	procs   map[int]*asyncexec.Cmd
+	exited  map[int]chan struct{}
+	workers map[int]*fakeWorker
+	hold    map[int]chan struct{} // drain worker ini ditahan sampai channel ditutup
+	crashed map[int]bool          // proses mati tanpa disuruh
+}
+
+var _ (Registry) = (*fakeProvider)(nil)
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{
+		procs:   make(map[int]*asyncexec.Cmd),
+		exited:  make(map[int]chan struct{}),
+		workers: make(map[int]*fakeWorker),
+		hold:    make(map[int]chan struct{}),
+		crashed: make(map[int]bool),
+	}
+}
+
+func (p *fakeProvider) Track(cmd *asyncexec.Cmd) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pid := cmd.Process.Pid
+	p.procs[pid] = cmd
+	p.exited[pid] = make(chan struct{})
+	p.workers[pid] = &fakeWorker{pid: pid, provider: p, draining: make(chan struct{}), release: p.hold[pid]}
+}
+
+func (p *fakeProvider) exit(pid int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.procs[pid]; !ok {
+		return
+	}
+
+	delete(p.procs, pid)
+	delete(p.workers, pid)
+	close(p.exited[pid])
+}
+
+func (p *fakeProvider) worker(pid int) *fakeWorker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.workers[pid]
+}
+
+func (p *fakeProvider) Process(pid int) (*asyncexec.Cmd, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cmd, ok := p.procs[pid]
+
+	return cmd, ok
+}
+
+func (p *fakeProvider) Wait(pid int, timeout time.Duration) bool {
+	p.mu.Lock()
+	exited, ok := p.exited[pid]
+	p.mu.Unlock()
+
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (p *fakeProvider) Live() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.procs)
+}
+
+func (p *fakeProvider) Newest(skip func(pid int) bool) (int, bool) {
+	pids := p.PIDs()
+	slices.Reverse(pids)
+
+	for _, pid := range pids {
+		if !skip(pid) {
+			return pid, true
+		}
+	}
+
+	return 0, false
+}
+
+func (p *fakeProvider) PIDs() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pids := make([]int, 0, len(p.procs))
+	for pid := range p.procs {
+		pids = append(pids, pid)
+	}
+
+	slices.Sort(pids)
+
+	return pids
+}
+
+func (p *fakeProvider) Exits() <-chan WorkerExit { return nil }
+
+func (p *fakeProvider) Stats() operation.StatsReport { return operation.StatsReport{} }
+
+func (p *fakeProvider) Get(pid int) workerclient.WorkerProcess {
+	if w := p.worker(pid); w != nil {
+		return w
+	}
+
+	return nil
+}
+
+func (p *fakeProvider) GetAll() []workerclient.WorkerProcess {
+	workers := []workerclient.WorkerProcess{}
+	for _, pid := range p.PIDs() {
+		workers = append(workers, p.Get(pid))
+	}
+
+	return workers
+}
+
+func (p *fakeProvider) Total() int64 { return int64(p.Live()) }
+
+func (p *fakeProvider) Add(worker workerclient.WorkerProcess) error { return nil }
+
+func (p *fakeProvider) Remove(pid int) error { return nil }
+
+// fakeSpawner hand out increasing pids without starting any process
+type fakeSpawner struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *fakeSpawner) Spawn(generation int, configHash, pinned string) (*asyncexec.Cmd, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next++
+
+	return &asyncexec.Cmd{Cmd: &exec.Cmd{Process: &os.Process{Pid: s.next}}, Terminated: make(chan bool)}, nil
+}
+
+func newTestOrchestrator(t *testing.T, workers int) (*Orchestrator, *fakeProvider) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	app := core.NewTestAppWithConfig(config.Config{
+		Master: config.MasterConfig{Workers: workers, StopTimeout: time.Second},
+	})
+
+	provider := newFakeProvider()
+	o := NewOrchestrator(app, provider)
+	o.spawner = &fakeSpawner{}
+
+	return o, provider
+}
+
+func TestOrchestratorReconcile(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 2)
+
+	assert.NoError(t, o.Reconcile())
+	assert.Equal(t, []int{1, 2}, provider.PIDs())
+
+	assert.NoError(t, o.Scale(4))
+	assert.Equal(t, []int{1, 2, 3, 4}, provider.PIDs())
+
+	// yang paling baru dipensiunkan duluan
+	assert.NoError(t, o.Scale(1))
+	assert.Equal(t, []int{1}, provider.PIDs())
+	assert.Equal(t, int64(1), o.GetDesiredWorkers())
+
+	assert.NoError(t, o.ScaleDown())
+	assert.Empty(t, provider.PIDs())
+	assert.Error(t, o.ScaleDown())
+}
+
+func TestOrchestratorRetireOutsideLock(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 2)
+
+	release := make(chan struct{})
+	provider.hold[2] = release
+
+	assert.NoError(t, o.Reconcile())
+
+	done := make(chan error, 1)
+	go func() { done <- o.Scale(1) }()
+
+	select {
+	case <-provider.worker(2).draining:
+	case <-time.After(time.Second):
+		t.Fatal("worker 2 was not drained")
+	}
+
+	// drain masih jalan, operasi lain tidak boleh ikut nunggu
+	desired := make(chan int64, 1)
+	go func() { desired <- o.GetDesiredWorkers() }()
+
+	select {
+	case n := <-desired:
+		assert.Equal(t, int64(1), n)
+	case <-time.After(time.Second):
+		t.Fatal("GetDesiredWorkers blocked by a draining worker")
+	}
+
+	// worker yang sedang dipensiunkan tidak dihitung dan tidak dipilih lagi
+	assert.NoError(t, o.Reconcile())
+	assert.Equal(t, []int{1, 2}, provider.PIDs())
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []int{1}, provider.PIDs())
+	assert.True(t, o.retired(2))
+}
+
+func TestOrchestratorReadDuringRollout(t *testing.T) {
+	o, _ := newTestOrchestrator(t, 2)
+
+	assert.NoError(t, o.Reconcile())
+
+	// RELOAD dan CANARY pegang o.mu sampai selesai, WORKERS tetap harus jawab
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		assert.Equal(t, int64(2), o.GetDesiredWorkers())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetDesiredWorkers blocked by a running rollout")
+	}
+}
+
+func TestOrchestratorPromoteOutsideLock(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 2)
+
+	release := make(chan struct{})
+	provider.hold[1] = release
+
+	assert.NoError(t, o.Reconcile())
+
+	o.begin(operation.ReloadStatus{Generation: 1, State: operation.ReloadRunning, Workers: []operation.ReloadWorker{}})
+
+	spawned, err := o.startGeneration(1, "next", 2, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, spawned)
+
+	// generasi yang belum dipromosikan tidak dihitung sebagai kelebihan worker
+	assert.NoError(t, o.Reconcile())
+	assert.Equal(t, []int{1, 2, 3, 4}, provider.PIDs())
+
+	promoted := make(chan struct{})
+	go func() {
+		defer close(promoted)
+		o.promote(1, spawned)
+	}()
+
+	select {
+	case <-provider.worker(1).draining:
+	case <-time.After(time.Second):
+		t.Fatal("worker 1 was not drained")
+	}
+
+	// worker lama masih di-drain, respawn dan scale tidak boleh ikut nunggu
+	reconciled := make(chan error, 1)
+	go func() { reconciled <- o.Reconcile() }()
+
+	select {
+	case err := <-reconciled:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Reconcile blocked by a promotion draining old workers")
+	}
+
+	assert.Contains(t, provider.PIDs(), 1)
+
+	close(release)
+	<-promoted
+
+	assert.Equal(t, []int{3, 4}, provider.PIDs())
+	assert.Equal(t, int64(2), o.GetDesiredWorkers())
+}
+
+func TestOrchestratorScaleDuringCanaryBake(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 2)
+
+	cfg := o.app.Config()
+	cfg.Proxy.Engine = config.EngineBuiltin
+	cfg.Proxy.ConfigFile = "haproxy.cfg"
+	cfg.Worker.StatsInterval = 10 * time.Millisecond
+	cfg.Master.UpgradeTimeout = time.Second
+	cfg.Master.Canary = config.CanaryConfig{Workers: 1, Bake: 300 * time.Millisecond}
+	o.app = core.NewTestAppWithConfig(cfg)
+
+	assert.NoError(t, os.WriteFile("haproxy.cfg", []byte("defaults\n    mode http\n"), 0o644))
+	assert.NoError(t, o.Reconcile())
+
+	done := make(chan error, 1)
+	go func() { done <- o.Canary("test", "canary") }()
+
+	assert.Eventually(t, func() bool {
+		status, ok := o.ReloadStatus()
+		return ok && len(status.Workers) > 0 && status.Workers[0].Phase == operation.PhaseReady
+	}, time.Second, 5*time.Millisecond)
+
+	// canary masih bake, scale tidak boleh nunggu dan canary-nya tidak dihitung
+	scaled := make(chan error, 1)
+	go func() { scaled <- o.Scale(3) }()
+
+	select {
+	case err := <-scaled:
+		assert.NoError(t, err)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Scale blocked by a baking canary")
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, provider.PIDs())
+
+	assert.NoError(t, <-done)
+
+	// sisa generasi baru ikut desired yang baru, generasi lama pensiun semua
+	assert.Equal(t, []int{3, 5, 6}, provider.PIDs())
+
+	status, _ := o.ReloadStatus()
+	assert.Equal(t, operation.ReloadCompleted, status.State)
+	assert.True(t, status.Canary.Promoted)
+
+	phases := map[int]operation.ReloadPhase{}
+	for _, w := range status.Workers {
+		phases[w.PID] = w.Phase
+	}
+
+	assert.Equal(t, map[int]operation.ReloadPhase{
+		1: operation.PhaseRetired,
+		2: operation.PhaseRetired,
+		3: operation.PhaseReady,
+		4: operation.PhaseRetired,
+		5: operation.PhaseReady,
+		6: operation.PhaseReady,
+	}, phases)
+}
+
+func TestRemoveListenerRollback(t *testing.T) {
+	o, _ := newTestOrchestrator(t, 1)
+
+	conns := manager.NewListenerManager()
+	defer conns.Close()
+	o.SetListeners(conns)
+
+	_, err := conns.OpenListener("http", "tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+	_, err = conns.OpenListener("admin", "tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+
+	before := conns.Listeners()
+
+	// rollout lain masih jalan, remove gagal dan listener-nya kembali tanpa bind ulang
+	o.reloading.Store(true)
+	assert.ErrorIs(t, o.RemoveListener("http"), operation.ErrReloadInProgress)
+	assert.Equal(t, before, conns.Listeners())
+
+	// dua remove barengan, listener terakhir tidak boleh ikut dilepas
+	errs := make(chan error, 2)
+	for _, name := range []string{"http", "admin"} {
+		go func() { errs <- o.RemoveListener(name) }()
+	}
+
+	for range 2 {
+		assert.Error(t, <-errs)
+	}
+
+	assert.Equal(t, before, conns.Listeners())
+}
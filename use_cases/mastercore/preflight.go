@@ -0,0 +1,102 @@
+package mastercore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"mox/pkg/config"
+	"mox/pkg/gateway"
+	"mox/pkg/haproxy"
+	"mox/tools/utils"
+	"mox/use_cases/manager"
+)
+
+// preflight run `haproxy -c` on the config the next generation will load,
+// dengan env dan FD listener yang sama seperti worker. Config yang ditolak
+// membatalkan rollout sebelum ada worker baru yang di-spawn.
+func (o *Orchestrator) preflight(ctx context.Context) error {
+	if o.app.Config().Proxy.Engine == config.EngineBuiltin {
+		return o.preflightBuiltin()
+	}
+
+	// worker generasi baru juga tidak akan bisa jalanin haproxy, rollout ditolak
+	executable, err := utils.LookupExecutablePathAbs("haproxy")
+	if err != nil {
+		return fmt.Errorf("preflight: haproxy not found: %w", err)
+	}
+
+	listeners, err := o.conns.Files()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, l := range listeners {
+			l.File().Close()
+		}
+	}()
+
+	files := make([]*os.File, 0, len(listeners))
+	names := make([]string, 0, len(listeners))
+
+	for _, l := range listeners {
+		files = append(files, l.File())
+		names = append(names, l.Name)
+	}
+
+	cfg := o.app.Config()
+	pid := os.Getpid()
+
+	checker := haproxy.NewChecker(executable).
+		SetEnv(haproxy.ProcessEnv(pid, manager.FirstWorkerFD, names)).
+		SetFiles(files)
+
+	var diagnostics []haproxy.Diagnostic
+
+	if proxy := o.proxyConfig(); proxy.Empty() {
+		diagnostics, err = checker.Check(ctx, proxy.ConfigFile)
+	} else {
+		model := proxy.Config
+		if model.Global.StatsSocket == "" {
+			model.Global.StatsSocket = strings.ReplaceAll(cfg.Worker.HAProxySocket, "${PID}", strconv.Itoa(pid))
+		}
+
+		diagnostics, err = checker.CheckConfig(ctx, model)
+	}
+
+	for _, d := range diagnostics {
+		o.app.Logger().Warn("haproxy config check", slog.String("diagnostic", d.String()))
+	}
+
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+
+	return nil
+}
+
+// preflightBuiltin compile the model the builtin engine would serve, tanpa haproxy
+func (o *Orchestrator) preflightBuiltin() error {
+	cfg := o.app.Config()
+
+	model, _, err := o.proxyConfig().Model()
+	if err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+
+	names := make([]string, 0, len(cfg.Listeners))
+	for _, l := range cfg.Listeners {
+		names = append(names, l.Name)
+	}
+
+	lookup := haproxy.EnvLookup(haproxy.ProcessEnv(os.Getpid(), manager.FirstWorkerFD, names))
+	if err := gateway.Validate(model, lookup); err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+
+	return nil
+}
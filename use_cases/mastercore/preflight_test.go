@@ -0,0 +1,44 @@
+package mastercore
+
+import (
+	"os"
+	"testing"
+
+	core "mox/internal"
+	"mox/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		engine  string
+		wantErr string
+	}{
+		{name: "haproxy without binary", engine: config.EngineHAProxy, wantErr: "haproxy not found"},
+		{name: "builtin without binary", engine: config.EngineBuiltin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := newTestOrchestrator(t, 1)
+
+			// PATH kosong, binary haproxy tidak ketemu
+			t.Setenv("PATH", t.TempDir())
+
+			o.app = core.NewTestAppWithConfig(config.Config{
+				Proxy: config.ProxyConfig{Engine: tt.engine, ConfigFile: "haproxy.cfg"},
+			})
+
+			assert.NoError(t, os.WriteFile("haproxy.cfg", []byte("defaults\n    mode http\n"), 0o644))
+
+			err := o.preflight(t.Context())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	core "mox/internal"
+	asyncexec "mox/pkg/async"
 	"mox/tools/utils"
 	"mox/use_cases/bus"
 	"mox/use_cases/operation"
@@ -18,16 +21,56 @@ import (
 type Registry interface {
 	workerclient.WorkerProvider
 	workerclient.WorkerRegistrar
+	ProcessTracker
+
+	// Stats aggregate EVENT_STATS per PID
+	Stats() operation.StatsReport
+}
+
+// ProcessTracker keep track of worker processes spawned by the master itself
+type ProcessTracker interface {
+	// Track mulai mantau proses worker sampai dia exit
+	Track(cmd *asyncexec.Cmd)
+	// Process ambil proses yang di-spawn master berdasarkan PID
+	Process(pid int) (*asyncexec.Cmd, bool)
+	// Wait nunggu proses exit, return false kalau timeout
+	Wait(pid int, timeout time.Duration) bool
+	// Live total worker hidup, baik yang masih spawning maupun yang sudah connect
+	Live() int
+	// Newest PID worker paling baru yang tidak di-skip, kandidat pertama buat dipensiunkan
+	Newest(skip func(pid int) bool) (int, bool)
+	// PIDs semua worker hidup, dipakai buat nentuin generasi lama waktu rollout
+	PIDs() []int
+	// Exits stream setiap worker yang mati atau putus koneksi
+	Exits() <-chan WorkerExit
+}
+
+// WorkerExit is emitted every time a worker process exits or drops its bus connection
+type WorkerExit struct {
+	PID      int
+	ExitCode int
+	// Disconnected true kalau worker tidak di-spawn master dan cuma putus koneksi
+	Disconnected bool
 }
 
 var _ (Registry) = (*ConnectionRegistry)(nil)
 
+type process struct {
+	cmd       *asyncexec.Cmd
+	startedAt time.Time
+	exited    chan struct{}
+}
+
 type ConnectionRegistry struct {
 	conns map[int]workerclient.WorkerProcess
+	procs map[int]*process
+	exits chan WorkerExit
 	bus   bus.Messaging
 	app   core.App
 	ctx   context.Context
 	mu    *sync.RWMutex
+
+	paused atomic.Bool // health check berhenti selama handover upgrade
 }
 
 func NewConnectionRegistry(ctx context.Context, app core.App) *ConnectionRegistry {
@@ -37,6 +80,8 @@ func NewConnectionRegistry(ctx context.Context, app core.App) *ConnectionRegistr
 		app:   app,
 		bus:   bus.NewEventBus(app),
 		conns: make(map[int]workerclient.WorkerProcess),
+		procs: make(map[int]*process),
+		exits: make(chan WorkerExit, 16),
 	}
 }
 
@@ -45,9 +90,163 @@ func (c *ConnectionRegistry) Remove(pid int) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if _, ok := c.conns[pid]; !ok {
+		return errors.New("no worker found to remove")
+	}
+
 	delete(c.conns, pid)
 
-	return errors.New("no worker found to remove")
+	return nil
+}
+
+// Track implements [ProcessTracker].
+func (c *ConnectionRegistry) Track(cmd *asyncexec.Cmd) {
+	p := &process{cmd: cmd, startedAt: time.Now(), exited: make(chan struct{})}
+
+	c.mu.Lock()
+	c.procs[cmd.Process.Pid] = p
+	c.mu.Unlock()
+
+	go c.watchProcess(p)
+}
+
+func (c *ConnectionRegistry) watchProcess(p *process) {
+	<-p.cmd.Terminated
+
+	pid := p.cmd.Process.Pid
+	c.app.Logger().Info("worker process exited", slog.Int("pid", pid), slog.String("status", p.cmd.Status()))
+
+	c.mu.Lock()
+	delete(c.procs, pid)
+	worker, ok := c.conns[pid]
+	delete(c.conns, pid)
+	c.mu.Unlock()
+
+	if ok && worker.State() != workerclient.Disconnected {
+		worker.Shutdown()
+	}
+
+	close(p.exited)
+
+	exitCode := -1
+	if p.cmd.ProcessState != nil {
+		exitCode = p.cmd.ProcessState.ExitCode()
+	}
+
+	c.notifyExit(WorkerExit{PID: pid, ExitCode: exitCode})
+}
+
+func (c *ConnectionRegistry) notifyExit(e WorkerExit) {
+	select {
+	case c.exits <- e:
+	case <-c.ctx.Done():
+	}
+}
+
+// Exits implements [ProcessTracker].
+func (c *ConnectionRegistry) Exits() <-chan WorkerExit {
+	return c.exits
+}
+
+// Process implements [ProcessTracker].
+func (c *ConnectionRegistry) Process(pid int) (*asyncexec.Cmd, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	p, ok := c.procs[pid]
+	if !ok {
+		return nil, false
+	}
+
+	return p.cmd, true
+}
+
+// Wait implements [ProcessTracker].
+func (c *ConnectionRegistry) Wait(pid int, timeout time.Duration) bool {
+	c.mu.RLock()
+	p, ok := c.procs[pid]
+	c.mu.RUnlock()
+
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-p.exited:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Live implements [ProcessTracker].
+func (c *ConnectionRegistry) Live() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	total := len(c.procs)
+	for pid, w := range c.conns {
+		if _, tracked := c.procs[pid]; tracked || w.State() == workerclient.Disconnected {
+			continue
+		}
+
+		total++
+	}
+
+	return total
+}
+
+// PIDs implements [ProcessTracker].
+func (c *ConnectionRegistry) PIDs() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pids := make([]int, 0, len(c.procs))
+	for pid := range c.procs {
+		pids = append(pids, pid)
+	}
+
+	for pid, w := range c.conns {
+		if _, tracked := c.procs[pid]; tracked || w.State() == workerclient.Disconnected {
+			continue
+		}
+
+		pids = append(pids, pid)
+	}
+
+	sort.Ints(pids)
+
+	return pids
+}
+
+// Newest implements [ProcessTracker]. Worker yang connect sendiri
+// (di luar master) dipilih paling akhir.
+func (c *ConnectionRegistry) Newest(skip func(pid int) bool) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	pid, newest := 0, time.Time{}
+	for p, proc := range c.procs {
+		if proc.startedAt.After(newest) && !skip(p) {
+			pid, newest = p, proc.startedAt
+		}
+	}
+
+	if pid != 0 {
+		return pid, true
+	}
+
+	for p, w := range c.conns {
+		if _, tracked := c.procs[p]; tracked || skip(p) {
+			continue
+		}
+
+		if w.State() != workerclient.Disconnected {
+			return p, true
+		}
+	}
+
+	return 0, false
 }
 
 // Get implements [workerclient.WorkerProvider].
@@ -74,14 +273,35 @@ func (c *ConnectionRegistry) GetAll() []workerclient.WorkerProcess {
 	return workers
 }
 
+// Stats aggregate the last EVENT_STATS of every connected worker, urut per PID
+func (c *ConnectionRegistry) Stats() operation.StatsReport {
+	report := operation.StatsReport{Workers: []operation.WorkerStats{}}
+
+	for _, w := range c.GetAll() {
+		stats, ok := w.Stats()
+		if !ok || w.State() == workerclient.Disconnected {
+			continue
+		}
+
+		report.Workers = append(report.Workers, stats)
+		report.Total.Add(stats)
+	}
+
+	sort.Slice(report.Workers, func(i, j int) bool {
+		return report.Workers[i].Worker.PID < report.Workers[j].Worker.PID
+	})
+
+	return report
+}
+
 func (c *ConnectionRegistry) Total() int64 {
 	c.mu.RLock() // Gunakan Read Lock agar efisien
 	defer c.mu.RUnlock()
 	return int64(len(c.conns))
 }
 
-func (c *ConnectionRegistry) Broadcast(payload []byte) {
-	c.bus.Broadcast(c.ctx, operation.MessagePayload{
+func (c *ConnectionRegistry) Broadcast(payload []byte) bus.Results {
+	results := c.bus.Broadcast(c.ctx, operation.MessagePayload{
 		ID: utils.GenerateUUID(),
 		Payload: operation.Command{
 			Type:    operation.Chat,
@@ -89,36 +309,90 @@ func (c *ConnectionRegistry) Broadcast(payload []byte) {
 		},
 		Timestamp: time.Now().UnixMilli(),
 	}, c.GetAll())
+
+	if err := results.Err(); err != nil {
+		c.app.Logger().Warn("broadcast not acknowledged by every worker", slog.String("err", err.Error()))
+	}
+
+	return results
 }
 
+// pingWorkers ping every worker at once. Tiap PING dibatasi satu interval,
+// worker yang macet tidak boleh nahan heartbeat worker lain dan write yang
+// gagal tetap dihitung missed di beat berikutnya.
 func (c *ConnectionRegistry) pingWorkers() {
-	if err := c.bus.Broadcast(c.ctx, operation.MessagePayload{
-		ID:      utils.GenerateUUID(),
-		FromPID: -1,
-		Payload: operation.Command{
-			Type: operation.Ping,
-		},
-		Timestamp: time.Now().UnixMilli(),
-	}, c.GetAll()); err != nil {
+	cfg := c.app.Config().Master
+
+	var wg sync.WaitGroup
+
+	for _, worker := range c.GetAll() {
+		if worker.State() == workerclient.Disconnected {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.ctx, cfg.HeartbeatInterval)
+			defer cancel()
+
+			c.ping(ctx, worker, cfg.HeartbeatMisses)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (c *ConnectionRegistry) ping(ctx context.Context, worker workerclient.WorkerProcess, threshold int) {
+	before := worker.State()
+	missed, err := worker.Heartbeat(ctx, threshold)
+
+	switch {
+	case errors.Is(err, workerclient.ErrHeartbeatLost):
+		c.evict(worker, missed)
+	case worker.State() == workerclient.Error && before != workerclient.Error:
+		c.app.Logger().Warn("worker missed heartbeats", slog.Int("pid", worker.PID()), slog.Int("missed", missed), slog.Time("last_seen", worker.LastSeen()))
+	case err != nil:
 		c.app.Logger().Error(fmt.Sprintf("error sending heart msg : %s", err.Error()))
-		return
+	}
+}
+
+// evict drop a worker that stopped answering PING. Proses yang di-spawn master
+// di-kill biar supervisor yang nentuin restart, selain itu cukup diputus.
+func (c *ConnectionRegistry) evict(worker workerclient.WorkerProcess, missed int) {
+	pid := worker.PID()
+
+	c.app.Logger().Error("worker evicted, heartbeat lost", slog.Int("pid", pid), slog.Int("missed", missed), slog.Time("last_seen", worker.LastSeen()))
+
+	if cmd, tracked := c.Process(pid); tracked {
+		if err := cmd.Process.Kill(); err != nil {
+			c.app.Logger().Error("cannot kill evicted worker", slog.Int("pid", pid), slog.String("err", err.Error()))
+		}
 	}
 }
 
 func (c *ConnectionRegistry) eliminateWorkers() {
-	for pid, worker := range c.conns {
+	for _, worker := range c.GetAll() {
+		pid := worker.PID()
 		if worker.State() == workerclient.Disconnected {
 			if err := c.Remove(pid); err != nil {
 				c.app.Logger().Error(err.Error())
 			}
 
 			c.app.Logger().Info(fmt.Sprintf("worker %d removed", pid))
+
+			// proses yang di-spawn master dilaporkan oleh watchProcess pas exit
+			if _, tracked := c.Process(pid); !tracked {
+				go c.notifyExit(WorkerExit{PID: pid, ExitCode: -1, Disconnected: true})
+			}
 		}
 	}
 }
 
 func (c *ConnectionRegistry) CheckHealthWorkers() {
-	ticker := time.NewTicker(3 * time.Second)
+	interval := c.app.Config().Master.HeartbeatInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -126,15 +400,36 @@ func (c *ConnectionRegistry) CheckHealthWorkers() {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
+			// master.heartbeat_interval bisa berubah lewat hot reload, nilai
+			// <= 0 sudah ditolak validasi tapi Reset bakal panic kalau lolos
+			if d := c.app.Config().Master.HeartbeatInterval; d > 0 && d != interval {
+				interval = d
+				ticker.Reset(interval)
+			}
+
+			if c.paused.Load() {
+				continue
+			}
+
 			c.pingWorkers()
 			c.eliminateWorkers()
 		}
 	}
 }
 
+// Pause stop touching worker connections, the next master is about to own them
+func (c *ConnectionRegistry) Pause() {
+	c.paused.Store(true)
+}
+
+func (c *ConnectionRegistry) Resume() {
+	c.paused.Store(false)
+}
+
 func (c *ConnectionRegistry) CloseAllConnections() {
 	c.app.Logger().Debug("closing", slog.Int("total", int(c.Total())))
-	for pid, v := range c.conns {
+	for _, v := range c.GetAll() {
+		pid := v.PID()
 		c.app.Logger().Info("closing connection", slog.Int("pid", pid))
 
 		if err := v.Shutdown(); err != nil {
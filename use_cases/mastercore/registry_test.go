@@ -0,0 +1,116 @@
+package mastercore
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionRegistryLiveNewest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewConnectionRegistry(ctx, core.NewTestAppWithConfig(config.Config{}))
+
+	cmds := make(map[int]*asyncexec.Cmd)
+	for _, pid := range []int{10, 11, 12} {
+		cmds[pid] = &asyncexec.Cmd{Cmd: &exec.Cmd{Process: &os.Process{Pid: pid}}, Terminated: make(chan bool)}
+		c.Track(cmds[pid])
+		time.Sleep(time.Millisecond)
+	}
+
+	// 10 di-spawn master dan sudah connect, 20 worker warisan yang cuma connect
+	assert.NoError(t, c.Add(&fakeWorker{pid: 10}))
+	assert.NoError(t, c.Add(&fakeWorker{pid: 20}))
+	assert.Equal(t, 4, c.Live())
+
+	none := func(int) bool { return false }
+	pid, ok := c.Newest(none)
+	assert.True(t, ok)
+	assert.Equal(t, 12, pid)
+
+	pid, _ = c.Newest(func(pid int) bool { return pid == 12 })
+	assert.Equal(t, 11, pid)
+
+	pid, _ = c.Newest(func(pid int) bool { return pid < 20 })
+	assert.Equal(t, 20, pid)
+
+	_, ok = c.Newest(func(int) bool { return true })
+	assert.False(t, ok)
+
+	cmds[12].Terminated <- true
+
+	select {
+	case e := <-c.Exits():
+		assert.Equal(t, 12, e.PID)
+	case <-time.After(time.Second):
+		t.Fatal("exit of worker 12 not reported")
+	}
+
+	assert.Equal(t, 3, c.Live())
+
+	pid, _ = c.Newest(none)
+	assert.Equal(t, 11, pid)
+}
+
+// beatWorker count heartbeats, yang stuck nunggu sampai ctx habis seperti
+// write ke worker yang berhenti baca
+type beatWorker struct {
+	fakeWorker
+
+	stuck bool
+	beats atomic.Int32
+}
+
+func (w *beatWorker) Heartbeat(ctx context.Context, threshold int) (int, error) {
+	w.beats.Add(1)
+
+	if w.stuck {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	return 0, nil
+}
+
+func TestPingWorkersStuckWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewConnectionRegistry(ctx, core.NewTestAppWithConfig(config.Config{
+		Master: config.MasterConfig{HeartbeatInterval: 200 * time.Millisecond, HeartbeatMisses: 3},
+	}))
+
+	stuck := &beatWorker{fakeWorker: fakeWorker{pid: 1}, stuck: true}
+	healthy := &beatWorker{fakeWorker: fakeWorker{pid: 2}}
+
+	assert.NoError(t, c.Add(stuck))
+	assert.NoError(t, c.Add(healthy))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.pingWorkers()
+	}()
+
+	// worker yang macet tidak menahan PING ke worker lain
+	assert.Eventually(t, func() bool { return healthy.beats.Load() == 1 }, 100*time.Millisecond, time.Millisecond)
+
+	// PING yang macet dibatasi satu interval
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pingWorkers blocked by a stuck worker")
+	}
+
+	assert.Equal(t, int32(1), stuck.beats.Load())
+}
@@ -0,0 +1,176 @@
+package mastercore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"mox/pkg/config"
+	"mox/use_cases/operation"
+)
+
+// SetRevisions attach the store of applied configs, tanpa store revisi tidak dicatat
+func (o *Orchestrator) SetRevisions(store operation.RevisionStore) *Orchestrator {
+	o.revisions = store
+
+	return o
+}
+
+// Revisions implements [operation.SystemCore].
+func (o *Orchestrator) Revisions() ([]operation.ConfigRevision, error) {
+	if o.revisions == nil {
+		return nil, operation.ErrRevisionsDisabled
+	}
+
+	return o.revisions.List(o.app.Context())
+}
+
+// Revision implements [operation.SystemCore].
+func (o *Orchestrator) Revision(n int) (operation.ConfigRevision, error) {
+	if o.revisions == nil {
+		return operation.ConfigRevision{}, operation.ErrRevisionsDisabled
+	}
+
+	return o.revisions.Get(o.app.Context(), n)
+}
+
+// DiffRevisions implements [operation.SystemCore].
+func (o *Orchestrator) DiffRevisions(a, b int) (string, error) {
+	from, err := o.Revision(a)
+	if err != nil {
+		return "", fmt.Errorf("revision %d: %w", a, err)
+	}
+
+	to, err := o.Revision(b)
+	if err != nil {
+		return "", fmt.Errorf("revision %d: %w", b, err)
+	}
+
+	return operation.DiffRevisions(from, to)
+}
+
+// RollbackRevision implements [operation.SystemCore]. haproxy.cfg revisi n
+// ditulis ke revisions.output lalu di-pin, generasi baru di-rollout lewat jalur
+// reload biasa. Pin bertahan sampai CONFIG_RELOAD berikutnya.
+func (o *Orchestrator) RollbackRevision(n int, author string) error {
+	rev, err := o.Revision(n)
+	if err != nil {
+		return fmt.Errorf("revision %d: %w", n, err)
+	}
+
+	file := strings.ReplaceAll(o.app.Config().Revisions.Output, "${REV}", strconv.Itoa(n))
+	if err := os.WriteFile(file, []byte(rev.Config), 0o600); err != nil {
+		return fmt.Errorf("cannot write revision %d: %w", n, err)
+	}
+
+	if !o.reloading.CompareAndSwap(false, true) {
+		return operation.ErrReloadInProgress
+	}
+	defer o.reloading.Store(false)
+
+	return o.withPin(file, func() error {
+		return o.rollout(author, fmt.Sprintf("rollback to revision %d", n))
+	})
+}
+
+// withPin run rollout with the next generation pinned to file, kosong = config
+// di disk. Kalau rollout gagal generasi lama tetap serve, jadi pin lama dikembalikan.
+func (o *Orchestrator) withPin(file string, rollout func() error) error {
+	prev := o.pinned.Load()
+
+	if file == "" {
+		o.pinned.Store(nil)
+	} else {
+		o.pinned.Store(&file)
+	}
+
+	if err := rollout(); err != nil {
+		o.pinned.Store(prev)
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) pinnedFile() string {
+	if file := o.pinned.Load(); file != nil {
+		return *file
+	}
+
+	return ""
+}
+
+// proxyConfig is the [proxy] the next worker will serve, termasuk revisi yang di-pin
+func (o *Orchestrator) proxyConfig() config.ProxyConfig {
+	if file := o.pinnedFile(); file != "" {
+		return o.app.Config().Proxy.Pin(file)
+	}
+
+	return o.app.Config().Proxy
+}
+
+// recordStartup store the config the master came up with
+func (o *Orchestrator) recordStartup() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.recordRevision("master", "master started", o.generation)
+}
+
+// recordRevision store the haproxy.cfg gen is serving as a new revision,
+// dilewati kalau isinya sama dengan revisi terakhir. Gagal simpan tidak
+// membatalkan rollout, cuma di-log.
+func (o *Orchestrator) recordRevision(author, reason string, gen int) {
+	if o.revisions == nil {
+		return
+	}
+
+	cfg := o.app.Config()
+
+	body, err := o.proxyConfig().Rendered(cfg.Worker.HAProxySocket)
+	if err != nil {
+		o.app.Logger().Warn("config revision is not recorded", slog.Int("generation", gen), slog.String("err", err.Error()))
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])[:12]
+
+	ctx, cancel := context.WithTimeout(o.app.Context(), cfg.Master.RequestTimeout)
+	defer cancel()
+
+	latest, err := o.revisions.Latest(ctx)
+	switch {
+	case err == nil && latest.Hash == hash:
+		return
+	case err != nil && !errors.Is(err, operation.ErrRevisionNotFound):
+		o.app.Logger().Warn("config revision is not recorded", slog.Int("generation", gen), slog.String("err", err.Error()))
+		return
+	}
+
+	rev, err := o.revisions.Save(ctx, operation.ConfigRevision{
+		Author:     author,
+		Reason:     reason,
+		Hash:       hash,
+		Generation: gen,
+		Config:     string(body),
+	})
+	if err != nil {
+		o.app.Logger().Warn("config revision is not recorded", slog.Int("generation", gen), slog.String("err", err.Error()))
+		return
+	}
+
+	o.app.Logger().Info("config revision recorded",
+		slog.Int("revision", rev.Number),
+		slog.Int("generation", gen),
+		slog.String("hash", hash),
+		slog.String("author", author),
+		slog.String("reason", reason),
+	)
+}
@@ -0,0 +1,522 @@
+package mastercore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"mox/use_cases/operation"
+)
+
+// Reload implements [operation.SystemCore]. Handler CONFIG_RELOAD, config
+// dibaca ulang dari disk oleh generasi worker baru, revisi yang di-pin dilepas.
+// Config mox master ikut dibaca ulang dulu, file yang invalid menolak reload.
+func (o *Orchestrator) Reload(author, reason string) error {
+	if _, err := o.applyConfig(author); err != nil {
+		return err
+	}
+
+	return o.reloadGeneration(author, reason)
+}
+
+func (o *Orchestrator) reloadGeneration(author, reason string) error {
+	if !o.reloading.CompareAndSwap(false, true) {
+		return operation.ErrReloadInProgress
+	}
+	defer o.reloading.Store(false)
+
+	return o.withPin("", func() error {
+		return o.rollout(author, reason)
+	})
+}
+
+// ReloadStatus implements [operation.SystemCore].
+func (o *Orchestrator) ReloadStatus() (operation.ReloadStatus, bool) {
+	o.rlMu.Lock()
+	defer o.rlMu.Unlock()
+
+	if o.reload == nil {
+		return operation.ReloadStatus{}, false
+	}
+
+	return o.reload.Clone(), true
+}
+
+// Rollout replace every live worker with a new generation. Worker baru dapat
+// set FD listener yang sekarang dan harus lolos readiness dulu, baru generasi
+// lama di-drain. Config dicek `haproxy -c` dulu, kalau gagal di tengah jalan
+// worker baru dipensiunkan lagi dan generasi lama tetap serve. Rollout
+// diserialkan lewat reloading, o.mu cuma dipegang sebentar waktu spawn dan
+// ganti generasi supaya scale dan respawn supervisor tidak ikut nunggu.
+func (o *Orchestrator) Rollout(author, reason string) error {
+	if !o.reloading.CompareAndSwap(false, true) {
+		return operation.ErrReloadInProgress
+	}
+	defer o.reloading.Store(false)
+
+	return o.rollout(author, reason)
+}
+
+func (o *Orchestrator) rollout(author, reason string) error {
+	gen, hash, err := o.prepare(author, reason, operation.ModeRolling, nil)
+	if err != nil {
+		return err
+	}
+
+	spawned, err := o.startGeneration(gen, hash, int(o.desired.Load()), nil)
+	if err != nil {
+		return fmt.Errorf("rollout generation %d: %w", gen, err)
+	}
+
+	o.promote(gen, spawned)
+
+	o.finish(operation.ReloadCompleted, nil)
+	o.app.Logger().Info("worker rollout finished", slog.Int("generation", gen), slog.String("config_hash", hash), slog.Any("workers", spawned))
+	o.recordRevision(author, reason, gen)
+
+	return nil
+}
+
+// Canary start master.canary.workers workers of a new generation next to the
+// old ones. Selama bake, rasio 5xx dan latency canary diambil dari stats
+// worker. Lolos sampai bake selesai = generasi baru dipromosikan seperti
+// Rollout, kalau tidak canary di-drain dan generasi lama tetap serve. Bake
+// jalan tanpa o.mu, scale dan respawn supervisor tetap jalan selama itu.
+func (o *Orchestrator) Canary(author, reason string) error {
+	if !o.reloading.CompareAndSwap(false, true) {
+		return operation.ErrReloadInProgress
+	}
+	defer o.reloading.Store(false)
+
+	return o.withPin("", func() error {
+		return o.canary(author, reason)
+	})
+}
+
+func (o *Orchestrator) canary(author, reason string) error {
+	desired := int(o.desired.Load())
+	if desired == 0 {
+		return errors.New("there is no worker to run a canary next to")
+	}
+
+	cfg := o.app.Config().Master.Canary
+	limits := operation.CanaryThresholds{
+		Max5xxRatio: cfg.Max5xxRatio,
+		MaxLatency:  cfg.MaxLatency,
+		MinRequests: cfg.MinRequests,
+	}
+
+	// porsi traffic diatur lewat jumlah worker, canary tidak boleh lebih dari pool-nya
+	k := min(cfg.Workers, desired)
+
+	gen, hash, err := o.prepare(author, reason, operation.ModeCanary, &operation.CanaryStatus{
+		Workers:    k,
+		Bake:       cfg.Bake,
+		Thresholds: limits,
+	})
+	if err != nil {
+		return err
+	}
+
+	canaries, err := o.startGeneration(gen, hash, k, nil)
+	if err != nil {
+		return fmt.Errorf("canary generation %d: %w", gen, err)
+	}
+
+	o.app.Logger().Info("canary baking", slog.Int("generation", gen), slog.Any("workers", canaries), slog.Duration("bake", cfg.Bake))
+
+	if failed, err := o.bake(canaries, cfg.Bake, limits); err != nil {
+		o.rollback(gen, canaries, failed, err)
+		return fmt.Errorf("canary generation %d: %w", gen, err)
+	}
+
+	rest, err := o.promoteCanary(gen, canaries)
+	if err != nil {
+		o.rollback(gen, canaries, 0, err)
+		return fmt.Errorf("canary generation %d: %w", gen, err)
+	}
+
+	o.app.Logger().Info("canary promoted", slog.Int("generation", gen), slog.Any("workers", canaries))
+
+	// sisa pool generasi baru, canary yang sudah jalan ikut di-rollback kalau gagal
+	spawned, err := o.startGeneration(gen, hash, rest, canaries)
+	if err != nil {
+		return fmt.Errorf("canary generation %d: %w", gen, err)
+	}
+
+	o.promote(gen, spawned)
+
+	o.finish(operation.ReloadCompleted, nil)
+	o.app.Logger().Info("worker rollout finished", slog.Int("generation", gen), slog.String("config_hash", hash), slog.Any("workers", spawned))
+	o.recordRevision(author, reason, gen)
+
+	return nil
+}
+
+// prepare open the reload status of the next generation and preflight its config
+func (o *Orchestrator) prepare(author, reason string, mode operation.ReloadMode, canary *operation.CanaryStatus) (gen int, hash string, err error) {
+	o.mu.Lock()
+	prev := o.generation
+	gen = max(o.issued, o.generation) + 1
+	o.mu.Unlock()
+
+	hash = o.configHash()
+
+	o.begin(operation.ReloadStatus{
+		Generation: gen,
+		Previous:   prev,
+		ConfigHash: hash,
+		Author:     author,
+		Reason:     reason,
+		Mode:       mode,
+		State:      operation.ReloadRunning,
+		StartedAt:  time.Now(),
+		Workers:    []operation.ReloadWorker{},
+		Canary:     canary,
+	})
+
+	// config ditolak = generasi yang jalan tidak disentuh sama sekali
+	if err := o.preflight(o.app.Context()); err != nil {
+		o.app.Logger().Error("worker rollout rejected", slog.String("reason", reason), slog.String("err", err.Error()))
+		o.finish(operation.ReloadRejected, err)
+		return 0, "", err
+	}
+
+	o.mu.Lock()
+	o.issued = gen
+	o.mu.Unlock()
+
+	o.app.Logger().Info("worker rollout started",
+		slog.Int("generation", gen),
+		slog.String("mode", string(mode)),
+		slog.String("config_hash", hash),
+		slog.String("author", author),
+		slog.String("reason", reason),
+		slog.Int("old_workers", o.provider.Live()),
+		slog.Int64("desired", o.desired.Load()),
+	)
+
+	return gen, hash, nil
+}
+
+// startGeneration spawn n workers of gen and wait until all of them are ready.
+// started is the workers of gen already serving, kalau gagal semuanya di-rollback.
+func (o *Orchestrator) startGeneration(gen int, hash string, n int, started []int) ([]int, error) {
+	spawned, err := o.stage(gen, hash, n)
+	pids := append(slices.Clone(started), spawned...)
+
+	if err != nil {
+		o.rollback(gen, pids, 0, err)
+		return nil, err
+	}
+
+	deadline := time.Now().Add(o.app.Config().Master.UpgradeTimeout)
+	for _, pid := range spawned {
+		if err := o.awaitReady(pid, deadline); err != nil {
+			o.rollback(gen, pids, pid, err)
+			return nil, err
+		}
+
+		o.phase(pid, gen, operation.PhaseReady, nil)
+	}
+
+	return pids, nil
+}
+
+// stage spawn n workers of gen under o.mu, ditandai starting dalam lock yang
+// sama supaya reconcile tidak menghitung atau memensiunkan mereka
+func (o *Orchestrator) stage(gen int, hash string, n int) ([]int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spawned := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		cmd, err := o.spawner.Spawn(gen, hash, o.pinnedFile())
+		if err != nil {
+			return spawned, err
+		}
+
+		o.provider.Track(cmd)
+		o.markStarting(cmd.Process.Pid)
+		spawned = append(spawned, cmd.Process.Pid)
+		o.phase(cmd.Process.Pid, gen, operation.PhaseSpawned, nil)
+	}
+
+	return spawned, nil
+}
+
+// promote make gen the serving generation and retire every other worker.
+// Di bawah o.mu cuma ganti generasi dan tandai worker lama retiring, drain
+// dan stop_timeout-nya jalan di luar lock seperti reconcileUnlock.
+func (o *Orchestrator) promote(gen int, pids []int) {
+	o.mu.Lock()
+
+	// dari sini generasi baru yang serve, worker yang di-restart supervisor ikut generasi ini
+	prev := o.generation
+	o.generation = gen
+
+	// termasuk worker generasi lama yang di-respawn supervisor selama rollout
+	old := make(map[int]int)
+	for _, pid := range o.provider.PIDs() {
+		if slices.Contains(pids, pid) || o.aside(pid) {
+			continue
+		}
+
+		old[pid] = prev
+		if w := o.provider.Get(pid); w != nil {
+			old[pid] = w.Generation()
+		}
+
+		o.markRetiring(pid)
+	}
+
+	o.unstage(pids)
+
+	// jumlah worker bisa di-scale selama rollout jalan
+	victims, err := o.reconcile()
+	o.mu.Unlock()
+
+	if err != nil {
+		o.app.Logger().Warn("cannot reconcile promoted generation", slog.Int("generation", gen), slog.String("err", err.Error()))
+	}
+
+	for pid, g := range old {
+		o.phase(pid, g, operation.PhaseDraining, nil)
+
+		if err := o.retire(pid); err != nil {
+			o.app.Logger().Warn("cannot retire old worker", slog.Int("generation", gen), slog.Int("pid", pid), slog.String("err", err.Error()))
+			o.phase(pid, g, operation.PhaseFailed, err)
+			continue
+		}
+
+		o.phase(pid, g, operation.PhaseRetired, nil)
+	}
+
+	if err := o.retireAll(victims); err != nil {
+		o.app.Logger().Warn("cannot retire surplus worker", slog.Int("generation", gen), slog.String("err", err.Error()))
+	}
+}
+
+// bake watch the canaries until bake is over. Dicek tiap worker kirim
+// EVENT_STATS, failed is the canary that exited, 0 kalau karena threshold.
+func (o *Orchestrator) bake(pids []int, bake time.Duration, limits operation.CanaryThresholds) (failed int, err error) {
+	tick := time.NewTicker(o.app.Config().Worker.StatsInterval)
+	defer tick.Stop()
+
+	done := time.NewTimer(bake)
+	defer done.Stop()
+
+	for {
+		select {
+		case <-o.app.Context().Done():
+			return 0, fmt.Errorf("canary interrupted: %w", o.app.Context().Err())
+		case <-tick.C:
+			for _, pid := range pids {
+				if _, alive := o.provider.Process(pid); !alive {
+					return pid, fmt.Errorf("canary worker %d exited", pid)
+				}
+			}
+
+			if err := o.measure(pids).Check(limits); err != nil {
+				return 0, err
+			}
+		case <-done.C:
+			m := o.measure(pids)
+			if err := m.Check(limits); err != nil {
+				return 0, err
+			}
+
+			if !m.Enough(limits) {
+				return 0, fmt.Errorf("canary served %d requests, %d needed", m.Requests, limits.MinRequests)
+			}
+
+			return 0, nil
+		}
+	}
+}
+
+// measure read the last stats of the canaries and record them in the reload status
+func (o *Orchestrator) measure(pids []int) operation.CanaryMetrics {
+	stats := make([]operation.WorkerStats, 0, len(pids))
+	for _, pid := range pids {
+		if w := o.provider.Get(pid); w != nil {
+			if s, ok := w.Stats(); ok {
+				stats = append(stats, s)
+			}
+		}
+	}
+
+	m := operation.MeasureCanary(stats)
+
+	o.rlMu.Lock()
+	o.reload.Canary.Metrics = m
+	o.rlMu.Unlock()
+
+	o.app.Logger().Debug("canary measured",
+		slog.Int64("requests", m.Requests),
+		slog.Float64("ratio_5xx", m.Ratio5xx),
+		slog.Duration("latency", m.Latency),
+	)
+
+	return m
+}
+
+// promoteCanary mark the canaries promoted and return how many workers of gen
+// are still missing. Pool bisa di-scale selama bake, jadi desired dibaca ulang.
+func (o *Orchestrator) promoteCanary(gen int, canaries []int) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.generation >= gen {
+		return 0, fmt.Errorf("generation %d is already serving", o.generation)
+	}
+
+	o.rlMu.Lock()
+	o.reload.Canary.Promoted = true
+	o.rlMu.Unlock()
+
+	return max(int(o.desired.Load())-len(canaries), 0), nil
+}
+
+// awaitReady wait until pid finished the bus handshake and its engine answer READINESS
+func (o *Orchestrator) awaitReady(pid int, deadline time.Time) error {
+	var notReady error
+
+	for {
+		if _, alive := o.provider.Process(pid); !alive {
+			return fmt.Errorf("worker %d exited before it was ready", pid)
+		}
+
+		if w := o.provider.Get(pid); w != nil {
+			ctx, cancel := context.WithTimeout(o.app.Context(), o.app.Config().Master.RequestTimeout)
+			notReady = w.Ready(ctx)
+			cancel()
+
+			if notReady == nil {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if notReady != nil {
+				return notReady
+			}
+
+			return fmt.Errorf("worker %d not connected in time", pid)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// rollback retire the half-started generation, generasi lama tetap jalan.
+// failed is the worker that broke the rollout, 0 kalau gagal waktu spawn.
+func (o *Orchestrator) rollback(gen int, pids []int, failed int, cause error) {
+	o.app.Logger().Error("worker rollout rolled back", slog.Int("generation", gen), slog.Any("workers", pids), slog.String("err", cause.Error()))
+
+	for _, pid := range pids {
+		// ditandai dulu sebelum dicek, exit worker generasi ini yang baru sampai
+		// ke supervisor belakangan tidak dianggap crash dan tidak di-restart
+		o.markRetiring(pid)
+
+		if pid == failed {
+			o.phase(pid, gen, operation.PhaseFailed, cause)
+		}
+
+		// config rusak biasanya bikin semua worker baru mati sendiri
+		if _, alive := o.provider.Process(pid); !alive {
+			if pid != failed {
+				o.phase(pid, gen, operation.PhaseFailed, fmt.Errorf("worker %d exited", pid))
+			}
+
+			continue
+		}
+
+		if pid != failed {
+			o.phase(pid, gen, operation.PhaseDraining, nil)
+		}
+
+		if err := o.retire(pid); err != nil {
+			o.app.Logger().Warn("cannot retire new worker", slog.Int("generation", gen), slog.Int("pid", pid), slog.String("err", err.Error()))
+			continue
+		}
+
+		if pid != failed {
+			o.phase(pid, gen, operation.PhaseRetired, nil)
+		}
+	}
+
+	o.finish(operation.ReloadRolledBack, cause)
+}
+
+func (o *Orchestrator) begin(status operation.ReloadStatus) {
+	o.rlMu.Lock()
+	defer o.rlMu.Unlock()
+
+	o.reload = &status
+}
+
+func (o *Orchestrator) phase(pid, gen int, phase operation.ReloadPhase, err error) {
+	o.rlMu.Lock()
+	defer o.rlMu.Unlock()
+
+	o.reload.SetPhase(pid, gen, phase, err, time.Now())
+}
+
+func (o *Orchestrator) finish(state operation.ReloadState, err error) {
+	o.rlMu.Lock()
+	defer o.rlMu.Unlock()
+
+	o.reload.Finish(state, err, time.Now())
+}
+
+// configHash hash the files the next worker will load, kosong kalau tidak bisa dibaca
+func (o *Orchestrator) configHash() string {
+	path := o.app.ConfigPath()
+	if path == "" {
+		path = "config.toml"
+	}
+
+	paths := []string{path}
+
+	// haproxy.cfg yang ditulis manual (atau revisi yang di-pin) juga ikut menentukan apa yang di-serve
+	if cfg := o.proxyConfig(); cfg.Empty() {
+		paths = append(paths, cfg.ConfigFile)
+	}
+
+	hash, err := hashFiles(paths...)
+	if err != nil {
+		o.app.Logger().Warn("config is not hashed", slog.String("err", err.Error()))
+		return ""
+	}
+
+	return hash
+}
+
+// hashFiles return the first 12 hex of the sha256 over every file, seperti commit git
+func hashFiles(paths ...string) (string, error) {
+	h := sha256.New()
+
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+
+		_, err = io.Copy(h, f)
+		f.Close()
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
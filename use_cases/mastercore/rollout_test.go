@@ -0,0 +1,79 @@
+package mastercore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.toml")
+	proxy := filepath.Join(dir, "haproxy.cfg")
+
+	assert.NoError(t, os.WriteFile(cfg, []byte("[master]\nworkers = 2\n"), 0o644))
+	assert.NoError(t, os.WriteFile(proxy, []byte("global\n"), 0o644))
+
+	first, err := hashFiles(cfg, proxy)
+	assert.NoError(t, err)
+	assert.Len(t, first, 12)
+
+	again, _ := hashFiles(cfg, proxy)
+	assert.Equal(t, first, again)
+
+	// isi haproxy.cfg berubah = generasi berikutnya beda config
+	assert.NoError(t, os.WriteFile(proxy, []byte("global\n    maxconn 10\n"), 0o644))
+	changed, _ := hashFiles(cfg, proxy)
+	assert.NotEqual(t, first, changed)
+
+	_, err = hashFiles(filepath.Join(dir, "missing.toml"))
+	assert.Error(t, err)
+}
+
+func TestOrchestratorRollback(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 2)
+
+	assert.NoError(t, o.Reconcile())
+
+	// generasi baru: 3 gagal readiness lalu exit, 4 ikut mati, 5 masih jalan
+	for range 3 {
+		cmd, err := o.spawner.Spawn(2, "next", "")
+		assert.NoError(t, err)
+		provider.Track(cmd)
+	}
+
+	provider.exit(3)
+	provider.exit(4)
+
+	o.begin(operation.ReloadStatus{Generation: 2, Previous: 1, State: operation.ReloadRunning, Workers: []operation.ReloadWorker{}})
+	o.rollback(2, []int{3, 4, 5}, 3, errors.New("worker 3 exited before it was ready"))
+
+	assert.Equal(t, []int{1, 2}, provider.PIDs())
+
+	status, ok := o.ReloadStatus()
+	assert.True(t, ok)
+	assert.Equal(t, operation.ReloadRolledBack, status.State)
+
+	phases := map[int]operation.ReloadPhase{}
+	for _, w := range status.Workers {
+		phases[w.PID] = w.Phase
+	}
+
+	assert.Equal(t, map[int]operation.ReloadPhase{
+		3: operation.PhaseFailed,
+		4: operation.PhaseFailed,
+		5: operation.PhaseRetired,
+	}, phases)
+
+	// exit yang sampai ke supervisor setelah rollback bukan crash
+	for _, pid := range []int{3, 4, 5} {
+		assert.True(t, o.retired(pid), "pid %d", pid)
+	}
+
+	assert.False(t, o.retired(1))
+}
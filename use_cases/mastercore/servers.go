@@ -0,0 +1,102 @@
+package mastercore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"mox/tools/utils"
+	"mox/use_cases/operation"
+)
+
+// Backends implements [operation.SystemCore]. Isinya state yang diminta
+// master (model [proxy] plus server state file), bukan hasil tanya worker.
+func (o *Orchestrator) Backends() ([]operation.Backend, error) {
+	model, _, err := o.proxyConfig().Model()
+	if err != nil {
+		return nil, err
+	}
+
+	o.srvMu.Lock()
+	defer o.srvMu.Unlock()
+
+	overrides, err := operation.LoadServerOverrides(o.app.Config().Proxy.ServerStateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return overrides.Backends(model), nil
+}
+
+// UpdateServer implements [operation.SystemCore]. Perubahan disimpan dulu di
+// server state file baru dikirim ke semua worker hidup, jadi worker yang
+// start di tengah-tengah tetap dapat state terbaru waktu engine-nya jalan.
+// Broadcast jalan di luar srvMu, worker yang lambat tidak menahan perubahan
+// berikutnya (API server maupun transisi health check).
+func (o *Orchestrator) UpdateServer(change operation.ServerChange) error {
+	if err := o.saveServer(change); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	workers := o.provider.GetAll()
+	results := o.bus.Broadcast(o.app.Context(), operation.MessagePayload{
+		ID: utils.GenerateUUID(),
+		Payload: operation.Command{
+			Type:    operation.ServerUpdate,
+			Payload: body,
+		},
+		Timestamp: time.Now().UnixMilli(),
+	}, workers)
+
+	failed := results.Failed()
+
+	o.app.Logger().Info("backend server updated",
+		slog.String("event", "server.update"),
+		slog.String("change", change.String()),
+		slog.Int("workers", len(workers)),
+		slog.Int("failed", len(failed)),
+	)
+
+	// state tetap disimpan: worker yang gagal akan ikut state ini setelah diganti generasi baru
+	if err := results.Err(); err != nil {
+		return fmt.Errorf("%s saved but not applied by %d of %d workers: %w", change, len(failed), len(workers), err)
+	}
+
+	return nil
+}
+
+// saveServer check change against the model and persist it in the server state file
+func (o *Orchestrator) saveServer(change operation.ServerChange) error {
+	cfg := o.proxyConfig()
+
+	model, _, err := cfg.Model()
+	if err != nil {
+		return err
+	}
+
+	o.srvMu.Lock()
+	defer o.srvMu.Unlock()
+
+	overrides, err := operation.LoadServerOverrides(cfg.ServerStateFile)
+	if err != nil {
+		return err
+	}
+
+	if err := overrides.Check(model, change); err != nil {
+		return err
+	}
+
+	overrides.Apply(change)
+
+	if err := overrides.Save(cfg.ServerStateFile); err != nil {
+		return fmt.Errorf("cannot persist server state: %w", err)
+	}
+
+	return nil
+}
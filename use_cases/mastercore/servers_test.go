@@ -0,0 +1,96 @@
+package mastercore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/use_cases/bus"
+	"mox/use_cases/operation"
+	"mox/use_cases/workerclient"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowWorker answer a request only after release is closed
+type slowWorker struct {
+	fakeWorker
+
+	requested chan struct{}
+	release   chan struct{}
+}
+
+func (w *slowWorker) Request(ctx context.Context, msg operation.MessagePayload) (operation.Reply, error) {
+	close(w.requested)
+
+	select {
+	case <-w.release:
+		return operation.Reply{Status: operation.ReplyAck}, nil
+	case <-ctx.Done():
+		return operation.Reply{}, ctx.Err()
+	}
+}
+
+// workersProvider is fakeProvider with a fixed set of connected workers
+type workersProvider struct {
+	*fakeProvider
+
+	workers []workerclient.WorkerProcess
+}
+
+func (p *workersProvider) GetAll() []workerclient.WorkerProcess { return p.workers }
+
+func TestUpdateServerBroadcastOutsideLock(t *testing.T) {
+	o, provider := newTestOrchestrator(t, 0)
+
+	o.app = core.NewTestAppWithConfig(config.Config{
+		Master: config.MasterConfig{RequestTimeout: 5 * time.Second},
+		Proxy: config.ProxyConfig{
+			Engine:          config.EngineBuiltin,
+			ConfigFile:      "haproxy.cfg",
+			ServerStateFile: "servers.json",
+		},
+	})
+	o.bus = bus.NewEventBus(o.app)
+
+	cfg := "defaults\n    mode http\n\nbackend api\n    server s1 127.0.0.1:8080\n"
+	require.NoError(t, os.WriteFile("haproxy.cfg", []byte(cfg), 0o644))
+
+	slow := &slowWorker{fakeWorker: fakeWorker{pid: 1}, requested: make(chan struct{}), release: make(chan struct{})}
+	o.provider = &workersProvider{fakeProvider: provider, workers: []workerclient.WorkerProcess{slow}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- o.UpdateServer(operation.ServerChange{Action: operation.ServerSetWeight, Backend: "api", Server: "s1", Weight: 10})
+	}()
+
+	select {
+	case <-slow.requested:
+	case <-time.After(time.Second):
+		t.Fatal("change was not broadcast")
+	}
+
+	// worker masih belum balas, state file sudah bisa dibaca dan diubah lagi
+	backends := make(chan []operation.Backend, 1)
+	go func() {
+		b, err := o.Backends()
+		assert.NoError(t, err)
+		backends <- b
+	}()
+
+	select {
+	case b := <-backends:
+		require.Len(t, b, 1)
+		require.Len(t, b[0].Servers, 1)
+		assert.Equal(t, 10, b[0].Servers[0].Weight)
+	case <-time.After(time.Second):
+		t.Fatal("Backends blocked by a slow worker")
+	}
+
+	close(slow.release)
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,84 @@
+package mastercore
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/use_cases/workercore"
+)
+
+// Spawner start one worker process for a generation
+type Spawner interface {
+	Spawn(generation int, configHash, pinned string) (*asyncexec.Cmd, error)
+}
+
+var _ (Spawner) = (*WorkerSpawner)(nil)
+
+// WorkerSpawner fork/exec `mox worker` children on behalf of the master
+type WorkerSpawner struct {
+	app core.App
+}
+
+func NewWorkerSpawner(app core.App) *WorkerSpawner {
+	return &WorkerSpawner{app: app}
+}
+
+func (s *WorkerSpawner) executable() (string, error) {
+	if e := s.app.Config().Master.Executable; e != "" {
+		return e, nil
+	}
+
+	return os.Executable()
+}
+
+func (s *WorkerSpawner) args() []string {
+	args := []string{"worker"}
+
+	if path := s.app.ConfigPath(); path != "" {
+		args = append(args, "-c", path)
+	}
+
+	return args
+}
+
+// Spawn start one worker process for the given generation, pinned is the
+// revision file it must serve instead of [proxy]. The process is not bound to the app context,
+// worker will exit by itself when the bus connection to the master is gone,
+// so a cancelled master never SIGKILLs a worker in the middle of a request.
+func (s *WorkerSpawner) Spawn(generation int, configHash, pinned string) (*asyncexec.Cmd, error) {
+	executable, err := s.executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve worker executable: %w", err)
+	}
+
+	cmd := asyncexec.Command(context.Background(), executable, s.args()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// generasi ikut dicatat di log worker dan haproxy-nya, dilaporkan balik waktu handshake
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d", workercore.GenerationEnv, generation),
+		fmt.Sprintf("%s=%s", workercore.ConfigHashEnv, configHash),
+	)
+
+	if pinned != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", workercore.PinnedConfigEnv, pinned))
+	}
+
+	if err := cmd.AsyncRun(); err != nil {
+		return nil, fmt.Errorf("cannot start worker: %w", err)
+	}
+
+	s.app.Logger().Info("worker spawned",
+		slog.Int("pid", cmd.Process.Pid),
+		slog.Int("generation", generation),
+		slog.String("config_hash", configHash),
+		slog.String("pinned", pinned),
+		slog.String("executable", executable),
+	)
+
+	return cmd, nil
+}
@@ -0,0 +1,206 @@
+package mastercore
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	core "mox/internal"
+)
+
+type RestartPolicy string
+
+const (
+	RestartAlways    RestartPolicy = "always"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartNever     RestartPolicy = "never"
+)
+
+// ShouldRestart decide from the exit code whether the worker must come back
+func (p RestartPolicy) ShouldRestart(exitCode int) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0
+	default:
+		return false
+	}
+}
+
+// Restart decision yang dicatat di event log
+const (
+	DecisionRestart  = "restart"
+	DecisionSkip     = "skip"
+	DecisionDegraded = "degraded"
+	DecisionRetired  = "retired"
+)
+
+// crashLoop count restarts inside a sliding window and compute the backoff.
+// Kalau restart di dalam window sudah lewat batas, breaker kebuka (degraded).
+type crashLoop struct {
+	base     time.Duration
+	max      time.Duration
+	limit    int
+	window   time.Duration
+	restarts []time.Time
+	degraded bool
+}
+
+func (c *crashLoop) prune(now time.Time) {
+	kept := c.restarts[:0]
+	for _, t := range c.restarts {
+		if now.Sub(t) < c.window {
+			kept = append(kept, t)
+		}
+	}
+
+	c.restarts = kept
+}
+
+// next record one restart at now and return the backoff to wait before it.
+// ok false berarti breaker sudah kebuka dan restart harus ditahan.
+func (c *crashLoop) next(now time.Time) (backoff time.Duration, ok bool) {
+	if c.degraded {
+		return 0, false
+	}
+
+	c.prune(now)
+
+	if len(c.restarts) >= c.limit {
+		c.degraded = true
+		return 0, false
+	}
+
+	backoff = c.base
+	for i := 0; i < len(c.restarts) && backoff < c.max; i++ {
+		backoff *= 2
+	}
+
+	if backoff > c.max {
+		backoff = c.max
+	}
+
+	c.restarts = append(c.restarts, now)
+
+	return backoff, true
+}
+
+func (c *crashLoop) reset() {
+	c.restarts = nil
+	c.degraded = false
+}
+
+// Supervisor watch worker exits and bring capacity back according to the restart policy
+type Supervisor struct {
+	app    core.App
+	orch   *Orchestrator
+	policy RestartPolicy
+	mu     *sync.Mutex
+	loop   *crashLoop
+}
+
+func NewSupervisor(app core.App, orch *Orchestrator) *Supervisor {
+	cfg := app.Config().Master
+
+	return &Supervisor{
+		app:    app,
+		orch:   orch,
+		policy: RestartPolicy(cfg.RestartPolicy),
+		mu:     &sync.Mutex{},
+		loop: &crashLoop{
+			base:   cfg.RestartBackoff,
+			max:    cfg.RestartMaxBackoff,
+			limit:  cfg.CrashLoopRestarts,
+			window: cfg.CrashLoopWindow,
+		},
+	}
+}
+
+// Degraded true kalau pool kena crash loop dan restart otomatis berhenti
+func (s *Supervisor) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loop.degraded
+}
+
+// Reset close the breaker, dipanggil kalau operator scale manual
+func (s *Supervisor) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loop.degraded {
+		s.app.Logger().Info("worker pool breaker reset", slog.String("event", "worker.pool.reset"))
+	}
+
+	s.loop.reset()
+}
+
+func (s *Supervisor) Run(ctx context.Context, exits <-chan WorkerExit) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-exits:
+			s.handle(ctx, e)
+		}
+	}
+}
+
+func (s *Supervisor) event(decision string, e WorkerExit, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("event", "worker.restart"),
+		slog.String("decision", decision),
+		slog.String("policy", string(s.policy)),
+		slog.Int("pid", e.PID),
+		slog.Int("exit_code", e.ExitCode),
+		slog.Bool("disconnected", e.Disconnected),
+	}, attrs...)
+
+	level := slog.LevelInfo
+	if decision == DecisionDegraded {
+		level = slog.LevelError
+	}
+
+	s.app.Logger().LogAttrs(context.Background(), level, "worker restart decision", attrs...)
+}
+
+func (s *Supervisor) handle(ctx context.Context, e WorkerExit) {
+	if s.orch.retired(e.PID) {
+		s.event(DecisionRetired, e)
+		return
+	}
+
+	if !s.policy.ShouldRestart(e.ExitCode) {
+		s.event(DecisionSkip, e)
+		// pool memang dibiarkan mengecil
+		s.orch.forget()
+		return
+	}
+
+	s.mu.Lock()
+	backoff, ok := s.loop.next(time.Now())
+	restarts := len(s.loop.restarts)
+	s.mu.Unlock()
+
+	if !ok {
+		s.event(DecisionDegraded, e, slog.Int("restarts_in_window", restarts))
+		return
+	}
+
+	s.event(DecisionRestart, e, slog.Duration("backoff", backoff), slog.Int("restarts_in_window", restarts))
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if err := s.orch.Reconcile(); err != nil {
+			s.app.Logger().Error("respawn failed", slog.Int("pid", e.PID), slog.String("err", err.Error()))
+		}
+	}()
+}
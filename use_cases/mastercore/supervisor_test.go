@@ -0,0 +1,87 @@
+package mastercore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestartPolicy(t *testing.T) {
+	tableTests := []struct {
+		name     string
+		policy   RestartPolicy
+		exitCode int
+		expected bool
+	}{
+		{name: "always on clean exit", policy: RestartAlways, exitCode: 0, expected: true},
+		{name: "always on crash", policy: RestartAlways, exitCode: 2, expected: true},
+		{name: "on-failure on clean exit", policy: RestartOnFailure, exitCode: 0, expected: false},
+		{name: "on-failure on crash", policy: RestartOnFailure, exitCode: 1, expected: true},
+		{name: "on-failure on signal", policy: RestartOnFailure, exitCode: -1, expected: true},
+		{name: "never", policy: RestartNever, exitCode: 1, expected: false},
+		{name: "unknown policy", policy: RestartPolicy("sometimes"), exitCode: 1, expected: false},
+	}
+
+	for _, test := range tableTests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.policy.ShouldRestart(test.exitCode))
+		})
+	}
+}
+
+func TestCrashLoopBackoff(t *testing.T) {
+	loop := &crashLoop{base: time.Second, max: 5 * time.Second, limit: 10, window: time.Minute}
+	now := time.Now()
+
+	expected := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	}
+
+	for i, e := range expected {
+		backoff, ok := loop.next(now.Add(time.Duration(i) * time.Millisecond))
+		assert.True(t, ok)
+		assert.Equal(t, e, backoff)
+	}
+}
+
+func TestCrashLoopBreaker(t *testing.T) {
+	loop := &crashLoop{base: time.Second, max: time.Minute, limit: 3, window: time.Minute}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, ok := loop.next(now)
+		assert.True(t, ok)
+	}
+
+	_, ok := loop.next(now)
+	assert.False(t, ok)
+	assert.True(t, loop.degraded)
+
+	// tetap kebuka walaupun window sudah lewat, nunggu operator
+	_, ok = loop.next(now.Add(2 * time.Minute))
+	assert.False(t, ok)
+
+	loop.reset()
+
+	backoff, ok := loop.next(now.Add(2 * time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, backoff)
+}
+
+func TestCrashLoopWindowExpires(t *testing.T) {
+	loop := &crashLoop{base: time.Second, max: time.Minute, limit: 2, window: time.Minute}
+	now := time.Now()
+
+	loop.next(now)
+	loop.next(now.Add(time.Second))
+
+	// restart lama sudah keluar dari window, backoff balik ke base
+	backoff, ok := loop.next(now.Add(2 * time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, backoff)
+}
@@ -0,0 +1,180 @@
+package mastercore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/pkg/upgrade"
+	"mox/use_cases/wire"
+)
+
+// fileConn is implemented by worker clients whose bus connection can be handed over
+type fileConn interface {
+	File() (*os.File, error)
+	Protocol() wire.Protocol
+	Detach()
+	Attach()
+}
+
+// Upgrader re-exec the mox binary and hand every socket to the new master.
+// Master lama baru exit setelah master baru lapor READY.
+type Upgrader struct {
+	app     core.App
+	master  *Master
+	running atomic.Bool
+}
+
+func NewUpgrader(app core.App, master *Master) *Upgrader {
+	return &Upgrader{app: app, master: master}
+}
+
+func (u *Upgrader) collect(b *upgrade.Builder) error {
+	if err := u.master.server.Handover(b); err != nil {
+		return err
+	}
+
+	for _, w := range u.master.workers.GetAll() {
+		fc, ok := w.(fileConn)
+		if !ok {
+			continue
+		}
+
+		// read loop harus berhenti dulu, setelah ini yang baca master baru
+		fc.Detach()
+
+		f, err := fc.File()
+		if err != nil {
+			u.app.Logger().Warn("worker connection cannot be handed over", slog.Int("pid", w.PID()), slog.String("err", err.Error()))
+			continue
+		}
+
+		p := fc.Protocol()
+		b.AddWorker(upgrade.Worker{
+			PID:        w.PID(),
+			Version:    p.Version,
+			Codec:      uint8(p.Codec),
+			Generation: w.Generation(),
+			ConfigHash: w.ConfigHash(),
+		}, f)
+	}
+
+	b.SetDesired(int(u.master.orch.GetDesiredWorkers()))
+
+	return b.CollectInheritables()
+}
+
+func (u *Upgrader) rollback() {
+	u.master.server.Resume()
+
+	for _, w := range u.master.workers.GetAll() {
+		if fc, ok := w.(fileConn); ok {
+			fc.Attach()
+		}
+	}
+
+	u.master.workers.Resume()
+}
+
+// Upgrade start the new master and wait for it. On success the current
+// master stops without shutting its workers down.
+func (u *Upgrader) Upgrade() error {
+	if !u.running.CompareAndSwap(false, true) {
+		return errors.New("upgrade already in progress")
+	}
+	defer u.running.Store(false)
+
+	u.app.Logger().Info("master upgrade started", slog.Int("pid", os.Getpid()))
+
+	u.master.workers.Pause()
+
+	b := upgrade.NewBuilder()
+	defer b.Close()
+
+	if err := u.collect(b); err != nil {
+		u.rollback()
+		return fmt.Errorf("cannot collect sockets for upgrade: %w", err)
+	}
+
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		u.rollback()
+		return err
+	}
+	defer ready.Close()
+
+	b.AddFile(upgrade.FileReady, readyW)
+
+	env, err := b.Env()
+	if err != nil {
+		u.rollback()
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		u.rollback()
+		return err
+	}
+
+	cmd := asyncexec.Command(context.Background(), executable, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env)
+	cmd.ExtraFiles = b.Files()
+
+	if err := cmd.AsyncRun(); err != nil {
+		u.rollback()
+		return fmt.Errorf("cannot start new master: %w", err)
+	}
+
+	// copy fd punya kita sudah tidak perlu, termasuk ujung tulis pipe ready
+	b.Close()
+
+	if err := u.waitReady(ready, cmd); err != nil {
+		if cmd.ProcessState == nil {
+			cmd.Process.Kill()
+		}
+
+		u.rollback()
+		return err
+	}
+
+	u.app.Logger().Info("new master is ready, handing over", slog.Int("new_pid", cmd.Process.Pid))
+
+	u.master.handedOff.Store(true)
+	u.app.Stop()
+
+	return nil
+}
+
+func (u *Upgrader) waitReady(ready *os.File, cmd *asyncexec.Cmd) error {
+	line := make(chan string, 1)
+
+	go func() {
+		s, _ := bufio.NewReader(ready).ReadString('\n')
+		line <- s
+	}()
+
+	timeout := u.app.Config().Master.UpgradeTimeout
+
+	select {
+	case s := <-line:
+		if s == "" {
+			return errors.New("new master exited before reporting ready")
+		}
+
+		return nil
+	case <-cmd.Terminated:
+		return fmt.Errorf("new master exited: %s", cmd.Status())
+	case <-time.After(timeout):
+		return fmt.Errorf("new master not ready after %s", timeout)
+	}
+}
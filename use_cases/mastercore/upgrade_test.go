@@ -0,0 +1,254 @@
+package mastercore
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	asyncexec "mox/pkg/async"
+	"mox/pkg/config"
+	"mox/pkg/upgrade"
+	"mox/use_cases/bus"
+	"mox/use_cases/manager"
+	"mox/use_cases/operation"
+	"mox/use_cases/wire"
+	"mox/use_cases/workerclient"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestUpgrader build a master with a listening gateway, one worker
+// connected over a real unix socket and one worker that cannot be handed over
+func newTestUpgrader(t *testing.T) (*Upgrader, *workerclient.WorkerClient, *net.UnixConn) {
+	t.Helper()
+
+	o, _ := newTestOrchestrator(t, 2)
+
+	app := core.NewTestAppWithConfig(config.Config{
+		Master: config.MasterConfig{BusCodec: "json", UpgradeTimeout: time.Second},
+	})
+	t.Cleanup(app.Stop)
+
+	listeners := manager.NewListenerManager()
+	_, err := listeners.OpenListener("gateway", "tcp://127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := bus.NewIPCServerGateway(app, filepath.Join(t.TempDir(), "mox.sock"), listeners)
+	require.NoError(t, server.ListenAndServe())
+	t.Cleanup(server.Close)
+
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "worker.sock"), Net: "unix"}
+	l, err := net.ListenUnix("unix", addr)
+	require.NoError(t, err)
+	defer l.Close()
+
+	conn, err := net.DialUnix("unix", nil, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	peer, err := l.AcceptUnix()
+	require.NoError(t, err)
+	t.Cleanup(func() { peer.Close() })
+
+	w := workerclient.NewWorkerClient(app, peer, 42).
+		SetProtocol(wire.Protocol{Version: 1, Codec: wire.CodecJSON}).
+		SetGeneration(3, "abc")
+	w.Attach()
+
+	workers := NewConnectionRegistry(t.Context(), app)
+	require.NoError(t, workers.Add(w))
+	require.NoError(t, workers.Add(&fakeWorker{pid: 7}))
+
+	m := &Master{app: app, workers: workers, server: server, orch: o}
+
+	return NewUpgrader(app, m), w, conn
+}
+
+// writeStats send a stats event from the worker end of the bus connection
+func writeStats(t *testing.T, conn net.Conn) {
+	t.Helper()
+
+	_, err := wire.Protocol{Version: 1, Codec: wire.CodecJSON}.Write(conn, operation.MessagePayload{
+		FromPID: 42,
+		Payload: operation.Command{Type: operation.EventStats, Payload: []byte("{}")},
+	})
+	require.NoError(t, err)
+}
+
+func manifestOf(t *testing.T, b *upgrade.Builder) upgrade.Manifest {
+	t.Helper()
+
+	env, err := b.Env()
+	require.NoError(t, err)
+
+	var m upgrade.Manifest
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(env, upgrade.EnvManifest+"=")), &m))
+
+	return m
+}
+
+func TestUpgraderCollect(t *testing.T) {
+	u, w, conn := newTestUpgrader(t)
+
+	b := upgrade.NewBuilder()
+	defer b.Close()
+
+	require.NoError(t, u.collect(b))
+
+	m := manifestOf(t, b)
+	assert.Contains(t, m.Files, bus.InheritBus)
+	assert.Contains(t, m.Files, bus.InheritListener+"gateway")
+	assert.Equal(t, 2, m.Desired)
+
+	// fakeWorker tidak punya koneksi, cuma worker 42 yang diwariskan
+	require.Len(t, m.Workers, 1)
+	assert.Equal(t, 42, m.Workers[0].PID)
+	assert.Equal(t, uint8(1), m.Workers[0].Version)
+	assert.Equal(t, uint8(wire.CodecJSON), m.Workers[0].Codec)
+	assert.Equal(t, 3, m.Workers[0].Generation)
+	assert.Equal(t, "abc", m.Workers[0].ConfigHash)
+
+	// read loop sudah berhenti, frame dari worker dibaca lewat fd yang diwariskan
+	writeStats(t, conn)
+	assert.Never(t, func() bool {
+		_, ok := w.Stats()
+		return ok
+	}, 200*time.Millisecond, 10*time.Millisecond)
+
+	// fd 3 = ExtraFiles[0]
+	handed, err := net.FileConn(b.Files()[m.Workers[0].FD-3])
+	require.NoError(t, err)
+	defer handed.Close()
+
+	handed.SetReadDeadline(time.Now().Add(time.Second))
+	msg, err := wire.Protocol{Version: 1, Codec: wire.CodecJSON}.Read(handed)
+	require.NoError(t, err)
+	assert.Equal(t, operation.EventStats, msg.Payload.Type)
+}
+
+func TestUpgraderRollback(t *testing.T) {
+	u, w, conn := newTestUpgrader(t)
+
+	u.master.workers.Pause()
+
+	b := upgrade.NewBuilder()
+	defer b.Close()
+
+	require.NoError(t, u.collect(b))
+
+	// master baru mati sebelum lapor ready
+	ready, readyW, err := os.Pipe()
+	require.NoError(t, err)
+	defer ready.Close()
+	readyW.Close()
+
+	assert.Error(t, u.waitReady(ready, asyncexec.Command(t.Context(), "true")))
+
+	u.rollback()
+
+	assert.False(t, u.master.workers.paused.Load())
+
+	// read loop jalan lagi
+	writeStats(t, conn)
+	assert.Eventually(t, func() bool {
+		_, ok := w.Stats()
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	// gateway terima worker baru lagi
+	worker, err := net.Dial("unix", u.master.server.SocketPath)
+	require.NoError(t, err)
+	defer worker.Close()
+
+	offer := wire.Offer(wire.CodecJSON)
+	offer.PID = os.Getpid()
+
+	f, err := wire.HelloFrame(operation.Hello, offer)
+	require.NoError(t, err)
+	_, err = wire.WriteFrame(worker, f)
+	require.NoError(t, err)
+
+	select {
+	case p := <-u.master.server.WorkerEvent:
+		assert.Equal(t, os.Getpid(), p.PID())
+	case <-time.After(2 * time.Second):
+		t.Fatal("gateway did not accept a worker after rollback")
+	}
+}
+
+func TestUpgraderWaitReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ready   func(w *os.File)
+		cmd     func() *asyncexec.Cmd
+		err     string
+	}{
+		{
+			name:  "ready",
+			ready: func(w *os.File) { w.WriteString("READY\n") },
+		},
+		{
+			name:  "closed without ready",
+			ready: func(w *os.File) { w.Close() },
+			err:   "new master exited before reporting ready",
+		},
+		{
+			name: "process exited",
+			cmd: func() *asyncexec.Cmd {
+				cmd := &asyncexec.Cmd{Cmd: &exec.Cmd{}, Terminated: make(chan bool, 1)}
+				cmd.Terminated <- true
+				return cmd
+			},
+			err: "new master exited: not started",
+		},
+		{
+			name:    "timeout",
+			timeout: 50 * time.Millisecond,
+			err:     "new master not ready after 50ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := time.Second
+			if tt.timeout > 0 {
+				timeout = tt.timeout
+			}
+
+			app := core.NewTestAppWithConfig(config.Config{
+				Master: config.MasterConfig{UpgradeTimeout: timeout},
+			})
+			u := NewUpgrader(app, nil)
+
+			ready, readyW, err := os.Pipe()
+			require.NoError(t, err)
+			defer ready.Close()
+			defer readyW.Close()
+
+			cmd := &asyncexec.Cmd{Cmd: &exec.Cmd{}, Terminated: make(chan bool)}
+			if tt.cmd != nil {
+				cmd = tt.cmd()
+			}
+
+			if tt.ready != nil {
+				tt.ready(readyW)
+			}
+
+			err = u.waitReady(ready, cmd)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
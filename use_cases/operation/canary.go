@@ -0,0 +1,76 @@
+package operation
+
+import (
+	"fmt"
+	"time"
+
+	"mox/pkg/haproxy"
+)
+
+// CanaryThresholds is the limit the canary workers must stay under during the bake
+type CanaryThresholds struct {
+	Max5xxRatio float64       `json:"max_5xx_ratio"`
+	MaxLatency  time.Duration `json:"max_latency"` // 0 = latency tidak dicek
+	MinRequests int64         `json:"min_requests"`
+}
+
+// CanaryMetrics is what the canary workers served so far, dihitung dari `show stat`
+type CanaryMetrics struct {
+	Requests  int64         `json:"requests"`
+	Errors5xx int64         `json:"errors_5xx"`
+	Ratio5xx  float64       `json:"ratio_5xx"`
+	Latency   time.Duration `json:"latency_ns"` // rata-rata ttime backend
+}
+
+// MeasureCanary sum the stats of the canary workers. Response dihitung dari
+// baris FRONTEND, latency dari ttime BACKEND dibobot jumlah session-nya.
+func MeasureCanary(stats []WorkerStats) CanaryMetrics {
+	var m CanaryMetrics
+	var timeSum, sessions int64
+
+	for _, s := range stats {
+		for _, p := range s.Proxies {
+			switch p.Type {
+			case haproxy.TypeFrontend:
+				m.Requests += p.Hrsp1xx + p.Hrsp2xx + p.Hrsp3xx + p.Hrsp4xx + p.Hrsp5xx
+				m.Errors5xx += p.Hrsp5xx
+			case haproxy.TypeBackend:
+				timeSum += p.TTime * p.STot
+				sessions += p.STot
+			}
+		}
+	}
+
+	if m.Requests > 0 {
+		m.Ratio5xx = float64(m.Errors5xx) / float64(m.Requests)
+	}
+
+	if sessions > 0 {
+		m.Latency = time.Duration(timeSum/sessions) * time.Millisecond
+	}
+
+	return m
+}
+
+// Check return why the canaries break t, nil kalau masih aman. Sebelum
+// MinRequests tercapai angkanya belum dianggap mewakili, jadi selalu lolos.
+func (m CanaryMetrics) Check(t CanaryThresholds) error {
+	if m.Requests == 0 || m.Requests < t.MinRequests {
+		return nil
+	}
+
+	if m.Ratio5xx > t.Max5xxRatio {
+		return fmt.Errorf("canary 5xx ratio %.4f is above %.4f (%d of %d responses)", m.Ratio5xx, t.Max5xxRatio, m.Errors5xx, m.Requests)
+	}
+
+	if t.MaxLatency > 0 && m.Latency > t.MaxLatency {
+		return fmt.Errorf("canary latency %s is above %s", m.Latency, t.MaxLatency)
+	}
+
+	return nil
+}
+
+// Enough report whether the canaries served enough requests to be promoted
+func (m CanaryMetrics) Enough(t CanaryThresholds) bool {
+	return m.Requests >= t.MinRequests
+}
@@ -0,0 +1,85 @@
+package operation
+
+import (
+	"testing"
+	"time"
+
+	"mox/pkg/haproxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasureCanary(t *testing.T) {
+	stats := []WorkerStats{
+		{Proxies: []haproxy.Stat{
+			{ProxyName: "gateway", ServiceName: "FRONTEND", Type: haproxy.TypeFrontend, Hrsp2xx: 90, Hrsp4xx: 5, Hrsp5xx: 5},
+			{ProxyName: "api", ServiceName: "BACKEND", Type: haproxy.TypeBackend, STot: 100, TTime: 20, Hrsp5xx: 5},
+			{ProxyName: "api", ServiceName: "s1", Type: haproxy.TypeServer, STot: 100, TTime: 900},
+		}},
+		{Proxies: []haproxy.Stat{
+			{ProxyName: "gateway", ServiceName: "FRONTEND", Type: haproxy.TypeFrontend, Hrsp2xx: 100},
+			{ProxyName: "api", ServiceName: "BACKEND", Type: haproxy.TypeBackend, STot: 300, TTime: 60},
+		}},
+		// worker yang belum kirim `show stat`
+		{},
+	}
+
+	m := MeasureCanary(stats)
+	assert.Equal(t, int64(200), m.Requests)
+	assert.Equal(t, int64(5), m.Errors5xx)
+	assert.Equal(t, 0.025, m.Ratio5xx)
+	// (20*100 + 60*300) / 400, baris server tidak dihitung
+	assert.Equal(t, 50*time.Millisecond, m.Latency)
+
+	assert.Equal(t, CanaryMetrics{}, MeasureCanary(nil))
+}
+
+func TestCanaryMetricsCheck(t *testing.T) {
+	limits := CanaryThresholds{Max5xxRatio: 0.05, MaxLatency: 100 * time.Millisecond, MinRequests: 50}
+
+	tests := []struct {
+		name    string
+		metrics CanaryMetrics
+		wantErr string
+		enough  bool
+	}{
+		{
+			name:    "idle",
+			metrics: CanaryMetrics{},
+		},
+		{
+			name:    "too few requests to judge",
+			metrics: CanaryMetrics{Requests: 10, Errors5xx: 10, Ratio5xx: 1},
+		},
+		{
+			name:    "healthy",
+			metrics: CanaryMetrics{Requests: 100, Errors5xx: 5, Ratio5xx: 0.05, Latency: 100 * time.Millisecond},
+			enough:  true,
+		},
+		{
+			name:    "too many 5xx",
+			metrics: CanaryMetrics{Requests: 100, Errors5xx: 6, Ratio5xx: 0.06},
+			wantErr: "canary 5xx ratio 0.0600 is above 0.0500 (6 of 100 responses)",
+			enough:  true,
+		},
+		{
+			name:    "too slow",
+			metrics: CanaryMetrics{Requests: 100, Latency: 150 * time.Millisecond},
+			wantErr: "canary latency 150ms is above 100ms",
+			enough:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.metrics.Check(limits)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+
+			assert.Equal(t, tt.enough, tt.metrics.Enough(limits))
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package operation
 
+import "strings"
+
 // 3. Metadata Command (biar bisa generate HELP otomatis)
 type Command struct {
 	Name        string
@@ -9,6 +11,17 @@ type Command struct {
 	Payload     []byte
 }
 
+// Args return the words after the command name, e.g. "LISTENER ADD web :80"
+// jadi ["ADD", "web", ":80"]. Payload berisi baris perintah mentah.
+func (c Command) Args() []string {
+	fields := strings.Fields(string(c.Payload))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields[1:]
+}
+
 type MsgType int
 
 const (
@@ -19,6 +32,13 @@ const (
 	Chat
 	EventStats
 	ConfigReload
+	Hello        // handshake bus, negosiasi versi protocol
+	Reject       // handshake ditolak, body berisi alasannya
+	Response     // balasan worker untuk satu request, lihat Reply
+	Runtime      // perintah mentah HAProxy Runtime API, dijalankan worker di stats socket-nya
+	DrainEvent   // progress drain dari worker, body berisi DrainReport
+	ServerUpdate // ubah satu server backend di engine worker, body berisi ServerChange
+	Readiness    // cek worker siap terima traffic, dipakai rollout sebelum generasi lama di-drain
 )
 
 // Define the map at package level (optional)
@@ -30,6 +50,13 @@ var commandNames = map[MsgType]string{
 	Pong:         "PONG",
 	EventStats:   "EVENT_STATS",
 	ConfigReload: "CONFIG_RELOAD",
+	Hello:        "HELLO",
+	Reject:       "REJECT",
+	Response:     "RESPONSE",
+	Runtime:      "RUNTIME",
+	DrainEvent:   "DRAIN_EVENT",
+	ServerUpdate: "SERVER_UPDATE",
+	Readiness:    "READINESS",
 }
 
 // String satisfies the fmt.Stringer interface
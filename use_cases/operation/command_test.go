@@ -0,0 +1,65 @@
+package operation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    []string
+	}{
+		{payload: "", want: nil},
+		{payload: "UPGRADE", want: []string{}},
+		{payload: "LISTENER ADD web tcp://:80", want: []string{"ADD", "web", "tcp://:80"}},
+		{payload: "  LISTENER   REMOVE  web ", want: []string{"REMOVE", "web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.payload, func(t *testing.T) {
+			assert.Equal(t, tt.want, Command{Payload: []byte(tt.payload)}.Args())
+		})
+	}
+}
+
+func TestMasterRegistryExecuteWithArgs(t *testing.T) {
+	r := NewMasterRegistry()
+
+	var got []string
+	r.Register("listener", "", "", func(ctx context.Context, systemCore SystemCore, cmd Command) (string, error) {
+		got = cmd.Args()
+		return "", nil
+	})
+
+	line := "listener REMOVE web"
+	_, err := r.Execute(context.Background(), nil, Command{Name: line, Payload: []byte(line)})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"REMOVE", "web"}, got)
+}
+
+func TestMasterRegistryHelp(t *testing.T) {
+	r := NewMasterRegistry()
+
+	noop := func(ctx context.Context, systemCore SystemCore, cmd Command) (string, error) {
+		return "done", nil
+	}
+	r.Register("scale", "Spawn or retire workers", "SCALE <n>", noop)
+	r.Register("DRAIN", "Drain one worker", "DRAIN <pid>", noop)
+
+	assert.Equal(t, []Command{
+		{Name: "DRAIN", Description: "Drain one worker", Usage: "DRAIN <pid>"},
+		{Name: "SCALE", Description: "Spawn or retire workers", Usage: "SCALE <n>"},
+	}, r.Commands())
+	assert.Equal(t, "DRAIN - Drain one worker\n    usage: DRAIN <pid>\nSCALE - Spawn or retire workers\n    usage: SCALE <n>\n", r.Help())
+
+	out, err := r.Execute(context.Background(), nil, Command{Name: "scale 3", Payload: []byte("scale 3")})
+	assert.NoError(t, err)
+	assert.Equal(t, "done", out)
+
+	_, err = r.Execute(context.Background(), nil, Command{Name: "BOGUS", Payload: []byte("BOGUS")})
+	assert.EqualError(t, err, "Unknown command: BOGUS. Type HELP for list.")
+}
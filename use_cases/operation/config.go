@@ -0,0 +1,78 @@
+package operation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrConfigRejected is returned when the mox config file fails to parse or validate
+var ErrConfigRejected = errors.New("config rejected")
+
+// restartKeys are config keys only read when the master starts, perubahannya
+// cuma dilaporkan. Prefix "apis" juga cocok dengan "apis.port", "apis.cors".
+var restartKeys = []string{
+	"app.name",
+	"app.version",
+	"apis",
+	"monitoring",
+	"default_database",
+	"databases_sql",
+	"listeners", // buka/tutup listener lewat LISTENER ADD/REMOVE
+	"master.socket_path",
+	"master.socket_mode",
+	"master.allowed_uids",
+	"master.allowed_gids",
+	"master.bus_codec",
+	"master.control_address",
+	"health",
+	"revisions.database",
+}
+
+// rolloutKeys are read by the workers, diterapkan lewat generasi worker baru
+var rolloutKeys = []string{"proxy", "worker"}
+
+// ConfigReport is the outcome of re-reading the mox config file
+type ConfigReport struct {
+	// Trigger is what asked the reload: inotify, SIGHUP, api or a reload author
+	Trigger string    `json:"trigger"`
+	At      time.Time `json:"at"`
+	// Applied are changed keys already in effect, app.log_level, worker count, heartbeat dsb
+	Applied []string `json:"applied"`
+	// Rollout are changed keys served by a new worker generation (proxy model, worker)
+	Rollout []string `json:"rollout"`
+	// Restart are changed keys that only take effect after the master restarts
+	Restart []string `json:"restart_required"`
+	// Error is set when the file was rejected, config yang lama tetap dipakai
+	Error string `json:"error,omitempty"`
+}
+
+// Changed tell whether the file differ from the running config at all
+func (r ConfigReport) Changed() bool {
+	return len(r.Applied)+len(r.Rollout)+len(r.Restart) > 0
+}
+
+// ClassifyConfig split the keys of config.Diff by how they take effect
+func ClassifyConfig(trigger string, keys []string) ConfigReport {
+	report := ConfigReport{Trigger: trigger, At: time.Now(), Applied: []string{}, Rollout: []string{}, Restart: []string{}}
+
+	for _, key := range keys {
+		switch {
+		case slices.ContainsFunc(restartKeys, matchKey(key)):
+			report.Restart = append(report.Restart, key)
+		case slices.ContainsFunc(rolloutKeys, matchKey(key)):
+			report.Rollout = append(report.Rollout, key)
+		default:
+			report.Applied = append(report.Applied, key)
+		}
+	}
+
+	return report
+}
+
+func matchKey(key string) func(prefix string) bool {
+	return func(prefix string) bool {
+		return key == prefix || strings.HasPrefix(key, prefix+".")
+	}
+}
@@ -0,0 +1,29 @@
+package operation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyConfig(t *testing.T) {
+	report := ClassifyConfig("SIGHUP", []string{
+		"app.mode",
+		"apis.port",
+		"master.workers",
+		"master.heartbeat_interval",
+		"master.socket_path",
+		"listeners",
+		"proxy.backends",
+		"worker.stats_interval",
+		"revisions.output",
+	})
+
+	assert.Equal(t, "SIGHUP", report.Trigger)
+	assert.True(t, report.Changed())
+	assert.Equal(t, []string{"app.mode", "master.workers", "master.heartbeat_interval", "revisions.output"}, report.Applied)
+	assert.Equal(t, []string{"proxy.backends", "worker.stats_interval"}, report.Rollout)
+	assert.Equal(t, []string{"apis.port", "master.socket_path", "listeners"}, report.Restart)
+
+	assert.False(t, ClassifyConfig("inotify", nil).Changed())
+}
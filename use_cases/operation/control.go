@@ -2,22 +2,69 @@ package operation
 
 import (
 	"context"
+
+	"mox/use_cases/manager"
 )
 
 // ini diisi interface Orchestrator core sama master
 type SystemCore interface {
 	CheckHealth() string
 	GetTotalWorkers() int64
-	ScaleUp()
-	ScaleDown()
+	// Workers daftar worker yang connect ke master, urut PID
+	Workers() []WorkerInfo
+	// GetDesiredWorkers total worker yang harus dijaga master
+	GetDesiredWorkers() int64
+	// ScaleUp nambah satu worker baru
+	ScaleUp() error
+	// ScaleDown drain lalu pensiunkan satu worker
+	ScaleDown() error
+	// Scale set jumlah worker persis ke n
+	Scale(n int) error
 	Drain(pid int) error
+	// Upgrade re-exec binary master tanpa lepas port
+	Upgrade() error
+	// Stats resource usage terakhir tiap worker plus totalnya
+	Stats() StatsReport
+	// ProxyStats `show info` dan `show stat` semua worker digabung per frontend/backend/server
+	ProxyStats() ProxyStats
+	// Listeners daftar listener publik beserta FD-nya
+	Listeners() []manager.Listener
+	// AddListener buka listener baru lalu rollout generasi worker baru
+	AddListener(name string, address string) error
+	// RemoveListener tutup listener lalu rollout generasi worker baru
+	RemoveListener(name string) error
+	// Backends daftar backend beserta server dan state yang dijaga master
+	Backends() ([]Backend, error)
+	// UpdateServer ubah satu server (weight, state, add, del) di semua worker lalu disimpan
+	UpdateServer(change ServerChange) error
+	// Reload rollout generasi worker baru dengan config terbaru di disk, rollback kalau gagal
+	Reload(author, reason string) error
+	// Canary rollout generasi baru ke sebagian worker dulu, dipromosikan kalau lolos bake
+	Canary(author, reason string) error
+	// ReloadStatus progress rollout terakhir, false kalau belum pernah ada
+	ReloadStatus() (ReloadStatus, bool)
+	// Health hasil health check upstream beserta history tiap server
+	Health() []HealthReport
+	// Revisions daftar config proxy yang pernah di-apply, terbaru dulu
+	Revisions() ([]ConfigRevision, error)
+	// Revision satu revisi lengkap dengan haproxy.cfg-nya
+	Revision(n int) (ConfigRevision, error)
+	// DiffRevisions unified diff haproxy.cfg revisi a ke b
+	DiffRevisions(a, b int) (string, error)
+	// RollbackRevision rollout generasi baru yang serve haproxy.cfg revisi n
+	RollbackRevision(n int, author string) error
+	// ReloadConfig baca ulang config mox, apply yang aman dan laporkan yang butuh restart
+	ReloadConfig(trigger string) (ConfigReport, error)
+	// ConfigReport hasil ReloadConfig terakhir, false kalau belum pernah ada
+	ConfigReport() (ConfigReport, bool)
 }
 
 type IControl interface {
 	Execute(ctx context.Context, master SystemCore, cmd Command) (string, error)
 }
 
-type handler func(ctx context.Context, systemCore SystemCore, cmd Command) error
+// handler run one command, output-nya dibalas ke client control port
+type handler func(ctx context.Context, systemCore SystemCore, cmd Command) (string, error)
 
 type MasterControlHandler handler
 
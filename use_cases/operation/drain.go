@@ -0,0 +1,32 @@
+package operation
+
+import "time"
+
+// DrainState is one step of the worker drain state machine
+type DrainState string
+
+const (
+	DrainStarted        DrainState = "started"
+	DrainFrontendClosed DrainState = "frontend_closed" // frontend berhenti accept koneksi baru
+	DrainWaiting        DrainState = "waiting"         // masih ada session aktif
+	DrainDrained        DrainState = "drained"         // session sudah nol
+	DrainTimedOut       DrainState = "timed_out"       // deadline lewat, session dipotong soft stop
+	DrainStopped        DrainState = "stopped"         // haproxy sudah dikirimi SIGUSR1
+	DrainFailed         DrainState = "failed"
+)
+
+// DrainRequest is the body of a DRAIN command
+type DrainRequest struct {
+	Timeout time.Duration `json:"timeout_ns"`
+}
+
+// DrainReport is pushed by the worker on every drain transition (DRAIN_EVENT),
+// report terakhir juga jadi Result balasan DRAIN.
+type DrainReport struct {
+	State     DrainState    `json:"state"`
+	Sessions  int64         `json:"sessions"`
+	Frontends []string      `json:"frontends,omitempty"`
+	TimedOut  bool          `json:"timed_out,omitempty"`
+	Elapsed   time.Duration `json:"elapsed_ns"`
+	Error     string        `json:"error,omitempty"`
+}
@@ -0,0 +1,38 @@
+package operation
+
+import (
+	"time"
+
+	"mox/pkg/haproxy"
+)
+
+// HealthResult is one health check run against one server
+type HealthResult struct {
+	Time     time.Time     `json:"time"`
+	OK       bool          `json:"ok"`
+	Status   int           `json:"status,omitempty"` // status HTTP, 0 untuk tcp
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// HealthTransition is recorded every time a server goes up or down
+type HealthTransition struct {
+	Time   time.Time            `json:"time"`
+	From   haproxy.ServerHealth `json:"from"`
+	To     haproxy.ServerHealth `json:"to"`
+	Reason string               `json:"reason"`
+}
+
+// HealthReport is the health of one checked server, history terbaru paling akhir
+type HealthReport struct {
+	Backend     string               `json:"backend"`
+	Server      string               `json:"server"`
+	Address     string               `json:"address"`
+	Check       string               `json:"check"`
+	Health      haproxy.ServerHealth `json:"health"`
+	Successes   int                  `json:"successes"` // berturut-turut
+	Failures    int                  `json:"failures"`  // berturut-turut
+	LastCheck   time.Time            `json:"last_check"`
+	History     []HealthResult       `json:"history"`
+	Transitions []HealthTransition   `json:"transitions"`
+}
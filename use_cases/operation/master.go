@@ -2,8 +2,8 @@ package operation
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -13,13 +13,19 @@ var _ (IControl) = (*MasterRegistry)(nil)
 // 4. The Registry (Thread-Safe)
 type MasterRegistry struct {
 	mu       sync.RWMutex
-	commands map[string]MasterControlHandler
+	commands map[string]masterCommand
+}
+
+// masterCommand keep the handler together with its HELP text
+type masterCommand struct {
+	info    Command
+	handler MasterControlHandler
 }
 
 // Constructor
 func NewMasterRegistry() *MasterRegistry {
 	return &MasterRegistry{
-		commands: make(map[string]MasterControlHandler),
+		commands: make(map[string]masterCommand),
 	}
 }
 
@@ -29,23 +35,56 @@ func (r *MasterRegistry) Register(name string, desc string, usage string, handle
 	defer r.mu.Unlock()
 
 	// Normalize ke uppercase biar case-insensitive (STOP == stop)
-	r.commands[strings.ToUpper(name)] = handler
+	name = strings.ToUpper(name)
+	r.commands[name] = masterCommand{
+		info:    Command{Name: name, Description: desc, Usage: usage},
+		handler: handler,
+	}
+}
+
+// Commands return the name, description and usage of every command, urut nama
+func (r *MasterRegistry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	commands := make([]Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		commands = append(commands, c.info)
+	}
+
+	slices.SortFunc(commands, func(a, b Command) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return commands
+}
+
+// Help format Commands for the HELP reply of the control port
+func (r *MasterRegistry) Help() string {
+	var b strings.Builder
+	for _, c := range r.Commands() {
+		fmt.Fprintf(&b, "%s - %s\n    usage: %s\n", c.Name, c.Description, c.Usage)
+	}
+
+	return b.String()
 }
 
-// Execute: Routing dari raw string telnet ke function
+// Execute: Routing dari raw string telnet ke function, string yang dikembalikan
+// adalah output command yang dibalas ke client
 func (r *MasterRegistry) Execute(ctx context.Context, syscore SystemCore, cmd Command) (string, error) {
+	// nama command = kata pertama, sisanya argumen (lihat Command.Args)
+	if fields := strings.Fields(cmd.Name); len(fields) > 0 {
+		cmd.Name = strings.ToUpper(fields[0])
+	}
+
 	r.mu.RLock()
-	cmdFn, exists := r.commands[cmd.Name]
+	c, exists := r.commands[cmd.Name]
 	r.mu.RUnlock()
 
 	if !exists {
-		return fmt.Sprintf("Unknown command: %s. Type HELP for list.", cmd.Name), errors.New(fmt.Sprintf("Unknown command: %s. Type HELP for list.", cmd.Name))
+		return "", fmt.Errorf("Unknown command: %s. Type HELP for list.", cmd.Name)
 	}
 
 	// 3. Eksekusi Handler
-	if err := cmdFn(ctx, syscore, cmd); err != nil {
-		return "", err
-	}
-
-	return "", nil
+	return c.handler(ctx, syscore, cmd)
 }
@@ -1,8 +1,66 @@
 package operation
 
+import "errors"
+
 type MessagePayload struct {
 	ID        string
 	FromPID   int
 	Payload   Command
 	Timestamp int64
+	Reply     *Reply // cuma diisi kalau Payload.Type == Response, ID sama dengan request
+}
+
+type ReplyStatus int
+
+const (
+	ReplyAck ReplyStatus = iota
+	ReplyError
+	ReplyResult
+)
+
+var replyNames = map[ReplyStatus]string{
+	ReplyAck:    "ACK",
+	ReplyError:  "ERROR",
+	ReplyResult: "RESULT",
+}
+
+func (s ReplyStatus) String() string {
+	if n, ok := replyNames[s]; ok {
+		return n
+	}
+
+	return replyNames[ReplyError]
+}
+
+// Reply is the worker answer to one request
+type Reply struct {
+	Status  ReplyStatus
+	Error   string
+	Payload []byte
+}
+
+// Err return the worker side error, nil kalau ack atau result
+func (r Reply) Err() error {
+	if r.Status != ReplyError {
+		return nil
+	}
+
+	if r.Error == "" {
+		return errors.New("worker replied with an error")
+	}
+
+	return errors.New(r.Error)
+}
+
+// NewReply build the reply message for req
+func NewReply(req MessagePayload, pid int, reply Reply, timestamp int64) MessagePayload {
+	return MessagePayload{
+		ID:      req.ID,
+		FromPID: pid,
+		Payload: Command{
+			Type: Response,
+		},
+		Timestamp: timestamp,
+		Reply:     &reply,
+	}
 }
@@ -0,0 +1,115 @@
+package operation
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// ErrReloadInProgress is returned when a rollout is asked while another one runs
+var ErrReloadInProgress = errors.New("a reload is already in progress")
+
+// ReloadPhase is where one worker is in a rolling reload
+type ReloadPhase string
+
+const (
+	PhaseSpawned  ReloadPhase = "spawned"  // process jalan, belum lolos readiness
+	PhaseReady    ReloadPhase = "ready"    // engine sudah serve listener yang diwariskan
+	PhaseDraining ReloadPhase = "draining" // generasi lama, session yang jalan dibiarkan selesai
+	PhaseRetired  ReloadPhase = "retired"
+	PhaseFailed   ReloadPhase = "failed"
+)
+
+// ReloadState is the outcome of a rolling reload
+type ReloadState string
+
+const (
+	ReloadRunning    ReloadState = "running"
+	ReloadCompleted  ReloadState = "completed"
+	ReloadRolledBack ReloadState = "rolled_back" // generasi baru dipensiunkan, generasi lama tetap serve
+	ReloadRejected   ReloadState = "rejected"    // config ditolak preflight, tidak ada worker yang disentuh
+)
+
+// ReloadMode is how the new generation takes over
+type ReloadMode string
+
+const (
+	ModeRolling ReloadMode = "rolling" // semua worker diganti sekaligus setelah lolos readiness
+	ModeCanary  ReloadMode = "canary"  // sebagian worker dulu, dipromosikan setelah bake
+)
+
+// CanaryStatus is the bake of a canary reload
+type CanaryStatus struct {
+	Workers    int              `json:"workers"`
+	Bake       time.Duration    `json:"bake_ns"`
+	Thresholds CanaryThresholds `json:"thresholds"`
+	Metrics    CanaryMetrics    `json:"metrics"`
+	Promoted   bool             `json:"promoted"`
+}
+
+// ReloadWorker is one worker taking part in a reload, baru maupun lama
+type ReloadWorker struct {
+	PID        int         `json:"pid"`
+	Generation int         `json:"generation"`
+	Phase      ReloadPhase `json:"phase"`
+	Error      string      `json:"error,omitempty"`
+	Since      time.Time   `json:"since"`
+}
+
+// ReloadStatus is the progress of the last rolling reload
+type ReloadStatus struct {
+	Generation int            `json:"generation"` // generasi yang di-rollout
+	Previous   int            `json:"previous"`   // generasi yang digantikan
+	ConfigHash string         `json:"config_hash"`
+	Author     string         `json:"author"`
+	Reason     string         `json:"reason"`
+	Mode       ReloadMode     `json:"mode"`
+	State      ReloadState    `json:"state"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Workers    []ReloadWorker `json:"workers"`
+	Canary     *CanaryStatus  `json:"canary,omitempty"` // nil kalau mode rolling
+}
+
+// SetPhase move pid to phase, worker yang belum tercatat ditambahkan
+func (s *ReloadStatus) SetPhase(pid, generation int, phase ReloadPhase, err error, now time.Time) {
+	w := ReloadWorker{PID: pid, Generation: generation, Phase: phase, Since: now}
+	if err != nil {
+		w.Error = err.Error()
+	}
+
+	if i := slices.IndexFunc(s.Workers, func(w ReloadWorker) bool { return w.PID == pid }); i >= 0 {
+		s.Workers[i] = w
+		return
+	}
+
+	s.Workers = append(s.Workers, w)
+}
+
+// Finish close the reload with its outcome
+func (s *ReloadStatus) Finish(state ReloadState, err error, now time.Time) {
+	s.State = state
+	s.FinishedAt = &now
+
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// Clone copy the status so the caller can read it without the lock
+func (s ReloadStatus) Clone() ReloadStatus {
+	s.Workers = slices.Clone(s.Workers)
+
+	if s.FinishedAt != nil {
+		at := *s.FinishedAt
+		s.FinishedAt = &at
+	}
+
+	if s.Canary != nil {
+		canary := *s.Canary
+		s.Canary = &canary
+	}
+
+	return s
+}
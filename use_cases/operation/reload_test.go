@@ -0,0 +1,38 @@
+package operation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadStatus(t *testing.T) {
+	now := time.Now()
+	status := ReloadStatus{Generation: 2, Previous: 1, State: ReloadRunning, StartedAt: now}
+
+	status.SetPhase(10, 2, PhaseSpawned, nil, now)
+	status.SetPhase(11, 2, PhaseSpawned, nil, now)
+	status.SetPhase(10, 2, PhaseReady, nil, now)
+	status.SetPhase(11, 2, PhaseFailed, errors.New("not ready"), now)
+
+	assert.Equal(t, []ReloadWorker{
+		{PID: 10, Generation: 2, Phase: PhaseReady, Since: now},
+		{PID: 11, Generation: 2, Phase: PhaseFailed, Error: "not ready", Since: now},
+	}, status.Workers)
+
+	view := status.Clone()
+	status.Finish(ReloadRolledBack, errors.New("worker 11 is not ready"), now)
+
+	assert.Equal(t, ReloadRolledBack, status.State)
+	assert.Equal(t, "worker 11 is not ready", status.Error)
+	assert.Equal(t, &now, status.FinishedAt)
+
+	// clone tidak ikut berubah
+	assert.Equal(t, ReloadRunning, view.State)
+	assert.Nil(t, view.FinishedAt)
+
+	status.Workers[0].Phase = PhaseRetired
+	assert.Equal(t, PhaseReady, view.Workers[0].Phase)
+}
@@ -0,0 +1,59 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	// ErrRevisionNotFound is returned when the asked revision number is not stored
+	ErrRevisionNotFound = errors.New("revision not found")
+	// ErrRevisionsDisabled is returned when [revisions] has no database
+	ErrRevisionsDisabled = errors.New("config revisions are not enabled")
+)
+
+// ConfigRevision is one applied proxy config, nomornya urut mulai dari 1
+type ConfigRevision struct {
+	Number     int       `json:"revision"`
+	Author     string    `json:"author"`
+	Reason     string    `json:"reason"`
+	Hash       string    `json:"hash"`
+	Generation int       `json:"generation"`
+	Config     string    `json:"config,omitempty"` // haproxy.cfg hasil render, kosong di list
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RevisionStore keep the applied configs, implementasinya di repositories
+type RevisionStore interface {
+	// Save store rev as the next revision number and return it filled in
+	Save(ctx context.Context, rev ConfigRevision) (ConfigRevision, error)
+	// List return every revision newest first, tanpa isi config
+	List(ctx context.Context) ([]ConfigRevision, error)
+	// Get return revision n, ErrRevisionNotFound kalau tidak ada
+	Get(ctx context.Context, n int) (ConfigRevision, error)
+	// Latest return the newest revision, ErrRevisionNotFound kalau belum ada sama sekali
+	Latest(ctx context.Context) (ConfigRevision, error)
+}
+
+// DiffRevisions return the unified diff from a to b, kosong kalau isinya sama
+func DiffRevisions(a, b ConfigRevision) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(a.Config),
+		B:        lines(b.Config),
+		FromFile: fmt.Sprintf("revision %d", a.Number),
+		ToFile:   fmt.Sprintf("revision %d", b.Number),
+		FromDate: a.CreatedAt.UTC().Format(time.RFC3339),
+		ToDate:   b.CreatedAt.UTC().Format(time.RFC3339),
+		Context:  3,
+	})
+}
+
+// lines split s for difflib, newline di akhir file tidak dianggap sebagai perubahan
+func lines(s string) []string {
+	return difflib.SplitLines(strings.TrimSuffix(s, "\n"))
+}
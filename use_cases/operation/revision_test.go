@@ -0,0 +1,33 @@
+package operation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	at := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+
+	a := ConfigRevision{Number: 3, CreatedAt: at, Config: "global\n    maxconn 2000\n\nbackend api\n    server s1 10.0.0.1:80\n"}
+	b := ConfigRevision{Number: 5, CreatedAt: at.Add(time.Hour), Config: "global\n    maxconn 4000\n\nbackend api\n    server s1 10.0.0.1:80"}
+
+	diff, err := DiffRevisions(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, `--- revision 3	2026-10-01T08:00:00Z
++++ revision 5	2026-10-01T09:00:00Z
+@@ -1,5 +1,5 @@
+ global
+-    maxconn 2000
++    maxconn 4000
+ 
+ backend api
+     server s1 10.0.0.1:80
+`, diff)
+
+	// newline di akhir file saja tidak dihitung
+	same, err := DiffRevisions(a, ConfigRevision{Number: 4, Config: "global\n    maxconn 2000\n\nbackend api\n    server s1 10.0.0.1:80"})
+	assert.NoError(t, err)
+	assert.Empty(t, same)
+}
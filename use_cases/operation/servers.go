@@ -0,0 +1,391 @@
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mox/pkg/haproxy"
+)
+
+// ErrInvalidServerChange is returned by Check when the change is rejected before anything is applied
+var ErrInvalidServerChange = errors.New("invalid server change")
+
+// ServerAction is one kind of runtime change to a backend server
+type ServerAction string
+
+const (
+	ServerSetWeight ServerAction = "weight"
+	ServerSetState  ServerAction = "state"
+	ServerAdd       ServerAction = "add"    // `add server`, server dinamis di luar [proxy]
+	ServerDel       ServerAction = "del"    // `del server`, server di-maint dulu baru dihapus
+	ServerSetHealth ServerAction = "health" // hasil health check master, up atau down
+)
+
+// ServerChange is the body of SERVER_UPDATE, satu perubahan untuk satu server
+type ServerChange struct {
+	Action  ServerAction `json:"action"`
+	Backend string       `json:"backend"`
+	Server  string       `json:"server"`
+	// Address host:port, cuma untuk add
+	Address string `json:"address,omitempty"`
+	// Weight 0-256 untuk weight, untuk add 0 = default 1
+	Weight int `json:"weight,omitempty"`
+	// State untuk state, untuk add kosong = ready
+	State haproxy.ServerState `json:"state,omitempty"`
+	// Health untuk health
+	Health haproxy.ServerHealth `json:"health,omitempty"`
+}
+
+func (c ServerChange) String() string {
+	s := fmt.Sprintf("%s %s/%s", c.Action, c.Backend, c.Server)
+
+	switch c.Action {
+	case ServerSetWeight:
+		s += fmt.Sprintf(" %d", c.Weight)
+	case ServerSetState:
+		s += " " + string(c.State)
+	case ServerAdd:
+		s += " " + c.Address
+	case ServerSetHealth:
+		s += " " + string(c.Health)
+	}
+
+	return s
+}
+
+// Validate check the change on its own, apakah backend/server-nya ada dicek master
+func (c ServerChange) Validate() error {
+	for _, name := range []string{c.Backend, c.Server} {
+		if name == "" || strings.ContainsAny(name, "/ \t\n;") {
+			return fmt.Errorf("invalid backend or server name %q", name)
+		}
+	}
+
+	validState := func(s haproxy.ServerState) bool {
+		return s == haproxy.StateReady || s == haproxy.StateDrain || s == haproxy.StateMaint
+	}
+
+	switch c.Action {
+	case ServerSetWeight:
+		if c.Weight < 0 || c.Weight > 256 {
+			return fmt.Errorf("weight %d must be between 0 and 256", c.Weight)
+		}
+	case ServerSetState:
+		if !validState(c.State) {
+			return fmt.Errorf("state %q must be ready, drain or maint", c.State)
+		}
+	case ServerAdd:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("address %q must be host:port", c.Address)
+		}
+
+		if c.Weight < 0 || c.Weight > 256 {
+			return fmt.Errorf("weight %d must be between 0 and 256", c.Weight)
+		}
+
+		if c.State != "" && !validState(c.State) {
+			return fmt.Errorf("state %q must be ready, drain or maint", c.State)
+		}
+	case ServerSetHealth:
+		if c.Health != haproxy.HealthUp && c.Health != haproxy.HealthDown {
+			return fmt.Errorf("health %q must be up or down", c.Health)
+		}
+	case ServerDel:
+	default:
+		return fmt.Errorf("unknown server action %q", c.Action)
+	}
+
+	return nil
+}
+
+// Server is one upstream server as the master wants every worker to run it
+type Server struct {
+	Name    string               `json:"name"`
+	Address string               `json:"address"`
+	Weight  int                  `json:"weight"`
+	State   haproxy.ServerState  `json:"state"`
+	Health  haproxy.ServerHealth `json:"health"`
+	Backup  bool                 `json:"backup,omitempty"`
+	// Dynamic true kalau ditambah lewat add, bukan dari [proxy]
+	Dynamic bool `json:"dynamic,omitempty"`
+}
+
+// Backend is one backend with its servers
+type Backend struct {
+	Name    string   `json:"name"`
+	Servers []Server `json:"servers"`
+}
+
+// ServerOverride is how one server differ from the [proxy] model
+type ServerOverride struct {
+	Backend string `json:"backend"`
+	Server  string `json:"server"`
+	// Deleted server dari model dihapus
+	Deleted bool `json:"deleted,omitempty"`
+	// Added server dinamis, kalau Deleted juga true berarti menggantikan server model
+	Added   bool                `json:"added,omitempty"`
+	Address string              `json:"address,omitempty"`
+	Weight  *int                `json:"weight,omitempty"`
+	State   haproxy.ServerState `json:"state,omitempty"`
+	// Health cuma dicatat kalau down, up = sama dengan worker yang baru start
+	Health haproxy.ServerHealth `json:"health,omitempty"`
+}
+
+// ServerOverrides is the server state file the master persist. Isinya sudah
+// dipadatkan: satu entry per server, bukan log semua perubahan.
+type ServerOverrides struct {
+	Servers []ServerOverride `json:"servers"`
+}
+
+// LoadServerOverrides read the state file, file yang belum ada = tanpa override
+func LoadServerOverrides(path string) (ServerOverrides, error) {
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ServerOverrides{}, nil
+	}
+	if err != nil {
+		return ServerOverrides{}, err
+	}
+
+	var o ServerOverrides
+	if err := json.Unmarshal(body, &o); err != nil {
+		return ServerOverrides{}, fmt.Errorf("server state %s: %w", path, err)
+	}
+
+	return o, nil
+}
+
+// Save write the state file atomically, worker yang lagi start tidak pernah baca file setengah jadi
+func (o ServerOverrides) Save(path string) error {
+	body, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (o *ServerOverrides) entry(backend, server string) *ServerOverride {
+	for i := range o.Servers {
+		if o.Servers[i].Backend == backend && o.Servers[i].Server == server {
+			return &o.Servers[i]
+		}
+	}
+
+	o.Servers = append(o.Servers, ServerOverride{Backend: backend, Server: server})
+
+	return &o.Servers[len(o.Servers)-1]
+}
+
+// Apply record c, c harus sudah dicek dengan Check
+func (o *ServerOverrides) Apply(c ServerChange) {
+	e := o.entry(c.Backend, c.Server)
+
+	switch c.Action {
+	case ServerSetWeight:
+		w := c.Weight
+		e.Weight = &w
+	case ServerSetState:
+		e.State = c.State
+	case ServerAdd:
+		w := c.Weight
+		if w == 0 {
+			w = 1
+		}
+
+		e.Added, e.Address, e.Weight, e.State, e.Health = true, c.Address, &w, c.State, ""
+	case ServerDel:
+		if e.Added && !e.Deleted {
+			// server dinamis murni, tidak ada bekasnya di model
+			o.remove(c.Backend, c.Server)
+			return
+		}
+
+		*e = ServerOverride{Backend: c.Backend, Server: c.Server, Deleted: true}
+	case ServerSetHealth:
+		e.Health = ""
+		if c.Health == haproxy.HealthDown {
+			e.Health = haproxy.HealthDown
+		}
+	}
+
+	// entry yang sudah sama dengan model tidak perlu disimpan
+	if *e == (ServerOverride{Backend: c.Backend, Server: c.Server}) {
+		o.remove(c.Backend, c.Server)
+	}
+}
+
+func (o *ServerOverrides) remove(backend, server string) {
+	servers := o.Servers[:0]
+	for _, e := range o.Servers {
+		if e.Backend != backend || e.Server != server {
+			servers = append(servers, e)
+		}
+	}
+
+	o.Servers = servers
+}
+
+// Changes return the changes that bring a worker started from the model to this state
+func (o ServerOverrides) Changes() []ServerChange {
+	changes := []ServerChange{}
+
+	for _, e := range o.Servers {
+		if e.Deleted {
+			changes = append(changes, ServerChange{Action: ServerDel, Backend: e.Backend, Server: e.Server})
+		}
+
+		if e.Added {
+			c := ServerChange{Action: ServerAdd, Backend: e.Backend, Server: e.Server, Address: e.Address, State: e.State}
+			if e.Weight != nil {
+				c.Weight = *e.Weight
+			}
+
+			changes = append(changes, c)
+		} else if !e.Deleted {
+			if e.Weight != nil {
+				changes = append(changes, ServerChange{Action: ServerSetWeight, Backend: e.Backend, Server: e.Server, Weight: *e.Weight})
+			}
+
+			if e.State != "" {
+				changes = append(changes, ServerChange{Action: ServerSetState, Backend: e.Backend, Server: e.Server, State: e.State})
+			}
+		}
+
+		if e.Health != "" && (e.Added || !e.Deleted) {
+			changes = append(changes, ServerChange{Action: ServerSetHealth, Backend: e.Backend, Server: e.Server, Health: e.Health})
+		}
+	}
+
+	return changes
+}
+
+// Backends return the servers of model with the overrides applied
+func (o ServerOverrides) Backends(model haproxy.Config) []Backend {
+	backends := make([]Backend, 0, len(model.Backends))
+
+	for _, b := range model.Backends {
+		be := Backend{Name: b.Name, Servers: []Server{}}
+
+		for _, s := range b.Servers {
+			srv := Server{Name: s.Name, Address: s.Address, Weight: s.Weight, State: haproxy.StateReady, Health: haproxy.HealthUp, Backup: s.Backup}
+			if srv.Weight == 0 {
+				srv.Weight = 1
+			}
+
+			if strings.Contains(" "+s.Options+" ", " disabled ") {
+				srv.State = haproxy.StateMaint
+			}
+
+			be.Servers = append(be.Servers, srv)
+		}
+
+		backends = append(backends, be)
+	}
+
+	for _, e := range o.Servers {
+		for i := range backends {
+			if backends[i].Name != e.Backend {
+				continue
+			}
+
+			backends[i].Servers = overrideServer(backends[i].Servers, e)
+		}
+	}
+
+	return backends
+}
+
+func overrideServer(servers []Server, e ServerOverride) []Server {
+	idx := -1
+	for i, s := range servers {
+		if s.Name == e.Server {
+			idx = i
+		}
+	}
+
+	if e.Deleted && idx >= 0 {
+		servers = append(servers[:idx], servers[idx+1:]...)
+		idx = -1
+	}
+
+	if e.Added {
+		servers = append(servers, Server{Name: e.Server, Address: e.Address, Weight: 1, State: haproxy.StateReady, Health: haproxy.HealthUp, Dynamic: true})
+		idx = len(servers) - 1
+	}
+
+	if idx < 0 {
+		return servers
+	}
+
+	if e.Weight != nil {
+		servers[idx].Weight = *e.Weight
+	}
+
+	if e.State != "" {
+		servers[idx].State = e.State
+	}
+
+	if e.Health != "" {
+		servers[idx].Health = e.Health
+	}
+
+	return servers
+}
+
+// Check make sure c make sense against model plus the current overrides
+func (o ServerOverrides) Check(model haproxy.Config, c ServerChange) error {
+	if err := o.check(model, c); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidServerChange, err)
+	}
+
+	return nil
+}
+
+func (o ServerOverrides) check(model haproxy.Config, c ServerChange) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	for _, b := range o.Backends(model) {
+		if b.Name != c.Backend {
+			continue
+		}
+
+		exists := false
+		for _, s := range b.Servers {
+			exists = exists || s.Name == c.Server
+		}
+
+		switch {
+		case c.Action == ServerAdd && exists:
+			return fmt.Errorf("server %s/%s already exists", c.Backend, c.Server)
+		case c.Action != ServerAdd && !exists:
+			return fmt.Errorf("server %s/%s does not exist", c.Backend, c.Server)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("backend %s does not exist", c.Backend)
+}
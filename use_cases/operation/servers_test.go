@@ -0,0 +1,138 @@
+package operation
+
+import (
+	"path/filepath"
+	"testing"
+
+	"mox/pkg/haproxy"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func poolModel() haproxy.Config {
+	return haproxy.Config{Backends: []haproxy.Backend{
+		{Name: "api", Servers: []haproxy.Server{
+			{Name: "a", Address: "10.0.0.1:80", Weight: 2},
+			{Name: "b", Address: "10.0.0.2:80", Options: "check disabled"},
+		}},
+		{Name: "versions"},
+	}}
+}
+
+func TestServerOverridesCheck(t *testing.T) {
+	o := ServerOverrides{}
+	o.Apply(ServerChange{Action: ServerAdd, Backend: "api", Server: "c", Address: "10.0.0.3:80"})
+
+	tests := []struct {
+		name   string
+		change ServerChange
+		err    string
+	}{
+		{name: "weight", change: ServerChange{Action: ServerSetWeight, Backend: "api", Server: "a", Weight: 0}},
+		{name: "state dynamic", change: ServerChange{Action: ServerSetState, Backend: "api", Server: "c", State: haproxy.StateDrain}},
+		{name: "add", change: ServerChange{Action: ServerAdd, Backend: "versions", Server: "v1", Address: "10.0.0.9:80"}},
+		{name: "del", change: ServerChange{Action: ServerDel, Backend: "api", Server: "b"}},
+		{name: "weight range", change: ServerChange{Action: ServerSetWeight, Backend: "api", Server: "a", Weight: 300}, err: "between 0 and 256"},
+		{name: "bad state", change: ServerChange{Action: ServerSetState, Backend: "api", Server: "a", State: "down"}, err: "ready, drain or maint"},
+		{name: "bad address", change: ServerChange{Action: ServerAdd, Backend: "api", Server: "d", Address: "10.0.0.4"}, err: "host:port"},
+		{name: "bad name", change: ServerChange{Action: ServerDel, Backend: "api", Server: "a b"}, err: "invalid backend or server name"},
+		{name: "unknown action", change: ServerChange{Action: "restart", Backend: "api", Server: "a"}, err: "unknown server action"},
+		{name: "duplicate", change: ServerChange{Action: ServerAdd, Backend: "api", Server: "c", Address: "10.0.0.3:80"}, err: "already exists"},
+		{name: "missing server", change: ServerChange{Action: ServerSetWeight, Backend: "api", Server: "z", Weight: 1}, err: "does not exist"},
+		{name: "missing backend", change: ServerChange{Action: ServerDel, Backend: "web", Server: "a"}, err: "backend web does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := o.Check(poolModel(), tt.change)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrInvalidServerChange)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestServerOverridesApply(t *testing.T) {
+	o := ServerOverrides{}
+
+	for _, c := range []ServerChange{
+		{Action: ServerSetWeight, Backend: "api", Server: "a", Weight: 5},
+		{Action: ServerSetState, Backend: "api", Server: "a", State: haproxy.StateDrain},
+		{Action: ServerAdd, Backend: "api", Server: "c", Address: "10.0.0.3:80"},
+		{Action: ServerSetWeight, Backend: "api", Server: "c", Weight: 7},
+		{Action: ServerAdd, Backend: "api", Server: "d", Address: "10.0.0.4:80"},
+		{Action: ServerDel, Backend: "api", Server: "d"},
+		// server model dihapus lalu diganti server dinamis dengan nama sama
+		{Action: ServerDel, Backend: "api", Server: "b"},
+		{Action: ServerAdd, Backend: "api", Server: "b", Address: "10.0.0.5:80", State: haproxy.StateMaint},
+	} {
+		assert.NoError(t, o.Check(poolModel(), c), c.String())
+		o.Apply(c)
+	}
+
+	assert.Equal(t, []ServerChange{
+		{Action: ServerSetWeight, Backend: "api", Server: "a", Weight: 5},
+		{Action: ServerSetState, Backend: "api", Server: "a", State: haproxy.StateDrain},
+		{Action: ServerAdd, Backend: "api", Server: "c", Address: "10.0.0.3:80", Weight: 7},
+		{Action: ServerDel, Backend: "api", Server: "b"},
+		{Action: ServerAdd, Backend: "api", Server: "b", Address: "10.0.0.5:80", Weight: 1, State: haproxy.StateMaint},
+	}, o.Changes())
+
+	assert.Equal(t, []Backend{
+		{Name: "api", Servers: []Server{
+			{Name: "a", Address: "10.0.0.1:80", Weight: 5, State: haproxy.StateDrain, Health: haproxy.HealthUp},
+			{Name: "c", Address: "10.0.0.3:80", Weight: 7, State: haproxy.StateReady, Health: haproxy.HealthUp, Dynamic: true},
+			{Name: "b", Address: "10.0.0.5:80", Weight: 1, State: haproxy.StateMaint, Health: haproxy.HealthUp, Dynamic: true},
+		}},
+		{Name: "versions", Servers: []Server{}},
+	}, o.Backends(poolModel()))
+
+	// hapus server pengganti, yang tersisa cuma catatan server model dihapus
+	o.Apply(ServerChange{Action: ServerDel, Backend: "api", Server: "b"})
+	assert.Contains(t, o.Changes(), ServerChange{Action: ServerDel, Backend: "api", Server: "b"})
+	assert.NotContains(t, o.Changes(), ServerChange{Action: ServerAdd, Backend: "api", Server: "b", Address: "10.0.0.5:80", Weight: 1, State: haproxy.StateMaint})
+}
+
+func TestServerOverridesHealth(t *testing.T) {
+	o := ServerOverrides{}
+
+	down := ServerChange{Action: ServerSetHealth, Backend: "api", Server: "a", Health: haproxy.HealthDown}
+	assert.NoError(t, o.Check(poolModel(), down))
+	o.Apply(down)
+
+	assert.Equal(t, []ServerChange{down}, o.Changes())
+	assert.Equal(t, haproxy.HealthDown, o.Backends(poolModel())[0].Servers[0].Health)
+
+	// up = sama dengan model, entry-nya dibuang
+	o.Apply(ServerChange{Action: ServerSetHealth, Backend: "api", Server: "a", Health: haproxy.HealthUp})
+	assert.Empty(t, o.Servers)
+
+	// server yang dihapus tidak perlu dikirim health-nya
+	o.Apply(down)
+	o.Apply(ServerChange{Action: ServerDel, Backend: "api", Server: "a"})
+	assert.Equal(t, []ServerChange{{Action: ServerDel, Backend: "api", Server: "a"}}, o.Changes())
+
+	err := o.Check(poolModel(), ServerChange{Action: ServerSetHealth, Backend: "api", Server: "b", Health: "sick"})
+	assert.ErrorContains(t, err, "must be up or down")
+}
+
+func TestServerOverridesSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.json")
+
+	empty, err := LoadServerOverrides(path)
+	assert.NoError(t, err)
+	assert.Empty(t, empty.Changes())
+
+	o := ServerOverrides{}
+	o.Apply(ServerChange{Action: ServerSetWeight, Backend: "api", Server: "a", Weight: 0})
+	assert.NoError(t, o.Save(path))
+
+	loaded, err := LoadServerOverrides(path)
+	assert.NoError(t, err)
+	assert.Equal(t, o.Changes(), loaded.Changes())
+	assert.Equal(t, 0, loaded.Backends(poolModel())[0].Servers[0].Weight)
+}
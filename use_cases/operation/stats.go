@@ -0,0 +1,140 @@
+package operation
+
+import (
+	"time"
+
+	"mox/pkg/haproxy"
+	"mox/pkg/procstat"
+)
+
+// HAProxyCounters is a subset of `show info` from the HAProxy stats socket
+type HAProxyCounters struct {
+	CurrConns    int64 `json:"curr_conns"`
+	CumConns     int64 `json:"cum_conns"`
+	CumReq       int64 `json:"cum_req"`
+	ConnRate     int64 `json:"conn_rate"`
+	MaxConn      int64 `json:"max_conn"`
+	SessRate     int64 `json:"sess_rate"`
+	Uptime       int64 `json:"uptime_sec"`
+	IdlePercent  int64 `json:"idle_pct"`
+	CurrSslConns int64 `json:"curr_ssl_conns"`
+}
+
+// WorkerStats is the body of an EVENT_STATS message, dikirim worker tiap interval
+type WorkerStats struct {
+	Worker    procstat.Process  `json:"worker"`
+	HAProxy   *procstat.Process `json:"haproxy,omitempty"`  // nil kalau haproxy belum jalan
+	Counters  *HAProxyCounters  `json:"counters,omitempty"` // nil kalau stats socket tidak bisa dibaca
+	Proxies   []haproxy.Stat    `json:"proxies,omitempty"`  // `show stat`, kosong kalau engine belum jalan
+	SampledAt time.Time         `json:"sampled_at"`
+}
+
+// StatsTotal is the sum over every worker that reported
+type StatsTotal struct {
+	Workers   int           `json:"workers"`
+	CPUTime   time.Duration `json:"cpu_time_ns"`
+	RSS       uint64        `json:"rss_bytes"`
+	OpenFDs   int           `json:"open_fds"`
+	Threads   int           `json:"threads"`
+	CurrConns int64         `json:"curr_conns"`
+	CumConns  int64         `json:"cum_conns"`
+	CumReq    int64         `json:"cum_req"`
+}
+
+// StatsReport is the aggregated view kept by the master
+type StatsReport struct {
+	Workers []WorkerStats `json:"workers"`
+	Total   StatsTotal    `json:"total"`
+}
+
+// Add count one worker sample (worker + haproxy child) into the total
+func (t *StatsTotal) Add(s WorkerStats) {
+	t.Workers++
+
+	for _, p := range []*procstat.Process{&s.Worker, s.HAProxy} {
+		if p == nil {
+			continue
+		}
+
+		t.CPUTime += p.CPUTime
+		t.RSS += p.RSS
+		t.OpenFDs += p.OpenFDs
+		t.Threads += p.Threads
+	}
+
+	if s.Counters != nil {
+		t.CurrConns += s.Counters.CurrConns
+		t.CumConns += s.Counters.CumConns
+		t.CumReq += s.Counters.CumReq
+	}
+}
+
+// ProxyStats is `show info` and `show stat` merged over every worker, plus
+// the per worker rows the merge was built from
+type ProxyStats struct {
+	Info    HAProxyCounters    `json:"info"`
+	Proxies []haproxy.Stat     `json:"proxies"`
+	Workers []WorkerProxyStats `json:"workers"`
+}
+
+// WorkerProxyStats is the `show info`/`show stat` of one worker
+type WorkerProxyStats struct {
+	PID       int             `json:"pid"`
+	Info      HAProxyCounters `json:"info"`
+	Proxies   []haproxy.Stat  `json:"proxies"`
+	SampledAt time.Time       `json:"sampled_at"`
+}
+
+// Worker return the breakdown of pid
+func (p ProxyStats) Worker(pid int) (WorkerProxyStats, bool) {
+	for _, w := range p.Workers {
+		if w.PID == pid {
+			return w, true
+		}
+	}
+
+	return WorkerProxyStats{}, false
+}
+
+// ProxyStats merge the engine stats of every worker that reported them.
+// Counter dan rate dijumlah, uptime diambil yang terlama dan idle dirata-rata.
+func (r StatsReport) ProxyStats() ProxyStats {
+	stats := ProxyStats{Proxies: []haproxy.Stat{}, Workers: []WorkerProxyStats{}}
+
+	samples := make([][]haproxy.Stat, 0, len(r.Workers))
+	for _, w := range r.Workers {
+		if w.Counters == nil {
+			continue
+		}
+
+		stats.Workers = append(stats.Workers, WorkerProxyStats{
+			PID:       w.Worker.PID,
+			Info:      *w.Counters,
+			Proxies:   w.Proxies,
+			SampledAt: w.SampledAt,
+		})
+		samples = append(samples, w.Proxies)
+		stats.Info.add(*w.Counters)
+	}
+
+	if n := int64(len(stats.Workers)); n > 0 {
+		stats.Info.IdlePercent /= n
+	}
+
+	stats.Proxies = haproxy.MergeStats(samples...)
+
+	return stats
+}
+
+// add sum c into t, IdlePercent dijumlah dulu lalu dibagi pemanggil
+func (t *HAProxyCounters) add(c HAProxyCounters) {
+	t.CurrConns += c.CurrConns
+	t.CumConns += c.CumConns
+	t.CumReq += c.CumReq
+	t.ConnRate += c.ConnRate
+	t.MaxConn += c.MaxConn
+	t.SessRate += c.SessRate
+	t.CurrSslConns += c.CurrSslConns
+	t.IdlePercent += c.IdlePercent
+	t.Uptime = max(t.Uptime, c.Uptime)
+}
@@ -0,0 +1,43 @@
+package operation
+
+import (
+	"testing"
+
+	"mox/pkg/haproxy"
+	"mox/pkg/procstat"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsReportProxyStats(t *testing.T) {
+	report := StatsReport{Workers: []WorkerStats{
+		{
+			Worker:   procstat.Process{PID: 10},
+			Counters: &HAProxyCounters{CurrConns: 3, CumReq: 100, MaxConn: 2000, Uptime: 60, IdlePercent: 90},
+			Proxies:  []haproxy.Stat{{ProxyName: "gateway", ServiceName: "FRONTEND", SCur: 3, ReqTot: 100}},
+		},
+		{
+			Worker:   procstat.Process{PID: 11},
+			Counters: &HAProxyCounters{CurrConns: 1, CumReq: 20, MaxConn: 2000, Uptime: 5, IdlePercent: 70},
+			Proxies:  []haproxy.Stat{{ProxyName: "gateway", ServiceName: "FRONTEND", SCur: 1, ReqTot: 20}},
+		},
+		// engine belum jalan, tidak ikut digabung
+		{Worker: procstat.Process{PID: 12}},
+	}}
+
+	stats := report.ProxyStats()
+
+	assert.Equal(t, HAProxyCounters{CurrConns: 4, CumReq: 120, MaxConn: 4000, Uptime: 60, IdlePercent: 80}, stats.Info)
+	assert.Equal(t, []haproxy.Stat{{ProxyName: "gateway", ServiceName: "FRONTEND", SCur: 4, ReqTot: 120}}, stats.Proxies)
+	assert.Len(t, stats.Workers, 2)
+
+	w, ok := stats.Worker(11)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), w.Proxies[0].SCur)
+
+	_, ok = stats.Worker(12)
+	assert.False(t, ok)
+
+	empty := StatsReport{}.ProxyStats()
+	assert.Equal(t, []haproxy.Stat{}, empty.Proxies)
+}
@@ -0,0 +1,14 @@
+package operation
+
+import "time"
+
+// WorkerInfo is one worker connected to the master, dipakai WORKERS
+type WorkerInfo struct {
+	PID        int           `json:"pid"`
+	Generation int           `json:"generation"`
+	ConfigHash string        `json:"config_hash"`
+	State      string        `json:"state"`
+	Retiring   bool          `json:"retiring"` // sedang di-drain/dipensiunkan master
+	LastSeen   time.Time     `json:"last_seen"`
+	RTT        time.Duration `json:"rtt_ns"`
+}
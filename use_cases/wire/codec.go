@@ -0,0 +1,72 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"mox/use_cases/operation"
+)
+
+// Codec id of a frame body
+type Codec uint8
+
+const (
+	CodecJSON Codec = iota + 1
+	CodecProto
+)
+
+var codecNames = map[Codec]string{
+	CodecJSON:  "json",
+	CodecProto: "protobuf",
+}
+
+func (c Codec) String() string {
+	if s, ok := codecNames[c]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("codec(%d)", uint8(c))
+}
+
+// ParseCodec map the config name back to the codec id
+func ParseCodec(name string) (Codec, error) {
+	for c, s := range codecNames {
+		if s == name {
+			return c, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+}
+
+// Codecs supported by this build, urutan = preferensi
+var Codecs = []Codec{CodecProto, CodecJSON}
+
+func supported(c Codec) bool {
+	_, ok := codecNames[c]
+	return ok
+}
+
+// Marshal encode a bus message with the given codec
+func Marshal(c Codec, msg operation.MessagePayload) ([]byte, error) {
+	switch c {
+	case CodecJSON:
+		return json.Marshal(msg)
+	case CodecProto:
+		return marshalProto(msg)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, c)
+	}
+}
+
+// Unmarshal decode a bus message with the given codec
+func Unmarshal(c Codec, b []byte, msg *operation.MessagePayload) error {
+	switch c {
+	case CodecJSON:
+		return json.Unmarshal(b, msg)
+	case CodecProto:
+		return unmarshalProto(b, msg)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownCodec, c)
+	}
+}
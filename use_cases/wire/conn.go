@@ -0,0 +1,62 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"mox/use_cases/operation"
+)
+
+// Protocol is the negotiated version and codec of one bus connection
+type Protocol struct {
+	Version uint8
+	Codec   Codec
+}
+
+// Default is used for connections without a handshake
+var Default = Protocol{Version: Version, Codec: CodecJSON}
+
+// Encode frame one message
+func (p Protocol) Encode(msg operation.MessagePayload) ([]byte, error) {
+	body, err := Marshal(p.Codec, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return Frame{Version: p.Version, Codec: p.Codec, Type: msg.Payload.Type, Body: body}.Bytes()
+}
+
+// Write frame one message and write it to w
+func (p Protocol) Write(w io.Writer, msg operation.MessagePayload) (int, error) {
+	b, err := p.Encode(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(b)
+}
+
+// Decode check a frame against the negotiated version and decode its body
+func (p Protocol) Decode(f Frame) (operation.MessagePayload, error) {
+	var msg operation.MessagePayload
+
+	if f.Version != p.Version {
+		return msg, fmt.Errorf("%w: got v%d, negotiated v%d", ErrVersionMismatch, f.Version, p.Version)
+	}
+
+	if err := Unmarshal(f.Codec, f.Body, &msg); err != nil {
+		return msg, fmt.Errorf("cannot decode %s frame: %w", f.Type, err)
+	}
+
+	return msg, nil
+}
+
+// Read read and decode the next message from r
+func (p Protocol) Read(r io.Reader) (operation.MessagePayload, error) {
+	f, err := ReadFrame(r)
+	if err != nil {
+		return operation.MessagePayload{}, err
+	}
+
+	return p.Decode(f)
+}
@@ -0,0 +1,92 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"mox/use_cases/operation"
+)
+
+// Frame layout di socket bus, semua angka big-endian:
+//
+//	| length u32 | version u8 | codec u8 | type u16 | body ... |
+//
+// length menghitung header setelah length itu sendiri plus body.
+const (
+	headerSize   = 4
+	lengthSize   = 4
+	MaxFrameSize = 4 << 20
+)
+
+var (
+	ErrFrameTooLarge   = errors.New("frame too large")
+	ErrVersionMismatch = errors.New("protocol version mismatch")
+	ErrUnknownCodec    = errors.New("unknown codec")
+	ErrRejected        = errors.New("rejected by peer")
+)
+
+type Frame struct {
+	Version uint8
+	Codec   Codec
+	Type    operation.MsgType
+	Body    []byte
+}
+
+// Bytes encode the frame with its length prefix
+func (f Frame) Bytes() ([]byte, error) {
+	size := headerSize + len(f.Body)
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	b := make([]byte, lengthSize+size)
+	binary.BigEndian.PutUint32(b[0:4], uint32(size))
+	b[4] = f.Version
+	b[5] = uint8(f.Codec)
+	binary.BigEndian.PutUint16(b[6:8], uint16(f.Type))
+	copy(b[8:], f.Body)
+
+	return b, nil
+}
+
+// WriteFrame write one frame in a single Write call
+func WriteFrame(w io.Writer, f Frame) (int, error) {
+	b, err := f.Bytes()
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(b)
+}
+
+// ReadFrame read exactly one frame from r
+func ReadFrame(r io.Reader) (Frame, error) {
+	var length [lengthSize]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return Frame{}, err
+	}
+
+	size := binary.BigEndian.Uint32(length[:])
+	if size < headerSize {
+		return Frame{}, fmt.Errorf("frame too short: %d bytes", size)
+	}
+
+	// peer lama yang masih kirim teks polos bakal jatuh di sini, bukan OOM
+	if size > MaxFrameSize {
+		return Frame{}, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return Frame{}, fmt.Errorf("truncated frame: %w", err)
+	}
+
+	return Frame{
+		Version: b[0],
+		Codec:   Codec(b[1]),
+		Type:    operation.MsgType(binary.BigEndian.Uint16(b[2:4])),
+		Body:    b[headerSize:],
+	}, nil
+}
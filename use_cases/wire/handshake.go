@@ -0,0 +1,130 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"mox/use_cases/operation"
+)
+
+// Protocol version range spoken by this build. Naikkan Version kalau
+// format body berubah, naikkan MinVersion kalau versi lama sudah tidak dilayani.
+const (
+	Version    uint8 = 1
+	MinVersion uint8 = 1
+)
+
+// Hello is the handshake body, always JSON encoded because the codec
+// belum disepakati waktu hello dikirim.
+type Hello struct {
+	PID        int     `json:"pid,omitempty"`
+	MinVersion uint8   `json:"min_version"`
+	MaxVersion uint8   `json:"max_version"`
+	Codecs     []Codec `json:"codecs,omitempty"`
+
+	// diisi master: nama listener sesuai urutan FD di SCM_RIGHTS
+	Listeners []string `json:"listeners,omitempty"`
+
+	// diisi master: hasil negosiasi
+	Version uint8 `json:"version,omitempty"`
+	Codec   Codec `json:"codec,omitempty"`
+
+	// diisi worker: generasi dan hash config yang diberikan master waktu spawn
+	Generation int    `json:"generation,omitempty"`
+	ConfigHash string `json:"config_hash,omitempty"`
+
+	// diisi kalau handshake ditolak
+	Reason string `json:"reason,omitempty"`
+}
+
+// Offer is the worker hello, preferred ditaruh paling depan
+func Offer(preferred Codec) Hello {
+	codecs := []Codec{preferred}
+	for _, c := range Codecs {
+		if c != preferred {
+			codecs = append(codecs, c)
+		}
+	}
+
+	return Hello{MinVersion: MinVersion, MaxVersion: Version, Codecs: codecs}
+}
+
+// Negotiate pick the highest common version and the first offered codec we support
+func Negotiate(offer Hello, pid int) (Hello, error) {
+	version := min(offer.MaxVersion, Version)
+	if version < max(offer.MinVersion, MinVersion) {
+		return Hello{}, fmt.Errorf("%w: peer speaks v%d..v%d, we speak v%d..v%d",
+			ErrVersionMismatch, offer.MinVersion, offer.MaxVersion, MinVersion, Version)
+	}
+
+	for _, c := range offer.Codecs {
+		if supported(c) {
+			return Hello{PID: pid, MinVersion: MinVersion, MaxVersion: Version, Version: version, Codec: c}, nil
+		}
+	}
+
+	return Hello{}, fmt.Errorf("%w: none of %v", ErrUnknownCodec, offer.Codecs)
+}
+
+// Choose is the master answer to a worker offer. Codec dari config dipakai
+// kalau worker bisa, selain itu codec pertama yang ditawarkan worker.
+func Choose(offer Hello, preferred Codec) (Hello, error) {
+	if slices.Contains(offer.Codecs, preferred) {
+		offer.Codecs = append([]Codec{preferred}, offer.Codecs...)
+	}
+
+	return Negotiate(offer, 0)
+}
+
+// Verify check the version and codec chosen by the peer against what this side can speak
+func Verify(reply Hello) error {
+	if reply.Version < MinVersion || reply.Version > Version {
+		return fmt.Errorf("%w: peer chose v%d, we speak v%d..v%d", ErrVersionMismatch, reply.Version, MinVersion, Version)
+	}
+
+	if !supported(reply.Codec) {
+		return fmt.Errorf("%w: %s", ErrUnknownCodec, reply.Codec)
+	}
+
+	return nil
+}
+
+// HelloFrame wrap a hello (or reject) into a frame
+func HelloFrame(t operation.MsgType, h Hello) (Frame, error) {
+	body, err := json.Marshal(h)
+	if err != nil {
+		return Frame{}, err
+	}
+
+	return Frame{Version: Version, Codec: CodecJSON, Type: t, Body: body}, nil
+}
+
+// RejectFrame tell the peer why the handshake failed before closing
+func RejectFrame(reason error) (Frame, error) {
+	return HelloFrame(operation.Reject, Hello{MinVersion: MinVersion, MaxVersion: Version, Reason: reason.Error()})
+}
+
+// ParseHello decode a handshake frame. Frame handshake sengaja tidak dicek
+// versinya, justru isinya yang dipakai buat negosiasi.
+func ParseHello(f Frame) (Hello, error) {
+	var h Hello
+
+	if f.Codec != CodecJSON {
+		return h, fmt.Errorf("%w: handshake must be json, got %s", ErrUnknownCodec, f.Codec)
+	}
+
+	if err := json.Unmarshal(f.Body, &h); err != nil {
+		return h, fmt.Errorf("invalid handshake body: %w", err)
+	}
+
+	if f.Type == operation.Reject {
+		return h, fmt.Errorf("%w: %s", ErrRejected, h.Reason)
+	}
+
+	if f.Type != operation.Hello {
+		return h, fmt.Errorf("expected %s frame, got %s", operation.Hello, f.Type)
+	}
+
+	return h, nil
+}
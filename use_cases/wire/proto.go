@@ -0,0 +1,73 @@
+package wire
+
+import (
+	"mox/use_cases/operation"
+	"mox/use_cases/wire/dto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func toProto(msg operation.MessagePayload) *dto.MessagePayload {
+	m := &dto.MessagePayload{
+		Id:      msg.ID,
+		FromPid: int64(msg.FromPID),
+		Payload: &dto.Command{
+			Name:        msg.Payload.Name,
+			Description: msg.Payload.Description,
+			Usage:       msg.Payload.Usage,
+			Type:        int32(msg.Payload.Type),
+			Payload:     msg.Payload.Payload,
+		},
+		Timestamp: msg.Timestamp,
+	}
+
+	// tetap dikirim walau kosong, biar ack tanpa isi tidak hilang
+	if msg.Reply != nil {
+		m.Reply = &dto.Reply{
+			Status:  int32(msg.Reply.Status),
+			Error:   msg.Reply.Error,
+			Payload: msg.Reply.Payload,
+		}
+	}
+
+	return m
+}
+
+func fromProto(m *dto.MessagePayload, msg *operation.MessagePayload) {
+	msg.ID = m.GetId()
+	msg.FromPID = int(m.GetFromPid())
+	msg.Timestamp = m.GetTimestamp()
+
+	if cmd := m.GetPayload(); cmd != nil {
+		msg.Payload = operation.Command{
+			Name:        cmd.GetName(),
+			Description: cmd.GetDescription(),
+			Usage:       cmd.GetUsage(),
+			Type:        operation.MsgType(cmd.GetType()),
+			Payload:     cmd.GetPayload(),
+		}
+	}
+
+	if r := m.GetReply(); r != nil {
+		msg.Reply = &operation.Reply{
+			Status:  operation.ReplyStatus(r.GetStatus()),
+			Error:   r.GetError(),
+			Payload: r.GetPayload(),
+		}
+	}
+}
+
+func marshalProto(msg operation.MessagePayload) ([]byte, error) {
+	return proto.Marshal(toProto(msg))
+}
+
+func unmarshalProto(b []byte, msg *operation.MessagePayload) error {
+	var m dto.MessagePayload
+	if err := proto.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	fromProto(&m, msg)
+
+	return nil
+}
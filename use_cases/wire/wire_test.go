@@ -0,0 +1,167 @@
+package wire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	in := Frame{Version: Version, Codec: CodecProto, Type: operation.Drain, Body: []byte("hello")}
+	_, err := WriteFrame(&buf, in)
+	assert.NoError(t, err)
+
+	// dua frame berurutan harus kebaca terpisah
+	_, err = WriteFrame(&buf, Frame{Version: Version, Codec: CodecJSON, Type: operation.Ping})
+	assert.NoError(t, err)
+
+	out, err := ReadFrame(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	out, err = ReadFrame(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, operation.Ping, out.Type)
+	assert.Empty(t, out.Body)
+}
+
+func TestReadFrameRejectsGarbage(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"legacy pid line", "12345\n"},
+		{"legacy die", "DIE"},
+		{"legacy ping", "PING\n"},
+		{"too short", "\x00\x00\x00\x01x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFrame(strings.NewReader(tt.input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	request := operation.MessagePayload{
+		ID:      "abc",
+		FromPID: -1,
+		Payload: operation.Command{
+			Name:        "drain",
+			Description: "drain one worker",
+			Usage:       "DRAIN <pid>",
+			Type:        operation.Drain,
+			Payload:     []byte("set maxconn frontend mygateway 100"),
+		},
+		Timestamp: 1700000000000,
+	}
+
+	messages := map[string]operation.MessagePayload{
+		"request": request,
+		"ack":     operation.NewReply(request, 42, operation.Reply{Status: operation.ReplyAck}, 1700000000001),
+		"error":   operation.NewReply(request, 42, operation.Reply{Status: operation.ReplyError, Error: "boom"}, 1700000000002),
+		"result":  operation.NewReply(request, 42, operation.Reply{Status: operation.ReplyResult, Payload: []byte("{}")}, 1700000000003),
+	}
+
+	for _, codec := range Codecs {
+		for name, msg := range messages {
+			t.Run(codec.String()+"/"+name, func(t *testing.T) {
+				p := Protocol{Version: Version, Codec: codec}
+
+				var buf bytes.Buffer
+				_, err := p.Write(&buf, msg)
+				assert.NoError(t, err)
+
+				out, err := p.Read(&buf)
+				assert.NoError(t, err)
+				assert.Equal(t, msg, out)
+			})
+		}
+	}
+}
+
+func TestDecodeVersionMismatch(t *testing.T) {
+	b, err := Protocol{Version: Version + 1, Codec: CodecJSON}.Encode(operation.MessagePayload{})
+	assert.NoError(t, err)
+
+	_, err = Default.Read(bytes.NewReader(b))
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name    string
+		offer   Hello
+		version uint8
+		codec   Codec
+		err     error
+	}{
+		{"same version", Offer(CodecProto), Version, CodecProto, nil},
+		{"newer master", Hello{MinVersion: MinVersion, MaxVersion: Version + 3, Codecs: []Codec{CodecJSON}}, Version, CodecJSON, nil},
+		{"master too new", Hello{MinVersion: Version + 1, MaxVersion: Version + 2, Codecs: Codecs}, 0, 0, ErrVersionMismatch},
+		{"unknown codec skipped", Hello{MinVersion: MinVersion, MaxVersion: Version, Codecs: []Codec{99, CodecJSON}}, Version, CodecJSON, nil},
+		{"no common codec", Hello{MinVersion: MinVersion, MaxVersion: Version, Codecs: []Codec{99}}, 0, 0, ErrUnknownCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := Negotiate(tt.offer, 42)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.version, reply.Version)
+			assert.Equal(t, tt.codec, reply.Codec)
+			assert.Equal(t, 42, reply.PID)
+			assert.NoError(t, Verify(reply))
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	tests := []struct {
+		name      string
+		offer     Hello
+		preferred Codec
+		codec     Codec
+		err       error
+	}{
+		{"preferred offered", Offer(CodecProto), CodecJSON, CodecJSON, nil},
+		{"preferred not offered", Hello{MinVersion: MinVersion, MaxVersion: Version, Codecs: []Codec{CodecJSON}}, CodecProto, CodecJSON, nil},
+		{"worker too new", Hello{MinVersion: Version + 1, MaxVersion: Version + 2, Codecs: Codecs}, CodecJSON, 0, ErrVersionMismatch},
+		{"no common codec", Hello{MinVersion: MinVersion, MaxVersion: Version, Codecs: []Codec{99}}, CodecJSON, 0, ErrUnknownCodec},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hello, err := Choose(tt.offer, tt.preferred)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.codec, hello.Codec)
+			assert.NoError(t, Verify(hello))
+		})
+	}
+}
+
+func TestRejectFrame(t *testing.T) {
+	f, err := RejectFrame(ErrVersionMismatch)
+	assert.NoError(t, err)
+
+	_, err = ParseHello(f)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.Contains(t, err.Error(), ErrVersionMismatch.Error())
+}
@@ -0,0 +1,119 @@
+package workerclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"mox/tools/utils"
+	"mox/use_cases/operation"
+)
+
+// ErrHeartbeatLost is returned when a worker missed more beats than allowed
+var ErrHeartbeatLost = errors.New("worker heartbeat lost")
+
+type heartbeat struct {
+	seq      uint64
+	sentAt   time.Time // waktu PING terakhir dikirim
+	waiting  bool      // PING terakhir belum dibalas PONG
+	missed   int
+	lastSeen time.Time
+	rtt      time.Duration
+}
+
+// Heartbeat implements [WorkerProcess]. Setiap PING yang belum dibalas sampai
+// beat berikutnya dihitung missed. Pas missed == threshold worker jadi Error,
+// lewat dari itu jadi Disconnected dan request yang nunggu langsung digagalkan.
+func (w *WorkerClient) Heartbeat(ctx context.Context, threshold int) (int, error) {
+	w.hbMu.Lock()
+	if w.hb.waiting {
+		w.hb.missed++
+	}
+	missed := w.hb.missed
+	w.hbMu.Unlock()
+
+	switch {
+	case missed > threshold:
+		w.setStatus(Disconnected)
+		w.failPending()
+
+		return missed, fmt.Errorf("%w: pid %d missed %d beats", ErrHeartbeatLost, w.pid, missed)
+	case missed == threshold:
+		// worker yang sudah Disconnected tidak balik jadi Error
+		w.status.CompareAndSwap(int32(Connected), int32(Error))
+	}
+
+	return missed, w.ping(ctx)
+}
+
+func (w *WorkerClient) ping(ctx context.Context) error {
+	w.hbMu.Lock()
+	w.hb.seq++
+	seq := w.hb.seq
+	w.hb.sentAt = time.Now()
+	w.hb.waiting = true
+	w.hbMu.Unlock()
+
+	_, err := w.Send(ctx, operation.MessagePayload{
+		ID:      utils.GenerateUUID(),
+		FromPID: -1,
+		Payload: operation.Command{
+			Type:    operation.Ping,
+			Payload: []byte(strconv.FormatUint(seq, 10)),
+		},
+		Timestamp: time.Now().UnixMilli(),
+	})
+
+	return err
+}
+
+// pong record the worker answer, cuma PONG untuk PING terakhir yang reset missed
+func (w *WorkerClient) pong(msg operation.MessagePayload) {
+	seq, err := strconv.ParseUint(string(msg.Payload.Payload), 10, 64)
+	if err != nil {
+		w.app.Logger().Warn("invalid pong sequence", slog.Int("pid", w.pid), slog.String("err", err.Error()))
+		return
+	}
+
+	w.hbMu.Lock()
+	defer w.hbMu.Unlock()
+
+	if seq != w.hb.seq || !w.hb.waiting {
+		return
+	}
+
+	w.hb.rtt = time.Since(w.hb.sentAt)
+	w.hb.waiting = false
+	w.hb.missed = 0
+
+	if w.status.CompareAndSwap(int32(Error), int32(Connected)) {
+		w.app.Logger().Info("worker heartbeat recovered", slog.Int("pid", w.pid), slog.Duration("rtt", w.hb.rtt))
+	}
+}
+
+// seen is called for every frame read from the worker
+func (w *WorkerClient) seen() {
+	w.hbMu.Lock()
+	defer w.hbMu.Unlock()
+
+	w.hb.lastSeen = time.Now()
+}
+
+// LastSeen implements [WorkerProcess].
+func (w *WorkerClient) LastSeen() time.Time {
+	w.hbMu.Lock()
+	defer w.hbMu.Unlock()
+
+	return w.hb.lastSeen
+}
+
+// RTT implements [WorkerProcess]. Round trip PING/PONG terakhir yang dibalas.
+func (w *WorkerClient) RTT() time.Duration {
+	w.hbMu.Lock()
+	defer w.hbMu.Unlock()
+
+	return w.hb.rtt
+}
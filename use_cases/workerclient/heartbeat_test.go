@@ -0,0 +1,162 @@
+package workerclient
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/use_cases/operation"
+	"mox/use_cases/wire"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPipeWorker connect a WorkerClient to the returned end of a net.Pipe,
+// ujung itu berperan sebagai proses worker
+func newPipeWorker(t *testing.T) (*WorkerClient, net.Conn) {
+	t.Helper()
+
+	master, worker := net.Pipe()
+	t.Cleanup(func() {
+		master.Close()
+		worker.Close()
+	})
+
+	app := core.NewTestAppWithConfig(config.Config{
+		Master: config.MasterConfig{RequestTimeout: time.Second},
+	})
+
+	w := NewWorkerClient(app, master, 42)
+	w.setStatus(Connected)
+	w.listen()
+
+	return w, worker
+}
+
+// readMessage read the next message the master sent to the worker
+func readMessage(t *testing.T, conn net.Conn) operation.MessagePayload {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	msg, err := wire.Default.Read(conn)
+	require.NoError(t, err)
+
+	return msg
+}
+
+func writeMessage(t *testing.T, conn net.Conn, msg operation.MessagePayload) {
+	t.Helper()
+
+	_, err := wire.Default.Write(conn, msg)
+	require.NoError(t, err)
+}
+
+// beat send one heartbeat and return the PING the worker received
+func beat(t *testing.T, w *WorkerClient, conn net.Conn, threshold int) (int, operation.MessagePayload, error) {
+	t.Helper()
+
+	ping := make(chan operation.MessagePayload, 1)
+	go func() { ping <- readMessage(t, conn) }()
+
+	missed, err := w.Heartbeat(context.Background(), threshold)
+	if err != nil {
+		return missed, operation.MessagePayload{}, err
+	}
+
+	return missed, <-ping, nil
+}
+
+func pong(t *testing.T, w *WorkerClient, conn net.Conn, ping operation.MessagePayload) {
+	t.Helper()
+
+	writeMessage(t, conn, operation.MessagePayload{
+		ID:      ping.ID,
+		FromPID: 42,
+		Payload: operation.Command{Type: operation.Pong, Payload: ping.Payload.Payload},
+	})
+
+	// PONG diproses read loop, tunggu sampai kebaca
+	assert.Eventually(t, func() bool {
+		w.hbMu.Lock()
+		defer w.hbMu.Unlock()
+
+		return !w.hb.waiting
+	}, time.Second, 5*time.Millisecond)
+}
+
+func seqOf(t *testing.T, ping operation.MessagePayload) uint64 {
+	t.Helper()
+
+	require.Equal(t, operation.Ping, ping.Payload.Type)
+
+	seq, err := strconv.ParseUint(string(ping.Payload.Payload), 10, 64)
+	require.NoError(t, err)
+
+	return seq
+}
+
+func TestHeartbeatMissedBeats(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	first := make([]operation.MessagePayload, 0, 3)
+	for want := range 3 {
+		missed, ping, err := beat(t, w, conn, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, want, missed)
+		assert.Equal(t, uint64(want+1), seqOf(t, ping))
+
+		first = append(first, ping)
+	}
+
+	// missed == threshold, worker ditandai Error tapi belum dilepas
+	assert.Equal(t, Error, w.State())
+
+	// PONG untuk PING lama tidak dihitung
+	writeMessage(t, conn, operation.MessagePayload{
+		FromPID: 42,
+		Payload: operation.Command{Type: operation.Pong, Payload: first[0].Payload.Payload},
+	})
+
+	missed, ping, err := beat(t, w, conn, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, missed)
+	assert.Equal(t, Error, w.State())
+
+	// PONG untuk PING terakhir, worker pulih
+	pong(t, w, conn, ping)
+	assert.Equal(t, Connected, w.State())
+	assert.Positive(t, w.RTT())
+
+	missed, _, err = beat(t, w, conn, 2)
+	assert.NoError(t, err)
+	assert.Zero(t, missed)
+}
+
+func TestHeartbeatLost(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	for range 2 {
+		_, _, err := beat(t, w, conn, 1)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, Error, w.State())
+
+	waiting := w.await("drain")
+
+	missed, err := w.Heartbeat(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrHeartbeatLost)
+	assert.Equal(t, 2, missed)
+	assert.Equal(t, Disconnected, w.State())
+
+	// request yang masih nunggu langsung digagalkan
+	_, ok := <-waiting
+	assert.False(t, ok)
+}
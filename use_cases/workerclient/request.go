@@ -0,0 +1,173 @@
+package workerclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"mox/use_cases/operation"
+	"mox/use_cases/wire"
+)
+
+// ErrDisconnected is returned to every pending request when the worker connection is gone
+var ErrDisconnected = errors.New("worker disconnected")
+
+// Request implements [WorkerProcess].
+func (w *WorkerClient) Request(ctx context.Context, msg operation.MessagePayload) (operation.Reply, error) {
+	ch := w.await(msg.ID)
+	defer w.forget(msg.ID)
+
+	if _, err := w.Send(ctx, msg); err != nil {
+		return operation.Reply{}, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return operation.Reply{}, fmt.Errorf("worker %d did not reply to %s: %w", w.pid, msg.Payload.Type, ctx.Err())
+	case reply, ok := <-ch:
+		if !ok {
+			return operation.Reply{}, fmt.Errorf("worker %d: %w", w.pid, ErrDisconnected)
+		}
+
+		return reply, nil
+	}
+}
+
+func (w *WorkerClient) await(id string) chan operation.Reply {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+
+	ch := make(chan operation.Reply, 1)
+	if w.gone {
+		close(ch)
+		return ch
+	}
+
+	w.pending[id] = ch
+
+	return ch
+}
+
+func (w *WorkerClient) forget(id string) {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+
+	delete(w.pending, id)
+}
+
+func (w *WorkerClient) resolve(msg operation.MessagePayload) {
+	w.pmu.Lock()
+	ch, ok := w.pending[msg.ID]
+	delete(w.pending, msg.ID)
+	w.pmu.Unlock()
+
+	if !ok {
+		// balasan buat Send biasa atau request yang sudah timeout
+		w.app.Logger().Debug("reply without pending request", slog.Int("pid", w.pid), slog.String("id", msg.ID))
+		return
+	}
+
+	reply := operation.Reply{Status: operation.ReplyAck}
+	if msg.Reply != nil {
+		reply = *msg.Reply
+	}
+
+	ch <- reply
+}
+
+// failPending wake every waiting request with ErrDisconnected
+func (w *WorkerClient) failPending() {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+
+	w.gone = true
+	for id, ch := range w.pending {
+		close(ch)
+		delete(w.pending, id)
+	}
+}
+
+// listen start the read loop once
+func (w *WorkerClient) listen() {
+	if w.l == nil || !w.reading.CompareAndSwap(false, true) {
+		return
+	}
+
+	w.readDone = make(chan struct{})
+	go w.readLoop(w.readDone)
+}
+
+func (w *WorkerClient) readLoop(done chan struct{}) {
+	defer close(done)
+
+	for {
+		f, err := wire.ReadFrame(w.l)
+		if err != nil {
+			// berhenti karena handover ke master baru, koneksi masih hidup
+			if w.detached.Load() {
+				return
+			}
+
+			w.app.Logger().Warn("worker connection lost", slog.Int("pid", w.pid), slog.String("err", err.Error()))
+			w.setStatus(Disconnected)
+			w.failPending()
+
+			return
+		}
+
+		w.seen()
+
+		msg, err := w.proto.Decode(f)
+		if err != nil {
+			w.app.Logger().Warn("cannot decode worker message", slog.Int("pid", w.pid), slog.String("err", err.Error()))
+			continue
+		}
+
+		w.dispatch(msg)
+	}
+}
+
+func (w *WorkerClient) dispatch(msg operation.MessagePayload) {
+	switch msg.Payload.Type {
+	case operation.Response:
+		w.resolve(msg)
+	case operation.Pong:
+		w.pong(msg)
+	case operation.EventStats:
+		w.recordStats(msg)
+	case operation.DrainEvent:
+		w.drainEvent(msg)
+	case operation.Shutdown:
+		w.app.Logger().Info("worker is shutting down", slog.Int("pid", w.pid))
+		w.setStatus(Disconnected)
+	default:
+		w.app.Logger().Debug("message from worker", slog.Int("pid", w.pid), slog.String("type", msg.Payload.Type.String()))
+	}
+}
+
+// Detach stop the read loop without closing the connection, dipanggil
+// sebelum koneksi diserahkan ke master baru biar frame tidak kebaca dua proses.
+func (w *WorkerClient) Detach() {
+	if !w.reading.Load() {
+		return
+	}
+
+	w.detached.Store(true)
+	w.l.SetReadDeadline(time.Now())
+	<-w.readDone
+
+	w.reading.Store(false)
+}
+
+// Attach resume reading after a failed handover
+func (w *WorkerClient) Attach() {
+	if w.l == nil {
+		return
+	}
+
+	w.detached.Store(false)
+	w.l.SetReadDeadline(time.Time{})
+	w.listen()
+}
@@ -0,0 +1,163 @@
+package workerclient
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	core "mox/internal"
+	"mox/pkg/config"
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reply(t *testing.T, conn net.Conn, req operation.MessagePayload, r operation.Reply) {
+	t.Helper()
+
+	writeMessage(t, conn, operation.NewReply(req, 42, r, time.Now().UnixMilli()))
+}
+
+func pending(w *WorkerClient) int {
+	w.pmu.Lock()
+	defer w.pmu.Unlock()
+
+	return len(w.pending)
+}
+
+func request(id string) operation.MessagePayload {
+	return operation.MessagePayload{ID: id, FromPID: -1, Payload: operation.Command{Type: operation.Readiness}}
+}
+
+func TestRequestReply(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	tests := []struct {
+		name  string
+		reply operation.Reply
+		err   string
+	}{
+		{name: "ack", reply: operation.Reply{Status: operation.ReplyAck}},
+		{name: "result", reply: operation.Reply{Status: operation.ReplyResult, Payload: []byte(`{"ready":true}`)}},
+		{name: "error", reply: operation.Reply{Status: operation.ReplyError, Error: "haproxy is not running"}, err: "haproxy is not running"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			go func() {
+				req := readMessage(t, conn)
+				reply(t, conn, req, tt.reply)
+			}()
+
+			got, err := w.Request(context.Background(), request(tt.name))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.reply, got)
+
+			if tt.err != "" {
+				assert.EqualError(t, got.Err(), tt.err)
+			} else {
+				assert.NoError(t, got.Err())
+			}
+
+			assert.Zero(t, pending(w))
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	sent := make(chan operation.MessagePayload, 1)
+	go func() { sent <- readMessage(t, conn) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := w.Request(ctx, request("slow"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Zero(t, pending(w))
+
+	// balasan yang telat dibuang, request berikutnya tetap dapat balasannya sendiri
+	reply(t, conn, <-sent, operation.Reply{Status: operation.ReplyAck})
+
+	go func() {
+		req := readMessage(t, conn)
+		reply(t, conn, request("unknown"), operation.Reply{Status: operation.ReplyError, Error: "not mine"})
+		reply(t, conn, req, operation.Reply{Status: operation.ReplyResult, Payload: []byte("ok")})
+	}()
+
+	got, err := w.Request(context.Background(), request("next"))
+	assert.NoError(t, err)
+	assert.Equal(t, operation.Reply{Status: operation.ReplyResult, Payload: []byte("ok")}, got)
+	assert.Zero(t, pending(w))
+}
+
+func TestRequestWriteDeadline(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	// worker berhenti baca, Write ke pipe nunggu terus tanpa deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := w.Request(ctx, request("stuck"))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Zero(t, pending(w))
+
+	// tanpa deadline di ctx dipakai master.request_timeout, w.mu tidak ketahan
+	start := time.Now()
+	_, err = w.Send(context.Background(), request("ping"))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// worker baca lagi, deadline sudah dilepas
+	go func() {
+		req := readMessage(t, conn)
+		reply(t, conn, req, operation.Reply{Status: operation.ReplyAck})
+	}()
+
+	got, err := w.Request(context.Background(), request("again"))
+	assert.NoError(t, err)
+	assert.Equal(t, operation.ReplyAck, got.Status)
+}
+
+func TestRequestFailOnDisconnect(t *testing.T) {
+	w, conn := newPipeWorker(t)
+
+	go func() {
+		readMessage(t, conn)
+		conn.Close()
+	}()
+
+	_, err := w.Request(context.Background(), request("drain"))
+	assert.ErrorIs(t, err, ErrDisconnected)
+	assert.Zero(t, pending(w))
+	assert.Equal(t, Disconnected, w.State())
+
+	// koneksi sudah putus, request baru tidak boleh nunggu selamanya
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err = w.Request(ctx, request("again"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFailPendingWakeWaiters(t *testing.T) {
+	w := NewWorkerClient(core.NewTestAppWithConfig(config.Config{}), nil, 42)
+
+	first, second := w.await("a"), w.await("b")
+	w.failPending()
+
+	for _, ch := range []chan operation.Reply{first, second} {
+		_, ok := <-ch
+		assert.False(t, ok)
+	}
+
+	assert.Zero(t, pending(w))
+
+	// setelah gone, await langsung mengembalikan channel yang tertutup
+	_, ok := <-w.await("c")
+	assert.False(t, ok)
+}
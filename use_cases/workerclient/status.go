@@ -11,3 +11,22 @@ const (
 	Retrying
 	Idle
 )
+
+var stateNames = map[WorkerClientState]string{
+	Disconnected: "disconnected",
+	Connected:    "connected",
+	Connecting:   "connecting",
+	Starting:     "starting",
+	Error:        "error",
+	Retrying:     "retrying",
+	Idle:         "idle",
+}
+
+// String satisfies the fmt.Stringer interface
+func (s WorkerClientState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return "unknown"
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	core "mox/internal"
 	"mox/tools/utils"
 	"mox/use_cases/operation"
+	"mox/use_cases/wire"
 )
 
 type WorkerProcess interface {
@@ -18,43 +22,163 @@ type WorkerProcess interface {
 	State() WorkerClientState
 	IsAlive() bool
 	Start() error
-	Drain() error
+	// Drain tutup frontend haproxy worker dan tunggu session habis atau timeout
+	Drain() (operation.DrainReport, error)
+	// Ready ask whether the proxy engine of the worker is serving
+	Ready(ctx context.Context) error
 	Shutdown() error
 
 	Send(ctx context.Context, msg operation.MessagePayload) (int, error)
+	// Request send msg and wait for the worker reply with the same ID
+	Request(ctx context.Context, msg operation.MessagePayload) (operation.Reply, error)
+
+	// Heartbeat send the next PING and return how many beats were missed before it
+	Heartbeat(ctx context.Context, threshold int) (int, error)
+	LastSeen() time.Time
+	RTT() time.Duration
+
+	// Stats return the last EVENT_STATS pushed by the worker
+	Stats() (operation.WorkerStats, bool)
+
+	// Generation is the rollout generation the worker was spawned for
+	Generation() int
+	// ConfigHash is the hash of the config the worker was spawned with
+	ConfigHash() string
 }
 
 var _ (WorkerProcess) = (*WorkerClient)(nil)
 
 type WorkerClient struct {
-	status WorkerClientState
+	status atomic.Int32 // WorkerClientState, ditulis read loop dan heartbeat
 	pid    int
 	app    core.App
 	mu     *sync.Mutex
-	l      *net.UnixConn
+	l      net.Conn      // *net.UnixConn, net.Pipe di test
+	proto  wire.Protocol // hasil negosiasi handshake
+
+	generation int
+	configHash string
+
+	pmu     *sync.Mutex
+	pending map[string]chan operation.Reply // request yang masih nunggu balasan
+	gone    bool                            // koneksi putus, request baru langsung gagal
+
+	hbMu *sync.Mutex
+	hb   heartbeat
+
+	statsMu *sync.RWMutex
+	stats   *operation.WorkerStats
+
+	reading  atomic.Bool
+	detached atomic.Bool
+	readDone chan struct{}
 }
 
 func NewWorkerClient(
 	app core.App,
-	l *net.UnixConn,
+	l net.Conn,
 	pid int,
 ) *WorkerClient {
-	return &WorkerClient{
-		app:    app,
-		status: Connecting,
-		pid:    pid,
-		mu:     &sync.Mutex{},
-		l:      l,
+	w := &WorkerClient{
+		app:     app,
+		pid:     pid,
+		mu:      &sync.Mutex{},
+		l:       l,
+		proto:   wire.Default,
+		pmu:     &sync.Mutex{},
+		pending: make(map[string]chan operation.Reply),
+		hbMu:    &sync.Mutex{},
+		statsMu: &sync.RWMutex{},
+	}
+	w.setStatus(Connecting)
+
+	return w
+}
+
+// SetProtocol set the version and codec negotiated at handshake
+func (w *WorkerClient) SetProtocol(p wire.Protocol) *WorkerClient {
+	w.proto = p
+
+	return w
+}
+
+func (w *WorkerClient) Protocol() wire.Protocol {
+	return w.proto
+}
+
+// SetGeneration set the generation and config hash reported at handshake
+func (w *WorkerClient) SetGeneration(generation int, configHash string) *WorkerClient {
+	w.generation = generation
+	w.configHash = configHash
+
+	return w
+}
+
+// Generation implements [WorkerProcess].
+func (w *WorkerClient) Generation() int {
+	return w.generation
+}
+
+// ConfigHash implements [WorkerProcess].
+func (w *WorkerClient) ConfigHash() string {
+	return w.configHash
+}
+
+// Drain implements [WorkerProcess]. Baru return setelah worker lapor drain
+// selesai atau kena timeout, progress-nya masuk lewat DRAIN_EVENT.
+func (w *WorkerClient) Drain() (operation.DrainReport, error) {
+	cfg := w.app.Config().Master
+
+	body, err := json.Marshal(operation.DrainRequest{Timeout: cfg.DrainTimeout})
+	if err != nil {
+		return operation.DrainReport{}, err
+	}
+
+	// worker butuh waktu drain penuh plus sedikit buat soft stop dan balasan
+	ctx, cancel := context.WithTimeout(w.app.Context(), cfg.DrainTimeout+cfg.RequestTimeout)
+	defer cancel()
+
+	reply, err := w.Request(ctx, operation.MessagePayload{
+		ID:      utils.GenerateUUID(),
+		FromPID: w.PID(),
+		Payload: operation.Command{
+			Type:    operation.Drain,
+			Payload: body,
+		},
+		Timestamp: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		return operation.DrainReport{}, err
+	}
+
+	var report operation.DrainReport
+	if len(reply.Payload) > 0 {
+		if err := json.Unmarshal(reply.Payload, &report); err != nil {
+			return report, fmt.Errorf("invalid drain report from worker %d: %w", w.pid, err)
+		}
+	}
+
+	if err := reply.Err(); err != nil {
+		return report, fmt.Errorf("worker %d cannot drain: %w", w.pid, err)
 	}
+
+	w.app.Logger().Info("worker drained",
+		slog.Int("pid", w.pid),
+		slog.Bool("timed_out", report.TimedOut),
+		slog.Int64("sessions_left", report.Sessions),
+		slog.Duration("elapsed", report.Elapsed),
+	)
+
+	return report, nil
 }
 
-// Drain implements [WorkerProcess].
-func (w *WorkerClient) Drain() error {
-	_, err := w.Send(w.app.Context(), operation.MessagePayload{
+// Ready implements [WorkerProcess].
+func (w *WorkerClient) Ready(ctx context.Context) error {
+	reply, err := w.Request(ctx, operation.MessagePayload{
 		ID:      utils.GenerateUUID(),
 		FromPID: w.PID(),
 		Payload: operation.Command{
-			Type: operation.Drain,
+			Type: operation.Readiness,
 		},
 		Timestamp: time.Now().UnixMilli(),
 	})
@@ -62,14 +186,34 @@ func (w *WorkerClient) Drain() error {
 		return err
 	}
 
-	w.app.Logger().Info("worker client already sent drain command")
+	if err := reply.Err(); err != nil {
+		return fmt.Errorf("worker %d is not ready: %w", w.pid, err)
+	}
 
 	return nil
 }
 
+// drainEvent log one transition of the worker drain state machine
+func (w *WorkerClient) drainEvent(msg operation.MessagePayload) {
+	var report operation.DrainReport
+	if err := json.Unmarshal(msg.Payload.Payload, &report); err != nil {
+		w.app.Logger().Warn("invalid drain event from worker", slog.Int("pid", w.pid), slog.String("err", err.Error()))
+		return
+	}
+
+	w.app.Logger().Info("worker drain progress",
+		slog.String("event", "worker.drain"),
+		slog.Int("pid", w.pid),
+		slog.String("state", string(report.State)),
+		slog.Int64("sessions", report.Sessions),
+		slog.Duration("elapsed", report.Elapsed),
+		slog.String("error", report.Error),
+	)
+}
+
 // IsAlive implements [WorkerProcess].
 func (w *WorkerClient) IsAlive() bool {
-	return w.status == Connected
+	return w.State() == Connected
 }
 
 // PID implements [WorkerProcess].
@@ -86,15 +230,30 @@ func (w *WorkerClient) Send(ctx context.Context, msg operation.MessagePayload) (
 		return 0, fmt.Errorf("worker %d has no active connection", w.pid)
 	}
 
-	b, err := json.Marshal(msg)
+	b, err := w.proto.Encode(msg)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal message: %w", err)
+		return 0, err
+	}
+
+	// worker yang berhenti baca bikin buffer socket penuh, Write tidak boleh
+	// nahan w.mu selamanya
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(w.app.Config().Master.RequestTimeout)
 	}
 
-	b = append(b, '\n')
+	if err := w.l.SetWriteDeadline(deadline); err != nil {
+		return 0, fmt.Errorf("failed to send message to worker %d: %w", w.pid, err)
+	}
+	defer w.l.SetWriteDeadline(time.Time{})
 
 	n, err := w.l.Write(b)
 	if err != nil {
+		// frame yang kepotong merusak framing, koneksi bus-nya sudah tidak bisa dipakai
+		if n > 0 {
+			w.l.Close()
+		}
+
 		return 0, fmt.Errorf("failed to send message to worker %d: %w", w.pid, err)
 	}
 
@@ -105,7 +264,7 @@ func (w *WorkerClient) Send(ctx context.Context, msg operation.MessagePayload) (
 
 // Shutdown implements [WorkerProcess].
 func (w *WorkerClient) Shutdown() error {
-	w.status = Disconnected
+	w.setStatus(Disconnected)
 
 	if w.l == nil {
 		w.app.Logger().Warn(fmt.Sprintf("listener for pid %d is nil", w.pid))
@@ -132,23 +291,58 @@ func (w *WorkerClient) Shutdown() error {
 }
 
 func (w *WorkerClient) Start() error {
-	w.status = Connected
+	w.setStatus(Connected)
+	w.seen()
+	w.listen()
 
-	_, err := w.Send(w.app.Context(), operation.MessagePayload{
-		ID:      utils.GenerateUUID(),
-		FromPID: w.PID(),
-		Payload: operation.Command{
-			Type: operation.Ping,
-		},
-		Timestamp: time.Now().UnixMilli(),
-	})
-	if err != nil {
-		return err
+	return w.ping(w.app.Context())
+}
+
+// File return a duplicate of the bus connection, dipakai waktu handover ke master baru
+func (w *WorkerClient) File() (*os.File, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.l == nil {
+		return nil, fmt.Errorf("worker %d has no active connection", w.pid)
 	}
 
-	return nil
+	conn, ok := w.l.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("worker %d connection cannot be handed over", w.pid)
+	}
+
+	return conn.File()
+}
+
+// Stats implements [WorkerProcess].
+func (w *WorkerClient) Stats() (operation.WorkerStats, bool) {
+	w.statsMu.RLock()
+	defer w.statsMu.RUnlock()
+
+	if w.stats == nil {
+		return operation.WorkerStats{}, false
+	}
+
+	return *w.stats, true
+}
+
+func (w *WorkerClient) recordStats(msg operation.MessagePayload) {
+	var stats operation.WorkerStats
+	if err := json.Unmarshal(msg.Payload.Payload, &stats); err != nil {
+		w.app.Logger().Warn("invalid stats from worker", slog.Int("pid", w.pid), slog.String("err", err.Error()))
+		return
+	}
+
+	w.statsMu.Lock()
+	w.stats = &stats
+	w.statsMu.Unlock()
 }
 
 func (w *WorkerClient) State() WorkerClientState {
-	return w.status
+	return WorkerClientState(w.status.Load())
+}
+
+func (w *WorkerClient) setStatus(s WorkerClientState) {
+	w.status.Store(int32(s))
 }
@@ -1,15 +1,25 @@
 package workercore
 
-import "net"
+import (
+	"net"
+	"sync"
+
+	"mox/use_cases/wire"
+)
 
 type WorkerBuilder struct {
 	w *Worker
 }
 
 func NewWorkerBuilder() *WorkerBuilder {
-	return &WorkerBuilder{
-		w: &Worker{},
+	w := &Worker{
+		mu:    &sync.Mutex{},
+		proto: wire.Default,
+		hmu:   &sync.RWMutex{},
 	}
+	w.handlers = defaultHandlers(w)
+
+	return &WorkerBuilder{w: w}
 }
 
 func (d *WorkerBuilder) SetPID(pid int) *WorkerBuilder {
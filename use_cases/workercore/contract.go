@@ -1,6 +1,10 @@
 package workercore
 
-import "context"
+import (
+	"context"
+
+	"mox/use_cases/operation"
+)
 
 type WorkerProcess interface {
 	// Lifecycle Management
@@ -15,7 +19,7 @@ type WorkerProcess interface {
 	// Heartbeat mengirimkan status dan metrik ke Master secara berkala
 	SendPing(ctx context.Context) error
 
-	Send(ctx context.Context, payload []byte) error
+	Send(ctx context.Context, msg operation.MessagePayload) error
 
 	// Identity & Monitoring
 	PID() int
@@ -54,5 +54,5 @@ func InspectFD(fd int, label string) {
 		fmt.Printf("   ❓ Tipe: UNKNOWN\n")
 	}
 
-	fmt.Println("----------------------------------------\n")
+	fmt.Print("----------------------------------------\n\n")
 }
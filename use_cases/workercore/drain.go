@@ -0,0 +1,203 @@
+package workercore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+)
+
+// default drain kalau master tidak kirim timeout
+const (
+	defaultDrainTimeout = 30 * time.Second
+	drainPollInterval   = 500 * time.Millisecond
+	// stopGrace is how long Stop may wait for sessions left after a timed out drain
+	stopGrace = 5 * time.Second
+)
+
+// drainEngine is the part of the ProxyEngine used while draining
+type drainEngine interface {
+	Stats(ctx context.Context) (EngineStats, error)
+	Drain(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// drainer run the drain state machine:
+// started -> frontend_closed -> waiting... -> drained | timed_out -> stopped.
+// Error di tengah jalan langsung jadi failed.
+type drainer struct {
+	engine drainEngine
+	report func(operation.DrainReport)
+	poll   time.Duration
+	grace  time.Duration
+}
+
+// sessions sum the current sessions of every frontend, session CLI kita sendiri
+// tidak ikut kehitung karena masuk frontend GLOBAL yang tidak muncul di show stat.
+func sessions(stats []haproxy.Stat) int64 {
+	var n int64
+	for _, s := range stats {
+		if s.Type == haproxy.TypeFrontend {
+			n += s.SCur
+		}
+	}
+
+	return n
+}
+
+func frontends(stats []haproxy.Stat) []string {
+	names := []string{}
+	for _, s := range stats {
+		if s.Type == haproxy.TypeFrontend {
+			names = append(names, s.ProxyName)
+		}
+	}
+
+	return names
+}
+
+func (d *drainer) run(ctx context.Context, timeout time.Duration) operation.DrainReport {
+	start := time.Now()
+
+	emit := func(r operation.DrainReport) operation.DrainReport {
+		r.Elapsed = time.Since(start)
+		d.report(r)
+
+		return r
+	}
+
+	fail := func(r operation.DrainReport, err error) operation.DrainReport {
+		r.State = operation.DrainFailed
+		r.Error = err.Error()
+
+		return emit(r)
+	}
+
+	last := emit(operation.DrainReport{State: operation.DrainStarted})
+
+	stats, err := d.engine.Stats(ctx)
+	if err != nil {
+		return fail(last, err)
+	}
+
+	last.Frontends = frontends(stats.Proxies)
+	if err := d.engine.Drain(ctx); err != nil {
+		return fail(last, err)
+	}
+
+	last.State = operation.DrainFrontendClosed
+	last.Sessions = sessions(stats.Proxies)
+	last = emit(last)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(d.poll)
+	defer ticker.Stop()
+
+	for last.Sessions > 0 {
+		select {
+		case <-ctx.Done():
+			return fail(last, ctx.Err())
+		case <-deadline.C:
+			last.State = operation.DrainTimedOut
+			last.TimedOut = true
+			last = emit(last)
+		case <-ticker.C:
+			stats, err := d.engine.Stats(ctx)
+			if err != nil {
+				// socket bisa sibuk sesaat, coba lagi di tick berikutnya
+				continue
+			}
+
+			if n := sessions(stats.Proxies); n != last.Sessions || last.State != operation.DrainWaiting {
+				last.Sessions = n
+				last.State = operation.DrainWaiting
+				last = emit(last)
+			}
+
+			continue
+		}
+
+		break
+	}
+
+	if !last.TimedOut {
+		last.State = operation.DrainDrained
+		last = emit(last)
+	}
+
+	stopCtx, cancel := context.WithTimeout(ctx, d.grace)
+	defer cancel()
+
+	if err := d.engine.Stop(stopCtx); err != nil {
+		return fail(last, fmt.Errorf("cannot stop proxy engine: %w", err))
+	}
+
+	last.State = operation.DrainStopped
+
+	return emit(last)
+}
+
+// drain handle DRAIN from the master. Balasan baru dikirim setelah drain selesai
+// atau timeout, progress tiap transisi dikirim sebagai DRAIN_EVENT.
+func (w *Worker) drain(ctx context.Context, msg operation.MessagePayload) operation.Reply {
+	if !w.draining.CompareAndSwap(false, true) {
+		return Fail(errors.New("drain already in progress"))
+	}
+	defer w.draining.Store(false)
+
+	req := operation.DrainRequest{Timeout: defaultDrainTimeout}
+	if len(msg.Payload.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload.Payload, &req); err != nil {
+			return Fail(fmt.Errorf("invalid drain request: %w", err))
+		}
+	}
+
+	report := func(r operation.DrainReport) {
+		body, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+
+		event := w.message(operation.DrainEvent)
+		event.ID = msg.ID
+		event.Payload.Payload = body
+
+		if err := w.Send(ctx, event); err != nil {
+			fmt.Printf("[WORKER %d] Gagal kirim progress drain: %s\n", w.pid, err.Error())
+		}
+	}
+
+	var final operation.DrainReport
+
+	if e := w.Engine(); e == nil {
+		// tidak ada proxy yang jalan, tidak ada yang perlu di-drain
+		final = operation.DrainReport{State: operation.DrainStopped}
+		report(final)
+	} else {
+		d := &drainer{
+			engine: e,
+			report: report,
+			poll:   drainPollInterval,
+			grace:  stopGrace,
+		}
+
+		final = d.run(ctx, req.Timeout)
+	}
+
+	body, err := json.Marshal(final)
+	if err != nil {
+		return Fail(err)
+	}
+
+	if final.State == operation.DrainFailed {
+		return operation.Reply{Status: operation.ReplyError, Error: final.Error, Payload: body}
+	}
+
+	return Result(body)
+}
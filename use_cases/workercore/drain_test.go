@@ -0,0 +1,124 @@
+package workercore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDrainEngine return the next session count on every Stats
+type fakeDrainEngine struct {
+	mu       sync.Mutex
+	sessions []int64
+	drained  int
+	stopped  bool
+	err      error
+}
+
+func (f *fakeDrainEngine) Stats(ctx context.Context) (EngineStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := f.sessions[0]
+	if len(f.sessions) > 1 {
+		f.sessions = f.sessions[1:]
+	}
+
+	return EngineStats{Proxies: []haproxy.Stat{
+		{ProxyName: "gateway", ServiceName: "FRONTEND", Type: haproxy.TypeFrontend, SCur: n},
+		{ProxyName: "web", ServiceName: "BACKEND", Type: haproxy.TypeBackend, SCur: n},
+	}}, nil
+}
+
+func (f *fakeDrainEngine) Drain(ctx context.Context) error {
+	f.drained++
+
+	return f.err
+}
+
+func (f *fakeDrainEngine) Stop(ctx context.Context) error {
+	f.stopped = true
+
+	return nil
+}
+
+func TestDrainerRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []int64
+		timeout  time.Duration
+		err      error
+		states   []operation.DrainState
+		timedOut bool
+		stopped  bool
+	}{
+		{
+			name:     "no session left",
+			sessions: []int64{0},
+			timeout:  time.Second,
+			states:   []operation.DrainState{"started", "frontend_closed", "drained", "stopped"},
+			stopped:  true,
+		},
+		{
+			name:     "sessions reach zero",
+			sessions: []int64{2, 1, 0},
+			timeout:  time.Second,
+			states:   []operation.DrainState{"started", "frontend_closed", "waiting", "waiting", "drained", "stopped"},
+			stopped:  true,
+		},
+		{
+			name:     "deadline passed",
+			sessions: []int64{5},
+			timeout:  30 * time.Millisecond,
+			timedOut: true,
+			stopped:  true,
+		},
+		{
+			name:     "frontend cannot be disabled",
+			sessions: []int64{1},
+			timeout:  time.Second,
+			err:      errors.New("No such frontend."),
+			states:   []operation.DrainState{"started", "failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeDrainEngine{sessions: tt.sessions, err: tt.err}
+
+			var states []operation.DrainState
+
+			d := &drainer{
+				engine: engine,
+				report: func(r operation.DrainReport) { states = append(states, r.State) },
+				poll:   5 * time.Millisecond,
+				grace:  time.Second,
+			}
+
+			final := d.run(context.Background(), tt.timeout)
+
+			assert.Equal(t, 1, engine.drained)
+			assert.Equal(t, []string{"gateway"}, final.Frontends)
+			assert.Equal(t, tt.stopped, engine.stopped)
+			assert.Equal(t, tt.timedOut, final.TimedOut)
+			assert.Equal(t, states[len(states)-1], final.State)
+
+			if tt.states != nil {
+				assert.Equal(t, tt.states, states)
+			}
+
+			if tt.timedOut {
+				assert.Contains(t, states, operation.DrainTimedOut)
+				assert.Equal(t, operation.DrainStopped, final.State)
+				assert.Equal(t, int64(5), final.Sessions)
+			}
+		})
+	}
+}
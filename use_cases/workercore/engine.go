@@ -0,0 +1,78 @@
+package workercore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+)
+
+// ProxyEngine is the proxy a worker runs on the listeners inherited from the
+// master. HAProxy (process terpisah) dan builtin (net/http/httputil di dalam
+// worker) sama-sama implement ini, jadi drain dan stats tidak peduli engine-nya.
+type ProxyEngine interface {
+	// Name is the proxy.engine value of this engine
+	Name() string
+	// Validate check the config against the listeners without serving anything
+	Validate(ctx context.Context, listeners []Listener) error
+	// Start serve the listeners, tidak blocking
+	Start(ctx context.Context, listeners []Listener) error
+	// Reload apply the current config without dropping connections
+	Reload(ctx context.Context) error
+	// Drain stop accepting new sessions, session yang sedang jalan dibiarkan
+	Drain(ctx context.Context) error
+	// Stats sample the engine counters
+	Stats(ctx context.Context) (EngineStats, error)
+	// Stop let the running sessions finish until ctx is done, sisanya diputus
+	Stop(ctx context.Context) error
+	// UpdateServer apply one runtime change to a backend server (weight, state, add, del)
+	UpdateServer(ctx context.Context, change operation.ServerChange) error
+}
+
+// EngineStats is one sample of a ProxyEngine
+type EngineStats struct {
+	// PID of the proxy process, 0 kalau engine jalan di dalam process worker
+	PID int
+	// Info carry the `show info` fields
+	Info haproxy.Info
+	// Proxies is one row per frontend, backend and server like `show stat`
+	Proxies []haproxy.Stat
+}
+
+// SetEngine hand the running proxy engine to the worker
+func (w *Worker) SetEngine(e ProxyEngine) *Worker {
+	w.engine.Store(&e)
+
+	return w
+}
+
+// Engine return the proxy engine, nil kalau belum ada yang jalan
+func (w *Worker) Engine() ProxyEngine {
+	if e := w.engine.Load(); e != nil {
+		return *e
+	}
+
+	return nil
+}
+
+// readiness handle READINESS, worker baru dianggap siap kalau engine-nya
+// sudah jalan dan bisa menjawab stats (haproxy: runtime socket sudah hidup)
+func (w *Worker) readiness(ctx context.Context, msg operation.MessagePayload) operation.Reply {
+	// tanpa listener tidak ada yang perlu di-serve
+	if len(w.Listeners()) == 0 {
+		return Ack()
+	}
+
+	e := w.Engine()
+	if e == nil {
+		return Fail(errors.New("proxy engine is not running"))
+	}
+
+	if _, err := e.Stats(ctx); err != nil {
+		return Fail(fmt.Errorf("proxy engine is not ready: %w", err))
+	}
+
+	return Ack()
+}
@@ -0,0 +1,84 @@
+package workercore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mox/use_cases/operation"
+)
+
+// Handler menjalankan satu perintah dari master dan mengembalikan balasannya
+type Handler func(ctx context.Context, msg operation.MessagePayload) operation.Reply
+
+// Ack is the reply for commands that need no result
+func Ack() operation.Reply {
+	return operation.Reply{Status: operation.ReplyAck}
+}
+
+// Result is the reply carrying a payload back to the master
+func Result(payload []byte) operation.Reply {
+	return operation.Reply{Status: operation.ReplyResult, Payload: payload}
+}
+
+// Fail is the reply telling the master the command did not succeed
+func Fail(err error) operation.Reply {
+	return operation.Reply{Status: operation.ReplyError, Error: err.Error()}
+}
+
+func ack(ctx context.Context, msg operation.MessagePayload) operation.Reply {
+	return Ack()
+}
+
+func defaultHandlers(w *Worker) map[operation.MsgType]Handler {
+	return map[operation.MsgType]Handler{
+		operation.Chat:         ack,
+		operation.Shutdown:     ack,
+		operation.Drain:        w.drain,
+		operation.ServerUpdate: w.updateServer,
+		operation.Readiness:    w.readiness,
+	}
+}
+
+// Handle register the handler for one message type, yang lama ditimpa
+func (w *Worker) Handle(t operation.MsgType, h Handler) *Worker {
+	w.hmu.Lock()
+	defer w.hmu.Unlock()
+
+	w.handlers[t] = h
+
+	return w
+}
+
+func (w *Worker) handler(t operation.MsgType) (Handler, bool) {
+	w.hmu.RLock()
+	defer w.hmu.RUnlock()
+
+	h, ok := w.handlers[t]
+
+	return h, ok
+}
+
+// pong answer a PING with the same sequence, bukan lewat handler biar tidak telat
+func (w *Worker) pong(ctx context.Context, ping operation.MessagePayload) {
+	msg := w.message(operation.Pong)
+	msg.ID = ping.ID
+	msg.Payload.Payload = ping.Payload.Payload
+
+	if err := w.Send(ctx, msg); err != nil {
+		fmt.Printf("[WORKER %d] Gagal kirim PONG: %s\n", w.pid, err.Error())
+	}
+}
+
+// reply jalankan handler lalu kirim balik hasilnya dengan ID yang sama
+func (w *Worker) reply(ctx context.Context, msg operation.MessagePayload) {
+	result := Fail(fmt.Errorf("worker %d has no handler for %s", w.pid, msg.Payload.Type))
+
+	if h, ok := w.handler(msg.Payload.Type); ok {
+		result = h(ctx, msg)
+	}
+
+	if err := w.Send(ctx, operation.NewReply(msg, w.pid, result, time.Now().UnixMilli())); err != nil {
+		fmt.Printf("[WORKER %d] Gagal balas %s: %s\n", w.pid, msg.Payload.Type, err.Error())
+	}
+}
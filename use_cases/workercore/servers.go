@@ -0,0 +1,60 @@
+package workercore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"mox/use_cases/operation"
+)
+
+// updateServer handle SERVER_UPDATE, perubahan yang sama juga sudah disimpan
+// master di server state file jadi engine yang belum jalan tidak ketinggalan.
+func (w *Worker) updateServer(ctx context.Context, msg operation.MessagePayload) operation.Reply {
+	var change operation.ServerChange
+	if err := json.Unmarshal(msg.Payload.Payload, &change); err != nil {
+		return Fail(fmt.Errorf("invalid server change: %w", err))
+	}
+
+	if err := change.Validate(); err != nil {
+		return Fail(err)
+	}
+
+	e := w.Engine()
+	if e == nil {
+		return Fail(errors.New("proxy engine is not running"))
+	}
+
+	if err := e.UpdateServer(ctx, change); err != nil {
+		return Fail(err)
+	}
+
+	return Ack()
+}
+
+// RestoreServers apply the server state file persisted by the master to e,
+// dipanggil tiap engine selesai start atau reload. Perubahan yang gagal
+// (misal servernya sudah tidak ada di config) dikumpulkan, sisanya tetap jalan.
+func RestoreServers(ctx context.Context, e ProxyEngine, path string) (int, error) {
+	overrides, err := operation.LoadServerOverrides(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		applied int
+		errs    []error
+	)
+
+	for _, change := range overrides.Changes() {
+		if err := e.UpdateServer(ctx, change); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", change, err))
+			continue
+		}
+
+		applied++
+	}
+
+	return applied, errors.Join(errs...)
+}
@@ -0,0 +1,130 @@
+package workercore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"mox/pkg/haproxy"
+	"mox/pkg/procstat"
+	"mox/use_cases/operation"
+)
+
+// SetHAProxy remember the HAProxy child pid so it is sampled too, 0 = tidak ada
+func (w *Worker) SetHAProxy(pid int) {
+	w.haproxyPID.Store(int64(pid))
+}
+
+// SetRuntime hand the HAProxy Runtime API client to the worker, sekalian
+// daftarin handler RUNTIME biar master bisa kirim perintah langsung ke HAProxy.
+func (w *Worker) SetRuntime(c *haproxy.Client) *Worker {
+	w.runtime.Store(c)
+
+	return w.Handle(operation.Runtime, w.runtimeCommand)
+}
+
+// Runtime return the HAProxy Runtime API client, nil kalau haproxy belum jalan
+func (w *Worker) Runtime() *haproxy.Client {
+	return w.runtime.Load()
+}
+
+func (w *Worker) runtimeCommand(ctx context.Context, msg operation.MessagePayload) operation.Reply {
+	rt := w.Runtime()
+	if rt == nil || w.haproxyPID.Load() == 0 {
+		return Fail(errors.New("haproxy is not running"))
+	}
+
+	out, err := rt.Execute(ctx, string(msg.Payload.Payload))
+	if err != nil {
+		return Fail(err)
+	}
+
+	return Result([]byte(out))
+}
+
+// ReportStats push EVENT_STATS to the master every interval until ctx is done
+func (w *Worker) ReportStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := w.sampleStats(ctx)
+		if err != nil {
+			fmt.Printf("[WORKER %d] Gagal ambil stats: %s\n", w.pid, err.Error())
+			continue
+		}
+
+		body, err := json.Marshal(stats)
+		if err != nil {
+			continue
+		}
+
+		msg := w.message(operation.EventStats)
+		msg.Payload.Payload = body
+
+		if err := w.Send(ctx, msg); err != nil {
+			fmt.Printf("[WORKER %d] Gagal kirim stats: %s\n", w.pid, err.Error())
+		}
+	}
+}
+
+func (w *Worker) sampleStats(ctx context.Context) (operation.WorkerStats, error) {
+	self, err := procstat.Sample(w.pid)
+	if err != nil {
+		return operation.WorkerStats{}, err
+	}
+
+	stats := operation.WorkerStats{Worker: self, SampledAt: time.Now()}
+
+	// engine bisa belum jalan atau sudah mati, stats worker tetap dikirim
+	if e := w.Engine(); e != nil {
+		if es, err := e.Stats(ctx); err == nil {
+			// builtin jalan di process worker, cuma haproxy yang di-sample terpisah
+			if es.PID > 0 {
+				if p, err := procstat.Sample(es.PID); err == nil {
+					stats.HAProxy = &p
+				}
+			}
+
+			if es.Info != nil {
+				c := counters(es.Info)
+				stats.Counters = &c
+			}
+
+			stats.Proxies = es.Proxies
+		}
+	}
+
+	return stats, nil
+}
+
+// counters pick the fields the master aggregates from `show info`
+func counters(info haproxy.Info) operation.HAProxyCounters {
+	var c operation.HAProxyCounters
+
+	fields := map[string]*int64{
+		"CurrConns":    &c.CurrConns,
+		"CumConns":     &c.CumConns,
+		"CumReq":       &c.CumReq,
+		"ConnRate":     &c.ConnRate,
+		"Maxconn":      &c.MaxConn,
+		"SessRate":     &c.SessRate,
+		"Uptime_sec":   &c.Uptime,
+		"Idle_pct":     &c.IdlePercent,
+		"CurrSslConns": &c.CurrSslConns,
+	}
+
+	for name, dst := range fields {
+		*dst = info.Int(name)
+	}
+
+	return c
+}
@@ -0,0 +1,80 @@
+package workercore
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"mox/pkg/haproxy"
+	"mox/use_cases/operation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatsEngine answer Stats with a fixed sample
+type fakeStatsEngine struct {
+	ProxyEngine
+
+	stats EngineStats
+}
+
+func (f *fakeStatsEngine) Stats(ctx context.Context) (EngineStats, error) {
+	return f.stats, nil
+}
+
+func TestSampleStatsCounters(t *testing.T) {
+	info, err := haproxy.ParseInfo(strings.NewReader(`Name: HAProxy
+Version: 2.8.3
+Maxconn: 2000
+Uptime_sec: 42
+CurrConns: 7
+CumConns: 1234
+CumReq: 5678
+ConnRate: 3
+SessRate: 3
+Idle_pct: 97
+CurrSslConns: 0
+Stopping: 0
+`))
+	require.NoError(t, err)
+
+	proxies := []haproxy.Stat{{ProxyName: "gateway", ServiceName: "FRONTEND", Type: haproxy.TypeFrontend, SCur: 7}}
+
+	tests := []struct {
+		name     string
+		stats    EngineStats
+		counters *operation.HAProxyCounters
+	}{
+		{
+			name:  "show info",
+			stats: EngineStats{Info: info, Proxies: proxies},
+			counters: &operation.HAProxyCounters{
+				CurrConns:   7,
+				CumConns:    1234,
+				CumReq:      5678,
+				ConnRate:    3,
+				MaxConn:     2000,
+				SessRate:    3,
+				Uptime:      42,
+				IdlePercent: 97,
+			},
+		},
+		{name: "no info", stats: EngineStats{Proxies: proxies}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorker()
+			w.pid = os.Getpid()
+			w.SetEngine(&fakeStatsEngine{stats: tt.stats})
+
+			stats, err := w.sampleStats(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.counters, stats.Counters)
+			assert.Equal(t, proxies, stats.Proxies)
+			assert.Nil(t, stats.HAProxy)
+		})
+	}
+}
@@ -1,26 +1,83 @@
 package workercore
 
 import (
-	"bufio"
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"mox/pkg/config"
+	"mox/pkg/haproxy"
 	"mox/use_cases/operation"
+	"mox/use_cases/wire"
 )
 
 var _ (WorkerProcess) = (*Worker)(nil)
 
+// Listener is one named public socket received from the master
+type Listener struct {
+	Name string
+	File *os.File
+}
+
+// maxListeners batas FD yang bisa diterima dalam satu SCM_RIGHTS
+const maxListeners = 64
+
+// GenerationEnv carry the rollout generation from the master to the worker
+const GenerationEnv = "MOX_GENERATION"
+
+// Generation return the generation this worker was spawned for, 0 kalau tidak diketahui
+func Generation() int {
+	gen, _ := strconv.Atoi(os.Getenv(GenerationEnv))
+
+	return gen
+}
+
+// ConfigHashEnv carry the hash of the config the master spawned this worker with
+const ConfigHashEnv = "MOX_CONFIG_HASH"
+
+// ConfigHash return the config hash given by the master, kosong kalau tidak diketahui
+func ConfigHash() string {
+	return os.Getenv(ConfigHashEnv)
+}
+
+// PinnedConfigEnv carry the haproxy.cfg of a stored revision, di-set master waktu rollback
+const PinnedConfigEnv = "MOX_PINNED_CONFIG"
+
+// ProxyConfig return the [proxy] section this worker serves, kalau master
+// pin satu revisi, model diganti file revisi tersebut.
+func ProxyConfig(cfg config.Config) config.ProxyConfig {
+	if file := os.Getenv(PinnedConfigEnv); file != "" {
+		return cfg.Proxy.Pin(file)
+	}
+
+	return cfg.Proxy
+}
+
 type Worker struct {
 	status    WorkerState
 	pid       int
-	ExtraFile *os.File // File object wrapper
+	ExtraFile *os.File // File object wrapper, listener pertama
 	fd        int      // Raw FD number
+	listeners []Listener
 	l         *net.UnixConn
+	mu        *sync.Mutex   // serialize write ke master
+	proto     wire.Protocol // hasil negosiasi handshake
+	hmu       *sync.RWMutex
+	handlers  map[operation.MsgType]Handler
+
+	haproxyPID atomic.Int64 // child haproxy, di-set DaemonAdapter
+	runtime    atomic.Pointer[haproxy.Client]
+	engine     atomic.Pointer[ProxyEngine]
+	draining   atomic.Bool
 }
 
 // Read implements [WorkerProcess].
@@ -34,9 +91,15 @@ func (w *Worker) Write() {
 }
 
 func NewWorker() *Worker {
-	return &Worker{
+	w := &Worker{
 		status: Disconnected,
+		mu:     &sync.Mutex{},
+		proto:  wire.Default,
+		hmu:    &sync.RWMutex{},
 	}
+	w.handlers = defaultHandlers(w)
+
+	return w
 }
 
 func (w *Worker) HandleFD(fd uintptr, portName string) error {
@@ -59,52 +122,148 @@ func (w *Worker) createFDFiles(fd int) *os.File {
 	return file
 }
 
+// Listeners return every listener received from the master, in FD order
+func (w *Worker) Listeners() []Listener {
+	return w.listeners
+}
+
+// adoptListeners pair the received FDs with the names from the master hello.
+// Master lama tidak kirim nama, listener pertama dianggap "gateway".
+func (w *Worker) adoptListeners(fds []int, names []string) error {
+	if len(names) == 0 && len(fds) == 1 {
+		names = []string{"gateway"}
+	}
+
+	if len(names) != len(fds) {
+		return fmt.Errorf("master kirim %d FD tapi %d nama listener", len(fds), len(names))
+	}
+
+	w.listeners = make([]Listener, 0, len(fds))
+	for i, fd := range fds {
+		w.listeners = append(w.listeners, Listener{Name: names[i], File: w.createFDFiles(fd)})
+	}
+
+	w.fd = fds[0]
+	w.ExtraFile = w.listeners[0].File
+
+	return nil
+}
+
+func (w *Worker) closeListeners() {
+	for _, l := range w.listeners {
+		l.File.Close()
+	}
+}
+
 func (w *Worker) AcceptHandshake() error {
-	// 1. Panggil fungsi private buat "nyolong" FD dari socket
-	fd, msgPayload, err := w.receiveFD()
+	// 1. Kenalan dulu (PID, versi, codec), FD listener baru dikirim master
+	// setelah PID ini cocok dengan yang dilaporkan kernel
+	offer := wire.Offer(wire.Codecs[0])
+	offer.PID = w.pid
+	offer.Generation = Generation()
+	offer.ConfigHash = ConfigHash()
+
+	f, err := wire.HelloFrame(operation.Hello, offer)
 	if err != nil {
-		return fmt.Errorf("gagal menerima FD: %w", err)
+		return err
 	}
 
-	fmt.Printf("[WORKER] Handshake Sukses! Pesan Master: %s | FD: %d\n", msgPayload, fd)
+	if _, err := wire.WriteFrame(w.l, f); err != nil {
+		return fmt.Errorf("gagal kirim hello ke master: %w", err)
+	}
 
-	// 2. Simpan FD ke struct Worker
-	w.fd = fd
-	w.ExtraFile = w.createFDFiles(fd)
+	// 2. Panggil fungsi private buat "nyolong" FD dari socket
+	fds, msgPayload, err := w.receiveFD()
+	if err != nil {
+		return fmt.Errorf("gagal menerima FD: %w", err)
+	}
 
-	// 3. Kirim laporan balik ke Master (PID)
-	report := fmt.Sprintf("%d\n", w.pid)
-	_, err = w.l.Write([]byte(report))
+	// 3. Cek versi protocol pilihan master, sekalian simpan listener-nya
+	hello, err := w.accept(msgPayload, fds)
 	if err != nil {
-		return fmt.Errorf("gagal kirim ack ke master: %w", err)
+		if f, ferr := wire.RejectFrame(err); ferr == nil {
+			wire.WriteFrame(w.l, f)
+		}
+
+		return fmt.Errorf("handshake ditolak: %w", err)
 	}
 
+	for _, l := range w.listeners {
+		fmt.Printf("[WORKER] Listener %s | FD: %d\n", l.Name, l.File.Fd())
+	}
+
+	fmt.Printf("[WORKER] Handshake Sukses! Protocol v%d (%s)\n", hello.Version, hello.Codec)
+
+	w.proto = wire.Protocol{Version: hello.Version, Codec: hello.Codec}
+
 	return nil
 }
 
-func (w *Worker) receiveFD() (int, string, error) {
-	oob := make([]byte, syscall.CmsgSpace(4))
-	dummy := make([]byte, 128)
+func (w *Worker) accept(payload []byte, fds []int) (wire.Hello, error) {
+	closeFDs := func() {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}
+
+	f, err := wire.ReadFrame(bytes.NewReader(payload))
+	if err != nil {
+		closeFDs()
+		return wire.Hello{}, fmt.Errorf("hello master tidak valid: %w", err)
+	}
+
+	hello, err := wire.ParseHello(f)
+	if err == nil {
+		err = wire.Verify(hello)
+	}
+
+	if err != nil {
+		closeFDs()
+		return wire.Hello{}, err
+	}
+
+	if err := w.adoptListeners(fds, hello.Listeners); err != nil {
+		closeFDs()
+		return wire.Hello{}, err
+	}
+
+	return hello, nil
+}
+
+func (w *Worker) receiveFD() ([]int, []byte, error) {
+	oob := make([]byte, syscall.CmsgSpace(4*maxListeners))
+	dummy := make([]byte, 4096)
 
 	// 1. ReadMsgUnix
-	n, oobn, _, _, err := w.l.ReadMsgUnix(dummy, oob)
+	n, oobn, flags, _, err := w.l.ReadMsgUnix(dummy, oob)
 	if err != nil {
-		return 0, "", err
+		return nil, nil, err
+	}
+
+	if flags&syscall.MSG_CTRUNC != 0 {
+		return nil, nil, fmt.Errorf("master kirim lebih dari %d listener, OOB terpotong", maxListeners)
 	}
 
 	// 2. Validasi Kritis: Ada data OOB gak?
 	if oobn == 0 {
-		return 0, string(dummy[:n]), fmt.Errorf("master kirim pesan '%s' tapi OOB DATA KOSONG", dummy[:n])
+		// master nolak kita (misal uid tidak diizinkan), tampilkan alasannya
+		if f, ferr := wire.ReadFrame(bytes.NewReader(dummy[:n])); ferr == nil {
+			if _, herr := wire.ParseHello(f); herr != nil {
+				return nil, nil, herr
+			}
+		}
+
+		return nil, dummy[:n], fmt.Errorf("master kirim pesan '%s' tapi OOB DATA KOSONG", dummy[:n])
 	}
 
 	// 3. Parsing Control Message
 	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
 	if err != nil {
-		return 0, "", fmt.Errorf("parse control msg error: %v", err)
+		return nil, nil, fmt.Errorf("parse control msg error: %v", err)
 	}
 
 	if len(msgs) == 0 {
-		return 0, "", fmt.Errorf("control message kosong")
+		return nil, nil, fmt.Errorf("control message kosong")
 	}
 
 	// 4. Debugging Log (Opsional, biar lu tetep bisa liat isinya)
@@ -115,22 +274,19 @@ func (w *Worker) receiveFD() (int, string, error) {
 	// 5. Ekstrak FD dari UnixRights
 	fds, err := syscall.ParseUnixRights(&msgs[0])
 	if err != nil {
-		return 0, "", fmt.Errorf("parse unix rights error: %v", err)
+		return nil, nil, fmt.Errorf("parse unix rights error: %v", err)
 	}
 
 	if len(fds) == 0 {
-		return 0, "", fmt.Errorf("paket OOB diterima tapi ARRAY FD KOSONG")
+		return nil, nil, fmt.Errorf("paket OOB diterima tapi ARRAY FD KOSONG")
 	}
 
 	fmt.Println(fds, "list fd files")
 
-	fd := fds[0]
-
-	return fd, string(dummy[:n]), nil
+	return fds, dummy[:n], nil
 }
 
 func (w *Worker) ReceiveMessage(ctx context.Context, cancelFunc context.CancelFunc) {
-	scanner := bufio.NewScanner(w.l)
 	// w.app.Logger().Info(fmt.Sprintf("worker %d listening messages", w.pid))
 
 	for {
@@ -140,45 +296,78 @@ func (w *Worker) ReceiveMessage(ctx context.Context, cancelFunc context.CancelFu
 		default:
 		}
 
-		if !scanner.Scan() {
-			if err := scanner.Err(); err != nil {
-				fmt.Println("Master hilang/mati. Worker ikut pamit!", err.Error())
+		f, err := wire.ReadFrame(w.l)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Println("Frame dari master rusak, koneksi diputus:", err.Error())
+			} else {
+				fmt.Println("Master hilang/mati. Worker ikut pamit!")
 			}
 			cancelFunc()
 			return
 		}
 
-		command := scanner.Bytes()
+		if f.Type == operation.Reject {
+			_, err := wire.ParseHello(f)
+			fmt.Printf("[WORKER %d] Ditolak master: %s\n", w.pid, err)
+			cancelFunc()
+			return
+		}
 
-		fmt.Printf("[WORKER %d] Nerima Instruksi: %s\n", w.pid, string(command))
+		body, err := w.proto.Decode(f)
+		if err != nil {
+			if errors.Is(err, wire.ErrVersionMismatch) {
+				fmt.Println("Versi protocol master berubah, worker pamit:", err.Error())
+				cancelFunc()
+				return
+			}
 
-		var body operation.MessagePayload
+			// framing masih sinkron, cukup buang pesan yang gagal di-decode
+			fmt.Printf("[WORKER %d] Pesan %s dibuang: %s\n", w.pid, f.Type, err.Error())
+			continue
+		}
 
-		if err := json.Unmarshal(command, &body); err != nil {
-			cancelFunc()
-			return
+		if body.Payload.Type == operation.Ping {
+			w.pong(ctx, body)
+			continue
 		}
 
+		fmt.Printf("[WORKER %d] Nerima Instruksi: %s\n", w.pid, body.Payload.Type)
+
 		if body.Payload.Type == operation.Shutdown {
+			// ack dulu biar master tahu perintahnya sampai
+			w.reply(ctx, body)
 			cancelFunc()
 			return
 		}
+
+		// handler bisa lama (drain), jangan blokir pembacaan frame berikutnya
+		go w.reply(ctx, body)
 	}
 }
 
 // Send implements [WorkerProcess].
-func (w *Worker) Send(ctx context.Context, payload []byte) error {
-	_, err := w.l.Write(payload)
-	if err != nil {
-		return err
-	}
+func (w *Worker) Send(ctx context.Context, msg operation.MessagePayload) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	return nil
+	_, err := w.proto.Write(w.l, msg)
+
+	return err
+}
+
+func (w *Worker) message(t operation.MsgType) operation.MessagePayload {
+	return operation.MessagePayload{
+		ID:        fmt.Sprintf("%d-%d", w.pid, time.Now().UnixNano()),
+		FromPID:   w.pid,
+		Payload:   operation.Command{Type: t},
+		Timestamp: time.Now().UnixMilli(),
+	}
 }
 
 // SendHeartbeat implements [WorkerProcess].
 func (w *Worker) SendPing(ctx context.Context) error {
-	return w.Send(ctx, []byte("PING\n"))
+	return w.Send(ctx, w.message(operation.Ping))
 }
 
 // Shutdown implements [WorkerProcess].
@@ -195,14 +384,12 @@ func (w *Worker) Shutdown() error {
 		return errors.New("connection already closed")
 	}
 
-	_, err := w.l.Write([]byte("DIE"))
-	if err != nil {
+	// pamit ke master pakai frame, bukan teks mentah
+	if err := w.Send(context.Background(), w.message(operation.Shutdown)); err != nil {
 		return err
 	}
 
-	if err := w.ExtraFile.Close(); err != nil {
-		return err
-	}
+	w.closeListeners()
 
 	return w.l.Close()
 }